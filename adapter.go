@@ -0,0 +1,185 @@
+package goscale
+
+import (
+	"sync"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// Adapter is a Bluetooth adapter that goscale scans and connects through.
+// By default this is the host's adapter, BTAdapter. SetAdapter installs
+// another, such as the in-memory fake in pkg/bttest, so the scanner and
+// drivers can be run without hardware. Implementations must be comparable,
+// e.g. pointers.
+type Adapter interface {
+	// Enable readies the adapter for use.
+	Enable() error
+
+	// Scan calls f with each advertisement received, and blocks until
+	// StopScan is called. The host adapter only records a result's
+	// ServiceUUIDs among those that registered drivers declare (see
+	// DriverOptions), as it can only look for known UUIDs.
+	Scan(f func(ScanResult)) error
+	StopScan() error
+
+	// Connect connects to the device at address.
+	Connect(address bluetooth.Address, params bluetooth.ConnectionParams) (Peripheral, error)
+
+	// SetConnectHandler sets the function called as devices connect and
+	// disconnect, on the adapter's event goroutine.
+	SetConnectHandler(f func(address bluetooth.Address, connected bool))
+}
+
+// Peripheral is a connected device, as drivers see it.
+type Peripheral interface {
+	// DiscoverServices returns the device's services with the given UUIDs,
+	// or all of them if uuids is empty.
+	DiscoverServices(uuids []bluetooth.UUID) ([]Service, error)
+
+	// Disconnect drops the connection.
+	Disconnect() error
+}
+
+// Service is a GATT service of a Peripheral.
+type Service interface {
+	UUID() bluetooth.UUID
+
+	// DiscoverCharacteristics returns the service's characteristics with
+	// the given UUIDs, or all of them if uuids is empty.
+	DiscoverCharacteristics(uuids []bluetooth.UUID) ([]Characteristic, error)
+}
+
+// Characteristic is a GATT characteristic of a Peripheral.
+type Characteristic interface {
+	UUID() bluetooth.UUID
+	Write(p []byte) (int, error)
+	WriteWithoutResponse(p []byte) (int, error)
+
+	// EnableNotifications calls callback with the value of each
+	// notification, on the adapter's event goroutine.
+	EnableNotifications(callback func(buf []byte)) error
+
+	// GetMTU returns the connection's negotiated ATT MTU.
+	GetMTU() (uint16, error)
+}
+
+var (
+	adapterMu     sync.RWMutex
+	activeAdapter Adapter = hostAdapter{}
+)
+
+// SetAdapter makes goscale scan and connect through a instead of the
+// host's adapter. Nil restores the host's. Devices already connected keep
+// the adapter they were connected through.
+func SetAdapter(a Adapter) {
+	if a == nil {
+		a = hostAdapter{}
+	}
+	adapterMu.Lock()
+	defer adapterMu.Unlock()
+	activeAdapter = a
+}
+
+// ActiveAdapter returns the adapter goscale scans and connects through.
+func ActiveAdapter() Adapter {
+	adapterMu.RLock()
+	defer adapterMu.RUnlock()
+	return activeAdapter
+}
+
+// hostAdapter is the host's Bluetooth adapter, BTAdapter, as an Adapter.
+type hostAdapter struct{}
+
+func (hostAdapter) Enable() error {
+	return BTAdapter.Enable()
+}
+
+func (hostAdapter) Scan(f func(ScanResult)) error {
+	candidates := getRegisteredServiceUUIDs()
+	return BTAdapter.Scan(func(_ *bluetooth.Adapter, result bluetooth.ScanResult) {
+		f(newScanResult(result, candidates))
+	})
+}
+
+func (hostAdapter) StopScan() error {
+	return BTAdapter.StopScan()
+}
+
+func (hostAdapter) Connect(address bluetooth.Address, params bluetooth.ConnectionParams) (Peripheral, error) {
+	device, err := BTAdapter.Connect(address, params)
+	if err != nil {
+		return nil, err
+	}
+	return hostPeripheral{device}, nil
+}
+
+func (hostAdapter) SetConnectHandler(f func(address bluetooth.Address, connected bool)) {
+	BTAdapter.SetConnectHandler(func(device bluetooth.Device, connected bool) {
+		f(device.Address, connected)
+	})
+}
+
+// hostPeripheral is a device connected through the host's adapter.
+type hostPeripheral struct {
+	device bluetooth.Device
+}
+
+func (p hostPeripheral) DiscoverServices(uuids []bluetooth.UUID) ([]Service, error) {
+	found, err := p.device.DiscoverServices(uuids)
+	if err != nil {
+		return nil, err
+	}
+	services := make([]Service, len(found))
+	for i := range found {
+		services[i] = hostService{found[i]}
+	}
+	return services, nil
+}
+
+func (p hostPeripheral) Disconnect() error {
+	return p.device.Disconnect()
+}
+
+type hostService struct {
+	service bluetooth.DeviceService
+}
+
+func (s hostService) UUID() bluetooth.UUID {
+	return s.service.UUID()
+}
+
+func (s hostService) DiscoverCharacteristics(uuids []bluetooth.UUID) ([]Characteristic, error) {
+	found, err := s.service.DiscoverCharacteristics(uuids)
+	if err != nil {
+		return nil, err
+	}
+	chars := make([]Characteristic, len(found))
+	for i := range found {
+		chars[i] = &hostCharacteristic{btChar: found[i]}
+	}
+	return chars, nil
+}
+
+type hostCharacteristic struct {
+	btChar bluetooth.DeviceCharacteristic
+}
+
+func (c *hostCharacteristic) UUID() bluetooth.UUID {
+	return c.btChar.UUID()
+}
+
+func (c *hostCharacteristic) Write(p []byte) (int, error) {
+	return c.btChar.Write(p)
+}
+
+func (c *hostCharacteristic) WriteWithoutResponse(p []byte) (int, error) {
+	return c.btChar.WriteWithoutResponse(p)
+}
+
+func (c *hostCharacteristic) EnableNotifications(callback func(buf []byte)) error {
+	return c.btChar.EnableNotifications(callback)
+}
+
+func (c *hostCharacteristic) GetMTU() (uint16, error) {
+	return c.btChar.GetMTU()
+}
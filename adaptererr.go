@@ -0,0 +1,180 @@
+package goscale
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrAdapterUnavailable is matched (via errors.Is) by every *AdapterError.
+var ErrAdapterUnavailable = errors.New("bluetooth adapter unavailable")
+
+// AdapterProblem classifies why the Bluetooth adapter couldn't be used.
+type AdapterProblem int
+
+const (
+	// ProblemUnknown is an adapter failure goscale doesn't recognise.
+	ProblemUnknown AdapterProblem = iota
+	// ProblemNoAdapter means there is no Bluetooth adapter.
+	ProblemNoAdapter
+	// ProblemBlocked means the radio is blocked, e.g. by rfkill.
+	ProblemBlocked
+	// ProblemPoweredOff means the adapter is present but switched off.
+	ProblemPoweredOff
+	// ProblemNoStack means the operating system's Bluetooth service can't be
+	// reached, e.g. BlueZ or the D-Bus system bus on Linux.
+	ProblemNoStack
+	// ProblemPermission means the program isn't allowed to use Bluetooth.
+	ProblemPermission
+)
+
+func (p AdapterProblem) String() string {
+	switch p {
+	case ProblemNoAdapter:
+		return "no adapter"
+	case ProblemBlocked:
+		return "blocked"
+	case ProblemPoweredOff:
+		return "powered off"
+	case ProblemNoStack:
+		return "bluetooth service unavailable"
+	case ProblemPermission:
+		return "permission denied"
+	default:
+		return "unknown"
+	}
+}
+
+// AdapterError reports a failure to use the Bluetooth adapter, with what is
+// likely wrong and how to fix it on this platform, so applications can show
+// something more useful than a raw D-Bus error.
+type AdapterError struct {
+	Op      string // What was being done: "enable" or "scan"
+	Problem AdapterProblem
+	Hint    string // How to fix it, or empty if unknown
+	Err     error
+}
+
+func (e *AdapterError) Error() string {
+	if e.Problem == ProblemUnknown {
+		return fmt.Sprintf("bluetooth %s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("bluetooth %s: %s: %v", e.Op, e.Problem, e.Err)
+}
+
+func (e *AdapterError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AdapterError) Is(target error) bool {
+	return target == ErrAdapterUnavailable
+}
+
+// ErrorHint returns the remediation hint carried by err, if it is or wraps
+// an *AdapterError, or an empty string.
+func ErrorHint(err error) string {
+	var ae *AdapterError
+	if errors.As(err, &ae) {
+		return ae.Hint
+	}
+	return ""
+}
+
+// adapterError classifies err, returned by the Bluetooth stack during op,
+// as an *AdapterError. The stacks only return strings, so this goes by the
+// messages BlueZ, CoreBluetooth and WinRT are known to give.
+func adapterError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ae *AdapterError
+	if errors.As(err, &ae) {
+		return err
+	}
+	problem, hint := classifyAdapterError(runtime.GOOS, strings.ToLower(err.Error()))
+	return &AdapterError{Op: op, Problem: problem, Hint: hint, Err: err}
+}
+
+func classifyAdapterError(goos, msg string) (AdapterProblem, string) {
+	has := func(subs ...string) bool {
+		for _, s := range subs {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch goos {
+	case "linux":
+		switch {
+		case has("accessdenied", "permission denied", "not authorized", "notpermitted"):
+			return ProblemPermission, "add the user to the bluetooth group (sudo usermod -aG bluetooth $USER) and log in again"
+		case has("system_bus_socket", "dbus_system_bus_address"):
+			return ProblemNoStack, "the D-Bus system bus isn't reachable; in a container, mount /run/dbus/system_bus_socket"
+		case has("org.bluez was not provided", "serviceunknown", "name has no owner"):
+			return ProblemNoStack, "BlueZ isn't running; start it with: sudo systemctl start bluetooth"
+		case has("rfkill", "blocked"), has("notready", "not powered", "powered off") && len(rfkillBlocked()) > 0:
+			return ProblemBlocked, "the radio is blocked; run: rfkill unblock bluetooth"
+		case has("notready", "not powered", "powered off"):
+			return ProblemPoweredOff, "power the adapter on: bluetoothctl power on"
+		case has("unknownobject", "no such object", "no such adapter", "adapter not found"):
+			return ProblemNoAdapter, "no Bluetooth adapter found; check it is plugged in and its driver loaded (dmesg)"
+		}
+	case "darwin":
+		switch {
+		case has("unauthorized", "denied", "not allowed"):
+			return ProblemPermission, "allow this program, or the terminal running it, in System Settings > Privacy & Security > Bluetooth"
+		case has("powered off", "poweredoff"):
+			return ProblemPoweredOff, "turn Bluetooth on in Control Center"
+		case has("unsupported"):
+			return ProblemNoAdapter, "this Mac has no usable Bluetooth LE adapter"
+		}
+	case "windows":
+		switch {
+		case has("access is denied", "denied"):
+			return ProblemPermission, "allow apps to use Bluetooth in Settings > Privacy & security > Radios"
+		case has("radio", "powered off", "turned off"):
+			return ProblemPoweredOff, "turn Bluetooth on in Settings > Bluetooth & devices"
+		case has("not found", "no adapter"):
+			return ProblemNoAdapter, "no Bluetooth adapter found; check Device Manager"
+		}
+	}
+	return ProblemUnknown, ""
+}
+
+// rfkillBlocked lists the Linux Bluetooth radios blocked by rfkill.
+func rfkillBlocked() []string {
+	read := func(path string) string {
+		b, _ := os.ReadFile(path)
+		return strings.TrimSpace(string(b))
+	}
+
+	var blocked []string
+	dirs, _ := filepath.Glob("/sys/class/rfkill/rfkill*")
+	for _, dir := range dirs {
+		if read(filepath.Join(dir, "type")) != "bluetooth" {
+			continue
+		}
+		name := read(filepath.Join(dir, "name"))
+		if read(filepath.Join(dir, "soft")) == "1" {
+			blocked = append(blocked, name+" soft blocked")
+		}
+		if read(filepath.Join(dir, "hard")) == "1" {
+			blocked = append(blocked, name+" hard blocked")
+		}
+	}
+	return blocked
+}
+
+// RfkillBlocked lists the Bluetooth radios blocked by rfkill, e.g.
+// "hci0 soft blocked". It is always empty except on Linux.
+func RfkillBlocked() []string {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	return rfkillBlocked()
+}
@@ -0,0 +1,129 @@
+package goscale
+
+import (
+	"math"
+	"time"
+)
+
+// ActivityGrams is how far the weight must move to count as the scale
+// being used, and so restart its sleep timer. Smaller changes are noise.
+const ActivityGrams = 0.2
+
+// DefaultAutoOffLead is how long before a scale is expected to switch
+// itself off that WatchAutoOff warns, if AutoOffWatchConfig.Lead is unset.
+const DefaultAutoOffLead = time.Minute
+
+// AutoOffWarning is sent when a scale is expected to switch itself off
+// soon, having gone unused for most of its sleep timeout. Using the scale,
+// or keeping it awake (see KeepAwaker), puts it off.
+type AutoOffWarning struct {
+	Device    string        // DeviceName of the scale
+	In        time.Duration // Expected time until it switches off
+	Timeout   SleepTimeout  // The scale's sleep timeout
+	Timestamp time.Time
+}
+
+// AutoOffWatchConfig configures WatchAutoOff.
+type AutoOffWatchConfig struct {
+	// Lead is how long before the expected auto-off to warn. Zero means
+	// DefaultAutoOffLead.
+	Lead time.Duration
+}
+
+// WatchAutoOff passes on every update from in, watching them for activity,
+// and sends an AutoOffWarning when s has gone unused for long enough that
+// it is expected to switch itself off within cfg.Lead. It warns once per
+// idle stretch. The scale's sleep timeout is read with SleepTimeoutSetting,
+// so scales that can't report it never produce warnings. Activity the host
+// can't see, such as button presses, isn't counted, so warnings err on the
+// early side. Both returned channels are closed when in closes.
+func WatchAutoOff(s Scale, in <-chan WeightUpdate, cfg AutoOffWatchConfig) (<-chan WeightUpdate, <-chan AutoOffWarning) {
+	if cfg.Lead <= 0 {
+		cfg.Lead = DefaultAutoOffLead
+	}
+	out := make(chan WeightUpdate, cap(in))
+	warnings := make(chan AutoOffWarning, 1)
+	go func() {
+		defer close(out)
+		defer close(warnings)
+		idle := newIdleTracker(time.Now())
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case u, ok := <-in:
+				if !ok {
+					return
+				}
+				idle.observe(u)
+				out <- u
+			case now := <-ticker.C:
+				if w, ok := idle.check(s, now, cfg.Lead); ok {
+					select {
+					case warnings <- w:
+					default:
+					}
+				}
+			}
+		}
+	}()
+	return out, warnings
+}
+
+// idleTracker follows how long a scale has gone unused.
+type idleTracker struct {
+	lastActive time.Time
+	lastWeight float64
+	weighed    bool
+	warned     bool
+}
+
+func newIdleTracker(now time.Time) *idleTracker {
+	return &idleTracker{lastActive: now}
+}
+
+// touch records activity at at, such as a button press.
+func (t *idleTracker) touch(at time.Time) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	t.lastActive, t.warned = at, false
+}
+
+// observe looks at an update for activity.
+func (t *idleTracker) observe(u WeightUpdate) {
+	if u.Error != nil {
+		return
+	}
+	grams, err := ConvertWeight(u.Value, u.Unit, UnitGrams)
+	if err != nil {
+		return
+	}
+	if !t.weighed || math.Abs(grams-t.lastWeight) >= ActivityGrams {
+		t.touch(u.Timestamp)
+	}
+	t.lastWeight, t.weighed = grams, true
+}
+
+// check returns a warning if s is due to switch off within lead of now, and
+// hasn't been warned about since it was last used.
+func (t *idleTracker) check(s Scale, now time.Time, lead time.Duration) (AutoOffWarning, bool) {
+	if t.warned || !s.IsConnected() {
+		return AutoOffWarning{}, false
+	}
+	timeout, err := SleepTimeoutSetting(s)
+	if err != nil || timeout == SleepTimeoutDisabled {
+		return AutoOffWarning{}, false
+	}
+	remaining := t.lastActive.Add(time.Duration(timeout)).Sub(now)
+	if remaining > lead {
+		return AutoOffWarning{}, false
+	}
+	t.warned = true
+	return AutoOffWarning{
+		Device:    s.DeviceName(),
+		In:        max(remaining, 0),
+		Timeout:   timeout,
+		Timestamp: now,
+	}, true
+}
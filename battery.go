@@ -0,0 +1,246 @@
+package goscale
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultLowBatteryThresholds are the charge percentages WatchBattery warns
+// at when none are configured.
+var DefaultLowBatteryThresholds = []float64{20, 10}
+
+// Defaults used by WatchBattery for zero BatteryWatchConfig fields.
+const (
+	DefaultBatteryWatchInterval = 30 * time.Second
+	DefaultBatteryHysteresis    = 5.0
+)
+
+// LowBatteryEvent is sent when a scale's charge drops below a threshold.
+type LowBatteryEvent struct {
+	Device    string  // DeviceName of the scale
+	Percent   float64 // Charge when the event was raised
+	Threshold float64 // The threshold that was crossed
+	Timestamp time.Time
+}
+
+// BatteryWatchConfig configures WatchBattery.
+type BatteryWatchConfig struct {
+	// Thresholds are the charge percentages to warn at.
+	Thresholds []float64
+
+	// Interval is how often the battery level is read.
+	Interval time.Duration
+
+	// Hysteresis is how many percent above a threshold the charge must rise
+	// before that threshold can fire again, e.g. after charging.
+	Hysteresis float64
+}
+
+// BatteryUpdate is a battery reading received from a scale.
+type BatteryUpdate struct {
+	Percent   float64
+	Timestamp time.Time // When the reading arrived
+}
+
+// BatteryNotifier is implemented by scales that pass on battery readings as
+// they arrive, rather than only through GetBatteryChargePercent.
+type BatteryNotifier interface {
+	// BatteryUpdates returns a channel of battery readings, sent with the
+	// first reading after connecting and whenever the level changes. The
+	// channel is replaced on each Connect and closed on Disconnect, so call
+	// this after Connect.
+	BatteryUpdates() <-chan BatteryUpdate
+}
+
+// BatteryUpdates returns the battery readings of s, or ErrNotSupported.
+func BatteryUpdates(s Scale) (<-chan BatteryUpdate, error) {
+	if bn, ok := As[BatteryNotifier](s); ok {
+		return bn.BatteryUpdates(), nil
+	}
+	return nil, ErrNotSupported
+}
+
+// BatteryStream helps drivers implement BatteryNotifier. Open it on
+// connect, Report each reading as it arrives, and Close it on disconnect.
+// A consumer that falls behind loses the oldest readings rather than
+// stalling the driver. It is safe for concurrent use.
+type BatteryStream struct {
+	mu   sync.Mutex
+	c    chan BatteryUpdate
+	last float64
+}
+
+// batteryStreamBuffer is how many readings a BatteryStream holds for a
+// slow consumer.
+const batteryStreamBuffer = 4
+
+// Open replaces the stream's channel with a new one, closing the old.
+func (b *BatteryStream) Open() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.c != nil {
+		close(b.c)
+	}
+	b.c = make(chan BatteryUpdate, batteryStreamBuffer)
+	b.last = -1
+}
+
+// Close closes the stream's channel.
+func (b *BatteryStream) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.c != nil {
+		close(b.c)
+		b.c = nil
+	}
+}
+
+// Updates returns the stream's channel, or nil before Open.
+func (b *BatteryStream) Updates() <-chan BatteryUpdate {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.c
+}
+
+// Report sends a reading received at at, if it differs from the last one
+// sent. It never blocks.
+func (b *BatteryStream) Report(at time.Time, percent float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.c == nil || percent == b.last {
+		return
+	}
+	b.last = percent
+	u := BatteryUpdate{Percent: percent, Timestamp: at}
+	select {
+	case b.c <- u:
+		return
+	default:
+	}
+	// Full: drop the oldest reading to make room.
+	select {
+	case <-b.c:
+	default:
+	}
+	b.c <- u
+}
+
+// WatchBattery reads the battery level of s every cfg.Interval and sends a
+// LowBatteryEvent each time it falls below one of cfg.Thresholds. A drop
+// has to be seen on two reads in a row to count, and a threshold only fires
+// again once the charge has recovered past it by cfg.Hysteresis. If the
+// charge falls past several thresholds at once, only the lowest is reported.
+// Scales that are BatteryNotifiers are read as their readings arrive, the
+// drop being confirmed if it still stands cfg.Interval later.
+//
+// Readings of zero are taken to mean the scale hasn't reported its battery
+// yet. Scales without the BatteryPercent feature never produce events. The
+// returned channel is closed when ctx is done.
+func WatchBattery(ctx context.Context, s Scale, cfg BatteryWatchConfig) <-chan LowBatteryEvent {
+	if len(cfg.Thresholds) == 0 {
+		cfg.Thresholds = DefaultLowBatteryThresholds
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultBatteryWatchInterval
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = DefaultBatteryHysteresis
+	}
+	thresholds := slices.Clone(cfg.Thresholds)
+	slices.Sort(thresholds)
+
+	out := make(chan LowBatteryEvent, len(thresholds))
+	go func() {
+		defer close(out)
+		if !s.GetFeatures().BatteryPercent {
+			<-ctx.Done()
+			return
+		}
+		notifier, notifies := As[BatteryNotifier](s)
+
+		fired := make([]bool, len(thresholds))
+		confirming := false
+		// check looks at a reading, returning the event to send, if any.
+		check := func(pct float64) (LowBatteryEvent, bool) {
+			for i, t := range thresholds {
+				if fired[i] && pct >= t+cfg.Hysteresis {
+					fired[i] = false
+				}
+			}
+
+			// The lowest threshold newly crossed, if any.
+			crossed := -1
+			for i, t := range thresholds {
+				if pct < t && !fired[i] {
+					crossed = i
+					break
+				}
+			}
+			if crossed < 0 {
+				confirming = false
+				return LowBatteryEvent{}, false
+			}
+			if !confirming {
+				// Wait for the next read to confirm it.
+				confirming = true
+				return LowBatteryEvent{}, false
+			}
+			confirming = false
+
+			for i, t := range thresholds {
+				if pct < t {
+					fired[i] = true
+				}
+			}
+			return LowBatteryEvent{Device: s.DeviceName(), Percent: pct, Threshold: thresholds[crossed], Timestamp: time.Now()}, true
+		}
+
+		var (
+			readings <-chan BatteryUpdate
+			latest   float64
+		)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if notifies && readings == nil && s.IsConnected() {
+				readings = notifier.BatteryUpdates()
+			}
+			var pct float64
+			select {
+			case <-ctx.Done():
+				return
+			case u, ok := <-readings:
+				if !ok {
+					readings, latest = nil, 0
+					continue
+				}
+				latest, pct = u.Percent, u.Percent
+			case <-ticker.C:
+				if !s.IsConnected() {
+					continue
+				}
+				if readings != nil {
+					pct = latest
+				} else {
+					var err error
+					if pct, err = s.GetBatteryChargePercent(); err != nil {
+						continue
+					}
+				}
+			}
+			if pct <= 0 {
+				continue
+			}
+			if ev, ok := check(pct); ok {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,63 @@
+package goscale
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OnWeight calls f with each update from in, in order, on a goroutine of
+// its own, for embedders such as GUI toolkits and cgo hosts that would
+// rather register a callback than run a channel loop. It carries on until
+// in closes or the returned cancel is called. After cancel, in is still
+// drained, without calling f, so the driver isn't held up; cancel doesn't
+// wait for a call under way, so f may call it. Updates queue in in while f
+// runs, so f should return promptly.
+func OnWeight(in <-chan WeightUpdate, f func(WeightUpdate)) (cancel func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		for u := range in {
+			select {
+			case <-done:
+				continue
+			default:
+			}
+			f(u)
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// OnWeight calls f with each weight update from the scale, as OnWeight
+// does, through a subscription of its own (see Subscribe), so callbacks and
+// channel consumers can share the connection. cancel unsubscribes.
+func (cs *ConnectedScale) OnWeight(f func(WeightUpdate)) (cancel func()) {
+	c := cs.Subscribe(0)
+	stop := OnWeight(c, f)
+	return func() {
+		stop()
+		cs.Unsubscribe(c)
+	}
+}
+
+// OnEvent calls f with each event on the bus that passes every filter, in
+// order, on a goroutine of its own, until the bus closes or the returned
+// cancel is called. It is a subscription with the default buffer, so a
+// slow f misses events rather than holding up publishers. cancel doesn't
+// wait for a call under way, so f may call it.
+func (b *Bus) OnEvent(f func(Event), filters ...Filter) (cancel func()) {
+	sub := b.Subscribe(0, filters...)
+	var cancelled atomic.Bool
+	go func() {
+		for ev := range sub.C {
+			// Events already queued when cancel was called are dropped.
+			if !cancelled.Load() {
+				f(ev)
+			}
+		}
+	}()
+	return func() {
+		cancelled.Store(true)
+		sub.Cancel()
+	}
+}
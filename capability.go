@@ -0,0 +1,347 @@
+package goscale
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+)
+
+// Not every scale can do everything. Functions beyond the Scale interface are
+// exposed as optional interfaces a driver may implement, along with helpers
+// that check for them, via As, so applications can stay scale-agnostic.
+
+// ErrNotSupported is returned when a scale lacks the requested capability.
+var ErrNotSupported = errors.New("not supported by this scale")
+
+// As returns s as a T, looking through wrappers such as fallback.Scale that
+// have an Unwrap() Scale method, outermost first. It is how to reach
+// capabilities without a helper here, including driver-specific ones,
+// without importing the driver: declare an interface with the methods
+// needed and ask for it. A wrapper that changes what a method does must
+// implement the interface too, or As finds the wrapped scale's.
+//
+//	type standbySetter interface{ SetStandbyMinutes(m uint16) error }
+//
+//	if ss, ok := goscale.As[standbySetter](scale); ok {
+//		err = ss.SetStandbyMinutes(10)
+//	}
+func As[T any](s Scale) (T, bool) {
+	for s != nil {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		u, ok := s.(interface{ Unwrap() Scale })
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// KeyLocker is implemented by scales whose buttons can be disabled.
+type KeyLocker interface {
+	// LockKeys disables the scale's buttons. d is the delay after the last
+	// key press before the lock takes effect; drivers round it up to the
+	// nearest setting the scale supports.
+	LockKeys(d time.Duration) error
+
+	// UnlockKeys re-enables the scale's buttons.
+	UnlockKeys() error
+}
+
+// LockKeys locks the buttons of s, or returns ErrNotSupported.
+func LockKeys(s Scale, d time.Duration) error {
+	if kl, ok := As[KeyLocker](s); ok {
+		return kl.LockKeys(d)
+	}
+	return ErrNotSupported
+}
+
+// UnlockKeys unlocks the buttons of s, or returns ErrNotSupported.
+func UnlockKeys(s Scale) error {
+	if kl, ok := As[KeyLocker](s); ok {
+		return kl.UnlockKeys()
+	}
+	return ErrNotSupported
+}
+
+// BatteryPoller is implemented by scales that only report their battery
+// level when asked. Polling is off until an interval is set; with it on,
+// GetBatteryChargePercent stays fresh without the application polling.
+type BatteryPoller interface {
+	// SetBatteryPollInterval sets how often the battery level is requested.
+	// Zero turns polling off.
+	SetBatteryPollInterval(d time.Duration)
+}
+
+// SetBatteryPollInterval sets the battery poll interval of s, or returns
+// ErrNotSupported if s doesn't need polling.
+func SetBatteryPollInterval(s Scale, d time.Duration) error {
+	if bp, ok := As[BatteryPoller](s); ok {
+		bp.SetBatteryPollInterval(d)
+		return nil
+	}
+	return ErrNotSupported
+}
+
+// KeepAwaker is implemented by scales that can be stopped from auto-sleeping
+// by sending them something innocuous from time to time, e.g. during a long
+// pour-over or while a session is armed but idle.
+type KeepAwaker interface {
+	// SetKeepAwake turns keep-awake on or off. It may be called at any time.
+	SetKeepAwake(on bool) error
+}
+
+// SetKeepAwake turns keep-awake on or off for s, or returns ErrNotSupported.
+func SetKeepAwake(s Scale, on bool) error {
+	if ka, ok := As[KeepAwaker](s); ok {
+		return ka.SetKeepAwake(on)
+	}
+	return ErrNotSupported
+}
+
+// Timer is implemented by scales with a built-in timer that can be driven
+// remotely.
+type Timer interface {
+	StartTimer() error
+	StopTimer() error
+	ResetTimer() error
+}
+
+// StartTimer starts the timer of s, or returns ErrNotSupported.
+func StartTimer(s Scale) error {
+	if t, ok := As[Timer](s); ok {
+		return t.StartTimer()
+	}
+	return ErrNotSupported
+}
+
+// StopTimer stops the timer of s, or returns ErrNotSupported.
+func StopTimer(s Scale) error {
+	if t, ok := As[Timer](s); ok {
+		return t.StopTimer()
+	}
+	return ErrNotSupported
+}
+
+// ResetTimer resets the timer of s, or returns ErrNotSupported.
+func ResetTimer(s Scale) error {
+	if t, ok := As[Timer](s); ok {
+		return t.ResetTimer()
+	}
+	return ErrNotSupported
+}
+
+// ErrVersionUnknown is returned by FirmwareVersion before the scale has
+// reported its firmware version.
+var ErrVersionUnknown = errors.New("firmware version not reported yet")
+
+// FirmwareVersioner is implemented by scales that report their firmware
+// version.
+type FirmwareVersioner interface {
+	// FirmwareVersion returns the version as the vendor writes it, e.g.
+	// "1.0.18", or ErrVersionUnknown.
+	FirmwareVersion() (string, error)
+}
+
+// FirmwareProgress reports how much of a firmware image has been sent.
+type FirmwareProgress struct {
+	Sent  int64
+	Total int64
+}
+
+// FirmwareUpdater is implemented by scales whose firmware can be updated
+// over Bluetooth, so fleets can be updated without the vendor's phone app.
+type FirmwareUpdater interface {
+	FirmwareVersioner
+
+	// UpdateFirmware sends a firmware image of size bytes to the scale and
+	// has it install the image. progress, if not nil, is called as the image
+	// is sent. Cancelling ctx aborts the transfer; what state the scale is
+	// left in then depends on the vendor's update flow.
+	UpdateFirmware(ctx context.Context, image io.Reader, size int64, progress func(FirmwareProgress)) error
+}
+
+// FirmwareVersion returns the firmware version of s, or ErrNotSupported.
+func FirmwareVersion(s Scale) (string, error) {
+	if fv, ok := As[FirmwareVersioner](s); ok {
+		return fv.FirmwareVersion()
+	}
+	return "", ErrNotSupported
+}
+
+// UpdateFirmware updates the firmware of s, or returns ErrNotSupported.
+func UpdateFirmware(ctx context.Context, s Scale, image io.Reader, size int64, progress func(FirmwareProgress)) error {
+	if fu, ok := As[FirmwareUpdater](s); ok {
+		return fu.UpdateFirmware(ctx, image, size, progress)
+	}
+	return ErrNotSupported
+}
+
+// FlowRater is implemented by scales that work out flow rate themselves and
+// report it.
+type FlowRater interface {
+	// FlowRate returns the flow rate last reported, in grams per second.
+	FlowRate() (float64, error)
+}
+
+// FlowRate returns the flow rate reported by s, or ErrNotSupported.
+func FlowRate(s Scale) (float64, error) {
+	if fr, ok := As[FlowRater](s); ok {
+		return fr.FlowRate()
+	}
+	return 0, ErrNotSupported
+}
+
+// UnitSetter is implemented by scales whose display unit can be switched
+// remotely.
+type UnitSetter interface {
+	// SetUnit switches the scale to unit, e.g. UnitGrams.
+	SetUnit(unit string) error
+}
+
+// SetUnit switches the display unit of s, or returns ErrNotSupported.
+func SetUnit(s Scale, unit string) error {
+	if us, ok := As[UnitSetter](s); ok {
+		return us.SetUnit(unit)
+	}
+	return ErrNotSupported
+}
+
+// Sleeper is implemented by scales that can be told to power down.
+type Sleeper interface {
+	// Sleep powers the scale down and disconnects from it.
+	Sleep() error
+}
+
+// Sleep powers s down, or returns ErrNotSupported.
+func Sleep(s Scale) error {
+	if sl, ok := As[Sleeper](s); ok {
+		return sl.Sleep()
+	}
+	return ErrNotSupported
+}
+
+// SleepTimeoutSetter is implemented by scales whose sleep timer can be read
+// and set as a SleepTimeout, rather than through the string getter and
+// stepping with AdvanceSleepTimeout.
+type SleepTimeoutSetter interface {
+	// SleepTimeoutSetting returns the scale's current sleep timeout. It
+	// fails until the scale has reported it, or if it reports a setting the
+	// driver doesn't know.
+	SleepTimeoutSetting() (SleepTimeout, error)
+
+	// SetSleepTimeout sets the sleep timeout, which must be one of
+	// SleepTimeouts, or returns an *UnsupportedSleepTimeoutError.
+	SetSleepTimeout(t SleepTimeout) error
+
+	// SleepTimeouts returns the timeouts the scale supports, shortest
+	// first.
+	SleepTimeouts() []SleepTimeout
+}
+
+// SleepTimeoutSetting returns the sleep timeout of s, or ErrNotSupported.
+func SleepTimeoutSetting(s Scale) (SleepTimeout, error) {
+	if st, ok := As[SleepTimeoutSetter](s); ok {
+		return st.SleepTimeoutSetting()
+	}
+	return 0, ErrNotSupported
+}
+
+// SetSleepTimeout sets the sleep timeout of s, or returns ErrNotSupported.
+func SetSleepTimeout(s Scale, t SleepTimeout) error {
+	if st, ok := As[SleepTimeoutSetter](s); ok {
+		return st.SetSleepTimeout(t)
+	}
+	return ErrNotSupported
+}
+
+// SleepTimeouts returns the sleep timeouts s supports, or nil if it isn't a
+// SleepTimeoutSetter.
+func SleepTimeouts(s Scale) []SleepTimeout {
+	if st, ok := As[SleepTimeoutSetter](s); ok {
+		return st.SleepTimeouts()
+	}
+	return nil
+}
+
+// DisconnectReasoner is implemented by scales that can say why their last
+// connection ended.
+type DisconnectReasoner interface {
+	// DisconnectReason returns why the last connection ended, or
+	// DisconnectUnknown while connected.
+	DisconnectReason() DisconnectReason
+}
+
+// DisconnectReasonOf returns why the last connection of s ended, or
+// DisconnectUnknown if s can't say.
+func DisconnectReasonOf(s Scale) DisconnectReason {
+	if dr, ok := As[DisconnectReasoner](s); ok {
+		return dr.DisconnectReason()
+	}
+	return DisconnectUnknown
+}
+
+// ButtonNotifier is implemented by scales that report presses of their
+// physical buttons.
+type ButtonNotifier interface {
+	// ButtonEvents returns a channel of button presses. The channel is
+	// replaced on each Connect and closed on Disconnect, so call this after
+	// Connect. Presses are dropped if the receiver falls behind.
+	ButtonEvents() <-chan ButtonEvent
+}
+
+// ButtonEvents returns the button presses of s, or ErrNotSupported.
+func ButtonEvents(s Scale) (<-chan ButtonEvent, error) {
+	if bn, ok := As[ButtonNotifier](s); ok {
+		return bn.ButtonEvents(), nil
+	}
+	return nil, ErrNotSupported
+}
+
+// ContextConnector is implemented by scales whose connection attempt can be
+// bounded or cancelled with a context, e.g. to abort a hung GATT discovery.
+type ContextConnector interface {
+	// ConnectContext is Connect, giving up and cleaning up if ctx is done
+	// before the connection is ready.
+	ConnectContext(ctx context.Context) (<-chan WeightUpdate, error)
+}
+
+// ConnectContext connects s, giving up when ctx is done. Scales that aren't
+// ContextConnectors are connected in the background, and disconnected again
+// if the connection completes after ConnectContext has given up.
+func ConnectContext(ctx context.Context, s Scale) (<-chan WeightUpdate, error) {
+	if cc, ok := As[ContextConnector](s); ok {
+		return cc.ConnectContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		updates <-chan WeightUpdate
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		updates, err := s.Connect()
+		done <- result{updates, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.updates, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.err == nil {
+				log.Printf("late connection to %s after giving up, disconnecting", s.DeviceName())
+				_ = s.Disconnect()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
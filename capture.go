@@ -0,0 +1,225 @@
+package goscale
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults used by OpenFrameCapture for zero config fields.
+const (
+	DefaultCaptureMaxSize     = 1 << 20 // 1 MiB
+	DefaultCaptureMaxFiles    = 3
+	DefaultCaptureMinInterval = time.Minute
+)
+
+// UnhandledFrame is a frame a driver received but doesn't know how to
+// decode or handle, along with enough about the device for someone to work
+// out what it means.
+type UnhandledFrame struct {
+	Device  string // Name as found during the scan
+	Address string
+	Model   string // Driver display name
+	Key     string // What kind of frame this is, e.g. its command id
+	Frame   []byte // The raw frame as received
+}
+
+// FrameCaptureConfig configures a FrameCapture.
+type FrameCaptureConfig struct {
+	// Path of the capture file. Rotated files get a numeric suffix, so the
+	// oldest of three is Path.2.
+	Path string
+
+	// MaxSize is the size in bytes at which the file is rotated.
+	MaxSize int64
+
+	// MaxFiles is how many files, including the current one, are kept.
+	MaxFiles int
+
+	// MinInterval is how often a frame with the same device and Key is
+	// written. Frames identical to the last one written for their key are
+	// never written twice.
+	MinInterval time.Duration
+}
+
+// FrameCapture writes unhandled frames to a rotating file of JSON lines,
+// one frame per line. Frames are deduplicated and rate limited per key, so
+// a scale chattering the same unknown message many times a second produces
+// a handful of lines rather than thousands.
+type FrameCapture struct {
+	cfg FrameCaptureConfig
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	seen map[string]*captureKey
+}
+
+type captureKey struct {
+	last       time.Time
+	frame      string
+	suppressed int
+}
+
+type captureLine struct {
+	Time       string `json:"time"`
+	Device     string `json:"device"`
+	Address    string `json:"address,omitempty"`
+	Model      string `json:"model,omitempty"`
+	Key        string `json:"key"`
+	Frame      string `json:"frame"`
+	Suppressed int    `json:"suppressed,omitempty"`
+}
+
+// OpenFrameCapture opens the capture file at cfg.Path for appending.
+func OpenFrameCapture(cfg FrameCaptureConfig) (*FrameCapture, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultCaptureMaxSize
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = DefaultCaptureMaxFiles
+	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = DefaultCaptureMinInterval
+	}
+
+	c := &FrameCapture{cfg: cfg, seen: make(map[string]*captureKey)}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *FrameCapture) open() error {
+	f, err := os.OpenFile(c.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open capture file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not open capture file: %w", err)
+	}
+	c.f = f
+	c.size = info.Size()
+	return nil
+}
+
+// Capture writes frame to the file unless it's a duplicate or its key was
+// written too recently. It reports whether the frame was written.
+func (c *FrameCapture) Capture(frame UnhandledFrame) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return false, os.ErrClosed
+	}
+
+	now := time.Now()
+	raw := hex.EncodeToString(frame.Frame)
+	id := frame.Device + "/" + frame.Key
+	k, ok := c.seen[id]
+	if !ok {
+		k = &captureKey{}
+		c.seen[id] = k
+	} else if k.frame == raw || now.Sub(k.last) < c.cfg.MinInterval {
+		k.suppressed++
+		return false, nil
+	}
+
+	line, err := json.Marshal(captureLine{
+		Time:       now.Format(time.RFC3339Nano),
+		Device:     frame.Device,
+		Address:    frame.Address,
+		Model:      frame.Model,
+		Key:        frame.Key,
+		Frame:      raw,
+		Suppressed: k.suppressed,
+	})
+	if err != nil {
+		return false, err
+	}
+	line = append(line, '\n')
+
+	if c.size > 0 && c.size+int64(len(line)) > c.cfg.MaxSize {
+		if err := c.rotate(); err != nil {
+			return false, err
+		}
+	}
+	n, err := c.f.Write(line)
+	c.size += int64(n)
+	if err != nil {
+		return false, fmt.Errorf("could not write capture file: %w", err)
+	}
+
+	k.last = now
+	k.frame = raw
+	k.suppressed = 0
+	return true, nil
+}
+
+// rotate shifts Path.N-1 to Path.N and so on down to Path becoming Path.1,
+// dropping the oldest, then starts a fresh Path.
+func (c *FrameCapture) rotate() error {
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+	c.f = nil
+
+	for i := c.cfg.MaxFiles - 1; i > 0; i-- {
+		from := c.cfg.Path
+		if i > 1 {
+			from = fmt.Sprintf("%s.%d", c.cfg.Path, i-1)
+		}
+		err := os.Rename(from, fmt.Sprintf("%s.%d", c.cfg.Path, i))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not rotate capture file: %w", err)
+		}
+	}
+	if c.cfg.MaxFiles == 1 {
+		if err := os.Remove(c.cfg.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not rotate capture file: %w", err)
+		}
+	}
+	return c.open()
+}
+
+// Close closes the capture file.
+func (c *FrameCapture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return nil
+	}
+	err := c.f.Close()
+	c.f = nil
+	return err
+}
+
+var frameCapture atomic.Pointer[FrameCapture]
+
+// SetFrameCapture makes drivers write unhandled frames to c instead of
+// logging them. Pass nil to go back to logging.
+func SetFrameCapture(c *FrameCapture) {
+	frameCapture.Store(c)
+}
+
+// CaptureUnhandledFrame is called by drivers when they receive a frame they
+// don't handle. It returns false if no FrameCapture is set, in which case
+// the driver should log the frame itself.
+func CaptureUnhandledFrame(frame UnhandledFrame) bool {
+	c := frameCapture.Load()
+	if c == nil {
+		return false
+	}
+	if _, err := c.Capture(frame); err != nil {
+		// Keep the frame rather than lose it along with the error.
+		return false
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+// Command grindbyweight doses coffee by weight: it finds a scale, connects
+// to it, and grinds a series of doses with a grind.Controller, which stops
+// the grinder ahead of the target and learns how far ahead from each dose.
+//
+// By default both the scale and the grinder are simulated, so the whole
+// workflow runs without hardware: the mock grinder fills the mock scale at
+// a steady rate and, like a real one, keeps delivering for a moment after
+// it is stopped. With -scan it streams a scan for a real scale instead and
+// connects to the first one found; the grinder is then switched by hand,
+// when prompted.
+//
+// Usage:
+//
+//	go run ./grindbyweight -target 18 -doses 5
+//	go run ./grindbyweight -scan
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/grind"
+	_ "github.com/mlsorensen/goscale/pkg/scales/all"
+	"github.com/mlsorensen/goscale/pkg/scales/mock"
+)
+
+func main() {
+	target := flag.Float64("target", grind.DefaultDoseConfig.Target, "grams per dose")
+	doses := flag.Int("doses", 5, "how many doses to grind")
+	scan := flag.Bool("scan", false, "use the first real scale found rather than the mock, and a grinder switched by hand")
+	scanTimeout := flag.Duration("scan-timeout", 30*time.Second, "how long to scan for a scale")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var (
+		myScale goscale.Scale
+		grinder grind.Grinder
+	)
+	if *scan {
+		dev, err := firstScale(ctx, *scanTimeout)
+		if err != nil {
+			log.Fatalf("Fatal: Could not find a scale: %v", err)
+		}
+		myScale, err = goscale.NewScaleForDevice(dev)
+		if err != nil {
+			log.Fatalf("Fatal: Could not create scale instance: %v", err)
+		}
+		grinder = manualGrinder{}
+	} else {
+		// Read often and hold steady, as a real scale would, so the dose
+		// settles once the grinder is done.
+		m := mock.NewWithOptions(&goscale.FoundDevice{Name: "MOCK-Grinder-Scale"},
+			mock.WithInterval(100*time.Millisecond), mock.WithWander(0))
+		myScale = m
+		grinder = newMockGrinder(m, 1.8, 400*time.Millisecond)
+	}
+
+	weightUpdates, err := goscale.ConnectContext(ctx, myScale)
+	if err != nil {
+		_ = myScale.Disconnect()
+		log.Fatalf("Fatal: Could not connect to scale: %v", err)
+	}
+	defer myScale.Disconnect()
+	log.Printf("Connected to %s", myScale.DisplayName())
+
+	cfg := grind.DefaultDoseConfig
+	cfg.Target = *target
+	controller := grind.NewController(myScale, grinder, cfg)
+	for i := 1; i <= *doses; i++ {
+		log.Printf("Dose %d: grinding %.1fg, stopping %s early", i, cfg.Target, controller.Lag().Round(time.Millisecond))
+		res, err := controller.Dose(ctx, weightUpdates)
+		if err != nil {
+			log.Fatalf("Fatal: Dose %d failed: %v", i, err)
+		}
+		log.Printf("Dose %d: %.2fg (%+.2fg) in %s, stopped at %.2fg",
+			i, res.Weight, res.Error(), res.Ground.Round(time.Millisecond), res.StoppedAt)
+
+		if *scan && i < *doses {
+			log.Println("Empty the cup and put it back, then press Enter")
+			_, _ = fmt.Scanln()
+		}
+	}
+}
+
+// firstScale streams a scan and returns the first supported scale seen.
+func firstScale(ctx context.Context, timeout time.Duration) (*goscale.FoundDevice, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	found, err := goscale.ScanStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dev, ok := <-found
+	if !ok {
+		return nil, fmt.Errorf("none found in %s", timeout)
+	}
+	log.Printf("Found %s (%s, %d dBm)", dev.Name, dev.ID(), dev.RSSI)
+	return &dev, nil
+}
+
+// mockGrinder fills a mock scale at a fixed rate while running, and for
+// spinDown after it is stopped.
+type mockGrinder struct {
+	scale    *mock.MockScale
+	rate     float64 // Grams per second
+	spinDown time.Duration
+
+	mu      sync.Mutex
+	running bool
+	stopped time.Time
+}
+
+func newMockGrinder(s *mock.MockScale, rate float64, spinDown time.Duration) *mockGrinder {
+	g := &mockGrinder{scale: s, rate: rate, spinDown: spinDown}
+	go g.run()
+	return g
+}
+
+func (g *mockGrinder) Start() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running = true
+	return nil
+}
+
+func (g *mockGrinder) Stop() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running {
+		g.running, g.stopped = false, time.Now()
+	}
+	return nil
+}
+
+func (g *mockGrinder) run() {
+	const tick = 20 * time.Millisecond
+	for range time.Tick(tick) {
+		g.mu.Lock()
+		delivering := g.running || time.Since(g.stopped) < g.spinDown
+		g.mu.Unlock()
+		if delivering {
+			g.scale.Place(g.rate * tick.Seconds())
+		}
+	}
+}
+
+// manualGrinder asks for the grinder to be switched by hand.
+type manualGrinder struct{}
+
+func (manualGrinder) Start() error {
+	log.Println(">>> Start the grinder now")
+	return nil
+}
+
+func (manualGrinder) Stop() error {
+	log.Println(">>> STOP the grinder now")
+	return nil
+}
@@ -0,0 +1,91 @@
+// Command headless connects to the first supported scale it finds and
+// prints each weight reading to stdout as "<unix seconds> <grams>", one per
+// line, for piping into other tools. It has no GUI dependencies, so it
+// suits single-board computers and servers.
+//
+// It takes the preferred scale, driver, unit and smoothing from the
+// configuration file shared with the other examples (see pkg/config), so
+// with several scales in range it connects to the one the UI example last
+// used.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/config"
+	_ "github.com/mlsorensen/goscale/pkg/scales/all"
+)
+
+func main() {
+	scanTimeout := flag.Duration("scan-timeout", 10*time.Second, "how long to scan for a scale")
+	configPath := flag.String("config", "", "configuration file (default: goscale/config.yaml in the user's config directory)")
+	flag.Parse()
+
+	// Diagnostics go to stderr so stdout carries only readings.
+	log.SetOutput(os.Stderr)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+
+	dev, err := findScale(cfg, *scanTimeout)
+	if err != nil {
+		log.Fatalf("Fatal: Could not find a scale: %v", err)
+	}
+
+	myScale, err := cfg.NewScale(dev)
+	if err != nil {
+		log.Fatalf("Fatal: Could not create scale instance: %v", err)
+	}
+
+	go func() {
+		sigchan := make(chan os.Signal, 1)
+		signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigchan
+		_ = myScale.Disconnect()
+	}()
+
+	weightUpdates, err := myScale.Connect()
+	if err != nil {
+		_ = myScale.Disconnect()
+		log.Fatalf("Fatal: Could not connect to scale: %v", err)
+	}
+	log.Printf("Connected to %s", myScale.DisplayName())
+
+	for update := range goscale.ConvertUnits(weightUpdates, cfg.Unit()) {
+		if update.Error != nil {
+			log.Printf("Error received on update channel: %v", update.Error)
+			continue
+		}
+		ts := update.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		fmt.Printf("%.3f %.2f\n", float64(ts.UnixMilli())/1000, update.Value)
+	}
+}
+
+// findScale returns the first scale found that the configuration prefers.
+func findScale(cfg *config.Config, timeout time.Duration) (*goscale.FoundDevice, error) {
+	if cfg.Device.Name == "" {
+		return goscale.ScanForOne(timeout)
+	}
+	devices, err := goscale.Scan(timeout)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		if cfg.Device.Matches(&devices[i]) {
+			return &devices[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no scale matching %q found", cfg.Device.Name)
+}
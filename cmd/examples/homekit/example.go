@@ -0,0 +1,169 @@
+// Command homekit bridges a scale to HomeKit, for Siri and Home app
+// automations around the brew bar. The scale appears as one accessory with
+// its weight, in grams, as a custom characteristic, and a switch that tares
+// it: turning the switch on tares the scale, and it turns itself off again.
+// The Home app doesn't show custom characteristics, but apps such as Eve and
+// Home+, and automations, can read them.
+//
+// Pair it from the Home app with the PIN (-pin). The pairing is kept in the
+// -db directory, so keep that between runs.
+//
+// It takes the preferred scale, driver and smoothing from the configuration
+// file shared with the other examples (see pkg/config). It is its own
+// module, so its HomeKit dependency stays out of the library's go.sum.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/brutella/hap"
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/config"
+	_ "github.com/mlsorensen/goscale/pkg/scales/all"
+)
+
+// The UUIDs of the weight service and characteristic, which HomeKit has no
+// type for. They are this bridge's own; apps show them by their
+// descriptions.
+const (
+	typeWeightService = "4A1B7E62-2F0D-4C5B-9E0A-6D3C8B1F5A01"
+	typeWeight        = "4A1B7E62-2F0D-4C5B-9E0A-6D3C8B1F5A02"
+)
+
+// maxWeight is the highest weight, in grams, the characteristic reports;
+// readings beyond it are clamped. HomeKit clients use it to scale their
+// displays.
+const maxWeight = 5000
+
+// scaleAccessory is a scale as a HomeKit accessory.
+type scaleAccessory struct {
+	*accessory.A
+	Weight *characteristic.Float
+	Tare   *service.Switch
+}
+
+func newScaleAccessory(name string) *scaleAccessory {
+	a := &scaleAccessory{A: accessory.New(accessory.Info{
+		Name:         name,
+		Manufacturer: "goscale",
+	}, accessory.TypeSensor)}
+
+	weight := service.New(typeWeightService)
+	weight.Primary = true
+	a.Weight = characteristic.NewFloat(typeWeight)
+	a.Weight.Format = characteristic.FormatFloat
+	a.Weight.Permissions = []string{characteristic.PermissionRead, characteristic.PermissionEvents}
+	a.Weight.Description = "Weight (g)"
+	a.Weight.SetMinValue(-maxWeight)
+	a.Weight.SetMaxValue(maxWeight)
+	a.Weight.SetStepValue(0.1)
+	a.Weight.SetValue(0)
+	weight.AddC(a.Weight.C)
+	a.AddS(weight)
+
+	a.Tare = service.NewSwitch()
+	tareName := characteristic.NewName()
+	tareName.SetValue("Tare")
+	a.Tare.AddC(tareName.C)
+	a.AddS(a.Tare.S)
+	return a
+}
+
+func main() {
+	scanTimeout := flag.Duration("scan-timeout", 10*time.Second, "how long to scan for a scale")
+	configPath := flag.String("config", "", "configuration file (default: goscale/config.yaml in the user's config directory)")
+	pin := flag.String("pin", "00102003", "8-digit PIN to pair with")
+	db := flag.String("db", "homekit", "directory to keep the HomeKit pairing in")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+
+	dev, err := findScale(cfg, *scanTimeout)
+	if err != nil {
+		log.Fatalf("Fatal: Could not find a scale: %v", err)
+	}
+
+	myScale, err := cfg.NewScale(dev)
+	if err != nil {
+		log.Fatalf("Fatal: Could not create scale instance: %v", err)
+	}
+
+	weightUpdates, err := myScale.Connect()
+	if err != nil {
+		_ = myScale.Disconnect()
+		log.Fatalf("Fatal: Could not connect to scale: %v", err)
+	}
+	defer func() { _ = myScale.Disconnect() }()
+	log.Printf("Connected to %s", myScale.DisplayName())
+
+	a := newScaleAccessory(myScale.DisplayName())
+	a.Tare.On.OnValueRemoteUpdate(func(on bool) {
+		if !on {
+			return
+		}
+		if err := myScale.Tare(false); err != nil {
+			log.Printf("Tare failed: %v", err)
+		}
+		// A momentary switch: it only ever stays on for the tare.
+		a.Tare.On.SetValue(false)
+	})
+
+	server, err := hap.NewServer(hap.NewFsStore(*db), a.A)
+	if err != nil {
+		log.Fatalf("Fatal: Could not create the HomeKit server: %v", err)
+	}
+	server.Pin = *pin
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		// Tenths of a gram are plenty for automations, and rounding keeps
+		// HomeKit from being sent an event for every reading.
+		for update := range goscale.ConvertUnits(weightUpdates, goscale.UnitGrams) {
+			if update.Error != nil {
+				log.Printf("Error received on update channel: %v", update.Error)
+				continue
+			}
+			a.Weight.SetValue(math.Round(update.Value*10) / 10)
+		}
+		log.Println("Scale disconnected")
+		stop()
+	}()
+
+	log.Printf("Serving %s to HomeKit; pair with PIN %s", myScale.DisplayName(), *pin)
+	if err := server.ListenAndServe(ctx); err != nil && ctx.Err() == nil {
+		_ = myScale.Disconnect()
+		log.Fatalf("Fatal: HomeKit server: %v", err)
+	}
+}
+
+// findScale returns the first scale found that the configuration prefers.
+func findScale(cfg *config.Config, timeout time.Duration) (*goscale.FoundDevice, error) {
+	if cfg.Device.Name == "" {
+		return goscale.ScanForOne(timeout)
+	}
+	devices, err := goscale.Scan(timeout)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		if cfg.Device.Matches(&devices[i]) {
+			return &devices[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no scale matching %q found", cfg.Device.Name)
+}
@@ -74,7 +74,7 @@ func main() {
 			if err != nil {
 				log.Printf("Error reading battery: %v", err)
 			} else {
-				log.Printf("--> Battery level is %d%%", batt)
+				log.Printf("--> Battery level is %.0f%%", batt)
 			}
 		}
 	}()
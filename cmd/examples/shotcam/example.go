@@ -0,0 +1,103 @@
+// Command shotcam records a video of every shot: it connects to the first
+// supported scale it finds and runs ffmpeg from the moment the weight starts
+// rising until it settles, saving each shot to its own file.
+//
+// It needs ffmpeg on the PATH and a camera ffmpeg can read. The defaults
+// suit a USB webcam on Linux (v4l2), macOS (avfoundation) or Windows
+// (dshow, where -input must name the camera).
+//
+// Usage:
+//
+//	go run ./shotcam -out ~/shots
+//	go run ./shotcam -input-format dshow -input "video=USB Camera"
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/brew"
+	"github.com/mlsorensen/goscale/pkg/bridge"
+	_ "github.com/mlsorensen/goscale/pkg/scales/all"
+)
+
+func main() {
+	format, input := defaultCamera()
+	scanTimeout := flag.Duration("scan-timeout", 10*time.Second, "how long to scan for a scale")
+	ffmpeg := flag.String("ffmpeg", "ffmpeg", "ffmpeg executable")
+	inputFormat := flag.String("input-format", format, "ffmpeg input format for the camera")
+	inputName := flag.String("input", input, "ffmpeg input name of the camera")
+	outDir := flag.String("out", ".", "directory to save videos to")
+	start := flag.Float64("start-threshold", brew.DefaultLifecycleConfig.StartThreshold, "grams the weight must rise by to start recording")
+	flag.Parse()
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+
+	dev, err := goscale.ScanForOne(*scanTimeout)
+	if err != nil {
+		log.Fatalf("Fatal: Could not find a scale: %v", err)
+	}
+	myScale, err := goscale.NewScaleForDevice(dev)
+	if err != nil {
+		log.Fatalf("Fatal: Could not create scale instance: %v", err)
+	}
+
+	go func() {
+		sigchan := make(chan os.Signal, 1)
+		signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigchan
+		_ = myScale.Disconnect()
+	}()
+
+	weightUpdates, err := myScale.Connect()
+	if err != nil {
+		_ = myScale.Disconnect()
+		log.Fatalf("Fatal: Could not connect to scale: %v", err)
+	}
+	log.Printf("Connected to %s; waiting for a shot", myScale.DisplayName())
+
+	// Record from brew_started until brew_ended. ffmpeg finishes the file
+	// when interrupted.
+	recorder := &bridge.CommandNotifier{Hooks: []bridge.CommandHook{{
+		Command: []string{
+			*ffmpeg, "-hide_banner", "-loglevel", "error", "-y",
+			"-f", *inputFormat, "-i", *inputName,
+			filepath.Join(*outDir, "shot-${GOSCALE_STAMP}.mp4"),
+		},
+		Events: []brew.LifecycleKind{brew.BrewStarted},
+		StopOn: brew.BrewEnded,
+	}}}
+	defer recorder.Stop()
+
+	cfg := brew.DefaultLifecycleConfig
+	cfg.StartThreshold = *start
+	for ev := range brew.TrackLifecycle(myScale.DeviceName(), weightUpdates, cfg) {
+		log.Printf("%s at %.1fg", ev.Kind, ev.Weight)
+		if err := recorder.Notify(context.Background(), ev); err != nil {
+			log.Printf("Error starting recording: %v", err)
+		}
+	}
+}
+
+// defaultCamera returns the ffmpeg input format and name of the usual
+// webcam on this platform.
+func defaultCamera() (format, input string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "avfoundation", "0"
+	case "windows":
+		return "dshow", "video=Integrated Camera"
+	default:
+		return "v4l2", "/dev/video0"
+	}
+}
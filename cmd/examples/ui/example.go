@@ -1,148 +1,408 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/app"
-	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/widget"
 	"log"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
 	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/config"
+	"github.com/mlsorensen/goscale/pkg/tare"
 	// This tells the Go compiler to include the package, which runs its init()
 	// function. The init() function, in turn, calls goscale.Register(). You can
 	// specify specific scales individually or just "all"
 	_ "github.com/mlsorensen/goscale/pkg/scales/all"
 )
 
+// uiRefreshInterval is how often the UI is allowed to redraw from scale data.
+// Scales can notify at 10-20 Hz; there's no point repainting faster than a
+// person can read, and a stalled redraw must never back up into the scale's
+// notification handler.
+const uiRefreshInterval = 100 * time.Millisecond
+
+// scanDuration is how long the device picker scans for before stopping.
+const scanDuration = 15 * time.Second
+
+// scaleApp walks through the three screens of the example: a live list of
+// scales found by the scan, a connecting screen, and a dashboard for the
+// connected scale. Its fields are only touched on the fyne thread; work done
+// elsewhere is handed back with fyne.Do.
+//
+// The configuration file shared with the other examples (see pkg/config)
+// supplies the unit, smoothing, target weights and tare presets. The scale
+// last connected to is remembered there, and connected to again as soon as
+// a scan finds it.
+type scaleApp struct {
+	app    fyne.App
+	window fyne.Window
+
+	cfg           *config.Config
+	saveConfig    bool // False if the file couldn't be read, so it isn't overwritten
+	autoConnected bool // The remembered scale has been connected to once
+
+	devices    []goscale.FoundDevice // In the order they were found
+	deviceList *widget.List
+	scanStatus *widget.Label
+	cancelScan context.CancelFunc
+
+	scale goscale.Scale // The connected scale, if any
+}
+
 func main() {
 	a := app.New()
 	w := a.NewWindow("Scale App")
-	dev, err := goscale.ScanForOne(10 * time.Second)
+	w.Resize(fyne.NewSize(360, 480))
 
+	cfg, err := config.Load("")
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("Ignoring configuration file: %v", err)
+		cfg = &config.Config{}
+	}
+
+	s := &scaleApp{app: a, window: w, cfg: cfg, saveConfig: err == nil}
+	s.showPicker()
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-shutdown
+		log.Println("Shutdown signal received:", sig)
+		fyne.Do(a.Quit)
+	}()
+
+	w.ShowAndRun()
+
+	if s.cancelScan != nil {
+		s.cancelScan()
+	}
+	if s.scale != nil {
+		if err := s.scale.Disconnect(); err != nil {
+			log.Printf("Error disconnecting from scale: %v", err)
+		}
+	}
+}
+
+// showPicker shows the list of scales in range and starts scanning for them.
+func (s *scaleApp) showPicker() {
+	s.devices = nil
+	s.scanStatus = widget.NewLabel("")
+	s.deviceList = widget.NewList(
+		func() int { return len(s.devices) },
+		func() fyne.CanvasObject {
+			badge := widget.NewLabel("")
+			badge.Importance = widget.HighImportance
+			return container.NewHBox(widget.NewLabel(""), badge, layout.NewSpacer(), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			dev := s.devices[id]
+			row := item.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(dev.Name)
+			row.Objects[1].(*widget.Label).SetText(goscale.MatchDriver(dev.Name))
+			row.Objects[3].(*widget.Label).SetText(fmt.Sprintf("%d dBm", dev.RSSI))
+		},
+	)
+	s.deviceList.OnSelected = func(id widget.ListItemID) {
+		dev := s.devices[id]
+		if s.cancelScan != nil {
+			s.cancelScan()
+		}
+		s.connect(&dev)
 	}
 
-	myScale, err := goscale.NewScaleForDevice(dev)
+	rescan := widget.NewButton("Scan again", s.startScan)
+	top := container.NewVBox(widget.NewLabel("Choose a scale"), s.scanStatus)
+	s.window.SetContent(container.NewBorder(top, rescan, nil, nil, s.deviceList))
+	s.startScan()
+}
+
+// startScan streams devices into the picker for scanDuration.
+func (s *scaleApp) startScan() {
+	if s.cancelScan != nil {
+		s.cancelScan()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), scanDuration)
+	s.cancelScan = cancel
+
+	found, err := goscale.ScanStream(ctx)
 	if err != nil {
-		log.Fatalf("Fatal: Could not create scale instance: %v", err)
+		cancel()
+		s.scanStatus.SetText(fmt.Sprintf("Scan failed: %v", err))
+		return
+	}
+	s.scanStatus.SetText("Scanning...")
+
+	go func() {
+		for dev := range found {
+			fyne.Do(func() { s.addDevice(dev) })
+		}
+		fyne.Do(func() {
+			if ctx.Err() == context.DeadlineExceeded {
+				s.scanStatus.SetText(fmt.Sprintf("Found %d scale(s)", len(s.devices)))
+			}
+		})
+	}()
+}
+
+// addDevice adds a newly found device to the picker, or updates its RSSI if
+// it is already listed.
+func (s *scaleApp) addDevice(dev goscale.FoundDevice) {
+	for i := range s.devices {
+		if s.devices[i].ID() == dev.ID() {
+			s.devices[i] = dev
+			s.deviceList.RefreshItem(i)
+			return
+		}
+	}
+	s.devices = append(s.devices, dev)
+	s.deviceList.Refresh()
+
+	if !s.autoConnected && s.cfg.Device.Name != "" && s.cfg.Device.Matches(&dev) {
+		s.autoConnected = true
+		if s.cancelScan != nil {
+			s.cancelScan()
+		}
+		s.connect(&dev)
+	}
+}
+
+// connect shows the connecting screen while connecting to dev, then the
+// dashboard, or the picker again if it fails.
+func (s *scaleApp) connect(dev *goscale.FoundDevice) {
+	s.window.SetContent(container.NewCenter(container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("Connecting to %s...", dev.Name)),
+		widget.NewProgressBarInfinite(),
+	)))
+
+	cfg := s.cfg
+	go func() {
+		scale, err := cfg.NewScale(dev)
+		if err != nil {
+			s.connectFailed(err)
+			return
+		}
+		updates, err := scale.Connect()
+		if err != nil {
+			s.connectFailed(err)
+			return
+		}
+		fyne.Do(func() {
+			if s.saveConfig {
+				s.cfg.Remember(dev)
+				if err := s.cfg.Save(""); err != nil {
+					log.Printf("Could not remember scale: %v", err)
+				}
+			}
+			s.showDashboard(scale, updates)
+		})
+	}()
+}
+
+func (s *scaleApp) connectFailed(err error) {
+	log.Printf("Could not connect to scale: %v", err)
+	fyne.Do(func() {
+		s.showPicker()
+		s.scanStatus.SetText(fmt.Sprintf("Connection failed: %v", err))
+	})
+}
+
+// showDashboard shows the connected scale's readings and controls until it
+// disconnects, then returns to the picker.
+func (s *scaleApp) showDashboard(scale goscale.Scale, updates <-chan goscale.WeightUpdate) {
+	s.scale = scale
+	features := scale.GetFeatures()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// All scale state shown in the dashboard lives in data bindings. Bindings
+	// are safe to set from any goroutine and fyne applies the widget updates
+	// on its own thread, so the loops below never touch widgets directly.
+	state := binding.NewString()
+	weight := binding.NewString()
+	flowRate := binding.NewString()
+	battery := binding.NewString()
+	batteryWarning := binding.NewString()
+	sleepTimeout := binding.NewString()
+	beepText := binding.NewString()
+	target := binding.NewFloat()
+	progress := binding.NewFloat()
+	_ = state.Set("connected")
+
+	// Readings are tared in software to a preset container weight, if one
+	// is chosen, and then shown in the configured unit.
+	presets, err := s.cfg.LoadTarePresets()
+	if err != nil {
+		log.Printf("Could not load tare presets: %v", err)
+		presets = tare.NewPresets()
+	}
+	softTare := tare.NewSoftTare(presets)
+	unit := s.cfg.Unit()
+	const noPreset = "no container"
+	presetSelect := widget.NewSelect(append([]string{noPreset}, presets.Names()...), func(name string) {
+		if name == noPreset {
+			softTare.Clear()
+			return
+		}
+		if err := softTare.TareToPreset(name); err != nil {
+			log.Printf("Error taring to preset: %v", err)
+		}
+	})
+	presetSelect.SetSelected(noPreset)
+
+	targetSelect := widget.NewSelect(s.cfg.TargetNames(), func(name string) {
+		grams, _ := s.cfg.Target(name)
+		_ = target.Set(grams)
+	})
+	if names := s.cfg.TargetNames(); len(names) > 0 {
+		targetSelect.SetSelected(names[0])
 	}
 
-	displayNameLabel := widget.NewLabel(myScale.DisplayName())
-	weightLabel := widget.NewLabel("")
-	batteryLabel := widget.NewLabel("")
-	sleepTimeoutLabel := widget.NewLabel("")
-	var wg sync.WaitGroup
 	tareButton := widget.NewButton("Tare", func() {
 		log.Println("-------------------------> Sending TARE command to scale...")
-		if err := myScale.Tare(true); err != nil {
+		if err := scale.Tare(true); err != nil {
 			log.Printf("Error taring scale: %v", err)
 		}
 	})
 
 	adjSleepButton := widget.NewButton("Adjust Sleep Timer", func() {
 		log.Println("advancing sleep timer")
-		if err := myScale.AdvanceSleepTimeout(); err != nil {
+		if err := scale.AdvanceSleepTimeout(); err != nil {
 			log.Printf("Error advancing sleep timer: %v", err)
 		}
 	})
 
-	beepFunc := func() {
-		if myScale.GetBeep() {
-			_ = myScale.SetBeep(false)
-		} else {
-			_ = myScale.SetBeep(true)
+	startTimerButton := widget.NewButton("Start Timer", func() {
+		if err := goscale.StartTimer(scale); err != nil {
+			log.Printf("Error starting timer: %v", err)
 		}
-	}
-	beepButton := widget.NewButton("", beepFunc)
-
-	var shutdown chan os.Signal
-	shutdown = make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-		for sig := range shutdown {
-			log.Println("Shutdown signal received:", sig)
-			a.Quit()
+	})
+	stopTimerButton := widget.NewButton("Stop Timer", func() {
+		if err := goscale.StopTimer(scale); err != nil {
+			log.Printf("Error stopping timer: %v", err)
 		}
-	}()
+	})
+	resetTimerButton := widget.NewButton("Reset Timer", func() {
+		if err := goscale.ResetTimer(scale); err != nil {
+			log.Printf("Error resetting timer: %v", err)
+		}
+	})
 
-	features := myScale.GetFeatures()
+	beepButton := widget.NewButton("", func() {
+		_ = scale.SetBeep(!scale.GetBeep())
+	})
+	// Buttons can't bind their text directly, so follow the binding with a
+	// listener instead. Listeners run on the fyne thread.
+	beepText.AddListener(binding.NewDataListener(func() {
+		text, _ := beepText.Get()
+		beepButton.SetText(text)
+	}))
 
-	var weightUpdates <-chan goscale.WeightUpdate
-	go func() {
-		defer wg.Done()
-		var err error
-		weightUpdates, err = myScale.Connect()
-		if err != nil {
-			log.Fatalf("Fatal: Could not connect to scale: %v", err)
-		}
-		for update := range weightUpdates {
-			if update.Error != nil {
-				log.Printf("Error received on update channel: %v", update.Error)
-				continue
-			}
-			fyne.Do(func() {
-				weightLabel.SetText(fmt.Sprintf("weight: %.2f %s", update.Value, update.Unit))
-				if features.BatteryPercent {
-					battPct, _ := myScale.GetBatteryChargePercent()
-					batteryLabel.SetText(fmt.Sprintf("battery: %.1f%%", battPct))
-				}
-				if features.SleepTimeout {
-					sleepTimeoutLabel.SetText(fmt.Sprintf("sleep timeout: %s", myScale.GetSleepTimeout()))
-				}
-				if features.Beep {
-					beepButton.SetText(fmt.Sprintf("Beep %s", enabledDisabled(myScale.GetBeep())))
-				}
-			})
-		}
-		if err := myScale.Disconnect(); err != nil {
+	disconnectButton := widget.NewButton("Disconnect", func() {
+		_ = state.Set("disconnecting")
+		if err := scale.Disconnect(); err != nil {
 			log.Printf("Error disconnecting from scale: %v", err)
 		}
-	}()
-
-	ctr := container.NewVBox()
-	ctr.Add(displayNameLabel)
-	ctr.Add(weightLabel)
+	})
 
+	ctr := container.NewVBox(
+		widget.NewLabel(scale.DisplayName()),
+		widget.NewLabelWithData(state),
+		widget.NewLabelWithData(weight),
+		widget.NewLabelWithData(flowRate),
+	)
 	if features.BatteryPercent {
-		ctr.Add(batteryLabel)
-	}
+		ctr.Add(widget.NewLabelWithData(battery))
+		ctr.Add(widget.NewLabelWithData(batteryWarning))
 
+		// Low battery arrives as events rather than being polled here.
+		go func() {
+			for ev := range goscale.WatchBattery(ctx, scale, goscale.BatteryWatchConfig{}) {
+				_ = batteryWarning.Set(fmt.Sprintf("battery below %.0f%%, charge soon", ev.Threshold))
+			}
+		}()
+	}
 	if features.SleepTimeout {
-		ctr.Add(sleepTimeoutLabel)
+		ctr.Add(widget.NewLabelWithData(sleepTimeout))
+	}
+	if len(s.cfg.Targets) > 0 {
+		ctr.Add(targetSelect)
+		ctr.Add(widget.NewProgressBarWithData(progress))
 	}
-
 	if features.Tare {
 		ctr.Add(tareButton)
 	}
-
+	if len(presets.Names()) > 0 {
+		ctr.Add(presetSelect)
+	}
+	if features.Timer {
+		ctr.Add(container.NewHBox(startTimerButton, stopTimerButton, resetTimerButton))
+	}
 	if features.SleepTimeout {
 		ctr.Add(adjSleepButton)
 	}
-
 	if features.Beep {
 		ctr.Add(beepButton)
 	}
-
-	w.SetContent(ctr)
+	ctr.Add(disconnectButton)
+	s.window.SetContent(ctr)
 
 	go func() {
-		wg.Wait()
-		if err := myScale.Disconnect(); err != nil {
-			log.Printf("Error disconnecting from scale: %v", err)
+		defer cancel()
+		// Throttle coalesces the raw stream down to the newest reading per
+		// refresh interval, so slow redraws drop stale readings rather than
+		// stalling the scale.
+		shown := goscale.ConvertUnits(softTare.Run(updates), unit)
+		for update := range goscale.Throttle(shown, uiRefreshInterval) {
+			if update.Error != nil {
+				log.Printf("Error received on update channel: %v", update.Error)
+				continue
+			}
+			if grams, _ := target.Get(); grams > 0 {
+				if value, err := goscale.ConvertWeight(update.Value, update.Unit, goscale.UnitGrams); err == nil {
+					_ = progress.Set(min(max(value/grams, 0), 1))
+				}
+			}
+			text := fmt.Sprintf("weight: %.2f %s", update.Value, update.Unit)
+			if features.StableFlag && update.HasStable && !update.Stable {
+				text += " ~"
+			}
+			_ = weight.Set(text)
+			if update.HasFlowRate {
+				source := "estimated"
+				if features.FlowRate {
+					source = "from scale"
+				}
+				_ = flowRate.Set(fmt.Sprintf("flow: %.1f %s/s (%s)", update.FlowRate, update.Unit, source))
+			}
+			if features.BatteryPercent {
+				battPct, _ := scale.GetBatteryChargePercent()
+				_ = battery.Set(fmt.Sprintf("battery: %.1f%%", battPct))
+			}
+			if features.SleepTimeout {
+				_ = sleepTimeout.Set(fmt.Sprintf("sleep timeout: %s", scale.GetSleepTimeout()))
+			}
+			if features.Beep {
+				_ = beepText.Set(fmt.Sprintf("Beep %s", enabledDisabled(scale.GetBeep())))
+			}
 		}
-	}()
 
-	w.ShowAndRun()
+		// The update channel closes when the scale disconnects, whether we
+		// asked it to or it went out of range.
+		log.Printf("%s disconnected", scale.DisplayName())
+		fyne.Do(func() {
+			s.scale = nil
+			s.showPicker()
+		})
+	}()
 }
 
 func enabledDisabled(enabled bool) string {
@@ -0,0 +1,29 @@
+// Command goscale-proxy runs a bleproxy agent, so goscale programs running
+// without a Bluetooth adapter, such as in a dev container, can use the
+// adapter of this host. Run it on the machine near the scale and dial it
+// with bleproxy.Dial.
+//
+// Usage:
+//
+//	goscale-proxy -listen :7331
+//
+// The agent has no authentication; only run it on a trusted network.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/mlsorensen/goscale/pkg/bleproxy"
+	_ "github.com/mlsorensen/goscale/pkg/scales/all"
+)
+
+func main() {
+	listen := flag.String("listen", bleproxy.DefaultAddr, "address to listen on")
+	flag.Parse()
+
+	log.Printf("bleproxy: listening on %s", *listen)
+	if err := bleproxy.NewAgent().ListenAndServe(*listen); err != nil {
+		log.Fatal(err)
+	}
+}
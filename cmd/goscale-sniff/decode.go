@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	akucomms "github.com/mlsorensen/goscale/pkg/scales/aku/comms"
+	lunarcomms "github.com/mlsorensen/goscale/pkg/scales/lunar/comms"
+	themiscomms "github.com/mlsorensen/goscale/pkg/scales/themis/comms"
+	umbracomms "github.com/mlsorensen/goscale/pkg/scales/umbra/comms"
+)
+
+// decoders maps device name prefixes, as registered by the drivers, to a
+// function describing a frame using that driver's decoder.
+var decoders = map[string]func([]byte) string{
+	"LUNAR": func(buf []byte) string {
+		msg, err := lunarcomms.DecodeNotification(buf)
+		if err != nil {
+			return "decode error: " + err.Error()
+		}
+		return fmt.Sprintf("%T %+v", msg, msg)
+	},
+	"UMBRA": func(buf []byte) string {
+		msg, err := umbracomms.DecodeNotification(buf)
+		if err != nil {
+			return "decode error: " + err.Error()
+		}
+		return fmt.Sprintf("%T %+v", msg, msg)
+	},
+	"BOOKOO": func(buf []byte) string {
+		status, ok := themiscomms.DecodeStatusUpdate(buf)
+		if !ok {
+			return "not a status frame"
+		}
+		return fmt.Sprintf("%+v", *status)
+	},
+	"Varia AKU": func(buf []byte) string {
+		weight, ok := akucomms.DecodeStatusUpdate(buf)
+		if !ok {
+			return "not a status frame"
+		}
+		return fmt.Sprintf("weight=%.2f", weight)
+	},
+}
+
+// decoderFor returns the decoder for a device name, or nil if there is none.
+func decoderFor(name string) func([]byte) string {
+	for prefix, decoder := range decoders {
+		if strings.HasPrefix(name, prefix) {
+			return decoder
+		}
+	}
+	return nil
+}
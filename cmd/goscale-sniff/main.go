@@ -0,0 +1,185 @@
+// Command goscale-sniff connects to a Bluetooth device, subscribes to every
+// notify characteristic in its primary service and prints each frame it
+// receives as timestamped hex. With -decode, frames from known scales are
+// also run through the matching driver's decoder. It is meant for working
+// out the protocol of scales that don't have a driver yet.
+//
+// Usage:
+//
+//	goscale-sniff -name LUNAR
+//	goscale-sniff -address AA:BB:CC:DD:EE:FF -service 0FFE -decode
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"tinygo.org/x/bluetooth"
+)
+
+// Services every device has, which are never the one carrying its protocol.
+var genericServices = []bluetooth.UUID{
+	bluetooth.ServiceUUIDGenericAccess,
+	bluetooth.ServiceUUIDGenericAttribute,
+	bluetooth.ServiceUUIDDeviceInformation,
+}
+
+func main() {
+	name := flag.String("name", "", "connect to the first device whose name starts with this")
+	address := flag.String("address", "", "connect to the device with this address")
+	service := flag.String("service", "", "UUID of the service to sniff (default: the first non-generic service)")
+	scanTimeout := flag.Duration("scan-timeout", 15*time.Second, "how long to scan for the device")
+	decode := flag.Bool("decode", false, "also print a best-effort decode of each frame")
+	flag.Parse()
+
+	if *name == "" && *address == "" {
+		fmt.Fprintln(os.Stderr, "one of -name or -address is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Frames go to stdout; everything else to stderr.
+	log.SetOutput(os.Stderr)
+
+	if err := goscale.TryEnableAdapter(); err != nil {
+		log.Fatalf("Fatal: Could not enable Bluetooth adapter: %v", err)
+	}
+
+	result, err := find(*name, *address, *scanTimeout)
+	if err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+	deviceName := result.LocalName()
+	log.Printf("Found %q at %s, connecting...", deviceName, result.Address)
+
+	device, err := goscale.BTAdapter.Connect(result.Address, bluetooth.ConnectionParams{})
+	if err != nil {
+		log.Fatalf("Fatal: Could not connect: %v", err)
+	}
+	defer device.Disconnect()
+
+	svc, err := pickService(device, *service)
+	if err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+	log.Printf("Sniffing service %s", svc.UUID())
+
+	chars, err := svc.DiscoverCharacteristics(nil)
+	if err != nil {
+		log.Fatalf("Fatal: Could not discover characteristics: %v", err)
+	}
+
+	var decoder func([]byte) string
+	if *decode {
+		decoder = decoderFor(deviceName)
+		if decoder == nil {
+			log.Printf("No decoder known for %q; printing raw frames only", deviceName)
+		}
+	}
+
+	subscribed := 0
+	for _, char := range chars {
+		uuid := char.UUID().String()
+		err := char.EnableNotifications(func(buf []byte) {
+			line := fmt.Sprintf("%s %s % X", time.Now().Format("15:04:05.000"), uuid, buf)
+			if decoder != nil {
+				line += "  " + decoder(buf)
+			}
+			fmt.Println(line)
+		})
+		if err != nil {
+			// Most likely not a notify characteristic.
+			log.Printf("Skipping characteristic %s: %v", uuid, err)
+			continue
+		}
+		log.Printf("Subscribed to characteristic %s", uuid)
+		subscribed++
+	}
+	if subscribed == 0 {
+		log.Fatalf("Fatal: No notify characteristics in service %s", svc.UUID())
+	}
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigchan
+	log.Println("Shutdown signal received. Disconnecting...")
+}
+
+// find scans until a device matching name (as a prefix) or address is seen.
+func find(name, address string, timeout time.Duration) (bluetooth.ScanResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	found := make(chan bluetooth.ScanResult, 1)
+	go func() {
+		err := goscale.BTAdapter.Scan(func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
+			if address != "" && !strings.EqualFold(result.Address.String(), address) {
+				return
+			}
+			if name != "" && !strings.HasPrefix(result.LocalName(), name) {
+				return
+			}
+			select {
+			case found <- result:
+				_ = adapter.StopScan()
+			default:
+			}
+		})
+		if err != nil {
+			log.Printf("Scan failed: %v", err)
+			cancel()
+		}
+	}()
+
+	select {
+	case result := <-found:
+		return result, nil
+	case <-ctx.Done():
+		_ = goscale.BTAdapter.StopScan()
+		return bluetooth.ScanResult{}, fmt.Errorf("no matching device found within %v", timeout)
+	}
+}
+
+// pickService returns the service with the given UUID or, if want is empty,
+// the first service that isn't one of the generic ones.
+func pickService(device bluetooth.Device, want string) (bluetooth.DeviceService, error) {
+	var filter []bluetooth.UUID
+	if want != "" {
+		uuid, err := bluetooth.ParseUUID(want)
+		if err != nil {
+			return bluetooth.DeviceService{}, fmt.Errorf("invalid service UUID %q: %w", want, err)
+		}
+		filter = []bluetooth.UUID{uuid}
+	}
+
+	services, err := device.DiscoverServices(filter)
+	if err != nil {
+		return bluetooth.DeviceService{}, fmt.Errorf("could not discover services: %w", err)
+	}
+	for _, svc := range services {
+		log.Printf("Found service %s", svc.UUID())
+	}
+	for _, svc := range services {
+		if want != "" || !isGeneric(svc.UUID()) {
+			return svc, nil
+		}
+	}
+	return bluetooth.DeviceService{}, fmt.Errorf("no suitable service found")
+}
+
+func isGeneric(uuid bluetooth.UUID) bool {
+	for _, g := range genericServices {
+		if uuid == g {
+			return true
+		}
+	}
+	return false
+}
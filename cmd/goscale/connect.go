@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/config"
+	"github.com/mlsorensen/goscale/pkg/reconnect"
+)
+
+// connectFlags are the flags every command that talks to a scale accepts.
+type connectFlags struct {
+	device      string
+	scanTimeout time.Duration
+	reconnect   bool
+	configPath  string
+
+	cfg *config.Config
+}
+
+func (c *connectFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.device, "device", "", "name prefix or address of the scale (default: the first one found)")
+	fs.DurationVar(&c.scanTimeout, "scan-timeout", 10*time.Second, "how long to scan for the scale")
+	fs.BoolVar(&c.reconnect, "reconnect", false, "reconnect if the scale drops out, rather than exiting")
+	fs.StringVar(&c.configPath, "config", "", "configuration file, for the preferred scale and smoothing (default: goscale/config.yaml in the user's config directory)")
+}
+
+// loadConfig loads the configuration file, once. -device takes precedence over
+// the scale it names.
+func (c *connectFlags) loadConfig() (*config.Config, error) {
+	if c.cfg != nil {
+		return c.cfg, nil
+	}
+	cfg, err := config.Load(c.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if c.device == "" {
+		c.device = cfg.Device.Name
+	}
+	c.cfg = cfg
+	return cfg, nil
+}
+
+// connect finds and connects to the scale. It disconnects the scale when the
+// process is interrupted, which closes the update channel.
+func (c *connectFlags) connect() (goscale.Scale, <-chan goscale.WeightUpdate, error) {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	dev, err := c.find()
+	if err != nil {
+		if hint := goscale.ErrorHint(err); hint != "" {
+			log.Printf("Hint: %s", hint)
+		}
+		return nil, nil, err
+	}
+	s, err := cfg.NewScale(dev)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.reconnect {
+		s = reconnect.Wrap(s, reconnect.DefaultConfig)
+	}
+
+	// A signal aborts a connection attempt in progress as well as ending
+	// one that is up.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigchan := make(chan os.Signal, 1)
+		signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigchan
+		cancel()
+		_ = s.Disconnect()
+	}()
+
+	updates, err := goscale.ConnectContext(ctx, s)
+	if err != nil {
+		_ = s.Disconnect()
+		return nil, nil, fmt.Errorf("could not connect to %s: %w", dev.Name, err)
+	}
+	log.Printf("Connected to %s", s.DisplayName())
+	return s, updates, nil
+}
+
+func (c *connectFlags) find() (*goscale.FoundDevice, error) {
+	if c.device == "" {
+		return goscale.ScanForOne(c.scanTimeout)
+	}
+	devices, err := goscale.Scan(c.scanTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if d := c.pick(devices); d != nil {
+		return d, nil
+	}
+	return nil, fmt.Errorf("no scale matching %q found", c.device)
+}
+
+// pick returns the device selected by -device from devices, or the first
+// one if -device wasn't given. It returns nil if none match.
+func (c *connectFlags) pick(devices []goscale.FoundDevice) *goscale.FoundDevice {
+	for i := range devices {
+		d := &devices[i]
+		if c.device == "" || strings.HasPrefix(d.Name, c.device) || strings.EqualFold(d.ID(), c.device) {
+			return d
+		}
+	}
+	return nil
+}
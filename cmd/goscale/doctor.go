@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// Check outcomes, worst last.
+const (
+	statusOK   = "ok"
+	statusInfo = "info"
+	statusWarn = "warn"
+	statusFail = "fail"
+	statusSkip = "skip"
+)
+
+// diagnosis is the outcome of one doctor check.
+type diagnosis struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// connectResult is what doctor saw while connected to a scale.
+type connectResult struct {
+	Device            string                `json:"device"`
+	Driver            string                `json:"driver"`
+	Model             string                `json:"model,omitempty"`
+	Features          goscale.ScaleFeatures `json:"features"`
+	Firmware          string                `json:"firmware,omitempty"`
+	Battery           *float64              `json:"battery,omitempty"`
+	ConnectTime       string                `json:"connect_time"`
+	FirstReading      string                `json:"first_reading,omitempty"`
+	Readings          int                   `json:"readings"`
+	Errors            []string              `json:"errors,omitempty"`
+	Dropped           uint64                `json:"dropped"`
+	DecoderPanics     uint64                `json:"decoder_panics"`
+	LastWeight        float64               `json:"last_weight"`
+	LastUnit          string                `json:"last_unit,omitempty"`
+	DisconnectedEarly bool                  `json:"disconnected_early,omitempty"`
+}
+
+// doctorReport is everything doctor found, for attaching to bug reports.
+type doctorReport struct {
+	Time      time.Time            `json:"time"`
+	OS        string               `json:"os"`
+	Arch      string               `json:"arch"`
+	GoVersion string               `json:"go_version"`
+	Version   string               `json:"goscale_version,omitempty"`
+	Checks    []diagnosis          `json:"checks"`
+	Devices   []goscale.ScanRecord `json:"devices"`
+	Connect   *connectResult       `json:"connect,omitempty"`
+}
+
+func (r *doctorReport) add(d diagnosis) {
+	r.Checks = append(r.Checks, d)
+}
+
+func (r *doctorReport) failed() bool {
+	for _, d := range r.Checks {
+		if d.Status == statusFail {
+			return true
+		}
+	}
+	return false
+}
+
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	var conn connectFlags
+	conn.register(fs)
+	listen := fs.Duration("listen", 5*time.Second, "how long to read from the scale once connected")
+	noConnect := fs.Bool("no-connect", false, "only scan; don't connect to a scale")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	_ = fs.Parse(args)
+
+	r := &doctorReport{
+		Time:      time.Now().UTC(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		Devices:   []goscale.ScanRecord{},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		r.Version = moduleVersion(info)
+	}
+
+	for _, d := range platformChecks() {
+		r.add(d)
+	}
+
+	if err := goscale.TryEnableAdapter(); err != nil {
+		hint := goscale.ErrorHint(err)
+		if hint == "" {
+			hint = adapterHint()
+		}
+		r.add(diagnosis{Name: "adapter", Status: statusFail, Detail: err.Error(), Hint: hint})
+		r.add(diagnosis{Name: "scan", Status: statusSkip, Detail: "no adapter"})
+		return r.print(*asJSON)
+	}
+	r.add(diagnosis{Name: "adapter", Status: statusOK, Detail: "enabled"})
+
+	devices, err := goscale.Scan(conn.scanTimeout)
+	switch {
+	case err != nil:
+		r.add(diagnosis{Name: "scan", Status: statusFail, Detail: err.Error()})
+	case len(devices) == 0:
+		r.add(diagnosis{
+			Name:   "scan",
+			Status: statusFail,
+			Detail: fmt.Sprintf("no supported scales found in %v", conn.scanTimeout),
+			Hint:   "make sure the scale is on, nearby and not connected to a phone app; " + scanHint(),
+		})
+	default:
+		r.add(diagnosis{Name: "scan", Status: statusOK, Detail: fmt.Sprintf("%d supported scale(s) found", len(devices))})
+	}
+	for _, d := range devices {
+		r.Devices = append(r.Devices, goscale.NewScanRecord(d))
+	}
+
+	if _, err := conn.loadConfig(); err != nil {
+		r.add(diagnosis{Name: "config", Status: statusFail, Detail: err.Error()})
+	}
+	dev := conn.pick(devices)
+	switch {
+	case *noConnect:
+		r.add(diagnosis{Name: "connect", Status: statusSkip, Detail: "-no-connect given"})
+	case dev == nil && conn.device != "":
+		r.add(diagnosis{Name: "connect", Status: statusFail, Detail: fmt.Sprintf("no scale matching %q found", conn.device)})
+	case dev == nil:
+		r.add(diagnosis{Name: "connect", Status: statusSkip, Detail: "no scale to connect to"})
+	default:
+		r.Connect = diagnoseConnect(r, dev, *listen)
+	}
+
+	return r.print(*asJSON)
+}
+
+// diagnoseConnect connects to dev and reads from it for listen, recording
+// how that went.
+func diagnoseConnect(r *doctorReport, dev *goscale.FoundDevice, listen time.Duration) *connectResult {
+	res := &connectResult{Device: dev.Name, Driver: goscale.MatchDriver(dev.Name)}
+
+	s, err := goscale.NewScaleForDevice(dev)
+	if err != nil {
+		r.add(diagnosis{Name: "connect", Status: statusFail, Detail: err.Error()})
+		return res
+	}
+	panicsBefore, _ := goscale.NotificationPanics()
+
+	ctx, cancel := context.WithTimeout(context.Background(), goscale.DefaultConnectTimeout)
+	defer cancel()
+	start := time.Now()
+	updates, err := goscale.ConnectContext(ctx, s)
+	res.ConnectTime = time.Since(start).Round(time.Millisecond).String()
+	if err != nil {
+		_ = s.Disconnect()
+		r.add(diagnosis{Name: "connect", Status: statusFail, Detail: err.Error(), Hint: "try again closer to the scale, with other apps disconnected from it"})
+		return res
+	}
+	defer func() { _ = s.Disconnect() }()
+	r.add(diagnosis{Name: "connect", Status: statusOK, Detail: "connected in " + res.ConnectTime})
+
+	var gaps goscale.GapDetector
+	timeout := time.After(listen)
+read:
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				res.DisconnectedEarly = true
+				break read
+			}
+			if u.Error != nil {
+				res.Errors = append(res.Errors, u.Error.Error())
+				continue
+			}
+			if res.Readings == 0 {
+				res.FirstReading = time.Since(start).Round(time.Millisecond).String()
+			}
+			res.Readings++
+			res.Dropped += gaps.Observe(u)
+			res.LastWeight = u.Value
+			res.LastUnit = u.Unit
+		case <-timeout:
+			break read
+		}
+	}
+
+	res.Model = s.DisplayName()
+	res.Features = s.GetFeatures()
+	if v, err := goscale.FirmwareVersion(s); err == nil {
+		res.Firmware = v
+	}
+	if res.Features.BatteryPercent {
+		if pct, err := s.GetBatteryChargePercent(); err == nil {
+			res.Battery = &pct
+		}
+	}
+	panicsAfter, _ := goscale.NotificationPanics()
+	res.DecoderPanics = panicsAfter - panicsBefore
+
+	switch {
+	case res.Readings == 0:
+		r.add(diagnosis{Name: "decode", Status: statusFail, Detail: fmt.Sprintf("no readings in %v", listen), Hint: "run goscale-sniff against the scale and attach its output"})
+	case res.DecoderPanics > 0 || len(res.Errors) > 0:
+		r.add(diagnosis{Name: "decode", Status: statusWarn, Detail: fmt.Sprintf("%d readings, %d errors, %d decoder panics", res.Readings, len(res.Errors), res.DecoderPanics)})
+	default:
+		r.add(diagnosis{Name: "decode", Status: statusOK, Detail: fmt.Sprintf("%d readings in %v", res.Readings, listen)})
+	}
+	if res.DisconnectedEarly {
+		r.add(diagnosis{Name: "link", Status: statusWarn, Detail: "scale disconnected while reading", Hint: "check for interference or a low scale battery"})
+	} else if res.Dropped > 0 {
+		r.add(diagnosis{Name: "link", Status: statusWarn, Detail: fmt.Sprintf("%d readings dropped", res.Dropped)})
+	}
+	return res
+}
+
+// print writes the report to stdout and returns the exit code: 1 if any
+// check failed.
+func (r *doctorReport) print(asJSON bool) int {
+	var err error
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	} else {
+		err = r.writeText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goscale doctor: %v\n", err)
+		return 1
+	}
+	if r.failed() {
+		return 1
+	}
+	return 0
+}
+
+func (r *doctorReport) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "goscale doctor, %s\n", r.Time.Format(time.RFC3339))
+	fmt.Fprintf(tw, "system:\t%s/%s, %s\n", r.OS, r.Arch, r.GoVersion)
+	if r.Version != "" {
+		fmt.Fprintf(tw, "goscale:\t%s\n", r.Version)
+	}
+
+	fmt.Fprintln(tw, "\nchecks:")
+	for _, d := range r.Checks {
+		fmt.Fprintf(tw, "  [%s]\t%s\t%s\n", d.Status, d.Name, d.Detail)
+		if d.Hint != "" {
+			fmt.Fprintf(tw, "  \t\thint: %s\n", d.Hint)
+		}
+	}
+
+	if len(r.Devices) > 0 {
+		fmt.Fprintln(tw, "\ndevices:")
+		for _, d := range r.Devices {
+			fmt.Fprintf(tw, "  %s\t%s\t%d dBm\tdriver %s\n", d.Name, d.Address, d.RSSI, d.Driver)
+		}
+	}
+
+	if c := r.Connect; c != nil {
+		fmt.Fprintf(tw, "\nconnection to %s:\n", c.Device)
+		fmt.Fprintf(tw, "  model:\t%s\n", c.Model)
+		if c.Firmware != "" {
+			fmt.Fprintf(tw, "  firmware:\t%s\n", c.Firmware)
+		}
+		if c.Battery != nil {
+			fmt.Fprintf(tw, "  battery:\t%.0f%%\n", *c.Battery)
+		}
+		fmt.Fprintf(tw, "  features:\t%+v\n", c.Features)
+		fmt.Fprintf(tw, "  connect time:\t%s\n", c.ConnectTime)
+		fmt.Fprintf(tw, "  first reading:\t%s\n", c.FirstReading)
+		fmt.Fprintf(tw, "  readings:\t%d (%d dropped)\n", c.Readings, c.Dropped)
+		fmt.Fprintf(tw, "  last weight:\t%.2f %s\n", c.LastWeight, c.LastUnit)
+		for _, e := range c.Errors {
+			fmt.Fprintf(tw, "  error:\t%s\n", e)
+		}
+	}
+	return tw.Flush()
+}
+
+// moduleVersion returns the version of goscale the binary was built with.
+func moduleVersion(info *debug.BuildInfo) string {
+	const path = "github.com/mlsorensen/goscale"
+	if info.Main.Path == path {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == path {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// platformChecks checks what can be checked about the operating system's
+// Bluetooth setup before touching the adapter.
+func platformChecks() []diagnosis {
+	switch runtime.GOOS {
+	case "linux":
+		return linuxChecks()
+	case "darwin":
+		return []diagnosis{{
+			Name:   "permission",
+			Status: statusInfo,
+			Detail: "macOS asks for Bluetooth permission the first time a program scans",
+			Hint:   "if nothing is found, allow your terminal in System Settings > Privacy & Security > Bluetooth",
+		}}
+	}
+	return nil
+}
+
+// linuxChecks checks that the kernel sees an adapter, that it isn't blocked,
+// and that BlueZ, which goscale talks to over D-Bus, is running.
+func linuxChecks() []diagnosis {
+	var checks []diagnosis
+
+	hcis, _ := filepath.Glob("/sys/class/bluetooth/hci*")
+	if len(hcis) == 0 {
+		checks = append(checks, diagnosis{Name: "kernel", Status: statusFail, Detail: "no Bluetooth adapter in /sys/class/bluetooth", Hint: "check the adapter is plugged in and its driver loaded (dmesg)"})
+	} else {
+		names := make([]string, len(hcis))
+		for i, h := range hcis {
+			names[i] = filepath.Base(h)
+		}
+		checks = append(checks, diagnosis{Name: "kernel", Status: statusOK, Detail: strings.Join(names, ", ")})
+	}
+
+	if blocked := goscale.RfkillBlocked(); len(blocked) > 0 {
+		checks = append(checks, diagnosis{Name: "rfkill", Status: statusFail, Detail: strings.Join(blocked, "; "), Hint: "run: rfkill unblock bluetooth"})
+	}
+
+	bus := "/run/dbus/system_bus_socket"
+	if addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); strings.HasPrefix(addr, "unix:path=") {
+		bus = strings.TrimPrefix(addr, "unix:path=")
+	}
+	if _, err := os.Stat(bus); err != nil {
+		checks = append(checks, diagnosis{Name: "dbus", Status: statusFail, Detail: "no system bus at " + bus, Hint: "goscale needs the D-Bus system bus; in a container, mount " + bus})
+	} else {
+		checks = append(checks, diagnosis{Name: "dbus", Status: statusOK, Detail: bus})
+	}
+
+	if processRunning("bluetoothd") {
+		checks = append(checks, diagnosis{Name: "bluez", Status: statusOK, Detail: "bluetoothd running"})
+	} else {
+		checks = append(checks, diagnosis{Name: "bluez", Status: statusWarn, Detail: "bluetoothd not seen running", Hint: "run: sudo systemctl start bluetooth"})
+	}
+
+	if u, err := user.Current(); err == nil && u.Uid != "0" && !inGroup(u, "bluetooth") {
+		checks = append(checks, diagnosis{Name: "permission", Status: statusInfo, Detail: "not in the bluetooth group", Hint: "if BlueZ refuses access, run: sudo usermod -aG bluetooth " + u.Username})
+	}
+	return checks
+}
+
+func processRunning(name string) bool {
+	comms, _ := filepath.Glob("/proc/[0-9]*/comm")
+	for _, c := range comms {
+		if readTrimmed(c) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func inGroup(u *user.User, name string) bool {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return false
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return false
+	}
+	for _, id := range ids {
+		if id == g.Gid {
+			return true
+		}
+	}
+	return false
+}
+
+func readTrimmed(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func adapterHint() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "see the kernel, dbus and bluez checks above; goscale uses the default adapter through BlueZ"
+	case "darwin":
+		return "turn Bluetooth on and allow your terminal in System Settings > Privacy & Security > Bluetooth"
+	case "windows":
+		return "turn Bluetooth on in Settings > Bluetooth & devices"
+	}
+	return ""
+}
+
+func scanHint() string {
+	if runtime.GOOS == "darwin" {
+		return "macOS reports no devices without Bluetooth permission"
+	}
+	return "some scales stop advertising once paired elsewhere"
+}
@@ -0,0 +1,58 @@
+// Command goscale is a command line tool for Bluetooth coffee scales.
+//
+// Usage:
+//
+//	goscale watch [-format table|ndjson|json|csv] [-device NAME]
+//	goscale wait [-above 36g] [-below W] [-stable] [-timeout 90s]
+//	goscale doctor [-json] [-device NAME] [-no-connect]
+//	goscale serve [-listen :8080] [-device NAME] [-token TOKEN=ID,...]
+//
+// Run "goscale <command> -h" for a command's flags.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	_ "github.com/mlsorensen/goscale/pkg/scales/all"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{"watch", "stream weight readings", runWatch},
+	{"wait", "wait for a weight condition, for scripts", runWait},
+	{"doctor", "diagnose Bluetooth problems, for bug reports", runDoctor},
+	{"serve", "serve every scale in range over HTTP", runServe},
+}
+
+func main() {
+	// Readings go to stdout; diagnostics to stderr.
+	log.SetOutput(os.Stderr)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "goscale: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: goscale <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+}
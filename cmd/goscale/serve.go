@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/brew"
+	"github.com/mlsorensen/goscale/pkg/bridge"
+	"github.com/mlsorensen/goscale/pkg/config"
+)
+
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "address to serve HTTP on, unless the configuration file gives one")
+	device := fs.String("device", "", "only serve scales with this name prefix or address (default: all)")
+	scanTimeout := fs.Duration("scan-timeout", 10*time.Second, "how long each scan for scales lasts")
+	scanInterval := fs.Duration("scan-interval", 30*time.Second, "how often to scan for scales that have come or gone")
+	tokens := make(map[string]bridge.Scope)
+	fs.Func("token", "`TOKEN=ID,...` allows TOKEN to see the listed scales, or all of them with *; repeatable (default: no authentication)", func(v string) error {
+		token, ids, ok := strings.Cut(v, "=")
+		if !ok || token == "" || ids == "" {
+			return errors.New("want TOKEN=ID,...")
+		}
+		tokens[token] = append(tokens[token], strings.Split(ids, ",")...)
+		return nil
+	})
+	advertise := fs.Bool("mdns", true, "advertise the server on the local network with mDNS, as "+bridge.ServiceType)
+	configPath := fs.String("config", "", "configuration file, for the listen address, tokens, webhooks and commands (default: goscale/config.yaml in the user's config directory)")
+	_ = fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Printf("Fatal: %v", err)
+		return 1
+	}
+	// Flags given on the command line win over the configuration file.
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if !given["listen"] && cfg.Bridge.Listen != "" {
+		*listen = cfg.Bridge.Listen
+	}
+	if len(tokens) == 0 {
+		tokens = cfg.Bridge.Tokens
+	}
+
+	hub := bridge.NewHub()
+	if len(tokens) > 0 {
+		hub.Tokens = tokens
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	srv := &http.Server{Addr: *listen, Handler: hub}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	log.Printf("Serving scales on %s", *listen)
+	if *advertise {
+		advertiseHub(ctx, hub, *listen)
+	}
+
+	mgr := goscale.NewManager()
+	defer func() {
+		if err := mgr.DisconnectAll(); err != nil {
+			log.Printf("Error disconnecting: %v", err)
+		}
+	}()
+
+	ticker := time.NewTicker(*scanInterval)
+	defer ticker.Stop()
+	for {
+		connectNew(ctx, mgr, hub, cfg, *device, *scanTimeout)
+		select {
+		case <-ticker.C:
+		case err := <-errc:
+			if errors.Is(err, http.ErrServerClosed) {
+				return 0
+			}
+			log.Printf("Fatal: %v", err)
+			return 1
+		case <-ctx.Done():
+			return 0
+		}
+	}
+}
+
+// advertiseHub advertises hub, served on listen, with mDNS. Failing to is
+// logged rather than fatal, as the server works without.
+func advertiseHub(ctx context.Context, hub *bridge.Hub, listen string) {
+	_, p, err := net.SplitHostPort(listen)
+	port, perr := strconv.Atoi(p)
+	if err != nil || perr != nil {
+		log.Printf("Not advertising with mDNS: no port in %q", listen)
+		return
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	host, _, _ = strings.Cut(host, ".")
+	if err := hub.Advertise(ctx, "goscale on "+host, port); err != nil {
+		log.Printf("Not advertising with mDNS: %v", err)
+		return
+	}
+	log.Printf("Advertising as %s on port %d", bridge.ServiceType, port)
+}
+
+// connectNew scans for scales matching device that aren't connected yet and
+// serves any it can connect to, telling the configured webhooks and
+// commands about their brews. Each is forgotten again when it disconnects,
+// so it is picked up by a later scan when it comes back.
+func connectNew(ctx context.Context, mgr *goscale.Manager, hub *bridge.Hub, cfg *config.Config, device string, timeout time.Duration) {
+	found, err := goscale.Scan(timeout)
+	if err != nil {
+		log.Printf("Error scanning: %v", err)
+		return
+	}
+	var devices []*goscale.FoundDevice
+	for i := range found {
+		d := &found[i]
+		if mgr.Get(d.ID()) != nil {
+			continue
+		}
+		if device == "" || strings.HasPrefix(d.Name, device) || strings.EqualFold(d.ID(), device) {
+			devices = append(devices, d)
+		}
+	}
+	if len(devices) == 0 {
+		return
+	}
+
+	connected, err := mgr.ConnectAll(ctx, devices)
+	if err != nil {
+		log.Printf("Error connecting: %v", err)
+	}
+	for _, cs := range connected {
+		log.Printf("Serving %s as %s", cs.Scale.DisplayName(), bridge.DeviceID(cs.Device))
+		done := hub.Add(ctx, cs)
+		go notifyBrews(ctx, cs, cfg.Bridge)
+		go func() {
+			<-done
+			if ctx.Err() == nil {
+				log.Printf("%s disconnected", cs.Scale.DisplayName())
+				_ = mgr.Disconnect(cs.Device.ID())
+			}
+		}()
+	}
+}
+
+// notifyBrews tells b's webhooks and commands about the brews on cs until
+// it disconnects or ctx is done.
+func notifyBrews(ctx context.Context, cs *goscale.ConnectedScale, b config.BridgeConfig) {
+	if len(b.Webhooks) == 0 && len(b.Commands) == 0 {
+		return
+	}
+	webhooks := &bridge.WebhookNotifier{Hooks: b.Webhooks}
+	commands := &bridge.CommandNotifier{Hooks: b.Commands}
+	defer commands.Stop()
+
+	updates := cs.Subscribe(0)
+	defer cs.Unsubscribe(updates)
+	for ev := range brew.TrackLifecycle(cs.Scale.DeviceName(), updates, brew.DefaultLifecycleConfig) {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := webhooks.Notify(ctx, ev); err != nil {
+			log.Printf("Error calling webhooks for %s: %v", ev.Kind, err)
+		}
+		if err := commands.Notify(ctx, ev); err != nil {
+			log.Printf("Error running commands for %s: %v", ev.Kind, err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// Exit codes of wait, besides 0 when the condition is met and 2 for usage
+// errors.
+const (
+	exitWaitFailed  = 1 // Could not connect, or the scale disconnected
+	exitWaitTimeout = 3
+)
+
+// weightFlag is a weight given on the command line, e.g. "36g" or "1.5oz",
+// held in grams. A bare number is grams.
+type weightFlag struct {
+	grams float64
+	set   bool
+}
+
+func (w *weightFlag) String() string {
+	if !w.set {
+		return ""
+	}
+	return strconv.FormatFloat(w.grams, 'f', -1, 64) + "g"
+}
+
+func (w *weightFlag) Set(s string) error {
+	g, err := parseWeight(s)
+	if err != nil {
+		return err
+	}
+	w.grams, w.set = g, true
+	return nil
+}
+
+func parseWeight(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	n := strings.IndexFunc(s, func(r rune) bool { return r != '.' && r != '-' && (r < '0' || r > '9') })
+	if n < 0 {
+		n = len(s)
+	}
+	v, err := strconv.ParseFloat(s[:n], 64)
+	if err != nil {
+		return 0, errors.New("weight must be a number, optionally followed by a unit such as g or oz")
+	}
+	return goscale.ConvertWeight(v, s[n:], goscale.UnitGrams)
+}
+
+func runWait(args []string) int {
+	fs := flag.NewFlagSet("wait", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: goscale wait [-above W | -target NAME] [-below W] [-stable] [-timeout D]")
+		fmt.Fprintln(fs.Output(), "\nExits 0 once the weight meets every condition given, 1 if the scale")
+		fmt.Fprintln(fs.Output(), "can't be reached or disconnects, and 3 on timeout.")
+		fs.PrintDefaults()
+	}
+	var conn connectFlags
+	conn.register(fs)
+	var above, below weightFlag
+	fs.Var(&above, "above", "wait for the weight to reach at least this, e.g. 36g or 1.2oz")
+	target := fs.String("target", "", "wait for the weight to reach the named target weight from the configuration file")
+	fs.Var(&below, "below", "wait for the weight to drop to at most this")
+	stable := fs.Bool("stable", false, "also wait for the weight to settle")
+	stableWindow := fs.Duration("stable-window", goscale.DefaultStableConfig.Window, "how long the weight must hold steady to count as settled")
+	stableTolerance := fs.Float64("stable-tolerance", goscale.DefaultStableConfig.Tolerance, "grams the weight may wander by and still count as settled")
+	timeout := fs.Duration("timeout", 0, "give up after this long (default: wait forever)")
+	_ = fs.Parse(args)
+
+	if *target != "" {
+		if above.set {
+			fmt.Fprintln(os.Stderr, "goscale wait: -above and -target are exclusive")
+			return 2
+		}
+		cfg, err := conn.loadConfig()
+		if err != nil {
+			log.Printf("Fatal: %v", err)
+			return exitWaitFailed
+		}
+		grams, ok := cfg.Target(*target)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "goscale wait: no target %q in the configuration file\n", *target)
+			return 2
+		}
+		above = weightFlag{grams: grams, set: true}
+	}
+
+	if !above.set && !below.set && !*stable {
+		fmt.Fprintln(os.Stderr, "goscale wait: one of -above, -target, -below or -stable is required")
+		fs.Usage()
+		return 2
+	}
+
+	s, updates, err := conn.connect()
+	if err != nil {
+		log.Printf("Fatal: %v", err)
+		return exitWaitFailed
+	}
+	defer func() { _ = s.Disconnect() }()
+
+	var expired <-chan time.Time
+	if *timeout > 0 {
+		timer := time.NewTimer(*timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	settle := goscale.NewStabilityDetector(goscale.StableConfig{Window: *stableWindow, Tolerance: *stableTolerance})
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				log.Printf("Scale disconnected before the condition was met")
+				return exitWaitFailed
+			}
+			if u.Error != nil {
+				log.Printf("Error received on update channel: %v", u.Error)
+				continue
+			}
+			u = goscale.ConvertUpdate(u, goscale.UnitGrams)
+			if u.Error != nil {
+				log.Printf("Ignoring reading: %v", u.Error)
+				continue
+			}
+			grams := u.Value
+
+			met := true
+			if above.set && grams < above.grams {
+				met = false
+			}
+			if below.set && grams > below.grams {
+				met = false
+			}
+			// Always feed the stability check, so it has history by the
+			// time the thresholds are met.
+			if *stable && !settle.Observe(u) {
+				met = false
+			}
+			if met {
+				fmt.Printf("%.2f\n", grams)
+				return 0
+			}
+		case <-expired:
+			log.Printf("Timed out after %s", *timeout)
+			return exitWaitTimeout
+		}
+	}
+}
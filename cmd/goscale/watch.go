@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// reading is a weight update as written by watch.
+type reading struct {
+	Time   time.Time `json:"time"`
+	Seq    uint64    `json:"seq"`
+	Weight float64   `json:"weight"`
+	Unit   string    `json:"unit"`
+}
+
+// formatter writes a stream of readings in one output format.
+type formatter interface {
+	begin() error
+	write(r reading) error
+	end() error
+}
+
+var formats = map[string]func(io.Writer) formatter{
+	"table":  func(w io.Writer) formatter { return &tableFormat{w: w} },
+	"ndjson": func(w io.Writer) formatter { return &ndjsonFormat{enc: json.NewEncoder(w)} },
+	"json":   func(w io.Writer) formatter { return &jsonFormat{w: w} },
+	"csv":    func(w io.Writer) formatter { return &csvFormat{w: csv.NewWriter(w)} },
+}
+
+func runWatch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	var conn connectFlags
+	conn.register(fs)
+	format := fs.String("format", "table", "output format: table, ndjson, json or csv")
+	unit := fs.String("unit", "", "unit to show weights in: g or oz (default: the configuration file's, or g)")
+	_ = fs.Parse(args)
+
+	newFormatter, ok := formats[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "goscale watch: unknown format %q\n", *format)
+		return 2
+	}
+
+	cfg, err := conn.loadConfig()
+	if err != nil {
+		log.Printf("Fatal: %v", err)
+		return 1
+	}
+	if *unit == "" {
+		*unit = cfg.Unit()
+	}
+	if _, err := goscale.NormalizeUnit(*unit); err != nil {
+		fmt.Fprintf(os.Stderr, "goscale watch: %v\n", err)
+		return 2
+	}
+
+	_, updates, err := conn.connect()
+	if err != nil {
+		log.Printf("Fatal: %v", err)
+		return 1
+	}
+
+	out := newFormatter(os.Stdout)
+	if err := out.begin(); err != nil {
+		return 1
+	}
+	for u := range goscale.ConvertUnits(updates, *unit) {
+		if u.Error != nil {
+			log.Printf("Error received on update channel: %v", u.Error)
+			continue
+		}
+		r := reading{Time: u.Timestamp, Seq: u.Seq, Weight: u.Value, Unit: u.Unit}
+		if r.Time.IsZero() {
+			r.Time = time.Now()
+		}
+		if err := out.write(r); err != nil {
+			// Most likely the reader of a pipe has gone away.
+			return 1
+		}
+	}
+	if err := out.end(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// tableFormat writes aligned columns for people to read.
+type tableFormat struct{ w io.Writer }
+
+func (f *tableFormat) begin() error {
+	_, err := fmt.Fprintf(f.w, "%-12s %8s %10s %s\n", "TIME", "SEQ", "WEIGHT", "UNIT")
+	return err
+}
+
+func (f *tableFormat) write(r reading) error {
+	_, err := fmt.Fprintf(f.w, "%-12s %8d %10.2f %s\n", r.Time.Format("15:04:05.000"), r.Seq, r.Weight, r.Unit)
+	return err
+}
+
+func (f *tableFormat) end() error { return nil }
+
+// ndjsonFormat writes one JSON object per line, flushed as it arrives.
+type ndjsonFormat struct{ enc *json.Encoder }
+
+func (f *ndjsonFormat) begin() error          { return nil }
+func (f *ndjsonFormat) write(r reading) error { return f.enc.Encode(r) }
+func (f *ndjsonFormat) end() error            { return nil }
+
+// jsonFormat writes a single JSON array, streaming its elements as they
+// arrive. The array is only complete once the scale disconnects.
+type jsonFormat struct {
+	w     io.Writer
+	count int
+}
+
+func (f *jsonFormat) begin() error {
+	_, err := io.WriteString(f.w, "[")
+	return err
+}
+
+func (f *jsonFormat) write(r reading) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if f.count == 0 {
+		sep = "\n"
+	}
+	f.count++
+	_, err = fmt.Fprintf(f.w, "%s  %s", sep, data)
+	return err
+}
+
+func (f *jsonFormat) end() error {
+	_, err := io.WriteString(f.w, "\n]\n")
+	return err
+}
+
+// csvFormat writes a header row then one row per reading.
+type csvFormat struct{ w *csv.Writer }
+
+func (f *csvFormat) begin() error {
+	return f.flush(f.w.Write([]string{"time", "seq", "weight", "unit"}))
+}
+
+func (f *csvFormat) write(r reading) error {
+	return f.flush(f.w.Write([]string{
+		r.Time.Format(time.RFC3339Nano),
+		strconv.FormatUint(r.Seq, 10),
+		strconv.FormatFloat(r.Weight, 'f', 2, 64),
+		r.Unit,
+	}))
+}
+
+func (f *csvFormat) end() error { return nil }
+
+// flush sends each row on straight away, so a pipeline sees it live.
+func (f *csvFormat) flush(err error) error {
+	if err != nil {
+		return err
+	}
+	f.w.Flush()
+	return f.w.Error()
+}
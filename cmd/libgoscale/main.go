@@ -0,0 +1,168 @@
+// Command libgoscale exposes a minimal C ABI over goscale, so projects in
+// other languages (Python, Node, ...) can reuse its scale drivers instead of
+// re-implementing the Bluetooth protocols. Build it as a shared library; the
+// Go toolchain writes the matching C header alongside it:
+//
+//	go build -buildmode=c-shared -o libgoscale.so ./cmd/libgoscale
+//
+// That produces libgoscale.so and libgoscale.h. Functions return a negative
+// number on failure; goscale_last_error then describes what went wrong.
+// Scales are referred to by the positive handle goscale_connect returns.
+package main
+
+/*
+#include <stddef.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/mlsorensen/goscale"
+	_ "github.com/mlsorensen/goscale/pkg/scales/all"
+)
+
+// Return codes shared by the exported functions.
+const (
+	codeOK       = 0
+	codeTimeout  = 1
+	codeError    = -1
+	codeClosed   = -2
+	codeNoHandle = -3
+)
+
+type handle struct {
+	scale   goscale.Scale
+	updates <-chan goscale.WeightUpdate
+}
+
+var (
+	mu         sync.Mutex
+	handles          = make(map[C.int]*handle)
+	nextHandle C.int = 1
+	lastError  string
+)
+
+func setError(err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	lastError = err.Error()
+}
+
+func lookup(h C.int) *handle {
+	mu.Lock()
+	defer mu.Unlock()
+	if sc, ok := handles[h]; ok {
+		return sc
+	}
+	lastError = "unknown scale handle"
+	return nil
+}
+
+// goscale_connect scans for up to scan_timeout_ms milliseconds, connects to
+// the first supported scale found and returns a handle to it.
+//
+//export goscale_connect
+func goscale_connect(scan_timeout_ms C.int) C.int {
+	scale, updates, err := goscale.ScanAndConnect(time.Duration(scan_timeout_ms) * time.Millisecond)
+	if err != nil {
+		setError(err)
+		return codeError
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	h := nextHandle
+	nextHandle++
+	handles[h] = &handle{scale: scale, updates: updates}
+	return h
+}
+
+// goscale_next_weight waits up to timeout_ms milliseconds for the next
+// reading and stores it, in grams, in *grams. It returns 0 on success, 1 on
+// timeout and -2 once the scale has disconnected.
+//
+//export goscale_next_weight
+func goscale_next_weight(h C.int, timeout_ms C.int, grams *C.double) C.int {
+	sc := lookup(h)
+	if sc == nil {
+		return codeNoHandle
+	}
+
+	timer := time.NewTimer(time.Duration(timeout_ms) * time.Millisecond)
+	defer timer.Stop()
+	for {
+		select {
+		case u, ok := <-sc.updates:
+			if !ok {
+				setError(errors.New("scale disconnected"))
+				return codeClosed
+			}
+			if u.Error != nil {
+				setError(u.Error)
+				continue
+			}
+			*grams = C.double(u.Value)
+			return codeOK
+		case <-timer.C:
+			return codeTimeout
+		}
+	}
+}
+
+// goscale_tare zeroes the scale.
+//
+//export goscale_tare
+func goscale_tare(h C.int) C.int {
+	sc := lookup(h)
+	if sc == nil {
+		return codeNoHandle
+	}
+	if err := sc.scale.Tare(false); err != nil {
+		setError(err)
+		return codeError
+	}
+	return codeOK
+}
+
+// goscale_disconnect disconnects the scale and frees its handle.
+//
+//export goscale_disconnect
+func goscale_disconnect(h C.int) C.int {
+	sc := lookup(h)
+	if sc == nil {
+		return codeNoHandle
+	}
+	mu.Lock()
+	delete(handles, h)
+	mu.Unlock()
+
+	if err := sc.scale.Disconnect(); err != nil {
+		setError(err)
+		return codeError
+	}
+	return codeOK
+}
+
+// goscale_last_error copies the last error message, NUL-terminated, into
+// buf, which is size bytes long, truncating it if needed. It returns the
+// full length of the message.
+//
+//export goscale_last_error
+func goscale_last_error(buf *C.char, size C.size_t) C.int {
+	mu.Lock()
+	msg := lastError
+	mu.Unlock()
+
+	if buf != nil && size > 0 {
+		dst := unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(size))
+		n := copy(dst[:len(dst)-1], msg)
+		dst[n] = 0
+	}
+	return C.int(len(msg))
+}
+
+func main() {}
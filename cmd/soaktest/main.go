@@ -0,0 +1,102 @@
+// Command soaktest keeps a scale connected for hours, reconnecting whenever
+// the link drops, and writes a JSON report of disconnects, how long each
+// reconnect took, memory use, and gaps in the notification stream. It is
+// for validating drivers' reconnection and watchdog handling against real
+// hardware, which is flakier than anything a unit test can fake.
+//
+// Usage:
+//
+//	soaktest -duration 8h -report soak.json
+//	soaktest -device LUNAR -gap 2s
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	_ "github.com/mlsorensen/goscale/pkg/scales/all"
+)
+
+// maxBackoff caps the wait between failed reconnect attempts.
+const maxBackoff = 30 * time.Second
+
+func main() {
+	device := flag.String("device", "", "name prefix or address of the scale (default: the first one found)")
+	scanTimeout := flag.Duration("scan-timeout", 15*time.Second, "how long to scan for the scale")
+	duration := flag.Duration("duration", 8*time.Hour, "how long to run for")
+	gapThreshold := flag.Duration("gap", 2*time.Second, "report notification gaps longer than this")
+	memInterval := flag.Duration("mem-interval", time.Minute, "how often to sample memory use")
+	reportPath := flag.String("report", "", "file to write the JSON report to (default: stdout)")
+	flag.Parse()
+
+	log.SetOutput(os.Stderr)
+
+	dev, err := find(*device, *scanTimeout)
+	if err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+	go func() {
+		sigchan := make(chan os.Signal, 1)
+		signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigchan
+		log.Println("Interrupted, writing report")
+		cancel()
+	}()
+
+	s := &soak{
+		device:       dev,
+		gapThreshold: *gapThreshold,
+		memInterval:  *memInterval,
+		report:       &Report{Device: dev.Name, Address: dev.ID()},
+	}
+	s.run(ctx)
+
+	if err := writeReport(*reportPath, s.report); err != nil {
+		log.Fatalf("Fatal: Could not write report: %v", err)
+	}
+	log.Print(s.report.Summary())
+}
+
+func find(device string, timeout time.Duration) (*goscale.FoundDevice, error) {
+	if device == "" {
+		return goscale.ScanForOne(timeout)
+	}
+	devices, err := goscale.Scan(timeout)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		d := &devices[i]
+		if strings.HasPrefix(d.Name, device) || strings.EqualFold(d.ID(), device) {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("no scale matching %q found", device)
+}
+
+func writeReport(path string, r *Report) error {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// maxGaps bounds how many notification gaps the report lists; the rest are
+// only counted.
+const maxGaps = 1000
+
+// Report is the outcome of a soak test.
+type Report struct {
+	Device  string    `json:"device"`
+	Address string    `json:"address"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+
+	// Connected is the total time the scale was connected.
+	Connected time.Duration `json:"connected_ns"`
+
+	Updates       uint64 `json:"updates"`
+	UpdateErrors  uint64 `json:"update_errors"`
+	MissedUpdates uint64 `json:"missed_updates"` // Sequence numbers skipped
+
+	Disconnects []Disconnect `json:"disconnects"`
+
+	Gaps      []Gap  `json:"gaps"`
+	GapsTotal uint64 `json:"gaps_total"` // Including any beyond the listed ones
+
+	Memory []MemorySample `json:"memory"`
+}
+
+// Disconnect is one loss of the connection.
+type Disconnect struct {
+	At           time.Time     `json:"at"`
+	ConnectedFor time.Duration `json:"connected_for_ns"`
+
+	// ReconnectAfter is how long it took to get the connection back, or
+	// zero if the test ended first.
+	ReconnectAfter time.Duration `json:"reconnect_after_ns"`
+	Attempts       int           `json:"attempts"`
+}
+
+// Gap is a stretch without notifications while connected.
+type Gap struct {
+	At     time.Time     `json:"at"` // When notifications resumed
+	Length time.Duration `json:"length_ns"`
+}
+
+// MemorySample is the process's memory use at a point in the test.
+type MemorySample struct {
+	At         time.Time `json:"at"`
+	HeapAlloc  uint64    `json:"heap_alloc"`
+	Sys        uint64    `json:"sys"`
+	Goroutines int       `json:"goroutines"`
+}
+
+// Summary describes the report in a few lines.
+func (r *Report) Summary() string {
+	var longest, total time.Duration
+	reconnects := 0
+	for _, d := range r.Disconnects {
+		if d.ReconnectAfter > 0 {
+			reconnects++
+			total += d.ReconnectAfter
+			longest = max(longest, d.ReconnectAfter)
+		}
+	}
+	var mean time.Duration
+	if reconnects > 0 {
+		mean = total / time.Duration(reconnects)
+	}
+	return fmt.Sprintf("soak of %s for %s: connected %s, %d update(s), %d missed, %d error(s); "+
+		"%d disconnect(s), reconnect mean %s max %s; %d notification gap(s)",
+		r.Device, r.End.Sub(r.Start).Round(time.Second), r.Connected.Round(time.Second),
+		r.Updates, r.MissedUpdates, r.UpdateErrors,
+		len(r.Disconnects), mean.Round(time.Millisecond), longest.Round(time.Millisecond), r.GapsTotal)
+}
+
+type soak struct {
+	device       *goscale.FoundDevice
+	gapThreshold time.Duration
+	memInterval  time.Duration
+	report       *Report
+}
+
+func (s *soak) run(ctx context.Context) {
+	s.report.Start = time.Now()
+	defer func() { s.report.End = time.Now() }()
+
+	// The report is only read once the sampler has stopped.
+	memCtx, stopMem := context.WithCancel(ctx)
+	memDone := make(chan struct{})
+	go func() {
+		defer close(memDone)
+		s.sampleMemory(memCtx)
+	}()
+	defer func() {
+		stopMem()
+		<-memDone
+	}()
+
+	var pending *Disconnect
+	for ctx.Err() == nil {
+		scale, updates, attempts := s.connect(ctx)
+		if scale == nil {
+			break
+		}
+		if pending != nil {
+			pending.ReconnectAfter = time.Since(pending.At)
+			pending.Attempts = attempts
+			s.report.Disconnects = append(s.report.Disconnects, *pending)
+			log.Printf("Reconnected after %s (%d attempt(s))", pending.ReconnectAfter.Round(time.Millisecond), attempts)
+			pending = nil
+		}
+
+		connectedAt := time.Now()
+		closed := s.watch(ctx, updates)
+		s.report.Connected += time.Since(connectedAt)
+		if !closed {
+			// The test is over; hang up ourselves.
+			_ = scale.Disconnect()
+			break
+		}
+		log.Printf("Disconnected after %s", time.Since(connectedAt).Round(time.Second))
+		_ = scale.Disconnect()
+		pending = &Disconnect{At: time.Now(), ConnectedFor: time.Since(connectedAt)}
+	}
+	if pending != nil {
+		s.report.Disconnects = append(s.report.Disconnects, *pending)
+	}
+}
+
+// connect makes a fresh driver instance and connects it, retrying with
+// backoff until it succeeds or ctx is done.
+func (s *soak) connect(ctx context.Context) (goscale.Scale, <-chan goscale.WeightUpdate, int) {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		scale, err := goscale.NewScaleForDevice(s.device)
+		if err != nil {
+			// Not a transient failure; no point retrying.
+			log.Printf("Could not create scale instance: %v", err)
+			return nil, nil, attempt
+		}
+		updates, err := scale.Connect()
+		if err == nil {
+			return scale, updates, attempt
+		}
+		_ = scale.Disconnect()
+		log.Printf("Connect attempt %d failed: %v", attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, attempt
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// watch consumes updates until the channel closes, returning true, or ctx
+// is done, returning false.
+func (s *soak) watch(ctx context.Context, updates <-chan goscale.WeightUpdate) bool {
+	var gaps goscale.GapDetector
+	last := time.Now()
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				return true
+			}
+			now := time.Now()
+			if d := now.Sub(last); d > s.gapThreshold {
+				s.report.GapsTotal++
+				if len(s.report.Gaps) < maxGaps {
+					s.report.Gaps = append(s.report.Gaps, Gap{At: now, Length: d})
+				}
+			}
+			last = now
+
+			if u.Error != nil {
+				s.report.UpdateErrors++
+				continue
+			}
+			s.report.Updates++
+			s.report.MissedUpdates += gaps.Observe(u)
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (s *soak) sampleMemory(ctx context.Context) {
+	ticker := time.NewTicker(s.memInterval)
+	defer ticker.Stop()
+	for {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		s.report.Memory = append(s.report.Memory, MemorySample{
+			At:         time.Now(),
+			HeapAlloc:  m.HeapAlloc,
+			Sys:        m.Sys,
+			Goroutines: runtime.NumGoroutine(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package goscale
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDedupWindow suits SetNotificationDedup for most scales: well
+// inside the interval of the fastest scale's notifications, and well
+// outside the few milliseconds by which a duplicate follows its original.
+const DefaultDedupWindow = 15 * time.Millisecond
+
+var (
+	dedupWindow atomic.Int64 // time.Duration; 0 when off
+
+	duplicateNotifications atomic.Uint64
+
+	duplicatesMu       sync.Mutex
+	duplicatesByDevice = map[string]uint64{}
+)
+
+// SetNotificationDedup turns on dropping duplicate notifications: a frame
+// identical to one received from the same connection is discarded before
+// the driver sees it if it arrives less than window after the driver
+// finished handling the original. Some Android and BlueZ
+// stacks call the notification handler twice for one frame, which doubles
+// samples and skews flow rates. It is off by default, as a scale may
+// legitimately repeat a frame, so keep window below the scale's
+// notification interval (see DefaultDedupWindow). Zero turns it off again.
+// It applies to every driver, through RecoverNotifications.
+func SetNotificationDedup(window time.Duration) {
+	dedupWindow.Store(int64(max(window, 0)))
+}
+
+// DuplicateNotifications returns how many duplicate notifications have been
+// dropped since the program started, in total and by device name.
+func DuplicateNotifications() (total uint64, byDevice map[string]uint64) {
+	duplicatesMu.Lock()
+	defer duplicatesMu.Unlock()
+
+	byDevice = make(map[string]uint64, len(duplicatesByDevice))
+	for device, n := range duplicatesByDevice {
+		byDevice[device] = n
+	}
+	return duplicateNotifications.Load(), byDevice
+}
+
+// notificationDeduper remembers the frames a connection received recently.
+type notificationDeduper struct {
+	mu     sync.Mutex
+	recent []recentFrame
+}
+
+type recentFrame struct {
+	hash uint64
+	at   time.Time // When the handler was last done with it
+}
+
+// duplicate reports whether the frame hashing to sum, received at now,
+// repeats one the handler was done with less than window before, and
+// otherwise remembers it. Measuring from when the handler returned, rather
+// than from when the original arrived, catches a duplicate that was queued
+// behind a slow handler call.
+func (d *notificationDeduper) duplicate(sum uint64, now time.Time, window time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kept := d.recent[:0]
+	for _, f := range d.recent {
+		if now.Sub(f.at) < window {
+			kept = append(kept, f)
+		}
+	}
+	d.recent = kept
+	for _, f := range d.recent {
+		if f.hash == sum {
+			return true
+		}
+	}
+	d.recent = append(d.recent, recentFrame{hash: sum, at: now})
+	return false
+}
+
+// handled records that the handler was done with the frame hashing to sum
+// at now.
+func (d *notificationDeduper) handled(sum uint64, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.recent {
+		if d.recent[i].hash == sum {
+			d.recent[i].at = now
+		}
+	}
+}
+
+// dedupNotifications wraps handler to drop duplicate frames while
+// SetNotificationDedup has it on.
+func dedupNotifications(device string, handler func([]byte)) func([]byte) {
+	var d notificationDeduper
+	return func(buf []byte) {
+		window := time.Duration(dedupWindow.Load())
+		if window <= 0 {
+			handler(buf)
+			return
+		}
+		h := fnv.New64a()
+		_, _ = h.Write(buf)
+		sum := h.Sum64()
+		if d.duplicate(sum, time.Now(), window) {
+			duplicateNotifications.Add(1)
+			duplicatesMu.Lock()
+			duplicatesByDevice[device]++
+			duplicatesMu.Unlock()
+			return
+		}
+		handler(buf)
+		d.handled(sum, time.Now())
+	}
+}
@@ -0,0 +1,67 @@
+package goscale
+
+import (
+	"testing"
+	"time"
+)
+
+// dedupWith turns on deduplication for the length of a test.
+func dedupWith(t *testing.T, window time.Duration) {
+	t.Helper()
+	SetNotificationDedup(window)
+	t.Cleanup(func() { SetNotificationDedup(0) })
+}
+
+func TestDedupNotifications(t *testing.T) {
+	a := []byte{0xEF, 0xDD, 0x0C, 0x01}
+	b := []byte{0xEF, 0xDD, 0x0C, 0x02}
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		frames [][]byte
+		pause  time.Duration // Between frames
+		want   int
+	}{
+		{"duplicate dropped", DefaultDedupWindow, [][]byte{a, a}, 0, 1},
+		{"different frames kept", DefaultDedupWindow, [][]byte{a, b, a}, 0, 2},
+		{"off by default", 0, [][]byte{a, a}, 0, 2},
+		{"repeat after window kept", 5 * time.Millisecond, [][]byte{a, a}, 20 * time.Millisecond, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dedupWith(t, tt.window)
+			var got int
+			handle := dedupNotifications(t.Name(), func([]byte) { got++ })
+			for i, f := range tt.frames {
+				if i > 0 {
+					time.Sleep(tt.pause)
+				}
+				handle(f)
+			}
+			if got != tt.want {
+				t.Errorf("handler called %d times, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// A duplicate queued behind a slow handler call arrives well after the
+// original, but only just after the handler was done with it.
+func TestDedupNotificationsSlowHandler(t *testing.T) {
+	dedupWith(t, DefaultDedupWindow)
+	frame := []byte{0xEF, 0xDD, 0x0C, 0x01}
+	var got int
+	handle := dedupNotifications(t.Name(), func([]byte) {
+		got++
+		time.Sleep(50 * time.Millisecond)
+	})
+	handle(frame)
+	handle(frame)
+	if got != 1 {
+		t.Errorf("handler called %d times, want 1", got)
+	}
+	if _, byDevice := DuplicateNotifications(); byDevice[t.Name()] != 1 {
+		t.Errorf("DuplicateNotifications for %s = %d, want 1", t.Name(), byDevice[t.Name()])
+	}
+}
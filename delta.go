@@ -0,0 +1,47 @@
+package goscale
+
+// DeltaTracker fills in WeightUpdate.DeltaSinceLast and
+// GramsPerSecondInstant from consecutive updates, so consumers needn't keep
+// the previous reading themselves. UpdateStream applies one to every update
+// a driver sends; drivers that deliver updates otherwise can use one
+// directly.
+//
+// The zero value is ready to use. A DeltaTracker is not safe for concurrent
+// use.
+type DeltaTracker struct {
+	last    WeightUpdate
+	started bool
+}
+
+// Apply returns u with its delta filled in from the previous update
+// applied, and remembers it for the next. The first update, and updates
+// carrying an Error, are returned unchanged; errors are not remembered.
+// The time between updates is taken from their Timestamps, and where it
+// isn't positive GramsPerSecondInstant is left at zero.
+func (d *DeltaTracker) Apply(u WeightUpdate) WeightUpdate {
+	if u.Error != nil {
+		return u
+	}
+	prev, started := d.last, d.started
+	d.last, d.started = u, true
+	if !started {
+		return u
+	}
+
+	prevValue, err := ConvertWeight(prev.Value, prev.Unit, u.Unit)
+	if err != nil {
+		return u
+	}
+	u.DeltaSinceLast = u.Value - prevValue
+	u.HasDelta = true
+	if dt := u.Timestamp.Sub(prev.Timestamp); dt > 0 {
+		grams, _ := ConvertWeight(u.DeltaSinceLast, u.Unit, UnitGrams)
+		u.GramsPerSecondInstant = grams / dt.Seconds()
+	}
+	return u
+}
+
+// Reset forgets the previous update, e.g. on reconnecting.
+func (d *DeltaTracker) Reset() {
+	*d = DeltaTracker{}
+}
@@ -0,0 +1,292 @@
+package goscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Category is the kind of device a driver drives. Each category has its own
+// interface, Scale, PressureSensor or Thermometer, while discovery and
+// registration are shared.
+type Category uint8
+
+const (
+	CategoryScale Category = iota
+	CategoryPressureSensor
+	CategoryThermometer
+)
+
+func (c Category) String() string {
+	switch c {
+	case CategoryScale:
+		return "scale"
+	case CategoryPressureSensor:
+		return "pressure sensor"
+	case CategoryThermometer:
+		return "thermometer"
+	default:
+		return fmt.Sprintf("Unknown Category (%d)", c)
+	}
+}
+
+// Device is what every category of device has in common.
+type Device interface {
+	// Disconnect terminates the connection.
+	Disconnect() error
+
+	// IsConnected reports the connection status.
+	IsConnected() bool
+
+	// DeviceName should report the name as found during bluetooth scan.
+	DeviceName() string
+
+	// DisplayName should return a user-friendly name for the device. This
+	// could be the model name.
+	DisplayName() string
+}
+
+// PressureUpdate is a single reading from a pressure sensor, such as one
+// fitted to an espresso machine's group head.
+type PressureUpdate struct {
+	Pressure float64 // Bar
+	Flow     float64 // Millilitres per second; valid if HasFlow
+	HasFlow  bool
+	Error    error
+
+	// Seq and Timestamp are as for WeightUpdate.
+	Seq       uint64
+	Timestamp time.Time
+}
+
+// PressureSensor is the interface for a Bluetooth pressure sensor.
+type PressureSensor interface {
+	Device
+
+	// ConnectPressure connects to the sensor, giving up if ctx is done
+	// first, and returns a channel of its readings. The channel is closed
+	// on disconnect.
+	ConnectPressure(ctx context.Context) (<-chan PressureUpdate, error)
+}
+
+// TemperatureUpdate is a single reading from a thermometer.
+type TemperatureUpdate struct {
+	Celsius float64
+	Error   error
+
+	// Seq and Timestamp are as for WeightUpdate.
+	Seq       uint64
+	Timestamp time.Time
+}
+
+// Thermometer is the interface for a Bluetooth thermometer, such as a
+// kettle or milk probe.
+type Thermometer interface {
+	Device
+
+	// ConnectTemperature connects to the thermometer, giving up if ctx is
+	// done first, and returns a channel of its readings. The channel is
+	// closed on disconnect.
+	ConnectTemperature(ctx context.Context) (<-chan TemperatureUpdate, error)
+}
+
+// DeviceFactory creates a new instance of a Device. What it returns must
+// implement its category's interface.
+type DeviceFactory func(*FoundDevice) Device
+
+// RegisterDevice makes a device implementation of the given category
+// available by its device name prefix, as Register does for scales.
+// Prefixes are shared across categories; where several match a name, the
+// longest decides the device's category.
+func RegisterDevice(category Category, namePrefix string, factory DeviceFactory, options DriverOptions) {
+	registerDevice(category, namePrefix, factory, options, false)
+}
+
+// registerDevice registers an implementation under key, which is a name
+// prefix unless matchOnly is set.
+func registerDevice(category Category, key string, factory DeviceFactory, options DriverOptions, matchOnly bool) {
+	regLock.Lock()
+	defer regLock.Unlock()
+
+	if _, found := registry[key]; found {
+		fmt.Printf("warning: %s implementation for prefix '%s' is being overwritten\n", category, key)
+	}
+	registry[key] = registration{category: category, factory: factory, options: options, matchOnly: matchOnly}
+}
+
+// Unregister removes the implementation registered under namePrefix,
+// reporting whether there was one, e.g. so a test can run against only the
+// driver it is testing.
+func Unregister(namePrefix string) bool {
+	regLock.Lock()
+	defer regLock.Unlock()
+	_, found := registry[namePrefix]
+	delete(registry, namePrefix)
+	return found
+}
+
+// RegisteredDriver describes a registered device implementation.
+type RegisteredDriver struct {
+	Prefix      string // The device name prefix it is registered under
+	Category    Category
+	DisplayName string // The DisplayName of its devices, e.g. "Acaia Lunar Scale"
+	Virtual     bool   // See DriverOptions.Virtual
+	Matcher     bool   // Also matches devices by advertisement; see DriverOptions.Matcher
+	MatchOnly   bool   // Registered by RegisterMatcher, so Prefix is just its name
+}
+
+// ListRegistered returns every registered implementation, ordered by
+// prefix, so an application can show which devices it supports. Each
+// factory is called once, with a device named for its prefix, to learn
+// its DisplayName; the device is never connected.
+func ListRegistered() []RegisteredDriver {
+	regLock.RLock()
+	regs := maps.Clone(registry)
+	regLock.RUnlock()
+
+	drivers := make([]RegisteredDriver, 0, len(regs))
+	for prefix, reg := range regs {
+		drivers = append(drivers, RegisteredDriver{
+			Prefix:      prefix,
+			Category:    reg.category,
+			DisplayName: reg.factory(&FoundDevice{Name: prefix, Category: reg.category}).DisplayName(),
+			Virtual:     reg.options.Virtual,
+			Matcher:     reg.options.Matcher != nil,
+			MatchOnly:   reg.matchOnly,
+		})
+	}
+	slices.SortFunc(drivers, func(a, b RegisteredDriver) int {
+		return strings.Compare(a.Prefix, b.Prefix)
+	})
+	return drivers
+}
+
+// ErrNoDriver is returned when no implementation is registered for a
+// device.
+var ErrNoDriver = errors.New("no implementation found")
+
+// NewDevice creates a new instance of whatever category of device is
+// registered for the given device's name, or failing that whose Matcher
+// accepts it. Use a type switch, or NewScaleForDevice, to get at its
+// category's interface.
+func NewDevice(device *FoundDevice) (Device, error) {
+	regLock.RLock()
+	_, reg, ok := matchDevice(device.scanResult())
+	regLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w for device '%s'", ErrNoDriver, device.Name)
+	}
+	if reg.options.Validate != nil {
+		if err := reg.options.Validate(device); err != nil {
+			return nil, err
+		}
+	}
+	return reg.factory(device), nil
+}
+
+// DeviceCategory returns the category of a device called name, or false if
+// no implementation is registered for it.
+func DeviceCategory(name string) (Category, bool) {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	_, reg, ok := longestMatch(name)
+	return reg.category, ok
+}
+
+// categoryOf returns the category of a device called name, taking one
+// with no implementation to be a scale.
+func categoryOf(name string) Category {
+	c, _ := DeviceCategory(name)
+	return c
+}
+
+// longestMatch returns the registration with the longest prefix of name.
+// regLock must be held.
+func longestMatch(name string) (string, registration, bool) {
+	var (
+		best   string
+		bestOK bool
+		reg    registration
+	)
+	for prefix, r := range registry {
+		if !r.matchOnly && strings.HasPrefix(name, prefix) && (!bestOK || len(prefix) > len(best)) {
+			best, reg, bestOK = prefix, r, true
+		}
+	}
+	return best, reg, bestOK
+}
+
+// matcherMatch returns the first registration, in order of name, whose
+// Matcher accepts r. regLock must be held.
+func matcherMatch(r ScanResult) (string, registration, bool) {
+	for _, key := range slices.Sorted(maps.Keys(registry)) {
+		if m := registry[key].options.Matcher; m != nil && m(r) {
+			return key, registry[key], true
+		}
+	}
+	return "", registration{}, false
+}
+
+// matchDevice returns the registration for the device that sent r: the
+// longest prefix of its name, or failing that the first matcher to accept
+// it. regLock must be held.
+func matchDevice(r ScanResult) (string, registration, bool) {
+	if key, reg, ok := longestMatch(r.Name); ok {
+		return key, reg, ok
+	}
+	return matcherMatch(r)
+}
+
+// matchScanResult is matchDevice for use from a scan callback.
+// regLock must not be held.
+func matchScanResult(r ScanResult) (registration, bool) {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	_, reg, ok := matchDevice(r)
+	return reg, ok
+}
+
+// matchOnlyDriver reports whether name was registered by RegisterMatcher,
+// so isn't a name prefix.
+func matchOnlyDriver(name string) bool {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	return registry[name].matchOnly
+}
+
+// categoryRegistered reports whether any implementation of the given
+// categories is registered, by prefix or matcher. regLock must not be held.
+func categoryRegistered(categories ...Category) bool {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	for _, r := range registry {
+		if slices.Contains(categories, r.category) {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryPrefixes returns the registered prefixes of the given categories.
+// regLock must not be held.
+func categoryPrefixes(categories ...Category) []string {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	var prefixes []string
+	for prefix, r := range registry {
+		if r.matchOnly {
+			continue
+		}
+		for _, c := range categories {
+			if r.category == c {
+				prefixes = append(prefixes, prefix)
+				break
+			}
+		}
+	}
+	return prefixes
+}
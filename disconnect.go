@@ -0,0 +1,64 @@
+package goscale
+
+import (
+	"fmt"
+	"time"
+)
+
+// DisconnectReason is why a scale's connection ended.
+type DisconnectReason uint8
+
+const (
+	DisconnectUnknown     DisconnectReason = iota
+	DisconnectRequested                    // Disconnect was called
+	DisconnectLinkLost                     // The Bluetooth link dropped, e.g. out of range
+	DisconnectSilent                       // The scale stopped sending notifications
+	DisconnectHostResumed                  // The host slept, leaving the link stale
+	DisconnectAutoOff                      // The scale switched itself off after its sleep timeout
+	DisconnectLowBattery                   // The scale switched itself off with a flat battery
+)
+
+var disconnectReasonNames = map[DisconnectReason]string{
+	DisconnectUnknown:     "unknown",
+	DisconnectRequested:   "requested",
+	DisconnectLinkLost:    "link lost",
+	DisconnectSilent:      "silent",
+	DisconnectHostResumed: "host resumed",
+	DisconnectAutoOff:     "auto-off",
+	DisconnectLowBattery:  "low battery",
+}
+
+func (r DisconnectReason) String() string {
+	if name, ok := disconnectReasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown DisconnectReason (%d)", r)
+}
+
+// MarshalText encodes the reason by name.
+func (r DisconnectReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ShutdownBatteryPercent is the charge at or below which a scale dropping
+// its link is taken to have switched itself off with a flat battery.
+const ShutdownBatteryPercent = 5.0
+
+// AutoOffSlack is how far short of its sleep timeout a scale may have been
+// idle, as seen by the host, and still be taken to have switched itself
+// off. The host sees activity a little after the scale does.
+const AutoOffSlack = 10 * time.Second
+
+// InferShutdown reports whether a scale that dropped its link switched
+// itself off, and why, from its last reported sleep timeout and battery
+// charge and how long it had been idle. Drivers whose protocols don't say
+// why the scale is going away use it to tell a shutdown from a lost link.
+func InferShutdown(timeout SleepTimeout, battery float64, idle time.Duration) (DisconnectReason, bool) {
+	if battery <= ShutdownBatteryPercent {
+		return DisconnectLowBattery, true
+	}
+	if timeout != SleepTimeoutDisabled && idle >= time.Duration(timeout)-AutoOffSlack {
+		return DisconnectAutoOff, true
+	}
+	return DisconnectUnknown, false
+}
@@ -0,0 +1,106 @@
+package goscale
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// ErrServiceNotFound is returned by DiscoverChars when the device doesn't
+// have the requested service.
+var ErrServiceNotFound = errors.New("service not found")
+
+// MissingCharacteristicsError reports characteristics DiscoverChars
+// couldn't find on a service.
+type MissingCharacteristicsError struct {
+	Service bluetooth.UUID
+	Missing []bluetooth.UUID
+}
+
+func (e *MissingCharacteristicsError) Error() string {
+	missing := make([]string, len(e.Missing))
+	for i, u := range e.Missing {
+		missing[i] = u.String()
+	}
+	return fmt.Sprintf("service %s is missing characteristics %s", e.Service, strings.Join(missing, ", "))
+}
+
+// DiscoveryConfig controls how DiscoverChars retries. Some stacks, notably
+// BlueZ, report a device's services a moment after the connection
+// completes, so the first discovery can come back empty.
+type DiscoveryConfig struct {
+	Attempts int           // Total discovery attempts; at least 1
+	Delay    time.Duration // Wait between attempts
+}
+
+// DefaultDiscoveryConfig is used by DiscoverChars.
+var DefaultDiscoveryConfig = DiscoveryConfig{
+	Attempts: 3,
+	Delay:    500 * time.Millisecond,
+}
+
+// DiscoverChars finds the characteristics want on service of device,
+// returning them in the same order as want. If the service or any of the
+// characteristics can't be found after DefaultDiscoveryConfig.Attempts, the
+// error matches ErrServiceNotFound or is a *MissingCharacteristicsError
+// naming the ones missing.
+func DiscoverChars(device Peripheral, service bluetooth.UUID, want ...bluetooth.UUID) ([]Characteristic, error) {
+	cfg := DefaultDiscoveryConfig
+	attempts := max(cfg.Attempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("discovering %s (attempt %d of %d): %v", service, attempt, attempts, err)
+			time.Sleep(cfg.Delay)
+		}
+		var chars []Characteristic
+		chars, err = discoverChars(device, service, want)
+		if err == nil {
+			return chars, nil
+		}
+	}
+	return nil, err
+}
+
+func discoverChars(device Peripheral, service bluetooth.UUID, want []bluetooth.UUID) ([]Characteristic, error) {
+	services, err := device.DiscoverServices([]bluetooth.UUID{service})
+	if err != nil {
+		return nil, fmt.Errorf("could not discover services: %w", err)
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, service)
+	}
+
+	found, err := services[0].DiscoverCharacteristics(want)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover characteristics: %w", err)
+	}
+	return matchChars(service, found, want)
+}
+
+// matchChars orders found to match want.
+func matchChars(service bluetooth.UUID, found []Characteristic, want []bluetooth.UUID) ([]Characteristic, error) {
+	chars := make([]Characteristic, len(want))
+	var missing []bluetooth.UUID
+	for i, u := range want {
+		ok := false
+		for _, c := range found {
+			if c.UUID() == u {
+				chars[i], ok = c, true
+				break
+			}
+		}
+		if !ok {
+			missing = append(missing, u)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &MissingCharacteristicsError{Service: service, Missing: missing}
+	}
+	return chars, nil
+}
@@ -0,0 +1,127 @@
+package goscale_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/bttest"
+	"tinygo.org/x/bluetooth"
+)
+
+var (
+	service = bluetooth.New16BitUUID(0xFFF0)
+	notify  = bluetooth.New16BitUUID(0xFFF1)
+	command = bluetooth.New16BitUUID(0xFFF2)
+	other   = bluetooth.New16BitUUID(0xFFF3)
+)
+
+// discoverWith sets the discovery attempts for the length of a test.
+func discoverWith(t *testing.T, attempts int) {
+	t.Helper()
+	restore := goscale.DefaultDiscoveryConfig
+	goscale.DefaultDiscoveryConfig = goscale.DiscoveryConfig{Attempts: attempts, Delay: time.Millisecond}
+	t.Cleanup(func() { goscale.DefaultDiscoveryConfig = restore })
+}
+
+// connectFake connects to a fake device with the notify and command
+// characteristics, set up by setup before connecting.
+func connectFake(t *testing.T, setup func(d *bttest.Device)) goscale.Peripheral {
+	t.Helper()
+	a := bttest.NewAdapter()
+	t.Cleanup(a.Close)
+	d := a.AddDevice("FAKE", "C8:3A:35:00:00:04")
+	setup(d)
+	p, err := a.Connect(d.Address(), bluetooth.ConnectionParams{})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return p
+}
+
+func TestDiscoverChars(t *testing.T) {
+	discoverWith(t, 1)
+	p := connectFake(t, func(d *bttest.Device) {
+		d.AddCharacteristic(service, notify)
+		d.AddCharacteristic(service, other)
+		d.AddCharacteristic(service, command)
+	})
+
+	chars, err := goscale.DiscoverChars(p, service, command, notify)
+	if err != nil {
+		t.Fatalf("DiscoverChars: %v", err)
+	}
+	if len(chars) != 2 || chars[0].UUID() != command || chars[1].UUID() != notify {
+		t.Errorf("DiscoverChars found %v, want command then notify", chars)
+	}
+}
+
+func TestDiscoverCharsMissing(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(d *bttest.Device)
+		wantNotFound bool
+		wantMissing  []bluetooth.UUID
+	}{
+		{"no service", func(d *bttest.Device) {
+			d.AddCharacteristic(bluetooth.New16BitUUID(0x180F), notify)
+		}, true, nil},
+		{"one characteristic", func(d *bttest.Device) {
+			d.AddCharacteristic(service, notify)
+		}, false, []bluetooth.UUID{command}},
+		{"both characteristics", func(d *bttest.Device) {
+			d.AddCharacteristic(service, other)
+		}, false, []bluetooth.UUID{command, notify}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discoverWith(t, 2)
+			p := connectFake(t, tt.setup)
+
+			_, err := goscale.DiscoverChars(p, service, command, notify)
+			if got := errors.Is(err, goscale.ErrServiceNotFound); got != tt.wantNotFound {
+				t.Errorf("DiscoverChars = %v, ErrServiceNotFound %t, want %t", err, got, tt.wantNotFound)
+			}
+			if tt.wantMissing == nil {
+				return
+			}
+			var missing *goscale.MissingCharacteristicsError
+			if !errors.As(err, &missing) {
+				t.Fatalf("DiscoverChars = %v, want a MissingCharacteristicsError", err)
+			}
+			if missing.Service != service || !slices.Equal(missing.Missing, tt.wantMissing) {
+				t.Errorf("missing %v from %s, want %v from %s", missing.Missing, missing.Service, tt.wantMissing, service)
+			}
+		})
+	}
+}
+
+// Discovery straight after connecting can come up empty, as with BlueZ.
+func TestDiscoverCharsRetries(t *testing.T) {
+	tests := []struct {
+		failures int
+		wantErr  bool
+	}{
+		{0, false},
+		{2, false},
+		{3, true},
+	}
+	for _, tt := range tests {
+		discoverWith(t, 3)
+		p := connectFake(t, func(d *bttest.Device) {
+			d.AddCharacteristic(service, command)
+			d.AddCharacteristic(service, notify)
+			d.FailDiscovery(tt.failures)
+		})
+
+		_, err := goscale.DiscoverChars(p, service, command, notify)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("with %d failed discoveries, DiscoverChars = %v", tt.failures, err)
+		}
+		if tt.wantErr && !errors.Is(err, goscale.ErrServiceNotFound) {
+			t.Errorf("with %d failed discoveries, DiscoverChars = %v, want ErrServiceNotFound", tt.failures, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package goscale
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// The environment variables read by NewScaleFromEnv.
+const (
+	// EnvDevice names the device to use: its name, the start of its name,
+	// or its Bluetooth address.
+	EnvDevice = "GOSCALE_DEVICE"
+
+	// EnvDriver names the driver to use, by its registered prefix, whatever
+	// the device is called.
+	EnvDriver = "GOSCALE_DRIVER"
+)
+
+// EnvScanTimeout bounds the scan NewScaleFromEnv makes for a real device.
+const EnvScanTimeout = 10 * time.Second
+
+// NewScaleFromEnv creates the Scale chosen by the GOSCALE_DEVICE and
+// GOSCALE_DRIVER environment variables, so an application can be pointed at
+// the mock in CI, or at a particular scale, without code changes:
+//
+//	GOSCALE_DEVICE=MOCK                  the mock, if pkg/scales/mock is linked in
+//	GOSCALE_DEVICE=LUNAR                 the first Lunar found
+//	GOSCALE_DEVICE=C8:3A:35:00:00:01     the scale at that address
+//	GOSCALE_DRIVER=LUNAR                 the first Lunar found
+//	GOSCALE_DRIVER=LUNAR GOSCALE_DEVICE=C8:3A:35:00:00:01
+//	                                     the device at that address, driven as a Lunar
+//
+// Devices of a Virtual driver, such as the mock, are created straight away;
+// others are scanned for, for up to EnvScanTimeout. With neither variable
+// set, the first scale found is used, as by ScanForOne. The scale is
+// returned unconnected.
+func NewScaleFromEnv() (Scale, error) {
+	device := strings.TrimSpace(os.Getenv(EnvDevice))
+	driver := strings.TrimSpace(os.Getenv(EnvDriver))
+
+	if device == "" && driver == "" {
+		found, err := ScanForOne(EnvScanTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if found == nil || found.Name == "" {
+			return nil, fmt.Errorf("no scale found within %s", EnvScanTimeout)
+		}
+		return NewScaleForDevice(found)
+	}
+
+	name, setting := device, EnvDevice+"="+device
+	if name == "" {
+		name, setting = driver, EnvDriver+"="+driver
+	}
+	regLock.RLock()
+	var (
+		reg registration
+		ok  bool
+	)
+	if driver != "" {
+		reg, ok = registry[driver]
+	} else {
+		_, reg, ok = longestMatch(name)
+	}
+	regLock.RUnlock()
+	if driver != "" && !ok {
+		return nil, fmt.Errorf("%s=%s: %w", EnvDriver, driver, ErrNoDriver)
+	}
+	if ok && reg.category != CategoryScale {
+		return nil, fmt.Errorf("%s: device is a %s, not a scale", setting, reg.category)
+	}
+
+	var found *FoundDevice
+	if ok && reg.options.Virtual {
+		found = &FoundDevice{Name: name, Category: CategoryScale}
+	} else {
+		var err error
+		if found, err = scanForEnv(setting, name, driver != ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if driver == "" {
+		return NewScaleForDevice(found)
+	}
+	return NewScaleForDeviceWithDriver(found, driver)
+}
+
+// scanForEnv scans for the first device whose address is id, or whose name
+// starts with it, as the environment setting asked for. Unless anyName is
+// set, only registered scales are considered.
+func scanForEnv(setting, id string, anyName bool) (*FoundDevice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), EnvScanTimeout)
+	defer cancel()
+
+	var (
+		devices <-chan FoundDevice
+		err     error
+	)
+	if anyName {
+		devices, err = ScanStreamPrefixes(ctx, "")
+	} else {
+		devices, err = ScanStream(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for d := range devices {
+		if strings.EqualFold(d.Address.String(), id) || strings.HasPrefix(d.Name, id) {
+			cancel()
+			for range devices {
+			}
+			return &d, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no such device found within %s", setting, EnvScanTimeout)
+}
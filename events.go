@@ -0,0 +1,334 @@
+package goscale
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Topic is a category of scale event.
+type Topic string
+
+const (
+	TopicWeight     Topic = "weight"     // Data is a WeightUpdate
+	TopicBattery    Topic = "battery"    // Data is a BatteryEvent
+	TopicButtons    Topic = "buttons"    // Data is a ButtonEvent
+	TopicConnection Topic = "connection" // Data is a ConnectionEvent
+	TopicSettings   Topic = "settings"   // Data is a SettingsEvent
+	TopicAutoOff    Topic = "auto_off"   // Data is an AutoOffWarning
+)
+
+// Event is something that happened on a scale, published on a Bus.
+type Event struct {
+	Topic  Topic
+	Device string // DeviceName of the scale
+	At     time.Time
+	Data   any // Depends on Topic
+}
+
+// BatteryEvent reports a scale's charge.
+type BatteryEvent struct {
+	Percent float64 `json:"percent"`
+}
+
+// ButtonEvent reports a press of one of the scale's buttons.
+type ButtonEvent struct {
+	Button string `json:"button"` // e.g. ButtonTare
+
+	// Timestamp is when the press was reported. On a Bus it is also the
+	// Event's At.
+	Timestamp time.Time `json:"-"`
+}
+
+// Names of the buttons most scales have, for ButtonEvent.Button.
+const (
+	ButtonTare       = "tare"
+	ButtonTimerStart = "start"
+	ButtonTimerStop  = "stop"
+	ButtonTimerReset = "reset"
+)
+
+// ConnectionEvent reports a scale connecting or disconnecting.
+type ConnectionEvent struct {
+	Connected bool `json:"connected"`
+
+	// Reconnecting is set, with Connected false, when the connection has
+	// dropped and is being re-established; see ErrReconnecting.
+	Reconnecting bool `json:"reconnecting,omitempty"`
+
+	// Reason is why the scale disconnected, for scales that can say; see
+	// DisconnectReasoner.
+	Reason DisconnectReason `json:"reason,omitempty"`
+}
+
+// ErrReconnecting is sent as an update's Error, by scales that reconnect
+// by themselves, when the connection drops and they start reconnecting.
+// The channel stays open; updates resume once the scale is back.
+var ErrReconnecting = errors.New("connection lost, reconnecting")
+
+// SettingsEvent reports a change to one of the scale's settings.
+type SettingsEvent struct {
+	Name  string `json:"name"` // e.g. "beep" or "sleep_timeout"
+	Value any    `json:"value"`
+}
+
+// Filter decides whether a subscriber wants an event.
+type Filter func(Event) bool
+
+// Topics returns a Filter passing events on any of the given topics.
+func Topics(topics ...Topic) Filter {
+	return func(ev Event) bool {
+		return slices.Contains(topics, ev.Topic)
+	}
+}
+
+// FromDevice returns a Filter passing events from the named device.
+func FromDevice(device string) Filter {
+	return func(ev Event) bool {
+		return ev.Device == device
+	}
+}
+
+// DefaultSubscriptionBuffer is used by Subscribe when no buffer size is
+// given.
+const DefaultSubscriptionBuffer = 64
+
+// Bus is a publish/subscribe hub for scale events. Publishers never block:
+// a subscriber that falls a full buffer behind misses events, and its
+// Dropped count says how many. It is safe for concurrent use.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewBus returns a Bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives the events on a Bus that pass all its filters.
+type Subscription struct {
+	// C delivers events. It is closed by Cancel or when the Bus closes.
+	C <-chan Event
+
+	c       chan Event
+	bus     *Bus
+	filters []Filter
+	dropped atomic.Uint64
+}
+
+// Subscribe returns a subscription to events passing every filter, buffered
+// to hold buffer events; zero means DefaultSubscriptionBuffer.
+func (b *Bus) Subscribe(buffer int, filters ...Filter) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	c := make(chan Event, buffer)
+	sub := &Subscription{C: c, c: c, bus: b, filters: filters}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Cancel unsubscribes and closes C.
+func (s *Subscription) Cancel() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.c)
+	}
+}
+
+// Dropped returns how many events the subscription has missed by falling
+// behind.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Publish sends ev to every subscriber that wants it. Events without a time
+// are stamped with the current one.
+func (b *Bus) Publish(ev Event) {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if !sub.wants(ev) {
+			continue
+		}
+		select {
+		case sub.c <- ev:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+func (s *Subscription) wants(ev Event) bool {
+	for _, f := range s.filters {
+		if !f(ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close closes every subscription. Later subscriptions are closed straight
+// away and later events are discarded.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.c)
+	}
+	clear(b.subs)
+}
+
+// WeightUpdates adapts a subscription to the weight update channel that the
+// stream helpers (Throttle, ConvertUnits, ...) and recorders take. Events on
+// other topics are skipped. The returned channel is closed when the
+// subscription is.
+func (s *Subscription) WeightUpdates() <-chan WeightUpdate {
+	out := make(chan WeightUpdate, cap(s.c))
+	go func() {
+		defer close(out)
+		for ev := range s.C {
+			if u, ok := ev.Data.(WeightUpdate); ok {
+				out <- u
+			}
+		}
+	}()
+	return out
+}
+
+// DefaultEventPollInterval is how often PublishScale reads a scale's battery
+// and settings to look for changes.
+const DefaultEventPollInterval = 5 * time.Second
+
+// PublishScale publishes the events of a connected scale on b: each update
+// from its channel on TopicWeight, connection changes on TopicConnection,
+// and changes to its battery level and settings, which are polled, on
+// TopicBattery and TopicSettings. Battery levels from a BatteryNotifier are
+// published as they arrive, as are button presses from a ButtonNotifier, on
+// TopicButtons. An AutoOffWarning is published on TopicAutoOff
+// when the scale is expected to switch itself off within
+// DefaultAutoOffLead; see WatchAutoOff. Updates carrying ErrReconnecting are
+// published as connection changes rather than weights. It returns once
+// updates closes, after publishing the disconnect, or when ctx is done.
+func PublishScale(ctx context.Context, b *Bus, s Scale, updates <-chan WeightUpdate) {
+	device := s.DeviceName()
+	publish := func(topic Topic, data any) {
+		b.Publish(Event{Topic: topic, Device: device, Data: data})
+	}
+
+	publish(TopicConnection, ConnectionEvent{Connected: true})
+
+	features := s.GetFeatures()
+	var (
+		battery     float64
+		beep        bool
+		sleep       string
+		hasPrevious bool
+	)
+	poll := func() {
+		if features.BatteryPercent {
+			if pct, err := s.GetBatteryChargePercent(); err == nil && pct > 0 && pct != battery {
+				battery = pct
+				publish(TopicBattery, BatteryEvent{Percent: pct})
+			}
+		}
+		if features.Beep {
+			if on := s.GetBeep(); !hasPrevious || on != beep {
+				beep = on
+				publish(TopicSettings, SettingsEvent{Name: "beep", Value: on})
+			}
+		}
+		if features.SleepTimeout {
+			if t := s.GetSleepTimeout(); !hasPrevious || t != sleep {
+				sleep = t
+				publish(TopicSettings, SettingsEvent{Name: "sleep_timeout", Value: t})
+			}
+		}
+		hasPrevious = true
+	}
+	poll()
+
+	// The scale's battery readings and button presses, if it passes them
+	// on. The channels are replaced when a self-reconnecting scale
+	// reconnects, so they are fetched again after closing.
+	batteryUpdates, _ := BatteryUpdates(s)
+	buttons, _ := ButtonEvents(s)
+
+	idle := newIdleTracker(time.Now())
+
+	var reconnecting bool
+	ticker := time.NewTicker(DefaultEventPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				publish(TopicConnection, ConnectionEvent{Connected: false, Reason: DisconnectReasonOf(s)})
+				return
+			}
+			if errors.Is(u.Error, ErrReconnecting) {
+				reconnecting = true
+				publish(TopicConnection, ConnectionEvent{Reconnecting: true})
+				continue
+			}
+			if reconnecting && u.Error == nil {
+				reconnecting = false
+				idle = newIdleTracker(time.Now())
+				publish(TopicConnection, ConnectionEvent{Connected: true})
+			}
+			idle.observe(u)
+			b.Publish(Event{Topic: TopicWeight, Device: device, At: u.Timestamp, Data: u})
+		case bu, ok := <-batteryUpdates:
+			if !ok {
+				batteryUpdates = nil
+				continue
+			}
+			if bu.Percent > 0 && bu.Percent != battery {
+				battery = bu.Percent
+				b.Publish(Event{Topic: TopicBattery, Device: device, At: bu.Timestamp, Data: BatteryEvent{Percent: bu.Percent}})
+			}
+		case be, ok := <-buttons:
+			if !ok {
+				buttons = nil
+				continue
+			}
+			idle.touch(be.Timestamp)
+			b.Publish(Event{Topic: TopicButtons, Device: device, At: be.Timestamp, Data: be})
+		case now := <-ticker.C:
+			if batteryUpdates == nil {
+				batteryUpdates, _ = BatteryUpdates(s)
+			}
+			if buttons == nil {
+				buttons, _ = ButtonEvents(s)
+			}
+			poll()
+			if w, ok := idle.check(s, now, DefaultAutoOffLead); ok {
+				publish(TopicAutoOff, w)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package goscale
+
+import "sync"
+
+// fanout copies one weight update channel to any number of subscribers,
+// each with its own buffer.
+type fanout struct {
+	mu     sync.Mutex
+	subs   map[<-chan WeightUpdate]chan WeightUpdate
+	closed bool
+}
+
+func newFanout(in <-chan WeightUpdate) *fanout {
+	f := &fanout{subs: make(map[<-chan WeightUpdate]chan WeightUpdate)}
+	go f.run(in)
+	return f
+}
+
+func (f *fanout) run(in <-chan WeightUpdate) {
+	for u := range in {
+		f.mu.Lock()
+		for _, c := range f.subs {
+			sendDroppingOldest(c, u)
+		}
+		f.mu.Unlock()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for _, c := range f.subs {
+		close(c)
+	}
+	clear(f.subs)
+}
+
+func (f *fanout) subscribe(buffer int) <-chan WeightUpdate {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	c := make(chan WeightUpdate, buffer)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(c)
+		return c
+	}
+	f.subs[c] = c
+	return c
+}
+
+func (f *fanout) unsubscribe(c <-chan WeightUpdate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if sub, ok := f.subs[c]; ok {
+		delete(f.subs, c)
+		close(sub)
+	}
+}
+
+// sendDroppingOldest sends u on c, first discarding the oldest update
+// queued if c is full, so a slow subscriber holds up no one else.
+func sendDroppingOldest(c chan WeightUpdate, u WeightUpdate) {
+	for {
+		select {
+		case c <- u:
+			return
+		default:
+		}
+		select {
+		case <-c:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a new channel that receives every weight update from
+// the scale, so several consumers, such as an HTTP server and a recorder,
+// can share one connection. Each subscriber has its own buffer of buffer
+// updates, zero meaning DefaultSubscriptionBuffer; one that falls a full
+// buffer behind loses its oldest updates, which shows as a gap in Seq,
+// rather than holding up the others. The channel is closed by Unsubscribe,
+// or when the scale's updates end.
+//
+// The first Subscribe takes over reading Updates, so once anything has
+// subscribed, don't read Updates directly.
+func (cs *ConnectedScale) Subscribe(buffer int) <-chan WeightUpdate {
+	cs.fanMu.Lock()
+	if cs.fan == nil {
+		cs.fan = newFanout(cs.Updates)
+	}
+	fan := cs.fan
+	cs.fanMu.Unlock()
+	return fan.subscribe(buffer)
+}
+
+// Unsubscribe stops updates to a channel returned by Subscribe, and closes
+// it. It does nothing if nothing has subscribed, leaving Updates to be read
+// directly.
+func (cs *ConnectedScale) Unsubscribe(c <-chan WeightUpdate) {
+	cs.fanMu.Lock()
+	fan := cs.fan
+	cs.fanMu.Unlock()
+	if fan == nil {
+		return
+	}
+	fan.unsubscribe(c)
+}
@@ -0,0 +1,68 @@
+package goscale
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// A stray Unsubscribe, such as a deferred cleanup, on a scale read directly
+// mustn't start the fan-out, which would take over Updates.
+func TestUnsubscribeBeforeSubscribe(t *testing.T) {
+	updates := make(chan WeightUpdate, 1)
+	cs := &ConnectedScale{Updates: updates}
+	cs.Unsubscribe(make(chan WeightUpdate))
+	if cs.fan != nil {
+		t.Fatal("Unsubscribe started the fan-out")
+	}
+
+	updates <- WeightUpdate{Value: 18.3}
+	select {
+	case u := <-cs.Updates:
+		if u.Value != 18.3 {
+			t.Errorf("read %v from Updates, want 18.3", u.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Updates didn't deliver")
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	updates := make(chan WeightUpdate)
+	defer close(updates)
+	cs := &ConnectedScale{Updates: updates}
+	a, b := cs.Subscribe(0), cs.Subscribe(0)
+	cs.Unsubscribe(a)
+	if _, ok := <-a; ok {
+		t.Error("unsubscribed channel still open")
+	}
+
+	updates <- WeightUpdate{Value: 18.3}
+	if u := <-b; u.Value != 18.3 {
+		t.Errorf("remaining subscriber got %v, want 18.3", u.Value)
+	}
+}
+
+// BenchmarkSubscribe measures an update's trip through the fan-out to every
+// subscriber.
+func BenchmarkSubscribe(b *testing.B) {
+	for _, n := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("%d subscribers", n), func(b *testing.B) {
+			b.ReportAllocs()
+			updates := make(chan WeightUpdate)
+			defer close(updates)
+			cs := &ConnectedScale{Updates: updates}
+			subs := make([]<-chan WeightUpdate, n)
+			for i := range subs {
+				subs[i] = cs.Subscribe(0)
+			}
+			u := WeightUpdate{Value: 18.3, Unit: UnitGrams}
+			for b.Loop() {
+				updates <- u
+				for _, c := range subs {
+					<-c
+				}
+			}
+		})
+	}
+}
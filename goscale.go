@@ -1,9 +1,13 @@
 package goscale
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"slices"
 	"sync"
+	"time"
+
+	"tinygo.org/x/bluetooth"
 )
 
 // WeightUpdate represents a single reading from the scale.
@@ -11,38 +15,109 @@ import (
 // An error can be propagated through the channel as well.
 type WeightUpdate struct {
 	Value float64
-	Unit  string
+	Unit  string // UnitGrams, UnitOunces, or empty for grams
 	Error error
+
+	// Seq is a per-connection sequence number. Drivers number the updates
+	// they emit starting at 1, so a consumer seeing a jump knows updates were
+	// dropped along the way. See GapDetector.
+	Seq uint64
+
+	// Timestamp is when the reading was taken, as seen by the host. Drivers
+	// capture it with time.Now on entry to their Bluetooth notification
+	// callback, before decoding or queueing, so it doesn't depend on how
+	// quickly the consumer receives the update. It lags the scale's own
+	// measurement by the BLE connection interval (typically 7.5-50 ms) plus
+	// the operating system's Bluetooth stack, which goscale can't observe.
+	//
+	// It carries a monotonic clock reading, so the difference between two
+	// timestamps (Sub) is immune to the wall clock being stepped, e.g. by
+	// NTP. Marshalling, or calling UTC, Local, In or Round(0), drops the
+	// monotonic reading, so work out durations before doing so.
+	Timestamp time.Time
+
+	// ScaleTimer is the scale's own timer reading at the time of the
+	// update, for scales that send one with each reading (HasScaleTimer).
+	// It only advances while the timer is running. See ScaleTimerClock.
+	ScaleTimer    time.Duration
+	HasScaleTimer bool
+
+	// FlowRate is the rate the weight is changing, in Unit per second. It
+	// is the scale's own figure where it reports one (the Themis does), and
+	// otherwise estimated by the driver over pkg/flow's DefaultWindow.
+	FlowRate    float64
+	HasFlowRate bool
+
+	// DeltaSinceLast is the change in Value, in Unit, since the previous
+	// reading on the connection, and GramsPerSecondInstant that change in
+	// grams over the time between the two, for updates after the first
+	// (HasDelta). Unlike FlowRate it isn't averaged, so it is noisy, but
+	// it reacts at once. They are filled in centrally by UpdateStream (see
+	// DeltaTracker), after the scale's own smoothing (see SetSmoothing),
+	// and aren't recomputed by filters downstream that change Value.
+	DeltaSinceLast        float64
+	GramsPerSecondInstant float64
+	HasDelta              bool
+
+	// Stable reports whether the scale considers the reading settled, for
+	// scales that flag it with each reading (HasStable).
+	Stable    bool
+	HasStable bool
+
+	// Raw is the reading as the scale sent it, a signed count of
+	// 1/RawDivisor of Unit, for scales that send an integer (HasRaw). Value
+	// is Raw/RawDivisor. Summing Raw avoids accumulating float rounding, and
+	// lets decoding be checked against captured frames. Raw is unaffected
+	// by TareOffset, so Value is then Raw/RawDivisor less TareOffset.
+	Raw        int64
+	RawDivisor int64
+	HasRaw     bool
+
+	// Unsmoothed is Value as it was before smoothing, in Unit, for updates
+	// that have been through a smoothing filter (HasUnsmoothed), whether
+	// the scale's own (see SetSmoothing) or Smooth.
+	Unsmoothed    float64
+	HasUnsmoothed bool
+
+	// TareOffset is the weight, in Unit, subtracted from Value by
+	// TareToOffset, such as a container's, so Value+TareOffset is what the
+	// scale reported. It is zero when there is none.
+	TareOffset float64
 }
 
-// ScaleFeatures is used to advertise the functions a scale supports.
+// ScaleFeatures is used to advertise the functions a scale supports, so a
+// UI can show only the controls that will work.
 type ScaleFeatures struct {
 	Tare           bool
 	BatteryPercent bool
 	SleepTimeout   bool
 	Beep           bool
+
+	Timer       bool // Has a timer the host can start, stop and reset; see Timer
+	FlowRate    bool // Reports its own flow rate; see FlowRater. Otherwise WeightUpdate.FlowRate is estimated
+	UnitSwitch  bool // Its display unit can be changed; see UnitSetter
+	KeyLock     bool // Its buttons can be locked; see KeyLocker
+	Resolution  bool // Reports its display resolution; see SettingResolution
+	StableFlag  bool // Flags each reading stable or not; see WeightUpdate.Stable
+	Calibration bool // Can be calibrated from the host
 }
 
 // Scale is the generic interface for a Bluetooth scale.
 // Implementations of this interface will handle communication with a specific model.
+//
+// Implementations are safe for concurrent use: a UI goroutine may call Tare
+// or read settings while the driver's notification handler and heartbeat
+// update its state, and Disconnect may be called while an update is being
+// delivered. Drivers deliver updates through an UpdateStream to make the
+// latter safe.
 type Scale interface {
+	Device
+
 	// Connect establishes a connection to the scale. Context should be handled internally
 	// between the connect and disconnect functions. Returns a read-only
 	// channel for weight updates. This channel should be closed on disconnect.
 	Connect() (<-chan WeightUpdate, error)
 
-	// Disconnect terminates the connection.
-	Disconnect() error
-
-	// IsConnected reports the connection status.
-	IsConnected() bool
-
-	// DeviceName should report the name as found during bluetooth scan.
-	DeviceName() string
-
-	// DisplayName should return a user-friendly name for the scale. This could be the model name.
-	DisplayName() string
-
 	// GetFeatures returns the ScaleFeatures supported by scale
 	GetFeatures() ScaleFeatures
 
@@ -55,9 +130,12 @@ type Scale interface {
 	AdvanceSleepTimeout() error
 
 	// GetSleepTimeout returns the current sleep timeout as a string
+	//
+	// Deprecated: use SleepTimeoutSetting, which returns a SleepTimeout, on
+	// scales that support it. Its String method gives the same text.
 	GetSleepTimeout() string
 
-	// GetBatteryChargePercent returns the current battery level as a float percentage (0-1.0).
+	// GetBatteryChargePercent returns the current battery level as a float percentage (0-100).
 	GetBatteryChargePercent() (float64, error)
 
 	// GetBeep() returns whether the scale's beep functionality is enabled.
@@ -72,37 +150,169 @@ type Scale interface {
 // Factory is a function that creates a new instance of a Scale.
 type Factory func(*FoundDevice) Scale
 
+// DriverOptions carries optional registration details for a scale implementation.
+type DriverOptions struct {
+	// ServiceUUIDs are GATT services the driver's devices may advertise. While
+	// scanning, any of these found in a device's advertisement are recorded in
+	// FoundDevice.ServiceUUIDs for Validate to inspect.
+	ServiceUUIDs []bluetooth.UUID
+
+	// Validate is called by NewScaleForDevice before the factory. It lets a
+	// driver refuse devices that share its name prefix but aren't something it
+	// can drive, typically by returning an *UnsupportedModelError.
+	Validate func(*FoundDevice) error
+
+	// Virtual marks a driver whose devices need no Bluetooth, such as the
+	// mock, so NewScaleFromEnv creates them without scanning.
+	Virtual bool
+
+	// Matcher, if set, also claims devices whose advertisement it accepts,
+	// whatever they are called, for scales that advertise a generic name.
+	// A matching name prefix takes precedence over any matcher. Service
+	// UUIDs the matcher looks for must be listed in ServiceUUIDs, as only
+	// those are recorded while scanning.
+	Matcher Matcher
+}
+
+// Matcher reports whether a driver can drive the device that sent an
+// advertisement, e.g. by its service UUIDs, manufacturer data or address.
+// It is called from the scan callback, so must be quick.
+type Matcher func(ScanResult) bool
+
+type registration struct {
+	category Category
+	factory  DeviceFactory
+	options  DriverOptions
+
+	// matchOnly is set for drivers registered by RegisterMatcher, whose
+	// key isn't a name prefix.
+	matchOnly bool
+}
+
 var (
-	registry = make(map[string]Factory)
+	registry = make(map[string]registration)
 	regLock  = sync.RWMutex{}
 )
 
+// ErrUnsupportedModel is matched (via errors.Is) by every *UnsupportedModelError.
+var ErrUnsupportedModel = errors.New("unsupported model")
+
+// UnsupportedModelError reports a device that matched a driver's name prefix
+// but turned out not to be a model the driver supports, e.g. non-scale
+// hardware from the same vendor.
+type UnsupportedModelError struct {
+	Device string
+	Reason string
+}
+
+func (e *UnsupportedModelError) Error() string {
+	return fmt.Sprintf("device '%s' is not a supported model: %s", e.Device, e.Reason)
+}
+
+func (e *UnsupportedModelError) Is(target error) bool {
+	return target == ErrUnsupportedModel
+}
+
 // Register makes a scale implementation available by its device name prefix.
 // This function should be called from the init() function of the implementation's package.
 // For example, an implementation for a "LUNAR" scale would register with the prefix "LUNAR".
 func Register(namePrefix string, factory Factory) {
-	regLock.Lock()
-	defer regLock.Unlock()
+	RegisterWithOptions(namePrefix, factory, DriverOptions{})
+}
 
-	if _, found := registry[namePrefix]; found {
-		// Or panic, depending on desired strictness
-		fmt.Printf("warning: scale implementation for prefix '%s' is being overwritten\n", namePrefix)
-	}
-	registry[namePrefix] = factory
+// RegisterWithOptions is like Register, with additional DriverOptions.
+func RegisterWithOptions(namePrefix string, factory Factory, options DriverOptions) {
+	RegisterDevice(CategoryScale, namePrefix, func(d *FoundDevice) Device { return factory(d) }, options)
+}
+
+// RegisterMatcher makes a scale implementation available for devices that
+// matcher accepts, for scales whose advertised names say nothing about
+// them. name identifies the driver, e.g. for GOSCALE_DRIVER and
+// Unregister, but isn't matched against device names.
+func RegisterMatcher(name string, factory Factory, matcher Matcher, options DriverOptions) {
+	options.Matcher = matcher
+	registerDevice(CategoryScale, name, func(d *FoundDevice) Device { return factory(d) }, options, true)
 }
 
 // NewScaleForDevice finds a registered factory for the given device name and
 // creates a new Scale instance. It matches based on the prefix.
 // Example: A device named "LUNAR-A23B" would match a registered "LUNAR" prefix.
+// Where several prefixes match, the longest wins, so a "LUNAR-2021" driver
+// takes the devices it names from "LUNAR". Failing a prefix, the drivers'
+// Matchers are tried against what the device advertised. If the matching
+// driver rejects the device, its error (usually an *UnsupportedModelError)
+// is returned. A device registered as another Category, such as a pressure
+// sensor sharing a scale's prefix, is refused.
 func NewScaleForDevice(device *FoundDevice) (Scale, error) {
 	regLock.RLock()
-	defer regLock.RUnlock()
+	_, reg, ok := matchDevice(device.scanResult())
+	regLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w for device '%s'", ErrNoDriver, device.Name)
+	}
+	if reg.category != CategoryScale {
+		return nil, fmt.Errorf("device '%s' is a %s, not a scale", device.Name, reg.category)
+	}
+	return newScale(reg, device)
+}
 
-	for prefix, factory := range registry {
-		if strings.HasPrefix(device.Name, prefix) {
-			return factory(device), nil
+// NewScaleForDeviceWithDriver is NewScaleForDevice using the driver
+// registered under driverName, whatever the device is called, e.g. when
+// the user has picked the model or the device's name is ambiguous. The
+// driver may still reject the device.
+func NewScaleForDeviceWithDriver(device *FoundDevice, driverName string) (Scale, error) {
+	regLock.RLock()
+	reg, ok := registry[driverName]
+	regLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w named '%s'", ErrNoDriver, driverName)
+	}
+	if reg.category != CategoryScale {
+		return nil, fmt.Errorf("driver '%s' is for a %s, not a scale", driverName, reg.category)
+	}
+	return newScale(reg, device)
+}
+
+// newScale creates a scale for device with reg, if its driver accepts it.
+func newScale(reg registration, device *FoundDevice) (Scale, error) {
+	if reg.options.Validate != nil {
+		if err := reg.options.Validate(device); err != nil {
+			return nil, err
 		}
 	}
+	return reg.factory(device).(Scale), nil
+}
+
+// MatchDriver returns the registered name prefix a device called name would
+// be driven by, or an empty string if there is none.
+func MatchDriver(name string) string {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	prefix, _, _ := longestMatch(name)
+	return prefix
+}
+
+// MatchDeviceDriver is MatchDriver for a found device, also trying the
+// registered Matchers against what it advertised. It returns the name the
+// driver was registered under.
+func MatchDeviceDriver(device *FoundDevice) string {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	name, _, _ := matchDevice(device.scanResult())
+	return name
+}
 
-	return nil, fmt.Errorf("no implementation found for device '%s'", device.Name)
+// getRegisteredServiceUUIDs returns every service UUID declared by a registered driver.
+func getRegisteredServiceUUIDs() []bluetooth.UUID {
+	regLock.RLock()
+	defer regLock.RUnlock()
+	var uuids []bluetooth.UUID
+	for _, reg := range registry {
+		for _, u := range reg.options.ServiceUUIDs {
+			if !slices.Contains(uuids, u) {
+				uuids = append(uuids, u)
+			}
+		}
+	}
+	return uuids
 }
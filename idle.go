@@ -0,0 +1,146 @@
+package goscale
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// IdleConfig defines when a connection counts as idle, for daemons that stay
+// connected around the clock and would otherwise keep the scale awake, and
+// its battery draining, indefinitely.
+type IdleConfig struct {
+	// After is how long the weight must stay unchanged, with no activity
+	// from the application, for the connection to be idle.
+	After time.Duration
+
+	// Tolerance is how many grams the weight may wander by and still count
+	// as unchanged.
+	Tolerance float64
+
+	// Sleep also powers the scale down, on scales that are Sleepers, rather
+	// than just disconnecting and leaving it to its own sleep timer.
+	Sleep bool
+}
+
+// DefaultIdleConfig disconnects after 15 minutes without the weight
+// changing.
+var DefaultIdleConfig = IdleConfig{
+	After:     15 * time.Minute,
+	Tolerance: 0.5,
+}
+
+// IdleMonitor disconnects a scale once its connection has been idle; see
+// WatchIdle. It is safe for concurrent use.
+type IdleMonitor struct {
+	scale Scale
+	cfg   IdleConfig
+
+	mu       sync.Mutex
+	last     time.Time // Last change or activity
+	ref      float64   // Grams the weight was at, at the last change
+	hasRef   bool
+	idledOut bool
+}
+
+// WatchIdle passes updates from in through, disconnecting s once the weight
+// has stayed unchanged, and Touch hasn't been called, for cfg.After. The
+// returned channel is closed when in closes, which the disconnect causes.
+func WatchIdle(s Scale, in <-chan WeightUpdate, cfg IdleConfig) (*IdleMonitor, <-chan WeightUpdate) {
+	m := &IdleMonitor{scale: s, cfg: cfg, last: time.Now()}
+	out := make(chan WeightUpdate, cap(in))
+	go m.run(in, out)
+	return m, out
+}
+
+// Touch records activity from the application, such as a user looking at
+// the reading, which keeps the connection from going idle.
+func (m *IdleMonitor) Touch() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = time.Now()
+}
+
+// IdledOut reports whether the monitor disconnected the scale, as opposed to
+// it disconnecting for some other reason.
+func (m *IdleMonitor) IdledOut() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.idledOut
+}
+
+// IdleFor returns how long the connection has been idle.
+func (m *IdleMonitor) IdleFor() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return time.Since(m.last)
+}
+
+func (m *IdleMonitor) run(in <-chan WeightUpdate, out chan<- WeightUpdate) {
+	defer close(out)
+
+	// Check often enough to disconnect within a tenth of After.
+	check := min(max(m.cfg.After/10, 100*time.Millisecond), 30*time.Second)
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case u, ok := <-in:
+			if !ok {
+				return
+			}
+			m.observe(u)
+			out <- u
+		case <-ticker.C:
+			if m.cfg.After > 0 && m.expire() {
+				// Keep draining in while the scale disconnects, so its
+				// notification handler isn't left blocked on a full
+				// channel.
+				go m.disconnect()
+			}
+		}
+	}
+}
+
+func (m *IdleMonitor) observe(u WeightUpdate) {
+	if u = ConvertUpdate(u, UnitGrams); u.Error != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.hasRef || math.Abs(u.Value-m.ref) > m.cfg.Tolerance {
+		m.ref, m.hasRef = u.Value, true
+		m.last = time.Now()
+	}
+}
+
+// expire marks the connection idled out, if it has been idle long enough
+// and hasn't already been.
+func (m *IdleMonitor) expire() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.idledOut || time.Since(m.last) < m.cfg.After {
+		return false
+	}
+	m.idledOut = true
+	return true
+}
+
+func (m *IdleMonitor) disconnect() {
+	log.Printf("%s idle for %s, disconnecting", m.scale.DeviceName(), m.cfg.After)
+	if m.cfg.Sleep {
+		err := Sleep(m.scale)
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, ErrNotSupported) {
+			log.Printf("Error putting %s to sleep: %v", m.scale.DeviceName(), err)
+		}
+	}
+	if err := m.scale.Disconnect(); err != nil {
+		log.Printf("Error disconnecting idle %s: %v", m.scale.DeviceName(), err)
+	}
+}
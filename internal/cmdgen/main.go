@@ -0,0 +1,199 @@
+// Command cmdgen generates a scale protocol's command builders from a table
+// of its commands, so each command is described once, by its op code and
+// payload, rather than as a hand-rolled byte slice, and its checksum is
+// always computed the same way.
+//
+// Each comms package keeps its table in commands.yaml and generates from it
+// with:
+//
+//	//go:generate go run github.com/mlsorensen/goscale/internal/cmdgen
+//
+// A table looks like:
+//
+//	header: [HeaderPrefix1, HeaderPrefix2] # Bytes before the op code
+//	checksum: sum2     # sum2, xor or none
+//	checksum_from: 3   # Offset into the frame the checksum starts at
+//	commands:
+//	  - name: AutoOff  # Generates BuildAutoOffCommand
+//	    doc: creates the command to adjust the auto-off timer.
+//	    op: 0x0A
+//	    params:
+//	      - {name: setting, type: AutoOffSetting}
+//	    payload: [0x00, 0x01, setting]
+//
+// Header and payload bytes are numbers, or Go expressions naming constants
+// or params. A bool param is sent as 0, or as its true value (1 unless
+// set) if true.
+//
+// The checksums are:
+//
+//	sum2  two bytes: the sums of the bytes at even and at odd offsets
+//	xor   one byte: the XOR of the bytes
+//	none  no checksum
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type table struct {
+	Header       []any     `yaml:"header"`
+	Checksum     string    `yaml:"checksum"`
+	ChecksumFrom int       `yaml:"checksum_from"`
+	Commands     []command `yaml:"commands"`
+}
+
+type command struct {
+	Name    string  `yaml:"name"`
+	Doc     string  `yaml:"doc"`
+	Op      *int    `yaml:"op"`
+	Params  []param `yaml:"params"`
+	Payload []any   `yaml:"payload"`
+}
+
+type param struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	True *int   `yaml:"true"` // Byte sent for a true bool; default 1
+}
+
+func main() {
+	in := flag.String("in", "commands.yaml", "command table to read")
+	out := flag.String("out", "commands_gen.go", "Go file to write")
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "package of the generated file")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("cmdgen: ")
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var t table
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		log.Fatalf("%s: %v", *in, err)
+	}
+	src, err := generate(*pkg, *in, t)
+	if err != nil {
+		log.Fatalf("%s: %v", *in, err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(pkg, in string, t table) ([]byte, error) {
+	if pkg == "" {
+		return nil, fmt.Errorf("no package; run from go generate or set -pkg")
+	}
+	header, err := byteExprs(t.Header, nil)
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by cmdgen from %s; DO NOT EDIT.\n\n", in)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+
+	var usesBool bool
+	for _, c := range t.Commands {
+		if c.Name == "" || c.Op == nil {
+			return nil, fmt.Errorf("command %q needs a name and an op code", c.Name)
+		}
+		params := make(map[string]param, len(c.Params))
+		args := make([]string, 0, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Name] = p
+			args = append(args, p.Name+" "+p.Type)
+			usesBool = usesBool || p.Type == "bool"
+		}
+		payload, err := byteExprs(c.Payload, params)
+		if err != nil {
+			return nil, fmt.Errorf("command %s: %w", c.Name, err)
+		}
+
+		name := "Build" + c.Name + "Command"
+		if c.Doc != "" {
+			for _, line := range strings.Split(strings.TrimSpace(name+" "+c.Doc), "\n") {
+				fmt.Fprintf(&b, "%s\n", strings.TrimSpace("// "+line))
+			}
+		}
+		fmt.Fprintf(&b, "func %s(%s) []byte {\n", name, strings.Join(args, ", "))
+		fmt.Fprintf(&b, "\treturn encodeFrame(0x%02X, %s)\n}\n\n", *c.Op, sliceLit(payload))
+	}
+
+	fmt.Fprintf(&b, "// encodeFrame frames a command: the header, op code and payload, then the\n")
+	fmt.Fprintf(&b, "// checksum.\n")
+	fmt.Fprintf(&b, "func encodeFrame(op byte, payload []byte) []byte {\n")
+	fmt.Fprintf(&b, "\tmsg := append(%s, op)\n", sliceLit(header))
+	fmt.Fprintf(&b, "\tmsg = append(msg, payload...)\n")
+	covered := "msg"
+	if t.ChecksumFrom > 0 {
+		covered = fmt.Sprintf("msg[%d:]", t.ChecksumFrom)
+	}
+	switch t.Checksum {
+	case "sum2":
+		fmt.Fprintf(&b, "\tvar csum1, csum2 byte\n")
+		fmt.Fprintf(&b, "\tfor i, c := range %s {\n", covered)
+		fmt.Fprintf(&b, "\t\tif i%%2 == 0 {\n\t\t\tcsum1 += c\n\t\t} else {\n\t\t\tcsum2 += c\n\t\t}\n\t}\n")
+		fmt.Fprintf(&b, "\treturn append(msg, csum1, csum2)\n}\n")
+	case "xor":
+		fmt.Fprintf(&b, "\tvar csum byte\n")
+		fmt.Fprintf(&b, "\tfor _, c := range %s {\n\t\tcsum ^= c\n\t}\n", covered)
+		fmt.Fprintf(&b, "\treturn append(msg, csum)\n}\n")
+	case "none", "":
+		fmt.Fprintf(&b, "\treturn msg\n}\n")
+	default:
+		return nil, fmt.Errorf("unknown checksum %q", t.Checksum)
+	}
+
+	if usesBool {
+		fmt.Fprintf(&b, "\n// boolByte returns on if v is true, and zero otherwise.\n")
+		fmt.Fprintf(&b, "func boolByte(v bool, on byte) byte {\n\tif v {\n\t\treturn on\n\t}\n\treturn 0\n}\n")
+	}
+
+	return format.Source(b.Bytes())
+}
+
+// byteExprs turns table bytes into Go expressions of type byte.
+func byteExprs(items []any, params map[string]param) ([]string, error) {
+	exprs := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case int:
+			if v < 0 || v > 0xFF {
+				return nil, fmt.Errorf("%d doesn't fit in a byte", v)
+			}
+			exprs = append(exprs, fmt.Sprintf("0x%02X", v))
+		case string:
+			p, ok := params[v]
+			switch {
+			case ok && p.Type == "bool":
+				on := 1
+				if p.True != nil {
+					on = *p.True
+				}
+				exprs = append(exprs, fmt.Sprintf("boolByte(%s, 0x%02X)", v, on))
+			case ok && p.Type != "byte":
+				exprs = append(exprs, fmt.Sprintf("byte(%s)", v))
+			default:
+				exprs = append(exprs, v)
+			}
+		default:
+			return nil, fmt.Errorf("can't send %v (%T) as a byte", item, item)
+		}
+	}
+	return exprs, nil
+}
+
+func sliceLit(exprs []string) string {
+	return "[]byte{" + strings.Join(exprs, ", ") + "}"
+}
@@ -0,0 +1,344 @@
+// Package connsup supervises a driver's connection to a scale: it sends the
+// driver's heartbeat, watches for the link dropping, the notifications
+// drying up or the host waking from sleep, and tears the connection down
+// when any happens, so each driver doesn't hand-roll its own watchdog
+// goroutine.
+package connsup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"tinygo.org/x/bluetooth"
+)
+
+// DefaultInterval is how often the link is checked if Config.Interval is
+// unset.
+const DefaultInterval = time.Second
+
+// DefaultResumeJump is how far the wall clock must get ahead of the
+// monotonic clock between checks, if Config.ResumeJump is unset, for the
+// host to be taken to have slept.
+const DefaultResumeJump = 10 * time.Second
+
+var (
+	// ErrLinkLost means the Bluetooth link dropped.
+	ErrLinkLost = errors.New("link lost")
+	// ErrSilent means the scale stopped sending notifications.
+	ErrSilent = errors.New("no notifications")
+	// ErrResumed means the host slept, and the connection's handles can't
+	// be trusted after it woke.
+	ErrResumed = errors.New("host resumed from sleep")
+)
+
+// State is where a supervised connection is.
+type State int
+
+const (
+	// Connected means notifications are arriving.
+	Connected State = iota
+	// Stalled means notifications have stopped for longer than
+	// Config.StallAfter, but the connection hasn't been given up on.
+	Stalled
+	// Disconnected means the supervisor has stopped, either because it was
+	// asked to or because it gave up on the link.
+	Disconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case Connected:
+		return "connected"
+	case Stalled:
+		return "stalled"
+	case Disconnected:
+		return "disconnected"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Config describes how to supervise a connection. Only Disconnect is
+// required.
+type Config struct {
+	// Interval is how often to check the link and send the heartbeat.
+	// Zero means DefaultInterval.
+	Interval time.Duration
+
+	// Heartbeat, if set, is called every Interval. An error gives up on the
+	// connection.
+	Heartbeat func() error
+
+	// StallAfter, if set, is how long without notifications before the
+	// connection counts as stalled. OnStall is then called, and again every
+	// StallAfter for as long as it stays stalled, to try to get the scale
+	// streaming again.
+	StallAfter time.Duration
+	OnStall    func()
+
+	// Silence, if set, is how long without notifications before giving up
+	// on the connection.
+	Silence time.Duration
+
+	// Address, if set, is watched for the Bluetooth link dropping, which is
+	// noticed much sooner than Silence.
+	Address bluetooth.Address
+
+	// LinkDone, if set, is closed when the link drops, for links that don't
+	// go through the local adapter.
+	LinkDone <-chan struct{}
+
+	// ResumeJump is how far the wall clock must get ahead of the
+	// monotonic clock between checks for the host to be taken to have
+	// slept and woken again. The monotonic clock stops while the host
+	// sleeps, so the difference is how long it slept; a check held up by a
+	// slow heartbeat advances both clocks alike, so isn't taken for one.
+	// Bluetooth stacks often leave a connection looking alive across a
+	// suspend while no notifications will ever arrive on it, so it is
+	// given up on instead. Zero means DefaultResumeJump, and a negative
+	// value turns the check off.
+	ResumeJump time.Duration
+
+	// Status, if set, returns the scale's sleep timeout and battery charge
+	// as of its last status report, or false before one has arrived. When
+	// the supervisor gives up on the link, it is used to tell whether the
+	// scale switched itself off; see Reason.
+	Status func() (timeout goscale.SleepTimeout, battery float64, ok bool)
+
+	// Disconnect tears the connection down. It is called, on the
+	// supervisor's goroutine, when the supervisor gives up on the
+	// connection, but not after Stop.
+	Disconnect func()
+}
+
+// Supervisor supervises one connection. It is safe for concurrent use.
+type Supervisor struct {
+	cfg     Config
+	ctx     context.Context
+	cancel  context.CancelFunc
+	unwatch func()
+
+	mu           sync.Mutex
+	state        State
+	err          error
+	lastNotified time.Time
+	lastStall    time.Time
+	lastCheck    time.Time
+	lastActive   time.Time
+	lastWeight   float64
+	weighed      bool
+	reason       goscale.DisconnectReason
+}
+
+// Start starts supervising a connection that has just been made.
+func Start(cfg Config) *Supervisor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.ResumeJump == 0 {
+		cfg.ResumeJump = DefaultResumeJump
+	}
+	now := time.Now()
+	s := &Supervisor{cfg: cfg, lastNotified: now, lastCheck: now, lastActive: now}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if cfg.Address != (bluetooth.Address{}) {
+		s.unwatch = WatchLink(cfg.Address, func() { s.fail(ErrLinkLost) })
+	}
+	go s.run()
+	return s
+}
+
+// Notified records that a notification arrived at at.
+func (s *Supervisor) Notified(at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastNotified = at
+	if s.state == Stalled {
+		s.state = Connected
+	}
+}
+
+// Weighed records a weight, in grams, reported at at, so the supervisor
+// knows when the scale was last in use.
+func (s *Supervisor) Weighed(at time.Time, grams float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.weighed || math.Abs(grams-s.lastWeight) >= goscale.ActivityGrams {
+		s.lastActive = at
+	}
+	s.lastWeight, s.weighed = grams, true
+}
+
+// Active records that the scale was used at at, e.g. a button was pressed.
+func (s *Supervisor) Active(at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastActive = at
+}
+
+// LastNotified returns when the last notification arrived, or when
+// supervision started if none has.
+func (s *Supervisor) LastNotified() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastNotified
+}
+
+// State returns the connection's state.
+func (s *Supervisor) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Err returns why the supervisor gave up on the connection, or nil if it
+// hasn't or was stopped.
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Reason returns why the connection ended, or DisconnectUnknown until it
+// has.
+func (s *Supervisor) Reason() goscale.DisconnectReason {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reason
+}
+
+// Done is closed once the supervisor has stopped.
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Stop stops supervising, without calling Config.Disconnect. Drivers call it
+// from their own Disconnect. It is safe to call more than once, and from
+// Config.Disconnect.
+func (s *Supervisor) Stop() {
+	s.stop(nil)
+}
+
+func (s *Supervisor) fail(err error) {
+	s.stop(err)
+}
+
+func (s *Supervisor) stop(err error) {
+	s.mu.Lock()
+	if s.state == Disconnected {
+		s.mu.Unlock()
+		return
+	}
+	s.state = Disconnected
+	s.err = err
+	if err == nil {
+		s.reason = goscale.DisconnectRequested
+	}
+	s.mu.Unlock()
+
+	if s.unwatch != nil {
+		s.unwatch()
+	}
+	s.cancel()
+}
+
+func (s *Supervisor) run() {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			// Disconnect runs here rather than on the Bluetooth event
+			// goroutine, as it calls back into the Bluetooth stack.
+			if err := s.Err(); err != nil {
+				s.giveUp(err)
+				s.cfg.Disconnect()
+			}
+			return
+		case <-s.cfg.LinkDone:
+			s.fail(ErrLinkLost)
+		case now := <-ticker.C:
+			s.check(now)
+		}
+	}
+}
+
+func (s *Supervisor) check(now time.Time) {
+	s.mu.Lock()
+	if s.state == Disconnected {
+		s.mu.Unlock()
+		return
+	}
+	if slept := s.sleptFor(now); s.cfg.ResumeJump > 0 && slept > s.cfg.ResumeJump {
+		s.mu.Unlock()
+		s.fail(fmt.Errorf("%w after about %s", ErrResumed, slept.Round(time.Second)))
+		return
+	}
+	silent := now.Sub(s.lastNotified)
+	if s.cfg.Silence > 0 && silent > s.cfg.Silence {
+		s.mu.Unlock()
+		s.fail(fmt.Errorf("%w for %s", ErrSilent, s.cfg.Silence))
+		return
+	}
+	var stall bool
+	if s.cfg.StallAfter > 0 && silent > s.cfg.StallAfter {
+		if s.state == Connected || now.Sub(s.lastStall) >= s.cfg.StallAfter {
+			s.state = Stalled
+			s.lastStall = now
+			stall = true
+		}
+	}
+	s.mu.Unlock()
+
+	if stall && s.cfg.OnStall != nil {
+		s.cfg.OnStall()
+	}
+	if s.cfg.Heartbeat != nil {
+		if err := s.cfg.Heartbeat(); err != nil {
+			s.fail(fmt.Errorf("heartbeat: %w", err))
+		}
+	}
+}
+
+// sleptFor returns how much further the wall clock has moved than the
+// monotonic clock since the last check, which is how long the host slept,
+// and records now as the last check. Stalls are timed on the monotonic
+// clock alone, so a wall clock stepped back, e.g. by NTP, counts for
+// nothing. s.mu must be held.
+func (s *Supervisor) sleptFor(now time.Time) time.Duration {
+	mono := now.Sub(s.lastCheck)
+	wall := now.Round(0).Sub(s.lastCheck.Round(0))
+	s.lastCheck = now
+	return wall - mono
+}
+
+// giveUp works out why the connection was given up on, before it is torn
+// down. A lost or silent link is put down to the scale switching itself
+// off if its last status suggests it did.
+func (s *Supervisor) giveUp(err error) {
+	reason := goscale.DisconnectLinkLost
+	switch {
+	case errors.Is(err, ErrResumed):
+		reason = goscale.DisconnectHostResumed
+	case errors.Is(err, ErrSilent):
+		reason = goscale.DisconnectSilent
+	}
+	if reason != goscale.DisconnectHostResumed && s.cfg.Status != nil {
+		if timeout, battery, ok := s.cfg.Status(); ok {
+			s.mu.Lock()
+			idle := s.lastNotified.Sub(s.lastActive)
+			s.mu.Unlock()
+			if shutdown, ok := goscale.InferShutdown(timeout, battery, idle); ok {
+				reason = shutdown
+			}
+		}
+	}
+	s.mu.Lock()
+	s.reason = reason
+	s.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+package connsup
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"tinygo.org/x/bluetooth"
+)
+
+// Dial connects to the device at address through goscale's active adapter,
+// giving up when ctx is done. ctx's deadline is passed on as the connection timeout
+// on stacks that support one. Otherwise the connection can't be interrupted,
+// so one that completes after Dial has given up is disconnected again in the
+// background rather than leaked.
+func Dial(ctx context.Context, address bluetooth.Address) (goscale.Peripheral, error) {
+	var params bluetooth.ConnectionParams
+	if deadline, ok := ctx.Deadline(); ok {
+		params.ConnectionTimeout = bluetooth.NewDuration(time.Until(deadline))
+	}
+
+	type result struct {
+		device goscale.Peripheral
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		device, err := goscale.ActiveAdapter().Connect(address, params)
+		done <- result{device, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.device, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.err == nil {
+				log.Printf("late connection to %s after giving up, disconnecting", address)
+				_ = r.device.Disconnect()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Await runs fn, returning its error, or ctx's if ctx is done first. fn is
+// left to finish in the background; callers abort it by tearing down the
+// connection it is using.
+func Await(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package connsup
+
+import (
+	"sync"
+
+	"github.com/mlsorensen/goscale"
+	"tinygo.org/x/bluetooth"
+)
+
+// The adapter has a single connect handler, so each driver setting its own
+// meant only the most recently connected scale heard about disconnects, and
+// it heard about every device's. Instead one handler is installed here and
+// dispatches by address. It is installed again if goscale's active adapter
+// changes.
+var (
+	linkMu       sync.Mutex
+	linkWatchers = make(map[string]map[*func()]struct{})
+	linkHooked   goscale.Adapter
+)
+
+// WatchLink calls lost, on the Bluetooth event goroutine, when the device at
+// address disconnects. It returns a function that stops watching. lost must
+// not block or call back into the Bluetooth stack.
+func WatchLink(address bluetooth.Address, lost func()) (cancel func()) {
+	key := address.String()
+	fn := &lost
+
+	linkMu.Lock()
+	if a := goscale.ActiveAdapter(); linkHooked != a {
+		a.SetConnectHandler(linkEvent)
+		linkHooked = a
+	}
+	if linkWatchers[key] == nil {
+		linkWatchers[key] = make(map[*func()]struct{})
+	}
+	linkWatchers[key][fn] = struct{}{}
+	linkMu.Unlock()
+
+	return func() {
+		linkMu.Lock()
+		defer linkMu.Unlock()
+		delete(linkWatchers[key], fn)
+		if len(linkWatchers[key]) == 0 {
+			delete(linkWatchers, key)
+		}
+	}
+}
+
+func linkEvent(address bluetooth.Address, connected bool) {
+	if connected {
+		return
+	}
+	linkMu.Lock()
+	var lost []func()
+	for fn := range linkWatchers[address.String()] {
+		lost = append(lost, *fn)
+	}
+	linkMu.Unlock()
+	for _, fn := range lost {
+		fn()
+	}
+}
@@ -0,0 +1,211 @@
+package goscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConnectTimeout bounds a single device's connection attempt when a
+// Manager has no ConnectTimeout configured.
+const DefaultConnectTimeout = 15 * time.Second
+
+// ConnectedScale pairs a live Scale with the device it was created for and
+// the weight update channel returned by its Connect. To share the updates
+// between several consumers, use Subscribe rather than reading Updates.
+type ConnectedScale struct {
+	Device  *FoundDevice
+	Scale   Scale
+	Updates <-chan WeightUpdate
+
+	fanMu sync.Mutex
+	fan   *fanout // Started by the first Subscribe
+}
+
+// ConnectError records why a single device failed to connect.
+type ConnectError struct {
+	Device *FoundDevice
+	Err    error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Device.Name, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// MultiConnectError aggregates the per-device failures of a ConnectAll call.
+// It unwraps to the individual *ConnectError values, so errors.Is and
+// errors.As work against any of the underlying causes.
+type MultiConnectError struct {
+	Errors []*ConnectError
+}
+
+func (e *MultiConnectError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("failed to connect %d scale(s): %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (e *MultiConnectError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Manager keeps track of several connected scales at once.
+type Manager struct {
+	// ConnectTimeout bounds each individual connection attempt made by
+	// ConnectAll. Zero means DefaultConnectTimeout.
+	ConnectTimeout time.Duration
+
+	mu     sync.Mutex
+	scales map[string]*ConnectedScale
+}
+
+// NewManager returns an empty Manager.
+func NewManager() *Manager {
+	return &Manager{
+		scales: make(map[string]*ConnectedScale),
+	}
+}
+
+// ConnectAll connects to every device concurrently, each attempt bounded by
+// the Manager's ConnectTimeout and by ctx. It returns the scales that
+// connected successfully, in the order of devices, and a *MultiConnectError
+// describing any that did not. Devices already connected through this
+// Manager are returned as-is without reconnecting, and a device listed more
+// than once, by address, is connected and returned once.
+func (m *Manager) ConnectAll(ctx context.Context, devices []*FoundDevice) ([]*ConnectedScale, error) {
+	timeout := m.ConnectTimeout
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
+	}
+
+	results := make([]*ConnectedScale, len(devices))
+	errs := make([]*ConnectError, len(devices))
+
+	seen := make(map[string]bool, len(devices))
+	var wg sync.WaitGroup
+	for i, dev := range devices {
+		if seen[dev.ID()] {
+			continue
+		}
+		seen[dev.ID()] = true
+		if existing := m.Get(dev.ID()); existing != nil {
+			results[i] = existing
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, dev *FoundDevice) {
+			defer wg.Done()
+			cs, err := connectWithTimeout(ctx, dev, timeout)
+			if err != nil {
+				errs[i] = &ConnectError{Device: dev, Err: err}
+				return
+			}
+			results[i] = cs
+		}(i, dev)
+	}
+	wg.Wait()
+
+	connected := make([]*ConnectedScale, 0, len(devices))
+	multiErr := &MultiConnectError{}
+	m.mu.Lock()
+	for i := range devices {
+		if errs[i] != nil {
+			multiErr.Errors = append(multiErr.Errors, errs[i])
+			continue
+		}
+		if results[i] == nil {
+			continue // A duplicate of an earlier device
+		}
+		m.scales[results[i].Device.ID()] = results[i]
+		connected = append(connected, results[i])
+	}
+	m.mu.Unlock()
+
+	if len(multiErr.Errors) > 0 {
+		return connected, multiErr
+	}
+	return connected, nil
+}
+
+// Get returns the connected scale with the given device ID, or nil.
+func (m *Manager) Get(id string) *ConnectedScale {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.scales[id]
+}
+
+// Scales returns all scales currently tracked by the Manager.
+func (m *Manager) Scales() []*ConnectedScale {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scales := make([]*ConnectedScale, 0, len(m.scales))
+	for _, cs := range m.scales {
+		scales = append(scales, cs)
+	}
+	return scales
+}
+
+// Disconnect disconnects the scale with the given device ID and stops
+// tracking it.
+func (m *Manager) Disconnect(id string) error {
+	m.mu.Lock()
+	cs, ok := m.scales[id]
+	delete(m.scales, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no connected scale with id '%s'", id)
+	}
+	return cs.Scale.Disconnect()
+}
+
+// DisconnectAll disconnects every tracked scale, returning the joined errors
+// of any that failed to disconnect cleanly.
+func (m *Manager) DisconnectAll() error {
+	m.mu.Lock()
+	scales := m.scales
+	m.scales = make(map[string]*ConnectedScale)
+	m.mu.Unlock()
+
+	var errs []error
+	for _, cs := range scales {
+		if err := cs.Scale.Disconnect(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cs.Device.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// connectWithTimeout creates the scale for dev and connects it, giving up
+// after timeout or when ctx is done.
+func connectWithTimeout(ctx context.Context, dev *FoundDevice, timeout time.Duration) (*ConnectedScale, error) {
+	s, err := NewScaleForDevice(dev)
+	if err != nil {
+		return nil, err
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	updates, err := ConnectContext(cctx, s)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, fmt.Errorf("connect timed out after %s: %w", timeout, err)
+		}
+		return nil, err
+	}
+	return &ConnectedScale{Device: dev, Scale: s, Updates: updates}, nil
+}
@@ -0,0 +1,64 @@
+package goscale_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/scales/mock"
+)
+
+// hungScale is a scale whose connection attempts never finish.
+type hungScale struct {
+	goscale.Scale
+}
+
+func (hungScale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// register registers factory under prefix for the length of a test.
+func register(t *testing.T, prefix string, factory goscale.Factory) {
+	t.Helper()
+	goscale.Register(prefix, factory)
+	t.Cleanup(func() { goscale.Unregister(prefix) })
+}
+
+func TestConnectAllTimeout(t *testing.T) {
+	register(t, "HUNG", func(d *goscale.FoundDevice) goscale.Scale { return hungScale{mock.New(d)} })
+	m := goscale.NewManager()
+	m.ConnectTimeout = 10 * time.Millisecond
+
+	connected, err := m.ConnectAll(context.Background(), []*goscale.FoundDevice{{Name: "HUNG 1"}})
+	var multi *goscale.MultiConnectError
+	if len(connected) != 0 || !errors.As(err, &multi) || len(multi.Errors) != 1 {
+		t.Fatalf("ConnectAll = %v, %v, want one failure", connected, err)
+	}
+	if !errors.Is(multi.Errors[0], context.DeadlineExceeded) {
+		t.Errorf("timeout %v isn't context.DeadlineExceeded", multi.Errors[0])
+	}
+}
+
+func TestConnectAllDuplicates(t *testing.T) {
+	var created atomic.Int32
+	register(t, "COUNTED", func(d *goscale.FoundDevice) goscale.Scale {
+		created.Add(1)
+		return mock.New(d)
+	})
+	m := goscale.NewManager()
+	t.Cleanup(func() { _ = m.DisconnectAll() })
+
+	dev := &goscale.FoundDevice{Name: "COUNTED 1"}
+	again := *dev
+	connected, err := m.ConnectAll(context.Background(), []*goscale.FoundDevice{dev, &again, dev})
+	if err != nil {
+		t.Fatalf("ConnectAll: %v", err)
+	}
+	if len(connected) != 1 || created.Load() != 1 {
+		t.Errorf("connected %d scales from %d instances, want 1 from 1", len(connected), created.Load())
+	}
+}
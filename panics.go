@@ -0,0 +1,80 @@
+package goscale
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// NotificationPanicError reports a panic recovered while a driver handled a
+// Bluetooth notification, typically a decoder indexing past the end of a
+// malformed frame. It is sent as the Error of a WeightUpdate.
+type NotificationPanicError struct {
+	Device string
+	Value  any    // What the handler panicked with
+	Frame  []byte // The notification being handled
+	Stack  []byte
+}
+
+func (e *NotificationPanicError) Error() string {
+	return fmt.Sprintf("%s: panic handling notification % X: %v", e.Device, e.Frame, e.Value)
+}
+
+var (
+	notificationPanics atomic.Uint64
+
+	panicsMu       sync.Mutex
+	panicsByDevice = map[string]uint64{}
+)
+
+// NotificationPanics returns how many notification handler panics have been
+// recovered since the program started, in total and by device name.
+func NotificationPanics() (total uint64, byDevice map[string]uint64) {
+	panicsMu.Lock()
+	defer panicsMu.Unlock()
+
+	byDevice = make(map[string]uint64, len(panicsByDevice))
+	for device, n := range panicsByDevice {
+		byDevice[device] = n
+	}
+	return notificationPanics.Load(), byDevice
+}
+
+// RecoverNotifications wraps a driver's notification handler so that a
+// panic while handling a frame can't crash the application. A recovered
+// panic is logged, counted (see NotificationPanics), and passed to report
+// as a *NotificationPanicError, e.g. for the driver to send on its update
+// channel. report may be nil, and a panic within it is ignored too.
+// Duplicate frames are dropped before reaching handler while
+// SetNotificationDedup has that on.
+func RecoverNotifications(device string, handler func([]byte), report func(error)) func([]byte) {
+	handler = dedupNotifications(device, handler)
+	return func(buf []byte) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			err := &NotificationPanicError{
+				Device: device,
+				Value:  v,
+				Frame:  append([]byte(nil), buf...),
+				Stack:  debug.Stack(),
+			}
+			log.Printf("%v\n%s", err, err.Stack)
+
+			notificationPanics.Add(1)
+			panicsMu.Lock()
+			panicsByDevice[device]++
+			panicsMu.Unlock()
+
+			if report != nil {
+				defer func() { _ = recover() }()
+				report(err)
+			}
+		}()
+		handler(buf)
+	}
+}
@@ -0,0 +1,170 @@
+// Package alerts routes notable events, such as a brew reaching its target
+// or a scale's battery running low, to the people who need to know about
+// them: desktop notifications, webhooks and email. It is meant for headless
+// setups with nobody watching a screen.
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// Kind is what an alert is about.
+type Kind string
+
+const (
+	KindTargetReached Kind = "target_reached"
+	KindLowBattery    Kind = "low_battery"
+	KindDisconnect    Kind = "disconnect"
+)
+
+// Alert is a notable event.
+type Alert struct {
+	Kind    Kind      `json:"kind"`
+	Device  string    `json:"device,omitempty"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+// TargetReached returns the alert for a brew on device reaching its target
+// weight.
+func TargetReached(device string, target, weight float64) Alert {
+	return Alert{
+		Kind:    KindTargetReached,
+		Device:  device,
+		Title:   "Target reached",
+		Message: fmt.Sprintf("%s reached %.1f g (target %.1f g)", device, weight, target),
+		At:      time.Now(),
+	}
+}
+
+// LowBattery returns the alert for a goscale.LowBatteryEvent.
+func LowBattery(ev goscale.LowBatteryEvent) Alert {
+	return Alert{
+		Kind:    KindLowBattery,
+		Device:  ev.Device,
+		Title:   "Scale battery low",
+		Message: fmt.Sprintf("%s battery is at %.0f%%", ev.Device, ev.Percent),
+		At:      ev.Timestamp,
+	}
+}
+
+// Disconnected returns the alert for device disconnecting unexpectedly.
+func Disconnected(device string) Alert {
+	return Alert{
+		Kind:    KindDisconnect,
+		Device:  device,
+		Title:   "Scale disconnected",
+		Message: fmt.Sprintf("%s has disconnected", device),
+		At:      time.Now(),
+	}
+}
+
+// Sink delivers alerts somewhere.
+type Sink interface {
+	Send(ctx context.Context, a Alert) error
+}
+
+type route struct {
+	sink  Sink
+	kinds []Kind
+}
+
+// Router fans alerts out to sinks. It is safe for concurrent use.
+type Router struct {
+	// Timeout bounds each delivery. Zero means DefaultTimeout.
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	routes []route
+}
+
+// DefaultTimeout is used by a Router with no Timeout set.
+const DefaultTimeout = 10 * time.Second
+
+// NewRouter returns a Router with no sinks.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Add sends alerts of the given kinds to sink; with no kinds, it is sent
+// every alert.
+func (r *Router) Add(sink Sink, kinds ...Kind) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, route{sink: sink, kinds: kinds})
+}
+
+// Send delivers a to every sink routed its kind, concurrently, and returns
+// the errors of any that failed, joined.
+func (r *Router) Send(ctx context.Context, a Alert) error {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if a.At.IsZero() {
+		a.At = time.Now()
+	}
+
+	r.mu.RLock()
+	var sinks []Sink
+	for _, rt := range r.routes {
+		if len(rt.kinds) == 0 || slices.Contains(rt.kinds, a.Kind) {
+			sinks = append(sinks, rt.sink)
+		}
+	}
+	r.mu.RUnlock()
+
+	errs := make([]error, len(sinks))
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			errs[i] = sink.Send(ctx, a)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Run sends every alert from in until it closes or ctx is done. Delivery
+// failures are logged.
+func (r *Router) Run(ctx context.Context, in <-chan Alert) {
+	for {
+		select {
+		case a, ok := <-in:
+			if !ok {
+				return
+			}
+			if err := r.Send(ctx, a); err != nil {
+				log.Printf("Error delivering %s alert: %v", a.Kind, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FromLowBattery converts low battery events, e.g. from goscale.WatchBattery,
+// to alerts. The returned channel is closed when in closes.
+func FromLowBattery(in <-chan goscale.LowBatteryEvent) <-chan Alert {
+	out := make(chan Alert, cap(in))
+	go func() {
+		defer close(out)
+		for ev := range in {
+			out <- LowBattery(ev)
+		}
+	}()
+	return out
+}
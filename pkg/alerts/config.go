@@ -0,0 +1,84 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strings"
+)
+
+// Config describes a Router's sinks, so a daemon can set up alerting from
+// its configuration file.
+type Config struct {
+	Sinks []SinkConfig `json:"sinks" yaml:"sinks"`
+}
+
+// SinkConfig describes one sink. Type is "desktop", "webhook" or "smtp";
+// only the fields for that type are used.
+type SinkConfig struct {
+	Type  string `json:"type" yaml:"type"`
+	Kinds []Kind `json:"kinds,omitempty" yaml:"kinds,omitempty"` // Empty for every kind
+
+	// Webhook
+	URL     string            `json:"url,omitempty" yaml:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+	// SMTP
+	Addr     string   `json:"addr,omitempty" yaml:"addr,omitempty"`
+	Username string   `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string   `json:"password,omitempty" yaml:"password,omitempty"`
+	From     string   `json:"from,omitempty" yaml:"from,omitempty"`
+	To       []string `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// ParseConfig reads a JSON Config.
+func ParseConfig(r io.Reader) (*Config, error) {
+	var c Config
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("failed to decode alerts config: %w", err)
+	}
+	return &c, nil
+}
+
+// NewSink builds the sink c describes.
+func NewSink(c SinkConfig) (Sink, error) {
+	switch c.Type {
+	case "desktop":
+		return DesktopSink{}, nil
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("webhook sink needs a url")
+		}
+		header := make(http.Header)
+		for k, v := range c.Headers {
+			header.Set(k, v)
+		}
+		return &WebhookSink{URL: c.URL, Header: header}, nil
+	case "smtp":
+		if c.Addr == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("smtp sink needs addr, from and to")
+		}
+		s := &SMTPSink{Addr: c.Addr, From: c.From, To: c.To}
+		if c.Username != "" {
+			host, _, _ := strings.Cut(c.Addr, ":")
+			s.Auth = smtp.PlainAuth("", c.Username, c.Password, host)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", c.Type)
+}
+
+// NewRouterFromConfig returns a Router with the sinks c describes.
+func NewRouterFromConfig(c *Config) (*Router, error) {
+	r := NewRouter()
+	for i, sc := range c.Sinks {
+		sink, err := NewSink(sc)
+		if err != nil {
+			return nil, fmt.Errorf("sink %d: %w", i, err)
+		}
+		r.Add(sink, sc.Kinds...)
+	}
+	return r, nil
+}
@@ -0,0 +1,129 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, a Alert) error
+
+func (f SinkFunc) Send(ctx context.Context, a Alert) error {
+	return f(ctx, a)
+}
+
+// DesktopSink shows alerts as desktop notifications, using notify-send on
+// Linux, osascript on macOS and PowerShell on Windows.
+type DesktopSink struct{}
+
+func (DesktopSink) Send(ctx context.Context, a Alert) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.CommandContext(ctx, "notify-send", a.Title, a.Message)
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(a.Message), appleScriptString(a.Title))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	case "windows":
+		script := fmt.Sprintf(`[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).InnerText = %s
+$x.Item(1).InnerText = %s
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('goscale').Show([Windows.UI.Notifications.ToastNotification]::new($t))`,
+			powerShellString(a.Title), powerShellString(a.Message))
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("desktop notification failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// WebhookSink POSTs each alert as JSON to URL.
+type WebhookSink struct {
+	URL    string
+	Header http.Header  // Extra headers, e.g. for authentication
+	Client *http.Client // Nil means http.DefaultClient
+}
+
+func (w *WebhookSink) Send(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPSink emails each alert.
+type SMTPSink struct {
+	Addr string    // Server host:port
+	Auth smtp.Auth // Nil for none
+	From string
+	To   []string
+}
+
+func (s *SMTPSink) Send(ctx context.Context, a Alert) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", a.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.At.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n", a.Message)
+
+	// net/smtp has no context support, so give up waiting rather than
+	// cancelling the send.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg.Bytes())
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("email failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,312 @@
+package bleproxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/internal/connsup"
+	"tinygo.org/x/bluetooth"
+)
+
+// DefaultScanTimeout is used for scans that don't give a timeout.
+const DefaultScanTimeout = 10 * time.Second
+
+// Agent serves the proxy protocol on behalf of goscale's active adapter,
+// normally the host's, goscale.BTAdapter. Each client connection has its own devices,
+// which are disconnected when the client goes away. Scans only report
+// devices matching a registered driver, so import the drivers the clients
+// will use.
+type Agent struct {
+	mu     sync.Mutex
+	nextID uint64
+}
+
+// NewAgent returns an Agent.
+func NewAgent() *Agent {
+	return &Agent{}
+}
+
+// ListenAndServe listens on addr and serves clients until an error occurs.
+func (a *Agent) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return a.Serve(l)
+}
+
+// Serve serves clients connecting to l until l is closed.
+func (a *Agent) Serve(l net.Listener) error {
+	if err := goscale.TryEnableAdapter(); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go a.serveConn(conn)
+	}
+}
+
+// session is one client connection.
+type session struct {
+	agent *Agent
+	conn  net.Conn
+
+	wmu sync.Mutex // Guards enc, written to from notification callbacks
+	enc *json.Encoder
+
+	mu      sync.Mutex
+	devices map[uint64]*agentDevice
+}
+
+type agentDevice struct {
+	device  goscale.Peripheral
+	chars   map[string]goscale.Characteristic
+	unwatch func()
+}
+
+func (a *Agent) serveConn(conn net.Conn) {
+	s := &session{
+		agent:   a,
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		devices: make(map[uint64]*agentDevice),
+	}
+	log.Printf("bleproxy: client %s connected", conn.RemoteAddr())
+	defer func() {
+		s.closeAll()
+		_ = conn.Close()
+		log.Printf("bleproxy: client %s disconnected", conn.RemoteAddr())
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			log.Printf("bleproxy: bad request from %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+		resp := s.handle(req)
+		resp.ID = req.ID
+		if err := s.send(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *session) send(m message) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	return s.enc.Encode(m)
+}
+
+// Requests are handled one at a time, as Bluetooth stacks don't cope well
+// with concurrent GATT operations anyway.
+func (s *session) handle(req request) message {
+	var m message
+	var err error
+	switch req.Op {
+	case opScan:
+		m.Devices, err = s.scan(req)
+	case opConnect:
+		m.Device, err = s.connect(req)
+	case opDiscover:
+		m.Chars, err = s.discover(req)
+	case opWrite:
+		m.Written, err = s.write(req)
+	case opNotify:
+		err = s.notify(req)
+	case opDisconnect:
+		err = s.disconnect(req.Device)
+	default:
+		err = fmt.Errorf("unknown operation %q", req.Op)
+	}
+	if err != nil {
+		m.Error = err.Error()
+	}
+	return m
+}
+
+func (s *session) scan(req request) ([]goscale.ScanRecord, error) {
+	timeout := DefaultScanTimeout
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		timeout = d
+	}
+	devices, err := goscale.Scan(timeout)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]goscale.ScanRecord, 0, len(devices))
+	for _, d := range devices {
+		records = append(records, goscale.NewScanRecord(d))
+	}
+	return records, nil
+}
+
+func (s *session) connect(req request) (uint64, error) {
+	var addr bluetooth.Address
+	addr.Set(req.Address)
+	if addr == (bluetooth.Address{}) {
+		return 0, fmt.Errorf("invalid address %q", req.Address)
+	}
+	device, err := goscale.ActiveAdapter().Connect(addr, bluetooth.ConnectionParams{})
+	if err != nil {
+		return 0, err
+	}
+
+	a := s.agent
+	a.mu.Lock()
+	a.nextID++
+	id := a.nextID
+	a.mu.Unlock()
+
+	s.mu.Lock()
+	s.devices[id] = &agentDevice{
+		device: device,
+		chars:  make(map[string]goscale.Characteristic),
+		// Tell the client off the Bluetooth event goroutine, as the send
+		// can block on the network.
+		unwatch: connsup.WatchLink(addr, func() { go s.lost(id) }),
+	}
+	s.mu.Unlock()
+	return id, nil
+}
+
+func (s *session) device(id uint64) (*agentDevice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[id]
+	if !ok {
+		return nil, fmt.Errorf("no device %d", id)
+	}
+	return d, nil
+}
+
+func (s *session) discover(req request) ([]string, error) {
+	d, err := s.device(req.Device)
+	if err != nil {
+		return nil, err
+	}
+	service, err := bluetooth.ParseUUID(req.Service)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service UUID: %w", err)
+	}
+	want := make([]bluetooth.UUID, 0, len(req.Chars))
+	for _, c := range req.Chars {
+		u, err := bluetooth.ParseUUID(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid characteristic UUID: %w", err)
+		}
+		want = append(want, u)
+	}
+
+	chars, err := goscale.DiscoverChars(d.device, service, want...)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := make([]string, 0, len(chars))
+	for _, c := range chars {
+		d.chars[c.UUID().String()] = c
+		found = append(found, c.UUID().String())
+	}
+	return found, nil
+}
+
+func (s *session) char(req request) (goscale.Characteristic, error) {
+	d, err := s.device(req.Device)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := d.chars[req.Char]
+	if !ok {
+		return nil, fmt.Errorf("characteristic %s not discovered", req.Char)
+	}
+	return c, nil
+}
+
+func (s *session) write(req request) (int, error) {
+	c, err := s.char(req)
+	if err != nil {
+		return 0, err
+	}
+	return c.WriteWithoutResponse(req.Data)
+}
+
+func (s *session) notify(req request) error {
+	c, err := s.char(req)
+	if err != nil {
+		return err
+	}
+	id, char := req.Device, req.Char
+	return c.EnableNotifications(func(buf []byte) {
+		_ = s.send(message{
+			Event:  eventNotification,
+			Device: id,
+			Char:   char,
+			Data:   append([]byte(nil), buf...),
+		})
+	})
+}
+
+func (s *session) disconnect(id uint64) error {
+	s.mu.Lock()
+	d, ok := s.devices[id]
+	delete(s.devices, id)
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no device %d", id)
+	}
+	d.unwatch()
+	return d.device.Disconnect()
+}
+
+// lost tells the client device id disconnected by itself.
+func (s *session) lost(id uint64) {
+	s.mu.Lock()
+	d, ok := s.devices[id]
+	delete(s.devices, id)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	d.unwatch()
+	_ = s.send(message{Event: eventDisconnected, Device: id})
+}
+
+// closeAll disconnects every device the client left connected.
+func (s *session) closeAll() {
+	s.mu.Lock()
+	ids := make([]uint64, 0, len(s.devices))
+	for id := range s.devices {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, id := range ids {
+		errs = append(errs, s.disconnect(id))
+	}
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("bleproxy: disconnecting devices: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package bleproxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"tinygo.org/x/bluetooth"
+)
+
+// ErrClosed is returned for operations on a closed Client, or one whose
+// connection to the agent was lost.
+var ErrClosed = errors.New("bleproxy: connection closed")
+
+// Client is a connection to an Agent. It is safe for concurrent use.
+type Client struct {
+	conn net.Conn
+
+	wmu sync.Mutex
+	enc *json.Encoder
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan message
+	devices map[uint64]*Device
+	err     error // Set once the connection is gone
+}
+
+// Dial connects to the agent at addr, e.g. "raspberrypi.local:7331".
+func Dial(addr string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach BLE proxy agent: %w", err)
+	}
+	c := &Client{
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		pending: make(map[uint64]chan message),
+		devices: make(map[uint64]*Device),
+	}
+	go c.read()
+	return c, nil
+}
+
+// Close closes the connection to the agent, which disconnects every device
+// connected through it.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) read() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var m message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			continue
+		}
+		if m.ID != 0 {
+			c.mu.Lock()
+			ch := c.pending[m.ID]
+			delete(c.pending, m.ID)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- m
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		d := c.devices[m.Device]
+		if m.Event == eventDisconnected {
+			delete(c.devices, m.Device)
+		}
+		c.mu.Unlock()
+		if d == nil {
+			continue
+		}
+		switch m.Event {
+		case eventNotification:
+			d.notification(m.Char, m.Data)
+		case eventDisconnected:
+			d.lost()
+		}
+	}
+
+	// The agent has gone: fail everything waiting on it.
+	c.mu.Lock()
+	c.err = ErrClosed
+	pending, devices := c.pending, c.devices
+	c.pending, c.devices = nil, nil
+	c.mu.Unlock()
+	for _, ch := range pending {
+		ch <- message{Error: ErrClosed.Error()}
+	}
+	for _, d := range devices {
+		d.lost()
+	}
+}
+
+// call sends req and waits for its response.
+func (c *Client) call(req request) (message, error) {
+	ch := make(chan message, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return message{}, c.err
+	}
+	c.nextID++
+	req.ID = c.nextID
+	c.pending[req.ID] = ch
+	c.mu.Unlock()
+
+	c.wmu.Lock()
+	err := c.enc.Encode(req)
+	c.wmu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, req.ID)
+		c.mu.Unlock()
+		return message{}, fmt.Errorf("bleproxy: %w", err)
+	}
+
+	m := <-ch
+	if m.Error != "" {
+		return m, fmt.Errorf("bleproxy %s: %s", req.Op, m.Error)
+	}
+	return m, nil
+}
+
+// Scan has the agent scan for timeout and returns the registered scales it
+// found, as goscale.Scan would.
+func (c *Client) Scan(timeout time.Duration) ([]goscale.FoundDevice, error) {
+	m, err := c.call(request{Op: opScan, Timeout: timeout.String()})
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]goscale.FoundDevice, 0, len(m.Devices))
+	for _, rec := range m.Devices {
+		d, err := rec.FoundDevice()
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// Connect has the agent connect to the device at address.
+func (c *Client) Connect(address bluetooth.Address) (*Device, error) {
+	m, err := c.call(request{Op: opConnect, Address: address.String()})
+	if err != nil {
+		return nil, err
+	}
+	d := &Device{
+		client: c,
+		id:     m.Device,
+		notify: make(map[string]func([]byte)),
+		done:   make(chan struct{}),
+	}
+	c.mu.Lock()
+	if c.devices != nil {
+		c.devices[d.id] = d
+	}
+	c.mu.Unlock()
+	return d, nil
+}
+
+// Device is a device connected through the agent.
+type Device struct {
+	client *Client
+	id     uint64
+
+	mu     sync.Mutex
+	notify map[string]func([]byte)
+	done   chan struct{}
+	closed bool
+}
+
+// DiscoverCharacteristics finds the given characteristics of service.
+func (d *Device) DiscoverCharacteristics(service bluetooth.UUID, chars []bluetooth.UUID) ([]*Characteristic, error) {
+	want := make([]string, len(chars))
+	for i, u := range chars {
+		want[i] = u.String()
+	}
+	m, err := d.client.call(request{Op: opDiscover, Device: d.id, Service: service.String(), Chars: want})
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*Characteristic, 0, len(m.Chars))
+	for _, s := range m.Chars {
+		u, err := bluetooth.ParseUUID(s)
+		if err != nil {
+			return nil, fmt.Errorf("bleproxy: invalid characteristic UUID from agent: %w", err)
+		}
+		found = append(found, &Characteristic{device: d, uuid: u})
+	}
+	return found, nil
+}
+
+// Disconnect has the agent disconnect the device.
+func (d *Device) Disconnect() error {
+	_, err := d.client.call(request{Op: opDisconnect, Device: d.id})
+	d.client.mu.Lock()
+	delete(d.client.devices, d.id)
+	d.client.mu.Unlock()
+	d.lost()
+	return err
+}
+
+// Done is closed when the device disconnects, whether asked to or not, or
+// the connection to the agent is lost.
+func (d *Device) Done() <-chan struct{} {
+	return d.done
+}
+
+func (d *Device) lost() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.closed {
+		d.closed = true
+		close(d.done)
+	}
+}
+
+func (d *Device) notification(char string, data []byte) {
+	d.mu.Lock()
+	fn := d.notify[char]
+	d.mu.Unlock()
+	if fn != nil {
+		fn(data)
+	}
+}
+
+// Characteristic is a characteristic of a Device connected through the
+// agent. It has the same methods as a bluetooth.DeviceCharacteristic, so
+// drivers can use either.
+type Characteristic struct {
+	device *Device
+	uuid   bluetooth.UUID
+}
+
+// UUID returns the characteristic's UUID.
+func (c *Characteristic) UUID() bluetooth.UUID {
+	return c.uuid
+}
+
+// WriteWithoutResponse writes p to the characteristic.
+func (c *Characteristic) WriteWithoutResponse(p []byte) (int, error) {
+	m, err := c.device.client.call(request{Op: opWrite, Device: c.device.id, Char: c.uuid.String(), Data: p})
+	return m.Written, err
+}
+
+// EnableNotifications calls callback with every notification on the
+// characteristic. The callback runs on the client's read goroutine, so it
+// must not wait on a call through the same client.
+func (c *Characteristic) EnableNotifications(callback func(buf []byte)) error {
+	c.device.mu.Lock()
+	c.device.notify[c.uuid.String()] = callback
+	c.device.mu.Unlock()
+
+	_, err := c.device.client.call(request{Op: opNotify, Device: c.device.id, Char: c.uuid.String()})
+	return err
+}
@@ -0,0 +1,61 @@
+// Package bleproxy tunnels Bluetooth LE GATT operations over TCP, so code
+// running somewhere without a Bluetooth adapter, such as a dev container or
+// a VM without passthrough, can drive real scales through an Agent running
+// on a host that has one, e.g. a Raspberry Pi next to the espresso machine.
+//
+// The protocol is newline-delimited JSON. The client sends requests, each
+// with an ID, and the agent answers each with a response carrying the same
+// ID. The agent also sends events, with no ID, for notifications and
+// disconnects. There is no authentication or encryption: only run an agent
+// on a trusted network, for development.
+package bleproxy
+
+import (
+	"github.com/mlsorensen/goscale"
+)
+
+// DefaultAddr is the address the agent listens on by default.
+const DefaultAddr = ":7331"
+
+// Request operations.
+const (
+	opScan       = "scan"
+	opConnect    = "connect"
+	opDiscover   = "discover"
+	opWrite      = "write"
+	opNotify     = "notify"
+	opDisconnect = "disconnect"
+)
+
+// Event kinds.
+const (
+	eventNotification = "notification"
+	eventDisconnected = "disconnected"
+)
+
+type request struct {
+	ID      uint64   `json:"id"`
+	Op      string   `json:"op"`
+	Timeout string   `json:"timeout,omitempty"` // scan
+	Address string   `json:"address,omitempty"` // connect
+	Device  uint64   `json:"device,omitempty"`  // discover, write, notify, disconnect
+	Service string   `json:"service,omitempty"` // discover
+	Chars   []string `json:"chars,omitempty"`   // discover
+	Char    string   `json:"char,omitempty"`    // write, notify
+	Data    []byte   `json:"data,omitempty"`    // write
+}
+
+// message is a response, if ID is set, or an event.
+type message struct {
+	ID    uint64 `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+
+	Devices []goscale.ScanRecord `json:"devices,omitempty"` // scan
+	Device  uint64               `json:"device,omitempty"`  // connect; events
+	Chars   []string             `json:"chars,omitempty"`   // discover
+	Written int                  `json:"written,omitempty"` // write
+
+	Event string `json:"event,omitempty"`
+	Char  string `json:"char,omitempty"` // notification
+	Data  []byte `json:"data,omitempty"` // notification
+}
@@ -0,0 +1,219 @@
+package brew
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/drift"
+)
+
+// BatchConfig tunes a BatchMonitor for slow processes such as cold brew,
+// which run for hours and change by grams per hour rather than per second.
+type BatchConfig struct {
+	// Interval is how much time each reading averages over. Averaging
+	// smooths out vibration and cuts the data rate to something sensible
+	// for a process that takes all night.
+	Interval time.Duration
+
+	// RateWindow is how far back the rate estimate looks.
+	RateWindow time.Duration
+
+	// Thresholds are changes in grams from the first reading to alert on,
+	// e.g. 800 for "the vessel has gained 800 g" or -50 for "50 g has
+	// evaporated". Each is reported once, by the reading that crosses it.
+	Thresholds []float64
+
+	// Drift, if set, is subtracted from every update before averaging. A
+	// budget load cell can drift by more over 12 hours than the process
+	// itself changes.
+	Drift *drift.Model
+}
+
+// DefaultBatchConfig averages over 10 seconds and estimates the rate over
+// the last 30 minutes.
+var DefaultBatchConfig = BatchConfig{
+	Interval:   10 * time.Second,
+	RateWindow: 30 * time.Minute,
+}
+
+// BatchReading is one averaged reading from a BatchMonitor.
+type BatchReading struct {
+	At     time.Time // Timestamp of the last update averaged
+	Weight float64   // Mean grams over the interval
+	Change float64   // Grams gained (or, if negative, lost) since the first reading
+
+	// Rate is the trend in grams per hour over the rate window; negative
+	// while losing weight, e.g. to evaporation. HasRate is false until
+	// there are enough readings to tell.
+	Rate    float64
+	HasRate bool
+
+	// Crossed lists the thresholds this reading crossed.
+	Crossed []float64
+}
+
+type batchPoint struct {
+	at     time.Time
+	weight float64
+}
+
+// BatchMonitor turns a weight update stream into averaged readings with a
+// rate estimate and threshold alerts. It is not safe for concurrent use.
+type BatchMonitor struct {
+	cfg         BatchConfig
+	compensator *drift.Compensator
+
+	sum      float64
+	count    int
+	bucketAt time.Time
+	last     time.Time
+
+	base    float64
+	hasBase bool
+	crossed []bool
+	history []batchPoint
+}
+
+// NewBatchMonitor returns a BatchMonitor using cfg.
+func NewBatchMonitor(cfg BatchConfig) *BatchMonitor {
+	m := &BatchMonitor{cfg: cfg, crossed: make([]bool, len(cfg.Thresholds))}
+	if cfg.Drift != nil {
+		m.compensator = drift.NewCompensator(*cfg.Drift)
+	}
+	return m
+}
+
+// Observe adds an update to the current interval. It returns the reading
+// for the interval, and true, once an update arrives past its end.
+func (m *BatchMonitor) Observe(u goscale.WeightUpdate) (BatchReading, bool) {
+	if u.Error != nil {
+		return BatchReading{}, false
+	}
+	if m.compensator != nil {
+		u = m.compensator.Apply(u)
+	}
+	at := u.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	var r BatchReading
+	var ok bool
+	if m.count > 0 && at.Sub(m.bucketAt) >= m.cfg.Interval {
+		r, ok = m.Flush()
+	}
+	if m.count == 0 {
+		m.bucketAt = at
+	}
+	m.sum += u.Value
+	m.count++
+	m.last = at
+	return r, ok
+}
+
+// Flush ends the current interval early and returns its reading, e.g. when
+// the stream ends. It returns false if the interval is empty.
+func (m *BatchMonitor) Flush() (BatchReading, bool) {
+	if m.count == 0 {
+		return BatchReading{}, false
+	}
+	r := BatchReading{At: m.last, Weight: m.sum / float64(m.count)}
+	m.sum, m.count = 0, 0
+
+	if !m.hasBase {
+		m.base = r.Weight
+		m.hasBase = true
+	}
+	r.Change = r.Weight - m.base
+	for i, t := range m.cfg.Thresholds {
+		if m.crossed[i] {
+			continue
+		}
+		if (t >= 0 && r.Change >= t) || (t < 0 && r.Change <= t) {
+			m.crossed[i] = true
+			r.Crossed = append(r.Crossed, t)
+		}
+	}
+
+	m.history = append(m.history, batchPoint{at: r.At, weight: r.Weight})
+	first := 0
+	for first < len(m.history) && r.At.Sub(m.history[first].at) > m.cfg.RateWindow {
+		first++
+	}
+	m.history = m.history[first:]
+	r.Rate, r.HasRate = m.rate()
+	return r, true
+}
+
+// rate fits a line through the readings in the window and returns its
+// slope in grams per hour.
+func (m *BatchMonitor) rate() (float64, bool) {
+	if len(m.history) < 3 {
+		return 0, false
+	}
+	t0 := m.history[0].at
+	n := float64(len(m.history))
+	var mt, mw float64
+	for _, p := range m.history {
+		mt += p.at.Sub(t0).Hours()
+		mw += p.weight
+	}
+	mt, mw = mt/n, mw/n
+
+	var stt, stw float64
+	for _, p := range m.history {
+		t := p.at.Sub(t0).Hours() - mt
+		stt += t * t
+		stw += t * (p.weight - mw)
+	}
+	if stt == 0 {
+		return 0, false
+	}
+	return stw / stt, true
+}
+
+// MonitorBatch runs a BatchMonitor with cfg over the updates from scale s.
+// While it runs, s is asked to stay awake, as scales that power off after a
+// few idle minutes would otherwise switch off mid-brew. The returned channel
+// is closed when in closes or ctx is done.
+func MonitorBatch(ctx context.Context, s goscale.Scale, in <-chan goscale.WeightUpdate, cfg BatchConfig) <-chan BatchReading {
+	if err := goscale.SetKeepAwake(s, true); err != nil {
+		if errors.Is(err, goscale.ErrNotSupported) {
+			log.Printf("%s can't be kept awake; make sure its auto-off is disabled", s.DisplayName())
+		} else {
+			log.Printf("Error keeping %s awake: %v", s.DisplayName(), err)
+		}
+	}
+
+	out := make(chan BatchReading, 4)
+	go func() {
+		defer close(out)
+		defer func() { _ = goscale.SetKeepAwake(s, false) }()
+
+		m := NewBatchMonitor(cfg)
+		for {
+			select {
+			case u, ok := <-in:
+				if !ok {
+					if r, ok := m.Flush(); ok {
+						out <- r
+					}
+					return
+				}
+				if r, ok := m.Observe(u); ok {
+					select {
+					case out <- r:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,145 @@
+package brew
+
+import (
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/session"
+)
+
+// LifecycleKind is a point in the life of a brew.
+type LifecycleKind string
+
+const (
+	BrewStarted   LifecycleKind = "brew_started"
+	TargetReached LifecycleKind = "target_reached"
+	BrewEnded     LifecycleKind = "brew_ended"
+)
+
+// LifecycleEvent marks a point in the life of a brew.
+type LifecycleEvent struct {
+	Kind   LifecycleKind
+	At     time.Time
+	Weight float64 // Grams when the event happened
+
+	// Session is the brew recorded so far, from the reading before it
+	// started.
+	Session *session.Session
+}
+
+// LifecycleConfig defines when a brew starts and ends.
+type LifecycleConfig struct {
+	// StartThreshold is how many grams the weight must rise by for a brew
+	// to have started.
+	StartThreshold float64
+
+	// EndAfter is how long the weight must stop rising, by more than
+	// EndTolerance grams, for the brew to have ended.
+	EndAfter     time.Duration
+	EndTolerance float64
+
+	// Target is the weight in grams at which TargetReached is sent, or zero
+	// for none.
+	Target float64
+}
+
+// DefaultLifecycleConfig suits pour-over and espresso.
+var DefaultLifecycleConfig = LifecycleConfig{
+	StartThreshold: 0.5,
+	EndAfter:       4 * time.Second,
+	EndTolerance:   0.3,
+}
+
+// Lifecycle follows brews one after another in a weight update stream. It
+// is not safe for concurrent use.
+type Lifecycle struct {
+	device string
+	cfg    LifecycleConfig
+
+	brewing    bool
+	hasBase    bool
+	base       float64
+	baseUpdate goscale.WeightUpdate
+	targetSent bool
+	riseWeight float64
+	riseAt     time.Time
+	session    *session.Session
+}
+
+// NewLifecycle returns a Lifecycle for brews on the named device.
+func NewLifecycle(device string, cfg LifecycleConfig) *Lifecycle {
+	return &Lifecycle{device: device, cfg: cfg}
+}
+
+// Observe feeds a reading in and returns the events it triggers, if any.
+func (l *Lifecycle) Observe(u goscale.WeightUpdate) []LifecycleEvent {
+	if u = goscale.ConvertUpdate(u, goscale.UnitGrams); u.Error != nil {
+		return nil
+	}
+	if u.Timestamp.IsZero() {
+		u.Timestamp = time.Now()
+	}
+	at := u.Timestamp
+
+	if !l.brewing {
+		// Follow the weight down, so taring or lifting the cup off resets
+		// the starting point.
+		if !l.hasBase || u.Value <= l.base {
+			l.base, l.baseUpdate, l.hasBase = u.Value, u, true
+			return nil
+		}
+		if u.Value-l.base < l.cfg.StartThreshold {
+			return nil
+		}
+		l.brewing = true
+		l.targetSent = false
+		l.riseWeight, l.riseAt = u.Value, at
+		l.session = session.New(l.device)
+		l.session.Add(l.baseUpdate)
+		l.session.Add(u)
+		events := []LifecycleEvent{l.event(BrewStarted, u)}
+		return l.checkTarget(u, events)
+	}
+
+	l.session.Add(u)
+	var events []LifecycleEvent
+	events = l.checkTarget(u, events)
+	if u.Value > l.riseWeight+l.cfg.EndTolerance {
+		l.riseWeight, l.riseAt = u.Value, at
+	} else if at.Sub(l.riseAt) >= l.cfg.EndAfter {
+		events = append(events, l.event(BrewEnded, u))
+		l.brewing = false
+		l.base, l.baseUpdate = u.Value, u
+	}
+	return events
+}
+
+func (l *Lifecycle) checkTarget(u goscale.WeightUpdate, events []LifecycleEvent) []LifecycleEvent {
+	if l.cfg.Target > 0 && !l.targetSent && u.Value-l.base >= l.cfg.Target {
+		l.targetSent = true
+		events = append(events, l.event(TargetReached, u))
+	}
+	return events
+}
+
+func (l *Lifecycle) event(kind LifecycleKind, u goscale.WeightUpdate) LifecycleEvent {
+	s := *l.session
+	s.Samples = append([]session.Sample(nil), l.session.Samples...)
+	return LifecycleEvent{Kind: kind, At: u.Timestamp, Weight: u.Value, Session: &s}
+}
+
+// TrackLifecycle runs a Lifecycle over a weight update stream and emits its
+// events. The returned channel is closed when the input closes.
+func TrackLifecycle(device string, in <-chan goscale.WeightUpdate, cfg LifecycleConfig) <-chan LifecycleEvent {
+	out := make(chan LifecycleEvent, 4)
+	go func() {
+		defer close(out)
+		l := NewLifecycle(device, cfg)
+		for u := range in {
+			for _, ev := range l.Observe(u) {
+				out <- ev
+			}
+		}
+	}()
+	return out
+}
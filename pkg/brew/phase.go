@@ -0,0 +1,195 @@
+// Package brew contains brewing-specific logic layered on top of the raw
+// weight stream, such as recognising the phases of an espresso shot.
+package brew
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/flow"
+)
+
+// Phase is a stage of an espresso extraction, as judged from weight alone.
+type Phase uint8
+
+const (
+	PhaseIdle        Phase = iota // Nothing in the cup yet
+	PhasePreInfusion              // First drips, flow still low
+	PhaseRamp                     // Flow building up towards its peak
+	PhaseSteady                   // Main extraction at a roughly constant flow
+	PhaseDecline                  // Flow falling away at the end (blonding)
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseIdle:
+		return "Idle"
+	case PhasePreInfusion:
+		return "Pre-infusion"
+	case PhaseRamp:
+		return "Ramp"
+	case PhaseSteady:
+		return "Steady"
+	case PhaseDecline:
+		return "Decline"
+	default:
+		return fmt.Sprintf("Unknown Phase (%d)", p)
+	}
+}
+
+// PhaseChange is emitted when the classifier moves to a new phase.
+type PhaseChange struct {
+	From, To Phase
+	At       time.Time // Timestamp of the reading that confirmed the change
+	Weight   float64   // Grams at that reading
+	Flow     float64   // Grams per second at that reading
+}
+
+// ClassifierConfig holds the thresholds used to tell phases apart.
+type ClassifierConfig struct {
+	FlowWindow      time.Duration // Window for the flow estimate
+	DripThreshold   float64       // Grams above the first reading that count as the first drip
+	RampFlow        float64       // Flow (g/s) at which pre-infusion gives way to the ramp
+	SteadyTolerance float64       // Relative flow change per window below which flow is steady
+	DeclineFraction float64       // Fraction of peak flow below which the shot is declining
+	Hold            time.Duration // How long a new phase must persist before it is reported
+}
+
+// DefaultClassifierConfig suits a typical 1:2 espresso shot.
+var DefaultClassifierConfig = ClassifierConfig{
+	FlowWindow:      time.Second,
+	DripThreshold:   0.5,
+	RampFlow:        1.0,
+	SteadyTolerance: 0.15,
+	DeclineFraction: 0.7,
+	Hold:            500 * time.Millisecond,
+}
+
+// Classifier labels live extraction phases from weight and flow trends.
+// Phases only ever move forward; call Reset between shots. It is not safe
+// for concurrent use.
+type Classifier struct {
+	cfg   ClassifierConfig
+	meter *flow.Meter
+
+	phase     Phase
+	base      float64
+	started   bool
+	peakFlow  float64
+	prevFlow  float64
+	prevAt    time.Time
+	trend     float64
+	candidate Phase
+	since     time.Time
+}
+
+// NewClassifier returns a Classifier using cfg.
+func NewClassifier(cfg ClassifierConfig) *Classifier {
+	return &Classifier{cfg: cfg, meter: flow.NewMeter(cfg.FlowWindow), trend: math.Inf(1)}
+}
+
+// Phase returns the current phase.
+func (c *Classifier) Phase() Phase {
+	return c.phase
+}
+
+// Reset returns the classifier to PhaseIdle for the next shot.
+func (c *Classifier) Reset() {
+	*c = *NewClassifier(c.cfg)
+}
+
+// Observe feeds a reading to the classifier. It returns a PhaseChange and
+// true when the reading confirms a move to a new phase.
+func (c *Classifier) Observe(u goscale.WeightUpdate) (PhaseChange, bool) {
+	if u.Error != nil {
+		return PhaseChange{}, false
+	}
+	at := u.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if !c.started {
+		c.started = true
+		c.base = u.Value
+		c.prevAt = at
+	}
+
+	rate := c.meter.Add(at, u.Value)
+	next := c.next(u.Value, rate, at)
+
+	if next == c.phase {
+		c.candidate = c.phase
+		return PhaseChange{}, false
+	}
+	if next != c.candidate {
+		c.candidate = next
+		c.since = at
+	}
+	if at.Sub(c.since) < c.cfg.Hold {
+		return PhaseChange{}, false
+	}
+
+	change := PhaseChange{From: c.phase, To: next, At: at, Weight: u.Value, Flow: rate}
+	c.phase = next
+	return change, true
+}
+
+// next decides which phase the latest reading points to.
+func (c *Classifier) next(weight, rate float64, at time.Time) Phase {
+	if c.phase >= PhaseRamp {
+		c.peakFlow = math.Max(c.peakFlow, rate)
+	}
+
+	// Once per window, compare flow against its value a window ago to judge
+	// the trend. Infinity means there isn't a trend to judge yet.
+	if at.Sub(c.prevAt) >= c.cfg.FlowWindow {
+		c.trend = math.Inf(1)
+		if c.prevFlow > 0 {
+			c.trend = (rate - c.prevFlow) / c.prevFlow
+		}
+		c.prevFlow = rate
+		c.prevAt = at
+	}
+
+	switch c.phase {
+	case PhaseIdle:
+		if weight-c.base >= c.cfg.DripThreshold {
+			if rate >= c.cfg.RampFlow {
+				return PhaseRamp
+			}
+			return PhasePreInfusion
+		}
+	case PhasePreInfusion:
+		if rate >= c.cfg.RampFlow {
+			return PhaseRamp
+		}
+	case PhaseRamp:
+		if math.Abs(c.trend) <= c.cfg.SteadyTolerance {
+			return PhaseSteady
+		}
+	case PhaseSteady:
+		if rate < c.peakFlow*c.cfg.DeclineFraction {
+			return PhaseDecline
+		}
+	}
+	return c.phase
+}
+
+// ClassifyPhases runs a Classifier with cfg over a weight update stream and
+// emits its phase changes. The returned channel is closed when the input
+// closes.
+func ClassifyPhases(in <-chan goscale.WeightUpdate, cfg ClassifierConfig) <-chan PhaseChange {
+	out := make(chan PhaseChange, 4)
+	go func() {
+		defer close(out)
+		c := NewClassifier(cfg)
+		for u := range in {
+			if change, ok := c.Observe(u); ok {
+				out <- change
+			}
+		}
+	}()
+	return out
+}
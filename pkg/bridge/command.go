@@ -0,0 +1,209 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale/pkg/brew"
+)
+
+// CommandHook runs a program when a brew starts or stops, e.g. to record
+// video of espresso shots.
+//
+// The program is given the event in its environment as GOSCALE_EVENT,
+// GOSCALE_DEVICE, GOSCALE_WEIGHT (grams) and GOSCALE_AT (RFC 3339), and
+// ${GOSCALE_...} in its arguments is replaced by the same values, as there is
+// no shell to do it, e.g. "shot-${GOSCALE_STAMP}.mp4". GOSCALE_STAMP is
+// GOSCALE_AT in a form that is safe in a file name.
+type CommandHook struct {
+	// Command is the program and its arguments.
+	Command []string `json:"command" yaml:"command"`
+
+	// Events limits the hook to some kinds of event. Empty means all.
+	Events []brew.LifecycleKind `json:"events,omitempty" yaml:"events,omitempty"`
+
+	// StopOn, if set, interrupts the program if it is still running when
+	// this event happens, so a recorder started by brew_started can be
+	// stopped by brew_ended. It is interrupted with SIGINT, which ffmpeg
+	// and most recorders take as a cue to finish the file, except on
+	// Windows, where it is killed.
+	StopOn brew.LifecycleKind `json:"stop_on,omitempty" yaml:"stop_on,omitempty"`
+}
+
+// CommandNotifier runs programs on brew lifecycle events. Programs are not
+// waited for; their output goes to the notifier's stdout and stderr.
+type CommandNotifier struct {
+	Hooks []CommandHook
+
+	mu      sync.Mutex
+	running map[int]*exec.Cmd // By hook index
+	wg      sync.WaitGroup
+}
+
+// commandStopGrace is how long an interrupted program has to exit before
+// it is killed.
+const commandStopGrace = 5 * time.Second
+
+// Notify starts the program of every hook that wants ev, and interrupts
+// those of hooks that stop on it. It returns the errors of any that
+// couldn't be started, joined.
+func (n *CommandNotifier) Notify(ctx context.Context, ev brew.LifecycleEvent) error {
+	vars := commandVars(ev)
+	var errs []error
+	for i, h := range n.Hooks {
+		if h.StopOn != "" && h.StopOn == ev.Kind {
+			n.stop(i)
+		}
+		if len(h.Events) > 0 && !slices.Contains(h.Events, ev.Kind) {
+			continue
+		}
+		if err := n.start(ctx, i, h, vars); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *CommandNotifier) start(ctx context.Context, i int, h CommandHook, vars map[string]string) error {
+	if len(h.Command) == 0 {
+		return errors.New("no command")
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.running[i] != nil {
+		return errors.New("still running from the last event")
+	}
+
+	// Only our own variables are replaced, so arguments meant for a shell,
+	// such as sh -c scripts, are left alone.
+	var pairs []string
+	for k, v := range vars {
+		pairs = append(pairs, "${"+k+"}", v)
+	}
+	expand := strings.NewReplacer(pairs...)
+	args := make([]string, len(h.Command))
+	for j, arg := range h.Command {
+		args[j] = expand.Replace(arg)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	// Interrupt rather than kill when ctx is done, giving the program a
+	// moment to finish writing.
+	cmd.Cancel = func() error { return interrupt(cmd.Process) }
+	cmd.WaitDelay = commandStopGrace
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	for k, v := range vars {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if n.running == nil {
+		n.running = make(map[int]*exec.Cmd)
+	}
+	n.running[i] = cmd
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		err := cmd.Wait()
+		n.mu.Lock()
+		delete(n.running, i)
+		n.mu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("%s: %v", h.name(), err)
+		}
+	}()
+	return nil
+}
+
+func (n *CommandNotifier) stop(i int) {
+	n.mu.Lock()
+	cmd := n.running[i]
+	n.mu.Unlock()
+	if cmd != nil {
+		_ = interrupt(cmd.Process)
+	}
+}
+
+// interrupt asks p to stop. Windows has no SIGINT to send, so p is killed.
+func interrupt(p *os.Process) error {
+	if runtime.GOOS == "windows" {
+		return p.Kill()
+	}
+	return p.Signal(os.Interrupt)
+}
+
+// Stop interrupts every program still running, as StopOn would, and waits
+// for them to exit, killing any that take longer than a few seconds.
+func (n *CommandNotifier) Stop() {
+	for i := range n.Hooks {
+		n.stop(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(commandStopGrace):
+		n.mu.Lock()
+		for _, cmd := range n.running {
+			_ = cmd.Process.Kill()
+		}
+		n.mu.Unlock()
+		<-done
+	}
+}
+
+// Run notifies about every event from in until it closes or ctx is done,
+// then stops any programs still running. Failures are logged.
+func (n *CommandNotifier) Run(ctx context.Context, in <-chan brew.LifecycleEvent) {
+	defer n.Stop()
+	for {
+		select {
+		case ev, ok := <-in:
+			if !ok {
+				return
+			}
+			if err := n.Notify(ctx, ev); err != nil {
+				log.Printf("Error running commands for %s: %v", ev.Kind, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h CommandHook) name() string {
+	if len(h.Command) == 0 {
+		return "command hook"
+	}
+	return h.Command[0]
+}
+
+func commandVars(ev brew.LifecycleEvent) map[string]string {
+	vars := map[string]string{
+		"GOSCALE_EVENT":  string(ev.Kind),
+		"GOSCALE_WEIGHT": strconv.FormatFloat(ev.Weight, 'f', 2, 64),
+		"GOSCALE_AT":     ev.At.Format(time.RFC3339),
+		"GOSCALE_STAMP":  ev.At.Format("20060102-150405"),
+		"GOSCALE_DEVICE": "",
+	}
+	if ev.Session != nil {
+		vars["GOSCALE_DEVICE"] = ev.Session.Device
+	}
+	return vars
+}
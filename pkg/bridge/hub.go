@@ -0,0 +1,324 @@
+package bridge
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/mdns"
+)
+
+// hubBuffer is how many events an HTTP event stream may fall behind before
+// it misses some.
+const hubBuffer = 256
+
+// AllDevices in a Scope grants access to every device.
+const AllDevices = "*"
+
+// Scope is the devices, by ID, a token grants access to. AllDevices grants
+// all of them.
+type Scope []string
+
+// Allows reports whether the scope covers the device with the given ID.
+func (s Scope) Allows(id string) bool {
+	return slices.Contains(s, AllDevices) || slices.Contains(s, id)
+}
+
+// DeviceID returns the ID a Hub serves a device under: its
+// goscale.FoundDevice ID, which is stable across reconnects, lowercased and
+// with anything but letters and digits replaced by "-", so it can be used
+// as is in URL paths and MQTT topics, e.g. "aa-bb-cc-dd-ee-ff".
+func DeviceID(d *goscale.FoundDevice) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, d.ID())
+}
+
+// HubDevice describes a scale served by a Hub.
+type HubDevice struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name"`
+	Weight      float64   `json:"weight"`
+	Unit        string    `json:"unit"`
+	At          time.Time `json:"at,omitzero"` // Of the last reading
+}
+
+// HubEvent is a scale event as sent by a Hub.
+type HubEvent struct {
+	Device string        `json:"device"` // HubDevice.ID
+	Name   string        `json:"name"`   // The scale's device name
+	Topic  goscale.Topic `json:"topic"`
+	At     time.Time     `json:"at"`
+	Data   any           `json:"data"`
+}
+
+// Hub serves any number of connected scales over one HTTP server, so a café
+// with three scales runs one daemon. It is safe for concurrent use.
+//
+// Endpoints:
+//
+//	GET /scales              the devices the caller may see, as []HubDevice
+//	GET /scales/{id}         one device, as a HubDevice
+//	GET /scales/{id}/events  Server-Sent Events of that device's HubEvents
+//	GET /events              Server-Sent Events of every visible device
+//
+// Devices appear when added and disappear when they disconnect; both are
+// sent as "connection" events. If Tokens is set, requests must carry one of
+// them, as a bearer token or, for EventSource clients that can't set
+// headers, a "token" query parameter, and only see the devices its Scope
+// allows.
+type Hub struct {
+	// Tokens maps access tokens to the devices they may see. Nil means no
+	// authentication: everyone sees everything.
+	Tokens map[string]Scope
+
+	mu         sync.Mutex
+	devices    map[string]*HubDevice
+	subs       map[*hubSub]struct{}
+	mux        *http.ServeMux
+	responders []*mdns.Responder // See Advertise
+}
+
+type hubSub struct {
+	c      chan HubEvent
+	scope  Scope
+	device string // Only this device, if set
+}
+
+// NewHub returns a Hub with no devices.
+func NewHub() *Hub {
+	h := &Hub{
+		devices: make(map[string]*HubDevice),
+		subs:    make(map[*hubSub]struct{}),
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET /scales", h.serveList)
+	h.mux.HandleFunc("GET /scales/{id}", h.serveDevice)
+	h.mux.HandleFunc("GET /scales/{id}/events", h.serveEvents)
+	h.mux.HandleFunc("GET /events", h.serveEvents)
+	return h
+}
+
+// Add serves cs until its updates close or ctx is done, then stops serving
+// it. It returns at once, with a channel that is closed when the Hub stops
+// serving cs, e.g. so a daemon can forget the device and reconnect when it
+// next sees it. The Hub reads cs's updates through cs.Subscribe, so other
+// subscribers can share them.
+func (h *Hub) Add(ctx context.Context, cs *goscale.ConnectedScale) <-chan struct{} {
+	dev := &HubDevice{
+		ID:          DeviceID(cs.Device),
+		Name:        cs.Scale.DeviceName(),
+		DisplayName: cs.Scale.DisplayName(),
+		Unit:        goscale.UnitGrams,
+	}
+	h.mu.Lock()
+	h.devices[dev.ID] = dev
+	h.mu.Unlock()
+	h.devicesChanged()
+
+	bus := goscale.NewBus()
+	sub := bus.Subscribe(hubBuffer)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ev := range sub.C {
+			h.publish(dev, ev)
+		}
+		h.mu.Lock()
+		if h.devices[dev.ID] == dev {
+			delete(h.devices, dev.ID)
+		}
+		h.mu.Unlock()
+		h.devicesChanged()
+	}()
+	go func() {
+		updates := cs.Subscribe(0)
+		goscale.PublishScale(ctx, bus, cs.Scale, updates)
+		cs.Unsubscribe(updates)
+		if ctx.Err() != nil {
+			// PublishScale only reports disconnects it sees.
+			bus.Publish(goscale.Event{Topic: goscale.TopicConnection, Device: dev.Name, Data: goscale.ConnectionEvent{Connected: false}})
+		}
+		bus.Close()
+	}()
+	return done
+}
+
+// Devices returns the devices being served, ordered by ID.
+func (h *Hub) Devices() []HubDevice {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	devices := make([]HubDevice, 0, len(h.devices))
+	for _, d := range h.devices {
+		devices = append(devices, *d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	return devices
+}
+
+func (h *Hub) publish(dev *HubDevice, ev goscale.Event) {
+	out := HubEvent{Device: dev.ID, Name: dev.Name, Topic: ev.Topic, At: ev.At, Data: ev.Data}
+	if out.At.IsZero() {
+		out.At = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if u, ok := ev.Data.(goscale.WeightUpdate); ok {
+		if u.Error != nil {
+			return
+		}
+		dev.Weight, dev.At = u.Value, out.At
+		if u.Unit != "" {
+			dev.Unit = u.Unit
+		}
+		out.Data = reading{Time: out.At, Seq: u.Seq, Weight: u.Value, Unit: dev.Unit}
+	}
+	for s := range h.subs {
+		if (s.device != "" && s.device != dev.ID) || (s.scope != nil && !s.scope.Allows(dev.ID)) {
+			continue
+		}
+		select {
+		case s.c <- out:
+		default:
+			// Too slow; it misses this one rather than holding up the
+			// others.
+		}
+	}
+}
+
+// reading is a weight update as sent by a Hub.
+type reading struct {
+	Time   time.Time `json:"time"`
+	Seq    uint64    `json:"seq"`
+	Weight float64   `json:"weight"`
+	Unit   string    `json:"unit"`
+}
+
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// scope returns what the request may see, or false, having written the
+// error, if it may see nothing.
+func (h *Hub) scope(w http.ResponseWriter, r *http.Request) (Scope, bool) {
+	if h.Tokens == nil {
+		return nil, true
+	}
+	token := r.URL.Query().Get("token")
+	if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = auth
+	}
+	for t, scope := range h.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return scope, true
+		}
+	}
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+	return nil, false
+}
+
+func (h *Hub) serveList(w http.ResponseWriter, r *http.Request) {
+	scope, ok := h.scope(w, r)
+	if !ok {
+		return
+	}
+	devices := h.Devices()
+	visible := make([]HubDevice, 0, len(devices))
+	for _, d := range devices {
+		if scope == nil || scope.Allows(d.ID) {
+			visible = append(visible, d)
+		}
+	}
+	writeJSON(w, visible)
+}
+
+func (h *Hub) serveDevice(w http.ResponseWriter, r *http.Request) {
+	scope, ok := h.scope(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	h.mu.Lock()
+	d, found := h.devices[id]
+	var dev HubDevice
+	if found {
+		dev = *d
+	}
+	h.mu.Unlock()
+	// Devices outside the scope are reported as missing, so tokens can't
+	// be used to probe for them.
+	if !found || (scope != nil && !scope.Allows(id)) {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, dev)
+}
+
+func (h *Hub) serveEvents(w http.ResponseWriter, r *http.Request) {
+	scope, ok := h.scope(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	if id != "" && scope != nil && !scope.Allows(id) {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	s := &hubSub{c: make(chan HubEvent, hubBuffer), scope: scope, device: id}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.subs, s)
+		h.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	for {
+		select {
+		case ev := <-s.c:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Topic, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,208 @@
+// Package bridge makes scale data available over the network.
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/session"
+)
+
+// viewerBuffer is how many samples a viewer may fall behind before it is
+// dropped. A dropped viewer can reconnect and will be sent the backlog.
+const viewerBuffer = 256
+
+// LiveSession records a session and streams it, as it happens, to any number
+// of remote viewers over Server-Sent Events, e.g. so a trainer can watch a
+// trainee's shot curve from another device. Viewers joining part way
+// through are sent everything recorded so far first. It is safe for
+// concurrent use.
+//
+// The stream carries three event types: "meta", sent first, with the device
+// and start time; "sample", one per reading, in the session JSON format; and
+// "end", sent when the session ends.
+//
+// Weights are sent in grams. A viewer can ask for another unit with the
+// "unit" query parameter, e.g. "?unit=oz".
+type LiveSession struct {
+	mu      sync.Mutex
+	session *session.Session
+	viewers map[chan session.Sample]struct{}
+	ended   bool
+	done    chan struct{}
+}
+
+// NewLiveSession returns a LiveSession for a new session on the named device.
+func NewLiveSession(device string) *LiveSession {
+	return &LiveSession{
+		session: session.New(device),
+		viewers: make(map[chan session.Sample]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Add records a weight update and sends it to every viewer. Updates the
+// session ignores, or arriving after End, are ignored.
+func (l *LiveSession) Add(u goscale.WeightUpdate) {
+	if u.Error != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ended {
+		return
+	}
+	n := len(l.session.Samples)
+	l.session.Add(u)
+	if len(l.session.Samples) == n {
+		return
+	}
+	smp := l.session.Samples[n]
+	for ch := range l.viewers {
+		select {
+		case ch <- smp:
+		default:
+			// Too slow; drop it rather than hold up the recording.
+			delete(l.viewers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Run adds every update from in, then ends the session when in closes.
+func (l *LiveSession) Run(in <-chan goscale.WeightUpdate) {
+	for u := range in {
+		l.Add(u)
+	}
+	l.End()
+}
+
+// End finishes the session. Viewers are sent an "end" event and
+// disconnected.
+func (l *LiveSession) End() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ended {
+		return
+	}
+	l.ended = true
+	close(l.done)
+}
+
+// Session returns a snapshot of the session recorded so far.
+func (l *LiveSession) Session() *session.Session {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := *l.session
+	s.Samples = append([]session.Sample(nil), l.session.Samples...)
+	return &s
+}
+
+type liveMeta struct {
+	Device string `json:"device"`
+	Start  string `json:"start,omitempty"`
+	Unit   string `json:"unit"`
+}
+
+// ServeHTTP streams the session to a viewer as Server-Sent Events.
+func (l *LiveSession) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	unit, err := goscale.NormalizeUnit(r.URL.Query().Get("unit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	convert := func(smp session.Sample) session.Sample {
+		smp.Weight, _ = goscale.ConvertWeight(smp.Weight, goscale.UnitGrams, unit)
+		return smp
+	}
+
+	// Take the backlog and subscribe in one step, so no sample is missed
+	// or sent twice.
+	l.mu.Lock()
+	backlog := append([]session.Sample(nil), l.session.Samples...)
+	meta := liveMeta{Device: l.session.Device, Unit: unit}
+	if !l.session.Start.IsZero() {
+		meta.Start = l.session.Start.Format(time.RFC3339Nano)
+	}
+	ch := make(chan session.Sample, viewerBuffer)
+	if !l.ended {
+		l.viewers[ch] = struct{}{}
+	}
+	l.mu.Unlock()
+	defer l.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	if err := writeEvent(w, "meta", meta); err != nil {
+		return
+	}
+	for _, smp := range backlog {
+		if err := writeEvent(w, "sample", convert(smp)); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case smp, ok := <-ch:
+			if !ok {
+				// Dropped for falling behind.
+				return
+			}
+			if err := writeEvent(w, "sample", convert(smp)); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-l.done:
+			// Send whatever arrived before the end.
+		drain:
+			for {
+				select {
+				case smp, ok := <-ch:
+					if !ok {
+						break drain
+					}
+					_ = writeEvent(w, "sample", convert(smp))
+				default:
+					break drain
+				}
+			}
+			_ = writeEvent(w, "end", struct{}{})
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (l *LiveSession) unsubscribe(ch chan session.Sample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.viewers[ch]; ok {
+		delete(l.viewers, ch)
+		close(ch)
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
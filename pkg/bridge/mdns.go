@@ -0,0 +1,94 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/mlsorensen/goscale/pkg/mdns"
+)
+
+// ServiceType is the DNS-SD service type Hubs are advertised as.
+const ServiceType = "_goscale._tcp"
+
+// Advertise announces h on the local network with mDNS, as instance, e.g.
+// "goscale on kitchen-pi", served on port, so apps can find it without
+// being given its address. It returns at once, and advertises h until ctx
+// is done. Its TXT records describe it, and are kept up to date as scales
+// come and go:
+//
+//	txtvers=1
+//	path=/scales     the device list; see Hub
+//	events=/events   every visible device's events
+//	auth=token       or "none"; whether requests need one of Tokens
+//	scales=2         how many scales are being served
+//	names=LUNAR-...  their names, comma separated, only without auth
+func (h *Hub) Advertise(ctx context.Context, instance string, port int) error {
+	r, err := mdns.Advertise(mdns.Service{
+		Instance: instance,
+		Type:     ServiceType,
+		Port:     port,
+		TXT:      h.txt(),
+	})
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.responders = append(h.responders, r)
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		h.responders = slices.DeleteFunc(h.responders, func(x *mdns.Responder) bool { return x == r })
+		h.mu.Unlock()
+		_ = r.Close()
+	}()
+	return nil
+}
+
+// devicesChanged updates the TXT records of h's advertisements.
+func (h *Hub) devicesChanged() {
+	txt := h.txt()
+	h.mu.Lock()
+	responders := slices.Clone(h.responders)
+	h.mu.Unlock()
+	for _, r := range responders {
+		if err := r.SetTXT(txt); err != nil {
+			log.Printf("Error advertising the hub: %v", err)
+		}
+	}
+}
+
+// txt returns the TXT records describing h.
+func (h *Hub) txt() []string {
+	devices := h.Devices()
+	auth := "none"
+	if h.Tokens != nil {
+		auth = "token"
+	}
+	txt := []string{
+		"txtvers=1",
+		"path=/scales",
+		"events=/events",
+		"auth=" + auth,
+		fmt.Sprintf("scales=%d", len(devices)),
+	}
+	// Which scales are here is only for those allowed to see them.
+	if h.Tokens == nil && len(devices) > 0 {
+		names := "names="
+		for i, d := range devices {
+			name := d.Name
+			if i > 0 {
+				name = "," + name
+			}
+			if len(names)+len(name) > 255 {
+				break
+			}
+			names += name
+		}
+		txt = append(txt, names)
+	}
+	return txt
+}
@@ -0,0 +1,124 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mlsorensen/goscale/pkg/brew"
+	"github.com/mlsorensen/goscale/pkg/session"
+)
+
+// DefaultWebhookTimeout bounds each webhook call when a WebhookNotifier has
+// no Timeout set.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Webhook is an outbound HTTP endpoint to tell about brews, e.g. an Airtable,
+// Google Sheets or Notion automation.
+type Webhook struct {
+	URL string `json:"url" yaml:"url"`
+
+	// Events limits the webhook to some kinds of event. Empty means all.
+	Events []brew.LifecycleKind `json:"events,omitempty" yaml:"events,omitempty"`
+
+	// Headers are added to each request, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks.
+type WebhookPayload struct {
+	Event   brew.LifecycleKind `json:"event"`
+	Device  string             `json:"device"`
+	At      time.Time          `json:"at"`
+	Weight  float64            `json:"weight"`
+	Summary session.Summary    `json:"summary"`
+}
+
+// WebhookNotifier POSTs brew lifecycle events, with the summary of the brew
+// so far, to webhooks.
+type WebhookNotifier struct {
+	Hooks   []Webhook
+	Client  *http.Client  // Nil means http.DefaultClient
+	Timeout time.Duration // Per call; zero means DefaultWebhookTimeout
+}
+
+// Notify sends ev to every webhook that wants it and returns the errors of
+// any that failed, joined.
+func (n *WebhookNotifier) Notify(ctx context.Context, ev brew.LifecycleEvent) error {
+	p := WebhookPayload{Event: ev.Kind, At: ev.At, Weight: ev.Weight}
+	if ev.Session != nil {
+		p.Device = ev.Session.Device
+		p.Summary = session.Summarize(ev.Session)
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, h := range n.Hooks {
+		if len(h.Events) > 0 && !slices.Contains(h.Events, ev.Kind) {
+			continue
+		}
+		if err := n.post(ctx, h, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.URL, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, h Webhook, body []byte) error {
+	timeout := n.Timeout
+	if timeout == 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// Run notifies about every event from in until it closes or ctx is done.
+// Failures are logged.
+func (n *WebhookNotifier) Run(ctx context.Context, in <-chan brew.LifecycleEvent) {
+	for {
+		select {
+		case ev, ok := <-in:
+			if !ok {
+				return
+			}
+			if err := n.Notify(ctx, ev); err != nil {
+				log.Printf("Error calling webhooks for %s: %v", ev.Kind, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,628 @@
+// Package bttest provides an in-memory Bluetooth adapter, so the scanner
+// and drivers can be exercised end to end without hardware, e.g. in CI.
+//
+// Script each simulated device's advertisement, services and replies, then
+// install the adapter in place of the host's:
+//
+//	a := bttest.NewAdapter()
+//	restore := a.Install()
+//	defer restore()
+//
+//	dev := a.AddDevice("UMBRA-01", "C8:3A:35:00:00:01")
+//	dev.Advertise(comms.UmbraServiceUUID)
+//	cmd := dev.AddCharacteristic(comms.UmbraServiceUUID, comms.UmbraCommandCharUUID)
+//	notify := dev.AddCharacteristic(comms.UmbraServiceUUID, comms.UmbraNotifyCharUUID)
+//	cmd.OnWrite(func(p []byte) {
+//		// Answer commands by notifying on notify.
+//	})
+//
+//	found, _ := goscale.ScanForOne(time.Second)
+//	scale, _ := goscale.NewScaleForDevice(found)
+//	updates, _ := scale.Connect()
+//	notify.Schedule([]bttest.Frame{{After: 100 * time.Millisecond, Data: frame}})
+package bttest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"tinygo.org/x/bluetooth"
+)
+
+// DefaultAdvertisingInterval is how often each device advertises while
+// scanning, unless Adapter.AdvertisingInterval is set.
+const DefaultAdvertisingInterval = 100 * time.Millisecond
+
+// DefaultMTU is the ATT MTU a simulated connection reports, the smallest
+// Bluetooth LE allows, unless Characteristic.SetMTU is called.
+const DefaultMTU = 23
+
+var (
+	// ErrNotConnected is returned for operations on a device that isn't
+	// connected.
+	ErrNotConnected = errors.New("bttest: device not connected")
+
+	// ErrNoDevice is returned by Connect for an address with no device.
+	ErrNoDevice = errors.New("bttest: no such device")
+
+	// ErrAlreadyScanning is returned by Scan while a scan is under way.
+	ErrAlreadyScanning = errors.New("bttest: already scanning")
+)
+
+var _ goscale.Adapter = (*Adapter)(nil)
+var _ goscale.Peripheral = (*Device)(nil)
+var _ goscale.Service = (*Service)(nil)
+var _ goscale.Characteristic = (*Characteristic)(nil)
+
+// Adapter is an in-memory goscale.Adapter. Callbacks, such as
+// notifications and the connect handler, are run one at a time on the
+// adapter's event goroutine, as a Bluetooth stack's are. It is safe for
+// concurrent use.
+type Adapter struct {
+	// AdvertisingInterval is how often each device advertises while
+	// scanning. Zero means DefaultAdvertisingInterval. Set it before
+	// scanning.
+	AdvertisingInterval time.Duration
+
+	mu        sync.Mutex
+	devices   []*Device
+	enableErr error
+	stopScan  chan struct{} // Non-nil while scanning
+	onConnect func(address bluetooth.Address, connected bool)
+
+	events *eventLoop
+}
+
+// NewAdapter returns an adapter with no devices.
+func NewAdapter() *Adapter {
+	return &Adapter{events: newEventLoop()}
+}
+
+// Install makes goscale scan and connect through a, and returns a function
+// that restores the host's adapter and stops a's event goroutine.
+func (a *Adapter) Install() (restore func()) {
+	goscale.SetAdapter(a)
+	return func() {
+		goscale.SetAdapter(nil)
+		a.Close()
+	}
+}
+
+// Close stops the adapter's event goroutine, dropping any callbacks still
+// queued. The adapter can't be used afterwards.
+func (a *Adapter) Close() {
+	a.events.close()
+}
+
+// SetEnableError makes Enable fail with err, e.g. to simulate an adapter
+// that is missing or switched off. Nil lets it succeed again.
+func (a *Adapter) SetEnableError(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enableErr = err
+}
+
+// AddDevice adds a device that advertises name from address, which is
+// parsed as bluetooth.Address.Set does. It panics if address is invalid.
+func (a *Adapter) AddDevice(name, address string) *Device {
+	d := &Device{adapter: a, name: name, rssi: -60}
+	d.address.Set(address)
+	if d.address == (bluetooth.Address{}) {
+		panic(fmt.Sprintf("bttest: invalid address %q", address))
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.devices = append(a.devices, d)
+	return d
+}
+
+// RemoveDevice takes d away, as if it went out of range. It is dropped if
+// connected.
+func (a *Adapter) RemoveDevice(d *Device) {
+	a.mu.Lock()
+	a.devices = slices.DeleteFunc(a.devices, func(x *Device) bool { return x == d })
+	a.mu.Unlock()
+	d.Drop()
+}
+
+// Enable implements goscale.Adapter.
+func (a *Adapter) Enable() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enableErr
+}
+
+// Scan implements goscale.Adapter. Each device that isn't connected
+// advertises straight away, then every AdvertisingInterval.
+func (a *Adapter) Scan(f func(goscale.ScanResult)) error {
+	a.mu.Lock()
+	if a.stopScan != nil {
+		a.mu.Unlock()
+		return ErrAlreadyScanning
+	}
+	stop := make(chan struct{})
+	a.stopScan = stop
+	interval := a.AdvertisingInterval
+	a.mu.Unlock()
+	if interval <= 0 {
+		interval = DefaultAdvertisingInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		a.mu.Lock()
+		devices := slices.Clone(a.devices)
+		a.mu.Unlock()
+		for _, d := range devices {
+			if r, ok := d.advertisement(); ok {
+				f(r)
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// StopScan implements goscale.Adapter.
+func (a *Adapter) StopScan() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stopScan != nil {
+		close(a.stopScan)
+		a.stopScan = nil
+	}
+	return nil
+}
+
+// Connect implements goscale.Adapter.
+func (a *Adapter) Connect(address bluetooth.Address, _ bluetooth.ConnectionParams) (goscale.Peripheral, error) {
+	a.mu.Lock()
+	i := slices.IndexFunc(a.devices, func(d *Device) bool { return d.address == address })
+	var d *Device
+	if i >= 0 {
+		d = a.devices[i]
+	}
+	a.mu.Unlock()
+	if d == nil {
+		return nil, fmt.Errorf("%w at %s", ErrNoDevice, address.String())
+	}
+	if err := d.connect(); err != nil {
+		return nil, err
+	}
+	a.linkEvent(address, true)
+	return d, nil
+}
+
+// SetConnectHandler implements goscale.Adapter.
+func (a *Adapter) SetConnectHandler(f func(address bluetooth.Address, connected bool)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onConnect = f
+}
+
+// linkEvent tells the connect handler, if any, that the device at address
+// connected or disconnected.
+func (a *Adapter) linkEvent(address bluetooth.Address, connected bool) {
+	a.mu.Lock()
+	f := a.onConnect
+	a.mu.Unlock()
+	if f != nil {
+		a.events.post(func() { f(address, connected) })
+	}
+}
+
+// Device is a simulated Bluetooth LE device. While connected it is also the
+// goscale.Peripheral drivers use.
+type Device struct {
+	adapter *Adapter
+	name    string
+	address bluetooth.Address
+
+	mu               sync.Mutex
+	rssi             int
+	advertised       []bluetooth.UUID
+	manufacturerData map[uint16][]byte
+	services         []*Service
+	connected        bool
+	connectErr       error
+	discoverFailures int
+}
+
+// Name returns the name the device advertises.
+func (d *Device) Name() string {
+	return d.name
+}
+
+// Address returns the device's address.
+func (d *Device) Address() bluetooth.Address {
+	return d.address
+}
+
+// SetRSSI sets the signal strength the device is seen at. The default is
+// -60 dBm.
+func (d *Device) SetRSSI(rssi int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rssi = rssi
+}
+
+// Advertise sets the service UUIDs in the device's advertisement. The
+// device's services needn't all be advertised, as is common.
+func (d *Device) Advertise(services ...bluetooth.UUID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.advertised = slices.Clone(services)
+}
+
+// SetManufacturerData adds manufacturer data for company to the device's
+// advertisement, or removes it if data is nil.
+func (d *Device) SetManufacturerData(company uint16, data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if data == nil {
+		delete(d.manufacturerData, company)
+		return
+	}
+	if d.manufacturerData == nil {
+		d.manufacturerData = make(map[uint16][]byte)
+	}
+	d.manufacturerData[company] = slices.Clone(data)
+}
+
+// SetConnectError makes connecting to the device fail with err, e.g. to
+// exercise retries. Nil lets it succeed again.
+func (d *Device) SetConnectError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.connectErr = err
+}
+
+// FailDiscovery makes the next n service discoveries find nothing, as
+// BlueZ's can straight after connecting.
+func (d *Device) FailDiscovery(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.discoverFailures = n
+}
+
+// AddCharacteristic adds a characteristic to the device, and the service
+// it belongs to if that is new.
+func (d *Device) AddCharacteristic(service, char bluetooth.UUID) *Characteristic {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := slices.IndexFunc(d.services, func(s *Service) bool { return s.uuid == service })
+	if i < 0 {
+		d.services = append(d.services, &Service{device: d, uuid: service})
+		i = len(d.services) - 1
+	}
+	c := &Characteristic{device: d, uuid: char, mtu: DefaultMTU}
+	d.services[i].chars = append(d.services[i].chars, c)
+	return c
+}
+
+// Connected reports whether a driver is connected to the device.
+func (d *Device) Connected() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.connected
+}
+
+// Drop ends the connection from the device's side, as if it went out of
+// range or switched off, telling the adapter's connect handler.
+func (d *Device) Drop() {
+	if d.disconnect() {
+		d.adapter.linkEvent(d.address, false)
+	}
+}
+
+// DiscoverServices implements goscale.Peripheral.
+func (d *Device) DiscoverServices(uuids []bluetooth.UUID) ([]goscale.Service, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.connected {
+		return nil, ErrNotConnected
+	}
+	if d.discoverFailures > 0 {
+		d.discoverFailures--
+		return nil, nil
+	}
+	var found []goscale.Service
+	for _, s := range d.services {
+		if len(uuids) == 0 || slices.Contains(uuids, s.uuid) {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
+// Disconnect implements goscale.Peripheral. Disconnecting a device that
+// has already dropped does nothing.
+func (d *Device) Disconnect() error {
+	if d.disconnect() {
+		d.adapter.linkEvent(d.address, false)
+	}
+	return nil
+}
+
+// advertisement returns what the device advertises, or false while it is
+// connected, as most devices stop advertising then.
+func (d *Device) advertisement() (goscale.ScanResult, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.connected {
+		return goscale.ScanResult{}, false
+	}
+	r := goscale.ScanResult{
+		Name:         d.name,
+		Address:      d.address,
+		RSSI:         d.rssi,
+		ServiceUUIDs: slices.Clone(d.advertised),
+	}
+	if len(d.manufacturerData) > 0 {
+		r.ManufacturerData = make(map[uint16][]byte, len(d.manufacturerData))
+		for company, data := range d.manufacturerData {
+			r.ManufacturerData[company] = slices.Clone(data)
+		}
+	}
+	return r, true
+}
+
+func (d *Device) connect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.connectErr != nil {
+		return d.connectErr
+	}
+	if d.connected {
+		return fmt.Errorf("bttest: %s is already connected", d.name)
+	}
+	d.connected = true
+	return nil
+}
+
+// disconnect marks the device disconnected and drops its notification
+// subscriptions, reporting whether it was connected.
+func (d *Device) disconnect() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.connected {
+		return false
+	}
+	d.connected = false
+	for _, s := range d.services {
+		for _, c := range s.chars {
+			c.notify = nil
+		}
+	}
+	return true
+}
+
+// Service is a GATT service of a simulated device.
+type Service struct {
+	device *Device
+	uuid   bluetooth.UUID
+	chars  []*Characteristic // Guarded by device.mu
+}
+
+// UUID implements goscale.Service.
+func (s *Service) UUID() bluetooth.UUID {
+	return s.uuid
+}
+
+// DiscoverCharacteristics implements goscale.Service.
+func (s *Service) DiscoverCharacteristics(uuids []bluetooth.UUID) ([]goscale.Characteristic, error) {
+	s.device.mu.Lock()
+	defer s.device.mu.Unlock()
+	if !s.device.connected {
+		return nil, ErrNotConnected
+	}
+	var found []goscale.Characteristic
+	for _, c := range s.chars {
+		if len(uuids) == 0 || slices.Contains(uuids, c.uuid) {
+			found = append(found, c)
+		}
+	}
+	return found, nil
+}
+
+// Frame is a notification to send after a delay; see Schedule.
+type Frame struct {
+	After time.Duration // Since the previous frame, or the call to Schedule
+	Data  []byte
+}
+
+// Characteristic is a GATT characteristic of a simulated device. Drivers
+// use it as a goscale.Characteristic; tests script it with OnWrite,
+// Notify and Schedule, and check what was written with Writes.
+type Characteristic struct {
+	device *Device
+	uuid   bluetooth.UUID
+
+	// Guarded by device.mu.
+	notify  func(buf []byte)
+	onWrite func(p []byte)
+	writes  [][]byte
+	mtu     uint16
+}
+
+// UUID implements goscale.Characteristic.
+func (c *Characteristic) UUID() bluetooth.UUID {
+	return c.uuid
+}
+
+// Write implements goscale.Characteristic.
+func (c *Characteristic) Write(p []byte) (int, error) {
+	return c.write(p)
+}
+
+// WriteWithoutResponse implements goscale.Characteristic.
+func (c *Characteristic) WriteWithoutResponse(p []byte) (int, error) {
+	return c.write(p)
+}
+
+// EnableNotifications implements goscale.Characteristic. Notifications
+// stop when the device disconnects.
+func (c *Characteristic) EnableNotifications(callback func(buf []byte)) error {
+	c.device.mu.Lock()
+	defer c.device.mu.Unlock()
+	if !c.device.connected {
+		return ErrNotConnected
+	}
+	c.notify = callback
+	return nil
+}
+
+// GetMTU implements goscale.Characteristic.
+func (c *Characteristic) GetMTU() (uint16, error) {
+	c.device.mu.Lock()
+	defer c.device.mu.Unlock()
+	if !c.device.connected {
+		return 0, ErrNotConnected
+	}
+	return c.mtu, nil
+}
+
+// SetMTU sets the MTU GetMTU reports. The default is DefaultMTU.
+func (c *Characteristic) SetMTU(mtu uint16) {
+	c.device.mu.Lock()
+	defer c.device.mu.Unlock()
+	c.mtu = mtu
+}
+
+// OnWrite sets a function called with each value written, e.g. to answer
+// a command by notifying on another characteristic. It runs on the
+// adapter's event goroutine, after the write has returned.
+func (c *Characteristic) OnWrite(f func(p []byte)) {
+	c.device.mu.Lock()
+	defer c.device.mu.Unlock()
+	c.onWrite = f
+}
+
+// Writes returns every value written so far, oldest first.
+func (c *Characteristic) Writes() [][]byte {
+	c.device.mu.Lock()
+	defer c.device.mu.Unlock()
+	return slices.Clone(c.writes)
+}
+
+// Notify sends data to the subscribed driver, on the adapter's event
+// goroutine. It reports false, sending nothing, if the device isn't
+// connected or notifications aren't enabled.
+func (c *Characteristic) Notify(data []byte) bool {
+	c.device.mu.Lock()
+	notify := c.notify
+	c.device.mu.Unlock()
+	if notify == nil {
+		return false
+	}
+	data = slices.Clone(data)
+	c.device.adapter.events.post(func() {
+		// Check again: the device may have disconnected while queued.
+		c.device.mu.Lock()
+		subscribed := c.notify != nil
+		c.device.mu.Unlock()
+		if subscribed {
+			notify(data)
+		}
+	})
+	return true
+}
+
+// Schedule sends frames in order, each After the one before, until they run
+// out or stop is called. Frames due while notifications aren't enabled are
+// dropped, as a real device's would be.
+func (c *Characteristic) Schedule(frames []Frame) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		for _, f := range frames {
+			timer := time.NewTimer(f.After)
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			c.Notify(f.Data)
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (c *Characteristic) write(p []byte) (int, error) {
+	c.device.mu.Lock()
+	if !c.device.connected {
+		c.device.mu.Unlock()
+		return 0, ErrNotConnected
+	}
+	p = slices.Clone(p)
+	c.writes = append(c.writes, p)
+	onWrite := c.onWrite
+	c.device.mu.Unlock()
+	if onWrite != nil {
+		c.device.adapter.events.post(func() { onWrite(p) })
+	}
+	return len(p), nil
+}
+
+// eventLoop runs callbacks one at a time, in order, on its own goroutine.
+type eventLoop struct {
+	mu     sync.Mutex
+	queue  []func()
+	closed bool
+	wake   chan struct{}
+}
+
+func newEventLoop() *eventLoop {
+	l := &eventLoop{wake: make(chan struct{}, 1)}
+	go l.run()
+	return l
+}
+
+// post queues f to run. It never blocks.
+func (l *eventLoop) post(f func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.queue = append(l.queue, f)
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *eventLoop) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed = true
+		l.queue = nil
+		close(l.wake)
+	}
+}
+
+func (l *eventLoop) run() {
+	for range l.wake {
+		for {
+			l.mu.Lock()
+			if len(l.queue) == 0 || l.closed {
+				l.mu.Unlock()
+				break
+			}
+			f := l.queue[0]
+			l.queue = l.queue[1:]
+			l.mu.Unlock()
+			f()
+		}
+	}
+}
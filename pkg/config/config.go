@@ -0,0 +1,265 @@
+// Package config reads and writes the YAML configuration file shared by
+// the goscale command and the example applications: which scale to use,
+// named target weights, the smoothing preset and the bridge's endpoints.
+// A scale picked in one application is remembered for the others.
+//
+// A file looks like:
+//
+//	device:
+//	  name: LUNAR             # name prefix or address; empty for the first found
+//	  driver: LUNAR           # optional, to drive it whatever it is called
+//	  unit: g
+//	smoothing: espresso       # see goscale.SmoothingPreset
+//	targets:
+//	  espresso: 36
+//	  v60: 250
+//	tare_presets: /home/me/tare.json
+//	bridge:
+//	  listen: :8080
+//	  webhooks:
+//	    - url: https://example.com/brews
+//	      events: [brew_ended]
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/bridge"
+	"github.com/mlsorensen/goscale/pkg/tare"
+)
+
+// Config is the contents of a configuration file. The zero Config is valid,
+// and leaves every choice to the application.
+type Config struct {
+	Device DeviceConfig `json:"device" yaml:"device"`
+
+	// Smoothing names the smoothing preset readings are run through, e.g.
+	// "espresso". Empty for none.
+	Smoothing string `json:"smoothing,omitempty" yaml:"smoothing,omitempty"`
+
+	// Targets are target weights in grams, by name, e.g. "espresso": 36.
+	Targets map[string]float64 `json:"targets,omitempty" yaml:"targets,omitempty"`
+
+	// TarePresets is the tare presets file, or empty for
+	// tare.DefaultPresetsPath.
+	TarePresets string `json:"tare_presets,omitempty" yaml:"tare_presets,omitempty"`
+
+	Bridge BridgeConfig `json:"bridge" yaml:"bridge"`
+}
+
+// DeviceConfig is the preferred scale.
+type DeviceConfig struct {
+	// Name is the name prefix or address of the scale, or empty for the
+	// first one found.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// Driver is the driver to use, whatever the scale is called (see
+	// goscale.NewScaleForDeviceWithDriver), or empty to go by its name.
+	Driver string `json:"driver,omitempty" yaml:"driver,omitempty"`
+
+	// Unit is the unit to show weights in, g or oz. Empty for grams.
+	Unit string `json:"unit,omitempty" yaml:"unit,omitempty"`
+}
+
+// BridgeConfig is where the bridge serves scales, and the endpoints it
+// tells about brews.
+type BridgeConfig struct {
+	Listen string `json:"listen,omitempty" yaml:"listen,omitempty"`
+
+	// Tokens allow each token to see the listed scales, or all of them with
+	// "*". Empty for no authentication.
+	Tokens map[string]bridge.Scope `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+
+	Webhooks []bridge.Webhook     `json:"webhooks,omitempty" yaml:"webhooks,omitempty"`
+	Commands []bridge.CommandHook `json:"commands,omitempty" yaml:"commands,omitempty"`
+}
+
+// DefaultPath returns where the configuration file is kept by default,
+// under the user's configuration directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goscale", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path, or DefaultPath if path is
+// empty. A missing file gives an empty Config.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read config: %w", err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a YAML Config and checks it. Unknown keys are refused, so
+// typos don't go unnoticed.
+func Parse(r io.Reader) (*Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &c, nil
+}
+
+// Validate checks that c's settings make sense.
+func (c *Config) Validate() error {
+	if c.Device.Unit != "" {
+		if _, err := goscale.NormalizeUnit(c.Device.Unit); err != nil {
+			return fmt.Errorf("device: %w", err)
+		}
+	}
+	if _, err := c.SmoothingConfig(); err != nil {
+		return err
+	}
+	for _, name := range c.TargetNames() {
+		if c.Targets[name] <= 0 {
+			return fmt.Errorf("target %q must be above zero", name)
+		}
+	}
+	for i, h := range c.Bridge.Webhooks {
+		if h.URL == "" {
+			return fmt.Errorf("bridge webhook %d needs a url", i)
+		}
+	}
+	for i, h := range c.Bridge.Commands {
+		if len(h.Command) == 0 {
+			return fmt.Errorf("bridge command %d needs a command", i)
+		}
+	}
+	return nil
+}
+
+// Save writes c to path as YAML, or to DefaultPath if path is empty,
+// creating its directory if needed.
+func (c *Config) Save(path string) error {
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return err
+		}
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not save config: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("could not save config: %w", err)
+	}
+	return nil
+}
+
+// SmoothingConfig returns the smoothing preset c names, or
+// goscale.SmoothingRaw if it names none.
+func (c *Config) SmoothingConfig() (goscale.SmoothingConfig, error) {
+	if c.Smoothing == "" {
+		return goscale.SmoothingRaw, nil
+	}
+	return goscale.SmoothingPreset(c.Smoothing)
+}
+
+// Unit returns the unit to show weights in, UnitGrams unless the file says
+// otherwise.
+func (c *Config) Unit() string {
+	unit, err := goscale.NormalizeUnit(c.Device.Unit)
+	if err != nil {
+		return goscale.UnitGrams
+	}
+	return unit
+}
+
+// Target returns the named target weight in grams.
+func (c *Config) Target(name string) (grams float64, ok bool) {
+	grams, ok = c.Targets[name]
+	return grams, ok
+}
+
+// TargetNames returns the names of the target weights in sorted order.
+func (c *Config) TargetNames() []string {
+	names := make([]string, 0, len(c.Targets))
+	for name := range c.Targets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// LoadTarePresets reads the tare presets file c names, as tare.LoadPresets
+// does.
+func (c *Config) LoadTarePresets() (*tare.Presets, error) {
+	path := c.TarePresets
+	if path == "" {
+		var err error
+		if path, err = tare.DefaultPresetsPath(); err != nil {
+			return nil, err
+		}
+	}
+	return tare.LoadPresets(path)
+}
+
+// Matches reports whether dev is the preferred scale: the one at the
+// configured address, or whose name starts with the configured name. Any
+// device matches when no name is configured.
+func (d DeviceConfig) Matches(dev *goscale.FoundDevice) bool {
+	return d.Name == "" || strings.HasPrefix(dev.Name, d.Name) || strings.EqualFold(dev.ID(), d.Name)
+}
+
+// Remember makes dev the preferred scale, by address, so it is picked
+// again next time even with others in range.
+func (c *Config) Remember(dev *goscale.FoundDevice) {
+	c.Device.Name = dev.ID()
+}
+
+// NewScale creates the scale for dev, with the configured driver if there
+// is one, and sets it to the configured smoothing. Scales that can't smooth
+// their own readings (see goscale.SmoothingConfigurable) are returned
+// without; run their updates through goscale.Smooth instead.
+func (c *Config) NewScale(dev *goscale.FoundDevice) (goscale.Scale, error) {
+	smoothing, err := c.SmoothingConfig()
+	if err != nil {
+		return nil, err
+	}
+	var s goscale.Scale
+	if c.Device.Driver != "" {
+		s, err = goscale.NewScaleForDeviceWithDriver(dev, c.Device.Driver)
+	} else {
+		s, err = goscale.NewScaleForDevice(dev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := goscale.SetSmoothing(s, smoothing); err != nil && !errors.Is(err, goscale.ErrNotSupported) {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,86 @@
+package drift
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// Compensator subtracts the drift predicted by a Model from weight readings.
+// Drift is measured from the first reading after creation or Reset, so
+// Reset it at the start of each brew, typically right after taring. It is
+// safe for concurrent use.
+type Compensator struct {
+	model Model
+
+	mu          sync.Mutex
+	start       time.Time
+	temperature float64
+	hasTemp     bool
+	startTemp   float64
+	hasStart    bool
+}
+
+// NewCompensator returns a Compensator using m.
+func NewCompensator(m Model) *Compensator {
+	return &Compensator{model: m}
+}
+
+// Reset starts measuring drift afresh from the next reading.
+func (c *Compensator) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start = time.Time{}
+	c.hasStart = false
+}
+
+// SetTemperature reports the current temperature in °C, for models with a
+// temperature term. Scales don't report temperature themselves, so it has
+// to come from elsewhere, e.g. a sensor on the brew bar.
+func (c *Compensator) SetTemperature(celsius float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.temperature = celsius
+	c.hasTemp = true
+}
+
+// Apply returns u with the predicted drift subtracted. Updates carrying an
+// error are returned unchanged.
+func (c *Compensator) Apply(u goscale.WeightUpdate) goscale.WeightUpdate {
+	if u.Error != nil {
+		return u
+	}
+	at := u.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.hasStart {
+		c.start = at
+		c.startTemp = c.temperature
+		c.hasStart = true
+	}
+
+	var tempDelta float64
+	if c.hasTemp {
+		tempDelta = c.temperature - c.startTemp
+	}
+	u.Value -= c.model.Predict(at.Sub(c.start), tempDelta)
+	return u
+}
+
+// Run applies compensation to every update from in. The returned channel is
+// closed when in closes.
+func (c *Compensator) Run(in <-chan goscale.WeightUpdate) <-chan goscale.WeightUpdate {
+	out := make(chan goscale.WeightUpdate, cap(in))
+	go func() {
+		defer close(out)
+		for u := range in {
+			out <- c.Apply(u)
+		}
+	}()
+	return out
+}
@@ -0,0 +1,112 @@
+// Package drift compensates for load cell drift: the slow sag or creep that
+// budget scales show under a constant load over the minutes of a pour-over.
+// A Model is learnt from calibration runs with a fixed load on the scale,
+// then a Compensator subtracts the drift it predicts from live readings.
+package drift
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/mlsorensen/goscale/pkg/session"
+)
+
+// ErrNotEnoughData is returned by Fit when the observations can't support a
+// model, e.g. because there are too few or they all share one time.
+var ErrNotEnoughData = errors.New("not enough data to fit a drift model")
+
+// Observation is a drift measurement from a calibration run.
+type Observation struct {
+	Elapsed time.Duration // Time since the run started
+	Drift   float64       // Grams the reading has moved since the run started
+
+	// TemperatureDelta is the change in temperature, in °C, since the run
+	// started. It is only used if every observation has one.
+	TemperatureDelta float64
+	HasTemperature   bool
+}
+
+// Model predicts drift as a linear function of elapsed time and, if it was
+// available during calibration, temperature change.
+type Model struct {
+	PerSecond float64 `json:"per_second"` // Grams of drift per second
+	PerDegree float64 `json:"per_degree"` // Grams of drift per °C of temperature change
+}
+
+// Predict returns the expected drift in grams after elapsed, with the
+// temperature having changed by tempDelta °C.
+func (m Model) Predict(elapsed time.Duration, tempDelta float64) float64 {
+	return m.PerSecond*elapsed.Seconds() + m.PerDegree*tempDelta
+}
+
+// Fit learns a model from calibration observations by least squares. The
+// temperature term is fitted only when every observation carries a
+// temperature; otherwise drift is modelled on time alone.
+func Fit(obs []Observation) (Model, error) {
+	if len(obs) < 2 {
+		return Model{}, ErrNotEnoughData
+	}
+	withTemp := true
+	for _, o := range obs {
+		withTemp = withTemp && o.HasTemperature
+	}
+
+	// Centre everything so the intercept, which a tare absorbs anyway,
+	// drops out of the normal equations.
+	n := float64(len(obs))
+	var mt, mT, md float64
+	for _, o := range obs {
+		mt += o.Elapsed.Seconds()
+		mT += o.TemperatureDelta
+		md += o.Drift
+	}
+	mt, mT, md = mt/n, mT/n, md/n
+
+	var stt, sTT, stT, std, sTd float64
+	for _, o := range obs {
+		t := o.Elapsed.Seconds() - mt
+		T := o.TemperatureDelta - mT
+		d := o.Drift - md
+		stt += t * t
+		sTT += T * T
+		stT += t * T
+		std += t * d
+		sTd += T * d
+	}
+
+	if withTemp {
+		det := stt*sTT - stT*stT
+		if math.Abs(det) > 1e-12 {
+			return Model{
+				PerSecond: (std*sTT - sTd*stT) / det,
+				PerDegree: (sTd*stt - std*stT) / det,
+			}, nil
+		}
+		// Temperature didn't vary independently of time; fall back to time.
+	}
+	if stt == 0 {
+		return Model{}, ErrNotEnoughData
+	}
+	return Model{PerSecond: std / stt}, nil
+}
+
+// FitSessions learns a time-only model from calibration sessions recorded
+// with a fixed load on the scale. Each session's drift is measured from its
+// own first sample.
+func FitSessions(sessions ...*session.Session) (Model, error) {
+	var obs []Observation
+	for _, s := range sessions {
+		if len(s.Samples) == 0 {
+			continue
+		}
+		first := s.Samples[0]
+		for _, smp := range s.Samples {
+			obs = append(obs, Observation{
+				Elapsed: smp.Elapsed - first.Elapsed,
+				Drift:   smp.Weight - first.Weight,
+			})
+		}
+	}
+	return Fit(obs)
+}
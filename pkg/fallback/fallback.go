@@ -0,0 +1,239 @@
+// Package fallback stands in for scale features the hardware lacks with
+// software equivalents: a host-side timer, flow rate computed from the
+// weight readings, and software tare. Applications can then code against
+// the same feature set whichever scale is connected.
+package fallback
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/flow"
+	"github.com/mlsorensen/goscale/pkg/tare"
+)
+
+// Emulated lists which features a wrapped Scale provides in software.
+type Emulated struct {
+	Timer bool
+	Flow  bool
+	Tare  bool
+}
+
+// Scale wraps a goscale.Scale, providing the timer, flow rate and tare in
+// software wherever the scale can't. Features the scale has itself are
+// always passed through to it. It is safe for concurrent use.
+//
+// Only the update channel returned by Scale.Connect is seen by the software
+// features, so connect through the wrapper, not the wrapped scale.
+type Scale struct {
+	goscale.Scale
+	emulated Emulated
+
+	tare *tare.SoftTare
+
+	mu      sync.Mutex
+	meter   *flow.Meter
+	flow    float64
+	watch   stopwatch
+	elapsed time.Duration // Last scale timer reading, for scales with a timer
+}
+
+var _ goscale.Scale = (*Scale)(nil)
+var _ goscale.Timer = (*Scale)(nil)
+var _ goscale.FlowRater = (*Scale)(nil)
+var _ goscale.ContextConnector = (*Scale)(nil)
+
+// Wrap returns s with its missing features provided in software. flowWindow
+// is the window flow rate is computed over; zero means flow.DefaultWindow.
+func Wrap(s goscale.Scale, flowWindow time.Duration) *Scale {
+	_, hasTimer := s.(goscale.Timer)
+	_, hasFlow := s.(goscale.FlowRater)
+	return &Scale{
+		Scale: s,
+		emulated: Emulated{
+			Timer: !hasTimer,
+			Flow:  !hasFlow,
+			Tare:  !s.GetFeatures().Tare,
+		},
+		tare:  tare.NewSoftTare(nil),
+		meter: flow.NewMeter(flowWindow),
+	}
+}
+
+// Emulated returns which features are being provided in software.
+func (s *Scale) Emulated() Emulated {
+	return s.emulated
+}
+
+// Unwrap returns the wrapped scale.
+func (s *Scale) Unwrap() goscale.Scale {
+	return s.Scale
+}
+
+// Connect connects the wrapped scale. Updates on the returned channel have
+// the software tare applied, and, if the timer is software, carry its
+// reading as their ScaleTimer.
+func (s *Scale) Connect() (<-chan goscale.WeightUpdate, error) {
+	return s.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect, giving up when ctx is done. It is needed so
+// goscale.ConnectContext doesn't find the wrapped scale's and skip the
+// software features.
+func (s *Scale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
+	in, err := goscale.ConnectContext(ctx, s.Scale)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.meter.Reset()
+	s.flow = 0
+	s.mu.Unlock()
+
+	out := make(chan goscale.WeightUpdate, cap(in))
+	go func() {
+		defer close(out)
+		for u := range in {
+			out <- s.apply(u)
+		}
+	}()
+	return out, nil
+}
+
+func (s *Scale) apply(u goscale.WeightUpdate) goscale.WeightUpdate {
+	if u.Error != nil {
+		return u
+	}
+	if u.Timestamp.IsZero() {
+		u.Timestamp = time.Now()
+	}
+	if s.emulated.Tare {
+		u = s.tare.Apply(u)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emulated.Timer {
+		u.ScaleTimer = s.watch.elapsed(u.Timestamp)
+		u.HasScaleTimer = true
+	} else if u.HasScaleTimer {
+		s.elapsed = u.ScaleTimer
+	}
+	if s.emulated.Flow {
+		if grams, err := goscale.ConvertWeight(u.Value, u.Unit, goscale.UnitGrams); err == nil {
+			s.flow = s.meter.Add(u.Timestamp, grams)
+		}
+	}
+	return u
+}
+
+// GetFeatures reports the features of the wrapped scale, with Tare always
+// supported.
+func (s *Scale) GetFeatures() goscale.ScaleFeatures {
+	f := s.Scale.GetFeatures()
+	f.Tare = true
+	return f
+}
+
+// Tare tares the scale, or zeroes the software tare if the scale can't.
+// The software tare takes effect at once, so blocking makes no difference.
+func (s *Scale) Tare(blocking bool) error {
+	if !s.emulated.Tare {
+		return s.Scale.Tare(blocking)
+	}
+	s.tare.Zero()
+	return nil
+}
+
+// StartTimer starts the scale's timer, or the software one.
+func (s *Scale) StartTimer() error {
+	if !s.emulated.Timer {
+		return goscale.StartTimer(s.Scale)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watch.start(time.Now())
+	return nil
+}
+
+// StopTimer stops the scale's timer, or the software one.
+func (s *Scale) StopTimer() error {
+	if !s.emulated.Timer {
+		return goscale.StopTimer(s.Scale)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watch.stop(time.Now())
+	return nil
+}
+
+// ResetTimer resets the scale's timer, or the software one.
+func (s *Scale) ResetTimer() error {
+	if !s.emulated.Timer {
+		return goscale.ResetTimer(s.Scale)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watch = stopwatch{}
+	return nil
+}
+
+// Elapsed returns the timer reading: the software timer's, or the last one
+// the scale sent.
+func (s *Scale) Elapsed() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.emulated.Timer {
+		return s.watch.elapsed(time.Now())
+	}
+	return s.elapsed
+}
+
+// FlowRate returns the flow rate in grams per second, as reported by the
+// scale, or computed from its readings if it doesn't report one.
+func (s *Scale) FlowRate() (float64, error) {
+	if !s.emulated.Flow {
+		return goscale.FlowRate(s.Scale)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flow, nil
+}
+
+// SoftTare returns the software tare, e.g. to tare to a preset. It only
+// affects readings if Emulated().Tare is set.
+func (s *Scale) SoftTare() *tare.SoftTare {
+	return s.tare
+}
+
+// stopwatch is a software timer.
+type stopwatch struct {
+	running bool
+	started time.Time
+	total   time.Duration // Accumulated before started
+}
+
+func (w *stopwatch) start(now time.Time) {
+	if !w.running {
+		w.running = true
+		w.started = now
+	}
+}
+
+func (w *stopwatch) stop(now time.Time) {
+	if w.running {
+		w.total += now.Sub(w.started)
+		w.running = false
+	}
+}
+
+func (w *stopwatch) elapsed(now time.Time) time.Duration {
+	if !w.running || now.Before(w.started) {
+		return w.total
+	}
+	return w.total + now.Sub(w.started)
+}
@@ -0,0 +1,142 @@
+// Package flow estimates flow rate, in grams per second, from a stream of
+// weight readings.
+package flow
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultWindow is a sensible window for espresso: long enough to average
+// out ±0.1 g reading jitter, short enough to follow a shot's ramp.
+const DefaultWindow = time.Second
+
+// PourOverWindow suits pour-over and batch brews, whose pours are slower
+// and lumpier, so a longer window gives a steadier rate.
+const PourOverWindow = 4 * time.Second
+
+// Method is how a Meter works out the rate from the readings in its window.
+type Method uint8
+
+const (
+	// Endpoints takes the change between the first and last readings in
+	// the window. It follows changes quickest.
+	Endpoints Method = iota
+	// LeastSquares fits a line through every reading in the window and
+	// takes its slope. It is steadier with noisy readings, but lags more.
+	LeastSquares
+)
+
+var methodNames = map[Method]string{
+	Endpoints:    "endpoints",
+	LeastSquares: "least squares",
+}
+
+func (m Method) String() string {
+	if name, ok := methodNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown Method (%d)", m)
+}
+
+// Config configures a Meter.
+type Config struct {
+	Window time.Duration // Non-positive means DefaultWindow
+	Method Method
+}
+
+type point struct {
+	t time.Time
+	w float64
+}
+
+// Meter estimates flow rate over a sliding time window. It is not safe for
+// concurrent use.
+type Meter struct {
+	window time.Duration
+	method Method
+	points []point
+}
+
+// NewMeter returns a Meter averaging over window. A non-positive window
+// means DefaultWindow.
+func NewMeter(window time.Duration) *Meter {
+	return NewMeterWithConfig(Config{Window: window})
+}
+
+// NewMeterWithConfig returns a Meter configured by cfg.
+func NewMeterWithConfig(cfg Config) *Meter {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	return &Meter{window: cfg.Window, method: cfg.Method}
+}
+
+// Window returns the length of the window the Meter averages over.
+func (m *Meter) Window() time.Duration {
+	return m.window
+}
+
+// Add records a reading taken at t and returns the updated flow estimate.
+// Readings must be added in time order.
+func (m *Meter) Add(t time.Time, weight float64) float64 {
+	m.points = append(m.points, point{t: t, w: weight})
+
+	// Drop readings that have fallen out of the window, keeping the newest
+	// one at or before the window start so the estimate always spans a full
+	// window once enough data has arrived.
+	cutoff := t.Add(-m.window)
+	drop := 0
+	for drop+1 < len(m.points) && !m.points[drop+1].t.After(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		m.points = append(m.points[:0], m.points[drop:]...)
+	}
+
+	return m.Rate()
+}
+
+// Rate returns the current flow estimate in grams per second, or 0 until
+// readings spanning some time have been added.
+func (m *Meter) Rate() float64 {
+	if len(m.points) < 2 {
+		return 0
+	}
+	first, last := m.points[0], m.points[len(m.points)-1]
+	dt := last.t.Sub(first.t).Seconds()
+	if dt <= 0 {
+		return 0
+	}
+	if m.method == LeastSquares {
+		return m.slope()
+	}
+	return (last.w - first.w) / dt
+}
+
+// slope returns the slope of the least squares line through the readings,
+// in grams per second.
+func (m *Meter) slope() float64 {
+	n := float64(len(m.points))
+	var sumT, sumW float64
+	for _, p := range m.points {
+		sumT += p.t.Sub(m.points[0].t).Seconds()
+		sumW += p.w
+	}
+	meanT, meanW := sumT/n, sumW/n
+	var cov, variance float64
+	for _, p := range m.points {
+		dt := p.t.Sub(m.points[0].t).Seconds() - meanT
+		cov += dt * (p.w - meanW)
+		variance += dt * dt
+	}
+	if variance == 0 {
+		return 0
+	}
+	return cov / variance
+}
+
+// Reset forgets all readings.
+func (m *Meter) Reset() {
+	m.points = m.points[:0]
+}
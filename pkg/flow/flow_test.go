@@ -0,0 +1,24 @@
+package flow
+
+import (
+	"testing"
+	"time"
+)
+
+// BenchmarkMeterAdd feeds the meter readings at 10 Hz, about what a scale
+// notifies at, so the window stays full.
+func BenchmarkMeterAdd(b *testing.B) {
+	for _, method := range []Method{Endpoints, LeastSquares} {
+		b.Run(method.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			m := NewMeterWithConfig(Config{Window: DefaultWindow, Method: method})
+			t := time.Now()
+			var weight float64
+			for b.Loop() {
+				t = t.Add(100 * time.Millisecond)
+				weight += 0.2
+				m.Add(t, weight)
+			}
+		})
+	}
+}
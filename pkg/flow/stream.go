@@ -0,0 +1,63 @@
+package flow
+
+import (
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// Reading is a flow rate estimate from one Meter in Watch.
+type Reading struct {
+	Config    Config // The meter's configuration, its Window defaulted
+	Rate      float64
+	Timestamp time.Time // The weight update's
+}
+
+// Watch passes on every update from in, and works out the flow rate from
+// them with a Meter for each of cfgs, so consumers can each use the window
+// and method that suits them: an espresso display a short window, a
+// pour-over guide a long one. Each meter's readings, in grams per second,
+// go to the channel at the same index in the returned slice; a reading is
+// dropped if its channel is full. Updates carrying an error, or in an
+// unknown unit, are passed on but not measured. All the returned channels
+// are closed when in closes.
+func Watch(in <-chan goscale.WeightUpdate, cfgs ...Config) (<-chan goscale.WeightUpdate, []<-chan Reading) {
+	out := make(chan goscale.WeightUpdate, cap(in))
+	meters := make([]*Meter, len(cfgs))
+	rates := make([]chan Reading, len(cfgs))
+	outs := make([]<-chan Reading, len(cfgs))
+	for i, cfg := range cfgs {
+		meters[i] = NewMeterWithConfig(cfg)
+		rates[i] = make(chan Reading, max(cap(in), 1))
+		outs[i] = rates[i]
+	}
+	go func() {
+		defer func() {
+			close(out)
+			for _, c := range rates {
+				close(c)
+			}
+		}()
+		for u := range in {
+			if g := goscale.ConvertUpdate(u, goscale.UnitGrams); g.Error == nil {
+				at := g.Timestamp
+				if at.IsZero() {
+					at = time.Now()
+				}
+				for i, m := range meters {
+					r := Reading{
+						Config:    Config{Window: m.window, Method: m.method},
+						Rate:      m.Add(at, g.Value),
+						Timestamp: at,
+					}
+					select {
+					case rates[i] <- r:
+					default:
+					}
+				}
+			}
+			out <- u
+		}
+	}()
+	return out, outs
+}
@@ -0,0 +1,201 @@
+// Package grind doses coffee by weight, switching a grinder off when the
+// scale under its cup reaches the target.
+package grind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/flow"
+)
+
+// Grinder is a grinder a Controller can switch on and off, e.g. through a
+// relay or a smart plug.
+type Grinder interface {
+	Start() error
+	Stop() error
+}
+
+// ErrDoseTimeout is returned by Dose if the target isn't reached in time,
+// e.g. because the hopper ran empty or the chute is clogged.
+var ErrDoseTimeout = errors.New("dose did not reach its target in time")
+
+// DoseConfig tunes a Controller.
+type DoseConfig struct {
+	// Target is the dose in grams.
+	Target float64
+
+	// Lag is how long the grinder keeps delivering after it is told to
+	// stop: the motor spinning down and grounds still in the chute. The
+	// grinder is stopped this far ahead of the target, going by the
+	// current flow. It is only a starting point; the Controller adjusts it
+	// after every dose.
+	Lag time.Duration
+
+	// Timeout bounds the grinding, and separately the wait for the weight
+	// to settle afterwards.
+	Timeout time.Duration
+
+	// Settle defines when the dose has settled, for reading the final
+	// weight.
+	Settle goscale.StableConfig
+}
+
+// DefaultDoseConfig doses 18 g.
+var DefaultDoseConfig = DoseConfig{
+	Target:  18,
+	Lag:     300 * time.Millisecond,
+	Timeout: 30 * time.Second,
+	Settle: goscale.StableConfig{
+		Window:    time.Second,
+		Tolerance: 0.2,
+	},
+}
+
+// Result describes a finished dose.
+type Result struct {
+	Target    float64       // Grams asked for
+	Weight    float64       // Grams dosed, once settled
+	StoppedAt float64       // Grams on the scale when the grinder was stopped
+	Ground    time.Duration // How long the grinder ran
+	Lag       time.Duration // The lag the grinder was stopped with
+}
+
+// Error returns how many grams the dose missed its target by; positive if
+// over.
+func (r Result) Error() float64 {
+	return r.Weight - r.Target
+}
+
+// Controller grinds doses by weight. It learns the grinder's lag from each
+// dose's error, so doses land closer to the target as it goes. It is safe
+// for concurrent use, though doses are ground one at a time.
+type Controller struct {
+	scale   goscale.Scale
+	grinder Grinder
+	cfg     DoseConfig
+
+	mu  sync.Mutex
+	lag time.Duration
+}
+
+// NewController returns a Controller dosing with g onto s.
+func NewController(s goscale.Scale, g Grinder, cfg DoseConfig) *Controller {
+	return &Controller{scale: s, grinder: g, cfg: cfg, lag: cfg.Lag}
+}
+
+// Lag returns the grinder lag the next dose will be stopped with.
+func (c *Controller) Lag() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lag
+}
+
+// Dose tares the scale, grinds until the dose, with what is still on its way
+// from the grinder, reaches the target, then waits for it to settle and
+// returns the result. in must be the scale's weight updates. The grinder is
+// always stopped before Dose returns.
+func (c *Controller) Dose(ctx context.Context, in <-chan goscale.WeightUpdate) (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := Result{Target: c.cfg.Target, Lag: c.lag}
+
+	if err := c.scale.Tare(true); err != nil {
+		return res, fmt.Errorf("tare: %w", err)
+	}
+	if err := c.awaitZero(ctx, in); err != nil {
+		return res, fmt.Errorf("tare: %w", err)
+	}
+
+	stoppedAt, rate, err := c.grind(ctx, in, &res)
+	if err != nil {
+		return res, err
+	}
+	res.StoppedAt = stoppedAt
+
+	sctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	settled, err := goscale.CaptureStable(sctx, in, c.cfg.Settle)
+	if err != nil {
+		return res, fmt.Errorf("waiting for the dose to settle: %w", err)
+	}
+	res.Weight = settled.Value
+
+	// Whatever landed after the stop, at the rate it was flowing, took
+	// this long to arrive; move halfway there, so one odd dose doesn't
+	// throw off the next.
+	if rate > 0 {
+		actual := time.Duration((res.Weight - stoppedAt) / rate * float64(time.Second))
+		c.lag = max((c.lag+actual)/2, 0)
+	}
+	return res, nil
+}
+
+// grind runs the grinder until the predicted weight reaches the target,
+// returning the weight and flow, in grams per second, when it stopped.
+func (c *Controller) grind(ctx context.Context, in <-chan goscale.WeightUpdate, res *Result) (weight, rate float64, err error) {
+	if err := c.grinder.Start(); err != nil {
+		return 0, 0, fmt.Errorf("start grinder: %w", err)
+	}
+	started := time.Now()
+	stop := func() error {
+		res.Ground = time.Since(started)
+		if err := c.grinder.Stop(); err != nil {
+			return fmt.Errorf("stop grinder: %w", err)
+		}
+		return nil
+	}
+
+	timeout := time.NewTimer(c.cfg.Timeout)
+	defer timeout.Stop()
+	meter := flow.NewMeter(flow.DefaultWindow)
+	for {
+		select {
+		case u, ok := <-in:
+			if !ok {
+				return 0, 0, errors.Join(goscale.ErrUpdatesClosed, stop())
+			}
+			if u = goscale.ConvertUpdate(u, goscale.UnitGrams); u.Error != nil {
+				continue
+			}
+			at := u.Timestamp
+			if at.IsZero() {
+				at = time.Now()
+			}
+			rate = meter.Add(at, u.Value)
+			if u.Value+max(rate, 0)*c.lag.Seconds() >= c.cfg.Target {
+				return u.Value, rate, stop()
+			}
+		case <-timeout.C:
+			return 0, 0, errors.Join(ErrDoseTimeout, stop())
+		case <-ctx.Done():
+			return 0, 0, errors.Join(ctx.Err(), stop())
+		}
+	}
+}
+
+// awaitZero reads updates until one shows the tare has taken effect.
+func (c *Controller) awaitZero(ctx context.Context, in <-chan goscale.WeightUpdate) error {
+	timeout := time.NewTimer(c.cfg.Timeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case u, ok := <-in:
+			if !ok {
+				return goscale.ErrUpdatesClosed
+			}
+			if u = goscale.ConvertUpdate(u, goscale.UnitGrams); u.Error == nil && math.Abs(u.Value) <= c.cfg.Settle.Tolerance {
+				return nil
+			}
+		case <-timeout.C:
+			return goscale.ErrTareNotConfirmed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/internal/connsup"
+	"tinygo.org/x/bluetooth"
+)
+
+// bleClient connects to a pressure sensor described by a Profile.
+type bleClient struct {
+	name    string
+	address bluetooth.Address
+	profile Profile
+
+	mu        sync.Mutex
+	connected bool
+	btDevice  goscale.Peripheral
+	sup       *connsup.Supervisor
+	out       chan goscale.PressureUpdate
+	seq       uint64
+}
+
+var _ goscale.PressureSensor = (*bleClient)(nil)
+
+func newBLEClient(device *goscale.FoundDevice, p Profile) *bleClient {
+	return &bleClient{name: device.Name, address: device.Address, profile: p}
+}
+
+func (c *bleClient) DeviceName() string {
+	return c.name
+}
+
+func (c *bleClient) DisplayName() string {
+	return c.profile.DisplayName
+}
+
+func (c *bleClient) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *bleClient) ConnectPressure(ctx context.Context) (<-chan goscale.PressureUpdate, error) {
+	if err := goscale.TryEnableAdapter(); err != nil {
+		return nil, err
+	}
+
+	device, err := connsup.Dial(ctx, c.address)
+	if err != nil {
+		return nil, err
+	}
+
+	var notifyChar goscale.Characteristic
+	err = connsup.Await(ctx, func() error {
+		chars, err := goscale.DiscoverChars(device, c.profile.Service, c.profile.Notify)
+		if err != nil {
+			return err
+		}
+		notifyChar = chars[0]
+		return nil
+	})
+	if err != nil {
+		_ = device.Disconnect()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.btDevice = device
+	c.out = make(chan goscale.PressureUpdate, 20)
+	c.seq = 0
+	c.mu.Unlock()
+
+	err = connsup.Await(ctx, func() error {
+		return notifyChar.EnableNotifications(goscale.RecoverNotifications(c.name, c.handleNotification, c.reportError))
+	})
+	if err != nil {
+		_ = device.Disconnect()
+		return nil, fmt.Errorf("failed to enable notifications: %w", err)
+	}
+
+	// These devices go quiet between shots, so only the link dropping ends
+	// the connection.
+	sup := connsup.Start(connsup.Config{
+		Address:    c.address,
+		Disconnect: func() { _ = c.Disconnect() },
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected, c.sup = true, sup
+	return c.out, nil
+}
+
+func (c *bleClient) Disconnect() error {
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
+		return nil
+	}
+	c.connected = false
+	close(c.out)
+	c.out = nil
+	sup := c.sup
+	c.mu.Unlock()
+
+	if sup != nil {
+		sup.Stop()
+	}
+	return c.btDevice.Disconnect()
+}
+
+func (c *bleClient) handleNotification(buf []byte) {
+	now := time.Now()
+	rd, ok := c.profile.Decode(buf)
+	if !ok {
+		log.Printf("unable to decode telemetry from %s: % X", c.name, buf)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.out == nil {
+		return
+	}
+	c.seq++
+	rd.Timestamp, rd.Seq = now, c.seq
+	select {
+	case c.out <- rd:
+	default:
+		// Drop rather than stall the notification goroutine.
+	}
+}
+
+// reportError sends err on the reading channel, if there is room.
+func (c *bleClient) reportError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.out == nil {
+		return
+	}
+	select {
+	case c.out <- goscale.PressureUpdate{Error: err}:
+	default:
+	}
+}
@@ -0,0 +1,85 @@
+// Package machine reads shot telemetry, such as pressure and flow, from
+// espresso machines and sensors that broadcast it over Bluetooth LE, so it
+// can be recorded alongside a scale's weight. Record puts a sensor's
+// readings into a session.Recorder, on the same timeline as the scale.
+//
+// Devices are described by Profiles and registered with goscale as the
+// goscale.CategoryPressureSensor category, so they are found and created
+// as scales are, and implement goscale.PressureSensor. Nothing is
+// registered by default. Profiles for the BOOKOO Espresso Monitor and the
+// Smart Espresso Profiler are provided, but their UUIDs and frame layouts
+// haven't been checked against captures yet, and the generic services they
+// use would otherwise claim unrelated devices; Register them to try them
+// out.
+package machine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/session"
+	"tinygo.org/x/bluetooth"
+)
+
+// Profile describes a pressure sensor that notifies its readings on a
+// single characteristic.
+type Profile struct {
+	Prefix      string // Advertised name prefix
+	DisplayName string
+
+	Service bluetooth.UUID
+	Notify  bluetooth.UUID
+
+	// Decode turns a notification into a reading, setting only its
+	// Pressure and Flow, or returns false if it isn't one.
+	Decode func([]byte) (goscale.PressureUpdate, bool)
+}
+
+// Register registers a profile's devices with goscale as pressure sensors,
+// replacing any registered with the same prefix.
+func Register(p Profile) {
+	goscale.RegisterDevice(goscale.CategoryPressureSensor, p.Prefix, func(d *goscale.FoundDevice) goscale.Device {
+		return newBLEClient(d, p)
+	}, goscale.DriverOptions{ServiceUUIDs: []bluetooth.UUID{p.Service}})
+}
+
+// NewSensor returns a client for a pressure sensor found by Scan.
+func NewSensor(device *goscale.FoundDevice) (goscale.PressureSensor, error) {
+	d, err := goscale.NewDevice(device)
+	if err != nil {
+		return nil, err
+	}
+	sensor, ok := d.(goscale.PressureSensor)
+	if !ok {
+		return nil, fmt.Errorf("device '%s' is not a pressure sensor", device.Name)
+	}
+	return sensor, nil
+}
+
+// Scan scans until ctx is done, sending pressure sensors as they are
+// found, as goscale.ScanStream does for scales.
+func Scan(ctx context.Context) (<-chan goscale.FoundDevice, error) {
+	return goscale.ScanStreamCategories(ctx, goscale.CategoryPressureSensor)
+}
+
+// Record adds every reading from in to r until in is closed, returning the
+// first journal error encountered, after draining in. Run it alongside the
+// scale's Recorder.Run to merge the two into one session timeline.
+func Record(r *session.Recorder, in <-chan goscale.PressureUpdate) error {
+	var firstErr error
+	for rd := range in {
+		if rd.Error != nil {
+			continue
+		}
+		err := r.RecordTelemetry(rd.Timestamp, session.Telemetry{
+			Pressure: rd.Pressure,
+			Flow:     rd.Flow,
+			HasFlow:  rd.HasFlow,
+		})
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
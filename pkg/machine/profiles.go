@@ -0,0 +1,75 @@
+package machine
+
+import (
+	"encoding/binary"
+
+	"github.com/mlsorensen/goscale"
+	"tinygo.org/x/bluetooth"
+)
+
+// BookooMonitor is the BOOKOO Espresso Monitor, a pressure sensor fitted
+// between the group head and portafilter. Its name shares the "BOOKOO"
+// prefix of the Themis scales, but its longer prefix keeps it from being
+// taken for one. Its UUIDs and frame layout are unverified, so it isn't
+// registered by default.
+var BookooMonitor = Profile{
+	Prefix:      "BOOKOO_EM",
+	DisplayName: "BOOKOO Espresso Monitor",
+	Service:     mustParseUUID("0FFF"),
+	Notify:      mustParseUUID("FF04"),
+	Decode:      decodeBookooMonitor,
+}
+
+// The Espresso Monitor's pressure frame: a product byte, a type byte, then
+// the pressure in hundredths of a bar, big-endian.
+const (
+	bookooMonitorProduct  = 0x02
+	bookooMonitorPressure = 0x0C
+	bookooMonitorFrameLen = 4
+)
+
+func decodeBookooMonitor(buf []byte) (goscale.PressureUpdate, bool) {
+	if len(buf) < bookooMonitorFrameLen || buf[0] != bookooMonitorProduct || buf[1] != bookooMonitorPressure {
+		return goscale.PressureUpdate{}, false
+	}
+	return goscale.PressureUpdate{Pressure: float64(binary.BigEndian.Uint16(buf[2:4])) / 100}, true
+}
+
+// SmartEspressoProfiler is the Smart Espresso Profiler, which reports the
+// pressure at the group head and, with its flow sensor fitted, the flow
+// into it. Its UUIDs and frame layout are unverified, so it isn't
+// registered by default.
+var SmartEspressoProfiler = Profile{
+	Prefix:      "Smart Espresso Profiler",
+	DisplayName: "Smart Espresso Profiler",
+	Service:     mustParseUUID("FFF0"),
+	Notify:      mustParseUUID("FFF4"),
+	Decode:      decodeSmartEspressoProfiler,
+}
+
+// The Smart Espresso Profiler's frame: the pressure in millibar, then
+// optionally the flow in hundredths of a millilitre per second, both
+// little-endian.
+const (
+	sepPressureLen = 2
+	sepFlowLen     = 4
+)
+
+func decodeSmartEspressoProfiler(buf []byte) (goscale.PressureUpdate, bool) {
+	if len(buf) < sepPressureLen {
+		return goscale.PressureUpdate{}, false
+	}
+	rd := goscale.PressureUpdate{Pressure: float64(binary.LittleEndian.Uint16(buf)) / 1000}
+	if len(buf) >= sepFlowLen {
+		rd.Flow, rd.HasFlow = float64(binary.LittleEndian.Uint16(buf[2:4]))/100, true
+	}
+	return rd, true
+}
+
+func mustParseUUID(s string) bluetooth.UUID {
+	u, err := bluetooth.ParseUUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,363 @@
+// Package mdns advertises a service on the local network with multicast DNS
+// and DNS-SD (RFC 6762 and 6763), so clients such as tablet apps can find it
+// without being told its address.
+//
+// It is a minimal responder: IPv4 only, on one interface, answering queries
+// for the advertised service and announcing it when started, when its TXT
+// records change and, with a TTL of zero, when closed. It doesn't probe for
+// name conflicts, so give each instance a name unique on the network, such
+// as one including the host name.
+package mdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Port is the mDNS port.
+const Port = 5353
+
+// TTL is the time to live given to the advertised records, in seconds, as
+// RFC 6762 recommends for records that name a host.
+const TTL = 120
+
+// legacyTTL is the most given to queriers not speaking mDNS, which send
+// from a port other than Port, so their caches don't keep stale records.
+const legacyTTL = 10
+
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: Port}
+
+// servicesName is queried to list every service type on the network.
+var servicesName = []string{"_services", "_dns-sd", "_udp", "local"}
+
+// Record types and classes.
+const (
+	typeA   uint16 = 1
+	typePTR uint16 = 12
+	typeTXT uint16 = 16
+	typeSRV uint16 = 33
+	typeANY uint16 = 255
+
+	classIN    uint16 = 1
+	classANY   uint16 = 255
+	cacheFlush uint16 = 0x8000 // Set on records only this host answers for
+	classMask  uint16 = 0x7FFF // Clears the cache flush or unicast response bit
+)
+
+// Service is a service to advertise.
+type Service struct {
+	// Instance names this instance of the service, e.g. "goscale on
+	// kitchen-pi". It may contain spaces and dots.
+	Instance string
+
+	// Type is the service type and protocol, e.g. "_goscale._tcp".
+	Type string
+
+	// Host is the host name the service is on, without ".local". Empty
+	// means os.Hostname.
+	Host string
+
+	Port int
+
+	// TXT is the service's metadata, as key=value strings.
+	TXT []string
+
+	// Interface is the network interface to advertise on. Nil means the
+	// system's default for multicast.
+	Interface *net.Interface
+
+	// IPs are the IPv4 addresses Host resolves to. Nil means those of
+	// Interface, or of every interface that is up if it is nil.
+	IPs []net.IP
+}
+
+// Responder advertises a Service until closed. It is safe for concurrent
+// use.
+type Responder struct {
+	conn *net.UDPConn
+	done chan struct{}
+
+	mu      sync.Mutex
+	svc     Service
+	closing bool
+}
+
+// Advertise starts advertising svc, announcing it straight away.
+func Advertise(svc Service) (*Responder, error) {
+	if svc.Instance == "" || svc.Type == "" || svc.Port <= 0 || svc.Port > 0xFFFF {
+		return nil, errors.New("mdns: service needs an instance, type and port")
+	}
+	if svc.Host == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("mdns: %w", err)
+		}
+		svc.Host = host
+	}
+	// A host name of "pi.lan" is advertised as "pi.local".
+	svc.Host, _, _ = strings.Cut(svc.Host, ".")
+	if svc.IPs == nil {
+		ips, err := interfaceIPs(svc.Interface)
+		if err != nil {
+			return nil, fmt.Errorf("mdns: %w", err)
+		}
+		svc.IPs = ips
+	}
+	if len(svc.IPs) == 0 {
+		return nil, errors.New("mdns: no IPv4 address to advertise")
+	}
+	service, instance, host := svc.names()
+	for _, name := range [][]string{service, instance, host} {
+		if err := checkLabels(name); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, strings.Join(name, "."))
+		}
+	}
+	if err := checkTXT(svc.TXT); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", svc.Interface, group)
+	if err != nil {
+		return nil, fmt.Errorf("mdns: %w", err)
+	}
+	r := &Responder{conn: conn, done: make(chan struct{}), svc: svc}
+	go r.serve()
+	go r.announce()
+	return r, nil
+}
+
+// SetTXT replaces the service's TXT records and announces the change.
+func (r *Responder) SetTXT(txt []string) error {
+	if err := checkTXT(txt); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.svc.TXT = append([]string(nil), txt...)
+	closing := r.closing
+	r.mu.Unlock()
+	if closing {
+		return nil
+	}
+	r.send(group, r.announcement(TTL))
+	return nil
+}
+
+// checkTXT checks each TXT string fits in a record.
+func checkTXT(txt []string) error {
+	for _, s := range txt {
+		if len(s) > 255 {
+			return fmt.Errorf("mdns: TXT string longer than 255 bytes: %.20q...", s)
+		}
+	}
+	return nil
+}
+
+// Close stops advertising the service, telling the network it has gone.
+func (r *Responder) Close() error {
+	r.mu.Lock()
+	if r.closing {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closing = true
+	r.mu.Unlock()
+
+	r.send(group, r.announcement(0))
+	close(r.done)
+	return r.conn.Close()
+}
+
+// announce sends the service's records unsolicited, twice, a second apart,
+// as RFC 6762 asks, so caches pick it up without querying.
+func (r *Responder) announce() {
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			select {
+			case <-r.done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		r.send(group, r.announcement(TTL))
+	}
+}
+
+// serve answers queries until the Responder is closed.
+func (r *Responder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-r.done:
+				return
+			default:
+			}
+			log.Printf("mdns: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if resp := r.answer(buf[:n], src.Port != Port); resp != nil {
+			to := group
+			if src.Port != Port {
+				to = src
+			}
+			r.send(to, resp)
+		}
+	}
+}
+
+func (r *Responder) send(to *net.UDPAddr, msg []byte) {
+	select {
+	case <-r.done:
+		return // Closed
+	default:
+	}
+	if _, err := r.conn.WriteToUDP(msg, to); err != nil {
+		log.Printf("mdns: %v", err)
+	}
+}
+
+// names returns the service's domain names, as labels.
+func (s *Service) names() (service, instance, host []string) {
+	service = append(strings.Split(s.Type, "."), "local")
+	instance = append([]string{s.Instance}, service...)
+	host = []string{s.Host, "local"}
+	return service, instance, host
+}
+
+// announcement is an unsolicited response carrying every record, with ttl.
+func (r *Responder) announcement(ttl uint32) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	service, instance, host := r.svc.names()
+
+	m := &message{}
+	m.answer(
+		ptrRecord(servicesName, service, ttl),
+		ptrRecord(service, instance, ttl),
+		srvRecord(instance, host, uint16(r.svc.Port), ttl, true),
+		txtRecord(instance, r.svc.TXT, ttl, true),
+	)
+	for _, ip := range r.svc.IPs {
+		m.answer(aRecord(host, ip, ttl, true))
+	}
+	return m.bytes()
+}
+
+// answer returns the response to query, or nil if it asks about nothing
+// this Responder advertises. Legacy queriers, not on Port, are answered as
+// a unicast DNS server would.
+func (r *Responder) answer(query []byte, legacy bool) []byte {
+	if len(query) < 12 || query[2]&0x80 != 0 { // A response, not a query
+		return nil
+	}
+	qdcount := int(binary.BigEndian.Uint16(query[4:6]))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	service, instance, host := r.svc.names()
+
+	ttl, flush := uint32(TTL), true
+	m := &message{}
+	if legacy {
+		ttl, flush = legacyTTL, false
+		m.id = binary.BigEndian.Uint16(query[0:2])
+	}
+
+	// Records asked for go in the answers; those the querier will want
+	// next, to reach the service, go along as additional records.
+	var instanceAsked, hostAsked, instanceWanted bool
+	off := 12
+	for range qdcount {
+		name, next, err := readName(query, off)
+		if err != nil || next+4 > len(query) {
+			return nil
+		}
+		qtype := binary.BigEndian.Uint16(query[next:])
+		qclass := binary.BigEndian.Uint16(query[next+2:]) & classMask
+		off = next + 4
+		if qclass != classIN && qclass != classANY {
+			continue
+		}
+		if legacy {
+			m.question(name, qtype, qclass)
+		}
+
+		switch {
+		case equalNames(name, servicesName) && (qtype == typePTR || qtype == typeANY):
+			m.answer(ptrRecord(servicesName, service, ttl))
+		case equalNames(name, service) && (qtype == typePTR || qtype == typeANY):
+			m.answer(ptrRecord(service, instance, ttl))
+			instanceWanted = true
+		case equalNames(name, instance) && (qtype == typeSRV || qtype == typeTXT || qtype == typeANY):
+			instanceAsked = true
+		case equalNames(name, host) && (qtype == typeA || qtype == typeANY):
+			hostAsked = true
+		}
+	}
+
+	instanceRecords := [][]byte{
+		srvRecord(instance, host, uint16(r.svc.Port), ttl, flush),
+		txtRecord(instance, r.svc.TXT, ttl, flush),
+	}
+	var hostRecords [][]byte
+	for _, ip := range r.svc.IPs {
+		hostRecords = append(hostRecords, aRecord(host, ip, ttl, flush))
+	}
+	switch {
+	case instanceAsked:
+		m.answer(instanceRecords...)
+	case instanceWanted:
+		m.additional(instanceRecords...)
+	}
+	switch {
+	case hostAsked:
+		m.answer(hostRecords...)
+	case instanceAsked || instanceWanted:
+		m.additional(hostRecords...)
+	}
+	if m.ancount == 0 {
+		return nil
+	}
+	return m.bytes()
+}
+
+// interfaceIPs returns the IPv4 addresses of ifi, or of every interface
+// that is up, not counting loopback, if ifi is nil.
+func interfaceIPs(ifi *net.Interface) ([]net.IP, error) {
+	ifis := []net.Interface{}
+	if ifi != nil {
+		ifis = append(ifis, *ifi)
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range all {
+			if i.Flags&net.FlagUp != 0 && i.Flags&net.FlagLoopback == 0 {
+				ifis = append(ifis, i)
+			}
+		}
+	}
+	var ips []net.IP
+	for _, i := range ifis {
+		addrs, err := i.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if n, ok := addr.(*net.IPNet); ok && n.IP.To4() != nil && !n.IP.IsLinkLocalUnicast() {
+				ips = append(ips, n.IP.To4())
+			}
+		}
+	}
+	return ips, nil
+}
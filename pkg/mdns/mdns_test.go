@@ -0,0 +1,242 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testResponder returns a Responder that isn't on the network, for
+// checking what it answers.
+func testResponder() *Responder {
+	return &Responder{done: make(chan struct{}), svc: Service{
+		Instance: "goscale on kitchen-pi",
+		Type:     "_goscale._tcp",
+		Host:     "kitchen-pi",
+		Port:     8080,
+		TXT:      []string{"path=/scales", "auth=none"},
+		IPs:      []net.IP{net.IPv4(192, 168, 1, 20)},
+	}}
+}
+
+type question struct {
+	name  string
+	qtype uint16
+}
+
+// query encodes a query with the given ID.
+func query(id uint16, qs ...question) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(qs)))
+	for _, q := range qs {
+		b = appendName(b, strings.Split(q.name, "."))
+		b = binary.BigEndian.AppendUint16(b, q.qtype)
+		b = binary.BigEndian.AppendUint16(b, classIN)
+	}
+	return b
+}
+
+type rr struct {
+	name  string
+	rtype uint16
+	class uint16
+	ttl   uint32
+	rdata []byte
+}
+
+// parse decodes a response, skipping its questions.
+func parse(t *testing.T, msg []byte) (id uint16, qdcount int, answers, extra []rr) {
+	t.Helper()
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[2:]) != flagsResponse {
+		t.Fatalf("not a response: % X", msg)
+	}
+	id = binary.BigEndian.Uint16(msg)
+	qdcount = int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+	arcount := int(binary.BigEndian.Uint16(msg[10:]))
+	off := 12
+	for range qdcount {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			t.Fatalf("question: %v", err)
+		}
+		off = next + 4
+	}
+	var records []rr
+	for range ancount + arcount {
+		name, next, err := readName(msg, off)
+		if err != nil || next+10 > len(msg) {
+			t.Fatalf("record at %d: malformed", off)
+		}
+		r := rr{
+			name:  strings.Join(name, "."),
+			rtype: binary.BigEndian.Uint16(msg[next:]),
+			class: binary.BigEndian.Uint16(msg[next+2:]),
+			ttl:   binary.BigEndian.Uint32(msg[next+4:]),
+		}
+		n := int(binary.BigEndian.Uint16(msg[next+8:]))
+		r.rdata = msg[next+10 : next+10+n]
+		records = append(records, r)
+		off = next + 10 + n
+	}
+	if off != len(msg) {
+		t.Errorf("%d bytes left over", len(msg)-off)
+	}
+	return id, qdcount, records[:ancount], records[ancount:]
+}
+
+// types lists the record types of rrs.
+func types(rrs []rr) []uint16 {
+	var ts []uint16
+	for _, r := range rrs {
+		ts = append(ts, r.rtype)
+	}
+	return ts
+}
+
+func TestAnswer(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        question
+		answers  []uint16
+		extra    []uint16
+		noAnswer bool
+	}{
+		{
+			name:    "browse",
+			q:       question{"_goscale._tcp.local", typePTR},
+			answers: []uint16{typePTR},
+			extra:   []uint16{typeSRV, typeTXT, typeA},
+		},
+		{
+			name:    "browse ignoring case",
+			q:       question{"_GoScale._TCP.local", typePTR},
+			answers: []uint16{typePTR},
+			extra:   []uint16{typeSRV, typeTXT, typeA},
+		},
+		{
+			name:    "resolve",
+			q:       question{"goscale on kitchen-pi._goscale._tcp.local", typeSRV},
+			answers: []uint16{typeSRV, typeTXT},
+			extra:   []uint16{typeA},
+		},
+		{
+			name:    "address",
+			q:       question{"kitchen-pi.local", typeA},
+			answers: []uint16{typeA},
+		},
+		{
+			name:    "service types",
+			q:       question{"_services._dns-sd._udp.local", typePTR},
+			answers: []uint16{typePTR},
+		},
+		{name: "another service", q: question{"_http._tcp.local", typePTR}, noAnswer: true},
+		{name: "another host", q: question{"oven.local", typeA}, noAnswer: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := testResponder().answer(query(0, tt.q), false)
+			if tt.noAnswer {
+				if resp != nil {
+					t.Errorf("answered % X", resp)
+				}
+				return
+			}
+			_, _, answers, extra := parse(t, resp)
+			if !slices.Equal(types(answers), tt.answers) || !slices.Equal(types(extra), tt.extra) {
+				t.Errorf("answered %v with %v extra, want %v with %v", types(answers), types(extra), tt.answers, tt.extra)
+			}
+			for _, r := range slices.Concat(answers, extra) {
+				if r.ttl != TTL {
+					t.Errorf("%s record TTL %d, want %d", r.name, r.ttl, TTL)
+				}
+				// Shared records, the PTRs, mustn't flush others' from caches.
+				if flush := r.class&cacheFlush != 0; flush != (r.rtype != typePTR) {
+					t.Errorf("%s record type %d has cache flush %t", r.name, r.rtype, flush)
+				}
+			}
+		})
+	}
+}
+
+func TestAnswerRecords(t *testing.T) {
+	resp := testResponder().answer(query(0, question{"_goscale._tcp.local", typePTR}), false)
+	_, _, answers, extra := parse(t, resp)
+
+	target, _, err := readName(answers[0].rdata, 0)
+	if err != nil || strings.Join(target, ".") != "goscale on kitchen-pi._goscale._tcp.local" {
+		t.Errorf("PTR to %q, %v", target, err)
+	}
+	srv := extra[0].rdata
+	host, _, err := readName(srv, 6)
+	if port := binary.BigEndian.Uint16(srv[4:]); port != 8080 || err != nil || strings.Join(host, ".") != "kitchen-pi.local" {
+		t.Errorf("SRV to %q port %d, %v", host, port, err)
+	}
+	if want := "\x0cpath=/scales\x09auth=none"; string(extra[1].rdata) != want {
+		t.Errorf("TXT = %q, want %q", extra[1].rdata, want)
+	}
+	if ip := net.IP(extra[2].rdata); !ip.Equal(net.IPv4(192, 168, 1, 20)) {
+		t.Errorf("A = %s", ip)
+	}
+}
+
+// Queriers not speaking mDNS, such as dig, are answered as a DNS server
+// would: with their ID and question, and records they won't cache long.
+func TestAnswerLegacy(t *testing.T) {
+	resp := testResponder().answer(query(0x1234, question{"kitchen-pi.local", typeA}), true)
+	id, qdcount, answers, _ := parse(t, resp)
+	if id != 0x1234 || qdcount != 1 {
+		t.Errorf("ID %#x with %d questions, want 0x1234 with 1", id, qdcount)
+	}
+	if len(answers) != 1 || answers[0].ttl != legacyTTL || answers[0].class != classIN {
+		t.Errorf("answers = %+v, want one A record with TTL %d and no cache flush", answers, legacyTTL)
+	}
+}
+
+func TestAnswerIgnores(t *testing.T) {
+	r := testResponder()
+	resp := r.answer(query(0, question{"kitchen-pi.local", typeA}), false)
+	if got := r.answer(resp, false); got != nil {
+		t.Errorf("answered a response: % X", got)
+	}
+	if got := r.answer([]byte{0, 0, 0}, false); got != nil {
+		t.Errorf("answered a truncated query: % X", got)
+	}
+	// A name pointing at itself.
+	loop := append(query(0), 0xC0, 12, 0, 1, 0, 1)
+	loop[5] = 1
+	if got := r.answer(loop, false); got != nil {
+		t.Errorf("answered a looping name: % X", got)
+	}
+}
+
+// A compressed name in a second question points into the first.
+func TestAnswerCompressedName(t *testing.T) {
+	q := query(0, question{"kitchen-pi.local", typeA})
+	q[5] = 2
+	q = append(q, 0xC0, 12) // kitchen-pi.local again
+	q = binary.BigEndian.AppendUint16(q, typeA)
+	q = binary.BigEndian.AppendUint16(q, classIN)
+	_, _, answers, _ := parse(t, testResponder().answer(q, false))
+	if len(answers) != 1 || answers[0].rtype != typeA {
+		t.Errorf("answered %v, want the A record once", types(answers))
+	}
+}
+
+func TestAnnouncement(t *testing.T) {
+	r := testResponder()
+	for _, ttl := range []uint32{TTL, 0} {
+		_, _, answers, _ := parse(t, r.announcement(ttl))
+		if want := []uint16{typePTR, typePTR, typeSRV, typeTXT, typeA}; !slices.Equal(types(answers), want) {
+			t.Errorf("announced %v, want %v", types(answers), want)
+		}
+		for _, a := range answers {
+			if a.ttl != ttl {
+				t.Errorf("%s record TTL %d, want %d", a.name, a.ttl, ttl)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+var errBadName = errors.New("mdns: malformed name")
+
+// message builds a DNS response. Names aren't compressed; the few records
+// a Responder sends fit in a packet without.
+type message struct {
+	id uint16
+
+	questions, answers, extra []byte
+	qdcount, ancount, arcount uint16
+}
+
+// flagsResponse marks a message as an authoritative response.
+const flagsResponse = 0x8400
+
+func (m *message) question(name []string, qtype, qclass uint16) {
+	m.questions = appendName(m.questions, name)
+	m.questions = binary.BigEndian.AppendUint16(m.questions, qtype)
+	m.questions = binary.BigEndian.AppendUint16(m.questions, qclass)
+	m.qdcount++
+}
+
+// answer adds records to the answer section.
+func (m *message) answer(rrs ...[]byte) {
+	for _, rr := range rrs {
+		m.answers = append(m.answers, rr...)
+		m.ancount++
+	}
+}
+
+// additional adds records to the additional section, for what the querier
+// is likely to ask next.
+func (m *message) additional(rrs ...[]byte) {
+	for _, rr := range rrs {
+		m.extra = append(m.extra, rr...)
+		m.arcount++
+	}
+}
+
+func (m *message) bytes() []byte {
+	b := make([]byte, 12, 12+len(m.questions)+len(m.answers)+len(m.extra))
+	binary.BigEndian.PutUint16(b[0:], m.id)
+	binary.BigEndian.PutUint16(b[2:], flagsResponse)
+	binary.BigEndian.PutUint16(b[4:], m.qdcount)
+	binary.BigEndian.PutUint16(b[6:], m.ancount)
+	binary.BigEndian.PutUint16(b[10:], m.arcount)
+	b = append(b, m.questions...)
+	b = append(b, m.answers...)
+	return append(b, m.extra...)
+}
+
+// record encodes a resource record with rdata. flush sets the cache flush
+// bit, for records only this host answers for.
+func record(name []string, rtype uint16, ttl uint32, flush bool, rdata []byte) []byte {
+	class := classIN
+	if flush {
+		class |= cacheFlush
+	}
+	b := appendName(nil, name)
+	b = binary.BigEndian.AppendUint16(b, rtype)
+	b = binary.BigEndian.AppendUint16(b, class)
+	b = binary.BigEndian.AppendUint32(b, ttl)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rdata)))
+	return append(b, rdata...)
+}
+
+func ptrRecord(name, target []string, ttl uint32) []byte {
+	return record(name, typePTR, ttl, false, appendName(nil, target))
+}
+
+func srvRecord(name, target []string, port uint16, ttl uint32, flush bool) []byte {
+	rdata := make([]byte, 6) // Priority and weight zero
+	binary.BigEndian.PutUint16(rdata[4:], port)
+	return record(name, typeSRV, ttl, flush, appendName(rdata, target))
+}
+
+func txtRecord(name []string, txt []string, ttl uint32, flush bool) []byte {
+	var rdata []byte
+	for _, s := range txt {
+		rdata = append(rdata, byte(len(s)))
+		rdata = append(rdata, s...)
+	}
+	if len(rdata) == 0 {
+		rdata = []byte{0} // RFC 6763 section 6.1: never empty
+	}
+	return record(name, typeTXT, ttl, flush, rdata)
+}
+
+func aRecord(name []string, ip net.IP, ttl uint32, flush bool) []byte {
+	return record(name, typeA, ttl, flush, ip.To4())
+}
+
+// appendName appends a domain name, given as labels, in wire format.
+// Labels must have been checked with checkLabels.
+func appendName(b []byte, labels []string) []byte {
+	for _, l := range labels {
+		b = append(b, byte(len(l)))
+		b = append(b, l...)
+	}
+	return append(b, 0)
+}
+
+// checkLabels checks labels fit in a name.
+func checkLabels(labels []string) error {
+	for _, l := range labels {
+		if len(l) == 0 || len(l) > 63 {
+			return errBadName
+		}
+	}
+	return nil
+}
+
+// readName reads the name at off in msg, following compression pointers,
+// and returns its labels and the offset just past it.
+func readName(msg []byte, off int) ([]string, int, error) {
+	var labels []string
+	end := -1
+	for hops := 0; ; hops++ {
+		if off >= len(msg) || hops > 64 {
+			return nil, 0, errBadName
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return labels, end, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) {
+				return nil, 0, errBadName
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+		case n&0xC0 != 0:
+			return nil, 0, errBadName
+		default:
+			if off+1+n > len(msg) {
+				return nil, 0, errBadName
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// equalNames compares names as DNS does, ignoring ASCII case.
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
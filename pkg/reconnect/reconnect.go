@@ -0,0 +1,232 @@
+// Package reconnect keeps a scale's update channel alive across brief
+// disconnects, such as the scale dropping out of range for a moment,
+// reconnecting with exponential backoff instead of closing the channel.
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// State is where a wrapped scale's connection is.
+type State uint8
+
+const (
+	Disconnected State = iota
+	Connected
+	Reconnecting // Dropped, and being re-established
+)
+
+func (s State) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	default:
+		return fmt.Sprintf("Unknown State (%d)", s)
+	}
+}
+
+// Config defines how hard to try reconnecting.
+type Config struct {
+	// Attempts is how many times to try reconnecting after each drop
+	// before giving up and closing the update channel. Zero means to keep
+	// trying until Disconnect.
+	Attempts int
+
+	// InitialDelay is how long to wait before the first attempt. Each
+	// failed attempt multiplies the wait by Multiplier, up to MaxDelay if
+	// set.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+
+	// ConnectTimeout bounds each attempt. Zero means
+	// goscale.DefaultConnectTimeout.
+	ConnectTimeout time.Duration
+
+	// OnStateChange, if set, is called with every change of state. It must
+	// not block.
+	OnStateChange func(State)
+}
+
+// DefaultConfig tries 8 times after each drop, starting after half a
+// second and backing off to 30 seconds between attempts.
+var DefaultConfig = Config{
+	Attempts:     8,
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+}
+
+// Scale wraps a goscale.Scale, reconnecting it when the connection drops
+// rather than closing the update channel. It is safe for concurrent use.
+//
+// While reconnecting, an update with goscale.ErrReconnecting as its Error is
+// sent; updates resume when the scale is back, numbered on from where they
+// left off, so Seq stays unique for the life of the channel. The channel
+// is only closed by Disconnect, or when reconnecting fails.
+type Scale struct {
+	goscale.Scale
+	cfg Config
+
+	mu     sync.Mutex
+	state  State
+	cancel context.CancelFunc // Stops reconnecting; set while connected
+}
+
+var _ goscale.Scale = (*Scale)(nil)
+var _ goscale.ContextConnector = (*Scale)(nil)
+
+// Wrap returns s, reconnecting as cfg says when its connection drops.
+func Wrap(s goscale.Scale, cfg Config) *Scale {
+	return &Scale{Scale: s, cfg: cfg}
+}
+
+// Unwrap returns the wrapped scale.
+func (s *Scale) Unwrap() goscale.Scale {
+	return s.Scale
+}
+
+// State returns where the connection is.
+func (s *Scale) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// IsConnected reports whether the scale is connected, which it isn't while
+// reconnecting.
+func (s *Scale) IsConnected() bool {
+	return s.State() == Connected
+}
+
+// Connect connects the wrapped scale, returning an update channel that
+// outlives transient disconnects.
+func (s *Scale) Connect() (<-chan goscale.WeightUpdate, error) {
+	return s.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect, giving up when ctx is done. ctx only bounds
+// this first connection, not the reconnects.
+func (s *Scale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
+	in, err := goscale.ConnectContext(ctx, s.Scale)
+	if err != nil {
+		return nil, err
+	}
+
+	rctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+	s.setState(Connected)
+
+	out := make(chan goscale.WeightUpdate, cap(in))
+	go s.run(rctx, in, out)
+	return out, nil
+}
+
+// Disconnect disconnects the scale and stops reconnecting. The update
+// channel is closed.
+func (s *Scale) Disconnect() error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+	return s.Scale.Disconnect()
+}
+
+func (s *Scale) run(ctx context.Context, in <-chan goscale.WeightUpdate, out chan<- goscale.WeightUpdate) {
+	defer close(out)
+	defer s.setState(Disconnected)
+
+	var base, last uint64 // Seq offset for this connection, and last sent
+	for {
+		for u := range in {
+			if u.Seq != 0 {
+				u.Seq += base
+				last = u.Seq
+			}
+			out <- u
+		}
+		if ctx.Err() != nil {
+			return // Disconnect was called.
+		}
+
+		s.setState(Reconnecting)
+		log.Printf("%s disconnected, reconnecting", s.DeviceName())
+		select {
+		case out <- goscale.WeightUpdate{Error: goscale.ErrReconnecting}:
+		case <-ctx.Done():
+			return
+		}
+		var err error
+		if in, err = s.reconnect(ctx); err != nil {
+			log.Printf("Giving up reconnecting to %s: %v", s.DeviceName(), err)
+			return
+		}
+		base = last
+		s.setState(Connected)
+		log.Printf("%s reconnected", s.DeviceName())
+	}
+}
+
+// reconnect tries to connect again, backing off between attempts, until it
+// succeeds, runs out of attempts, or ctx is done.
+func (s *Scale) reconnect(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
+	timeout := s.cfg.ConnectTimeout
+	if timeout <= 0 {
+		timeout = goscale.DefaultConnectTimeout
+	}
+	delay := s.cfg.InitialDelay
+	for attempt := 1; s.cfg.Attempts <= 0 || attempt <= s.cfg.Attempts; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		// The driver has already torn its side down, but make sure before
+		// starting over.
+		_ = s.Scale.Disconnect()
+		actx, cancel := context.WithTimeout(ctx, timeout)
+		in, err := goscale.ConnectContext(actx, s.Scale)
+		cancel()
+		if err == nil && ctx.Err() != nil {
+			// Disconnect was called while connecting.
+			_ = s.Scale.Disconnect()
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			return in, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("Reconnect attempt %d to %s failed: %v", attempt, s.DeviceName(), err)
+		delay = time.Duration(float64(delay) * max(s.cfg.Multiplier, 1))
+		if s.cfg.MaxDelay > 0 {
+			delay = min(delay, s.cfg.MaxDelay)
+		}
+	}
+	return nil, fmt.Errorf("no connection after %d attempts", s.cfg.Attempts)
+}
+
+func (s *Scale) setState(state State) {
+	s.mu.Lock()
+	changed := s.state != state
+	s.state = state
+	s.mu.Unlock()
+	if changed && s.cfg.OnStateChange != nil {
+		s.cfg.OnStateChange(state)
+	}
+}
@@ -0,0 +1,151 @@
+// Package roast helps coffee roasters track how much weight a batch loses
+// in the roaster: weigh the green beans, roast, weigh again, and keep a log
+// of the results.
+package roast
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// ErrIncomplete is returned when a batch is missing its green or roasted
+// weight.
+var ErrIncomplete = errors.New("batch needs both green and roasted weights")
+
+// Batch is one roast.
+type Batch struct {
+	Name string
+
+	Green   float64 // Grams before roasting
+	GreenAt time.Time
+
+	Roasted   float64 // Grams after roasting
+	RoastedAt time.Time
+}
+
+// Complete reports whether both weights have been recorded.
+func (b *Batch) Complete() bool {
+	return !b.GreenAt.IsZero() && !b.RoastedAt.IsZero()
+}
+
+// Loss returns the grams lost in roasting.
+func (b *Batch) Loss() float64 {
+	return b.Green - b.Roasted
+}
+
+// LossPercent returns the weight lost as a percentage of the green weight,
+// typically 12-20%.
+func (b *Batch) LossPercent() float64 {
+	if b.Green == 0 {
+		return 0
+	}
+	return b.Loss() / b.Green * 100
+}
+
+// CaptureGreen waits for the weight from in to settle and records it as the
+// green weight. Tare with the empty container on the scale first.
+func (b *Batch) CaptureGreen(ctx context.Context, in <-chan goscale.WeightUpdate, cfg goscale.StableConfig) error {
+	u, err := goscale.CaptureStable(ctx, in, cfg)
+	if err != nil {
+		return err
+	}
+	b.Green, b.GreenAt = u.Value, stamp(u)
+	return nil
+}
+
+// CaptureRoasted waits for the weight from in to settle and records it as
+// the roasted weight.
+func (b *Batch) CaptureRoasted(ctx context.Context, in <-chan goscale.WeightUpdate, cfg goscale.StableConfig) error {
+	u, err := goscale.CaptureStable(ctx, in, cfg)
+	if err != nil {
+		return err
+	}
+	b.Roasted, b.RoastedAt = u.Value, stamp(u)
+	return nil
+}
+
+func stamp(u goscale.WeightUpdate) time.Time {
+	if u.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return u.Timestamp
+}
+
+var csvHeader = []string{"name", "green_at", "green_g", "roasted_at", "roasted_g", "loss_g", "loss_percent"}
+
+// AppendCSV appends a completed batch to the CSV log at path, creating it,
+// with a header row, if it doesn't exist.
+func AppendCSV(path string, b *Batch) error {
+	if !b.Complete() {
+		return ErrIncomplete
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open roast log: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	if info.Size() == 0 {
+		_ = w.Write(csvHeader)
+	}
+	_ = w.Write([]string{
+		b.Name,
+		b.GreenAt.Format(time.RFC3339),
+		formatGrams(b.Green),
+		b.RoastedAt.Format(time.RFC3339),
+		formatGrams(b.Roasted),
+		formatGrams(b.Loss()),
+		strconv.FormatFloat(b.LossPercent(), 'f', 2, 64),
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("could not write roast log: %w", err)
+	}
+	return f.Sync()
+}
+
+func formatGrams(g float64) string {
+	return strconv.FormatFloat(g, 'f', 1, 64)
+}
+
+// ReadCSV reads the batches from a log written by AppendCSV.
+func ReadCSV(r io.Reader) ([]Batch, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	var batches []Batch
+	for i, row := range rows[1:] {
+		if len(row) < 5 {
+			return nil, fmt.Errorf("roast log line %d: expected at least 5 fields, got %d", i+2, len(row))
+		}
+		b := Batch{Name: row[0]}
+		var errs [4]error
+		b.GreenAt, errs[0] = time.Parse(time.RFC3339, row[1])
+		b.Green, errs[1] = strconv.ParseFloat(row[2], 64)
+		b.RoastedAt, errs[2] = time.Parse(time.RFC3339, row[3])
+		b.Roasted, errs[3] = strconv.ParseFloat(row[4], 64)
+		if err := errors.Join(errs[:]...); err != nil {
+			return nil, fmt.Errorf("roast log line %d: %w", i+2, err)
+		}
+		batches = append(batches, b)
+	}
+	return batches, nil
+}
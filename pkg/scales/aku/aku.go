@@ -2,37 +2,54 @@ package aku
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/internal/connsup"
+	"github.com/mlsorensen/goscale/pkg/flow"
 	"github.com/mlsorensen/goscale/pkg/scales/aku/comms"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 	"tinygo.org/x/bluetooth"
 )
 
+// namePrefix is the device name prefix the driver is registered under.
+const namePrefix = "Varia AKU"
+
 func init() {
-	goscale.Register("Varia AKU", New)
+	goscale.Register(namePrefix, New)
 }
 
 type AkuScale struct {
-	name           string
-	address        bluetooth.Address
-	disconnectCtx  context.Context
-	disconnectFunc context.CancelFunc
-	connected      bool
+	name    string
+	address bluetooth.Address
+	sup     atomic.Pointer[connsup.Supervisor]
+
+	mu        sync.Mutex // Guards connected
+	connected bool
+
+	btDevice   goscale.Peripheral
+	writeChar  goscale.Characteristic
+	notifyChar goscale.Characteristic
 
-	btDevice   bluetooth.Device
-	writeChar  bluetooth.DeviceCharacteristic
-	notifyChar bluetooth.DeviceCharacteristic
+	updates goscale.UpdateStream
+	seq     uint64
+	flow    *flow.Meter
 
-	weightUpdateChan chan goscale.WeightUpdate
-	lastNotified     time.Time
+	limiter atomic.Pointer[goscale.CommandLimiter]
+	quirks  goscale.Quirks
 }
 
 // This line is the compile-time check. It will fail to compile if
 // *AkuScale ever stops satisfying the goscale.Scale interface.
 var _ goscale.Scale = (*AkuScale)(nil)
+var _ goscale.ContextConnector = (*AkuScale)(nil)
+var _ goscale.CommandLimited = (*AkuScale)(nil)
+var _ goscale.OverflowConfigurable = (*AkuScale)(nil)
+var _ goscale.SmoothingConfigurable = (*AkuScale)(nil)
+var _ goscale.TareOffsetter = (*AkuScale)(nil)
+var _ goscale.DisconnectReasoner = (*AkuScale)(nil)
 
 var features = goscale.ScaleFeatures{
 	Tare: true,
@@ -45,77 +62,130 @@ func New(device *goscale.FoundDevice) goscale.Scale {
 	}
 }
 
+// SetCommandLimiter rate limits the commands sent to the scale. Nil, the
+// default, turns limiting off.
+func (a *AkuScale) SetCommandLimiter(limiter *goscale.CommandLimiter) {
+	a.limiter.Store(limiter)
+}
+
+// SetOverflowPolicy sets what happens to weight updates when the consumer
+// falls behind. The default, goscale.OverflowBlock, holds up notifications
+// until it catches up.
+func (a *AkuScale) SetOverflowPolicy(p goscale.OverflowPolicy) {
+	a.updates.SetPolicy(p)
+}
+
+// SetSmoothing sets the filter weight updates are run through. The
+// default, goscale.SmoothingRaw, passes them on as the scale sent them.
+func (a *AkuScale) SetSmoothing(cfg goscale.SmoothingConfig) {
+	a.updates.SetSmoothing(cfg)
+}
+
+// SetTareOffset sets the grams subtracted from weight updates, such as a
+// container's. See goscale.TareToOffset.
+func (a *AkuScale) SetTareOffset(grams float64) {
+	a.updates.SetTareOffset(grams)
+}
+
+// TareOffset returns the grams subtracted from weight updates.
+func (a *AkuScale) TareOffset() float64 {
+	return a.updates.TareOffset()
+}
+
+// DroppedUpdates returns how many weight updates have been dropped under the
+// overflow policy since the scale connected.
+func (a *AkuScale) DroppedUpdates() uint64 {
+	return a.updates.Dropped()
+}
+
 func (a *AkuScale) GetFeatures() goscale.ScaleFeatures {
 	return features
 }
 
 func (a *AkuScale) Connect() (<-chan goscale.WeightUpdate, error) {
+	return a.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect, giving up and disconnecting if ctx is done
+// before the scale is connected and streaming.
+func (a *AkuScale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
 	err := goscale.TryEnableAdapter()
 	if err != nil {
 		return nil, err
 	}
 
-	a.weightUpdateChan = make(chan goscale.WeightUpdate, 20)
-
-	a.disconnectCtx, a.disconnectFunc = context.WithCancel(context.Background())
+	updates := a.updates.Open(20)
+	a.seq = 0
+	a.flow = flow.NewMeter(flow.DefaultWindow)
+	// The firmware version can't be read, so any version-specific quirks
+	// apply.
+	a.quirks = goscale.QuirksFor(namePrefix, "")
 
-	a.btDevice, err = goscale.BTAdapter.Connect(a.address, bluetooth.ConnectionParams{})
+	a.btDevice, err = connsup.Dial(ctx, a.address)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = a.setupCharacteristics()
+	err = connsup.Await(ctx, a.setupCharacteristics)
 	if err != nil {
-		_ = a.Disconnect()
+		_ = a.btDevice.Disconnect()
 		return nil, err
 	}
 
 	log.Println("setting up notifications")
-	err = a.setupNotifications()
+	err = connsup.Await(ctx, a.setupNotifications)
 	if err != nil {
-		_ = a.Disconnect()
+		_ = a.btDevice.Disconnect()
 		return nil, err
 	}
-	a.lastNotified = time.Now()
-
+	a.mu.Lock()
 	a.connected = true
-
-	// start the connectivity monitor
-	go func() {
-		for {
-			select {
-			case <-a.disconnectCtx.Done():
-				_ = a.Disconnect()
-				return
-			default:
-				// If we haven't received notifications in a while, disconnect
-				if time.Now().After(a.lastNotified.Add(time.Second)) {
-					_ = a.Disconnect()
-				}
-			}
-		}
-	}()
-
-	return a.weightUpdateChan, nil
+	a.mu.Unlock()
+
+	// The AKU streams continuously, so a second without notifications means
+	// the link is gone.
+	a.sup.Store(connsup.Start(connsup.Config{
+		Interval:   250 * time.Millisecond,
+		Silence:    time.Second,
+		Address:    a.address,
+		Disconnect: func() { _ = a.Disconnect() },
+	}))
+
+	return updates, nil
 }
 
 func (a *AkuScale) Disconnect() error {
+	// Idempotent — the supervisor and the external scale.Driver can race
+	// into Disconnect. Closing the update channel twice panics.
+	a.mu.Lock()
+	if !a.connected {
+		a.mu.Unlock()
+		return nil
+	}
+	a.connected = false
+	a.mu.Unlock()
+
 	err := a.btDevice.Disconnect()
-	if err != nil {
-		// are we still connected or not? who knows
-		return err
+	a.updates.Close()
+	if sup := a.sup.Load(); sup != nil {
+		sup.Stop()
 	}
-	//TODO: mutex
-	if a.weightUpdateChan != nil {
-		close(a.weightUpdateChan)
+	return err
+}
+
+// DisconnectReason returns why the last connection ended.
+func (a *AkuScale) DisconnectReason() goscale.DisconnectReason {
+	sup := a.sup.Load()
+	if sup == nil {
+		return goscale.DisconnectUnknown
 	}
-	a.disconnectFunc()
-	a.connected = false
-	return nil
+	return sup.Reason()
 }
 
 func (a *AkuScale) IsConnected() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	return a.connected
 }
 
@@ -128,10 +198,16 @@ func (a *AkuScale) DisplayName() string {
 }
 
 func (a *AkuScale) Tare(blocking bool) error {
-	buf := []byte{0xfa, 0x82, 0x01, 0x01}
-	xor := buf[1] ^ buf[2] ^ buf[3]
-	_, err := a.writeChar.WriteWithoutResponse(append(buf, xor))
-	return err
+	return a.limiter.Load().Do(goscale.CommandTare, func() error {
+		if _, err := a.writeChar.WriteWithoutResponse(comms.BuildTareCommand()); err != nil {
+			return err
+		}
+		if a.quirks.Has(goscale.QuirkResubscribeAfterTare) {
+			log.Println("re-enabling notifications after tare")
+			return a.setupNotifications()
+		}
+		return nil
+	})
 }
 
 func (a *AkuScale) AdvanceSleepTimeout() error {
@@ -151,51 +227,43 @@ func (a *AkuScale) GetBatteryChargePercent() (float64, error) {
 
 func (a *AkuScale) setupCharacteristics() error {
 	log.Println("Discovering services...")
-	services, err := a.btDevice.DiscoverServices([]bluetooth.UUID{comms.AkuServiceUUID})
+	chars, err := goscale.DiscoverChars(a.btDevice, comms.AkuServiceUUID, comms.AkuCommandCharUUID, comms.AkuNotifyCharUUID)
 	if err != nil {
-		return fmt.Errorf("could not discover services: %w", err)
-	}
-
-	if len(services) == 0 {
-		return errors.New("could not find the Lunar BT service")
-	}
-
-	for _, service := range services {
-		log.Printf("found service %v, scanning for write char", service)
-		chars, err := service.DiscoverCharacteristics([]bluetooth.UUID{
-			comms.AkuCommandCharUUID,
-			comms.AkuNotifyCharUUID,
-		})
-
-		if err != nil || len(chars) != 2 {
-			return fmt.Errorf("could not discover characteristics: %w", err)
-		}
-
-		for _, char := range chars {
-			if char.UUID() == comms.AkuCommandCharUUID {
-				a.writeChar = char
-			}
-			if char.UUID() == comms.AkuNotifyCharUUID {
-				a.notifyChar = char
-			}
-		}
+		return err
 	}
+	a.writeChar, a.notifyChar = chars[0], chars[1]
 
 	log.Println("Successfully set up characteristics.")
 	return nil
 }
 
 func (a *AkuScale) handleNotification(buf []byte) {
-	a.lastNotified = time.Now()
-	weight, ok := comms.DecodeStatusUpdate(buf)
+	// Stamp the reading on arrival, before decoding or any channel send can
+	// delay it.
+	now := time.Now()
+	if sup := a.sup.Load(); sup != nil {
+		sup.Notified(now)
+	}
+	raw, ok := comms.DecodeRawWeight(buf)
 	if !ok {
 		log.Printf("unable to decode raw data from notification")
 	}
-	a.weightUpdateChan <- goscale.WeightUpdate{Value: weight}
+	weight := float64(raw) / comms.WeightDivisor
+	a.seq++
+	a.updates.Send(goscale.WeightUpdate{
+		Value:       weight,
+		Seq:         a.seq,
+		Timestamp:   now,
+		FlowRate:    a.flow.Add(now, weight),
+		HasFlowRate: true,
+		Raw:         raw,
+		RawDivisor:  comms.WeightDivisor,
+		HasRaw:      ok,
+	})
 }
 
 func (a *AkuScale) setupNotifications() error {
-	err := a.notifyChar.EnableNotifications(a.handleNotification)
+	err := a.notifyChar.EnableNotifications(goscale.RecoverNotifications(a.name, a.handleNotification, a.reportError))
 	if err != nil {
 		return fmt.Errorf("failed to enable notifications: %w", err)
 	}
@@ -212,3 +280,9 @@ func (a *AkuScale) GetBeep() bool {
 	//TODO implement me
 	panic("implement me")
 }
+
+// reportError sends err on the update channel, if there is room. It must not
+// block, as it runs on the notification goroutine.
+func (a *AkuScale) reportError(err error) {
+	a.updates.TrySend(goscale.WeightUpdate{Error: err})
+}
@@ -0,0 +1,20 @@
+// Code generated by cmdgen from commands.yaml; DO NOT EDIT.
+
+package comms
+
+// BuildTareCommand creates the command to tare the scale.
+func BuildTareCommand() []byte {
+	return encodeFrame(0x82, []byte{0x01, 0x01})
+}
+
+// encodeFrame frames a command: the header, op code and payload, then the
+// checksum.
+func encodeFrame(op byte, payload []byte) []byte {
+	msg := append([]byte{0xFA}, op)
+	msg = append(msg, payload...)
+	var csum byte
+	for _, c := range msg[1:] {
+		csum ^= c
+	}
+	return append(msg, csum)
+}
@@ -1,5 +1,7 @@
 package comms
 
+//go:generate go run github.com/mlsorensen/goscale/internal/cmdgen
+
 import (
 	"tinygo.org/x/bluetooth"
 )
@@ -10,15 +12,24 @@ var (
 	AkuNotifyCharUUID, _  = bluetooth.ParseUUID("FFF1")
 )
 
+// WeightDivisor is what the raw weight is divided by to give grams.
+const WeightDivisor = 100
+
 // DecodeStatusUpdate decodes the raw Aku notification. Returns the weight and whether decode was successful
 func DecodeStatusUpdate(rawStatus []byte) (float64, bool) {
+	raw, ok := DecodeRawWeight(rawStatus)
+	return float64(raw) / WeightDivisor, ok
+}
+
+// DecodeRawWeight is DecodeStatusUpdate, returning the signed weight as sent,
+// in 1/WeightDivisor grams.
+func DecodeRawWeight(rawStatus []byte) (int64, bool) {
 	if rawStatus[1] == 0x01 {
-		sign := 1.0
+		var sign int64 = 1
 		if (rawStatus[3] & 0x10) != 0 {
-			sign = -1.0
+			sign = -1
 		}
-		actualData := sign * (float64(((int(rawStatus[3]) & 0x0f) << 16) + (int(rawStatus[4]) << 8) + int(rawStatus[5])))
-		return actualData / 100, true
+		return sign * int64((int(rawStatus[3])&0x0f)<<16+int(rawStatus[4])<<8+int(rawStatus[5])), true
 	}
 	return 0, false
 }
@@ -0,0 +1,13 @@
+package comms
+
+import "testing"
+
+// weightStatus is a 18.30 g weight notification.
+var weightStatus = []byte{0x02, 0x01, 0x00, 0x00, 0x07, 0x26}
+
+func BenchmarkDecodeRawWeight(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = DecodeRawWeight(weightStatus)
+	}
+}
@@ -0,0 +1,217 @@
+// Package generic implements a goscale.Scale driver configured by a
+// descriptor file instead of code. Many inexpensive scales differ only in
+// their UUIDs, where the weight sits in the notification frame and what
+// bytes tare them; a descriptor captures those details so such a scale can
+// be supported without writing Go.
+//
+// A descriptor in YAML looks like:
+//
+//	name: ACME
+//	display_name: Acme kitchen scale
+//	service: "0FFE"
+//	notify_char: "FF11"
+//	command_char: "FF12"
+//	tare_command: "03 0a 01 00 00 08"
+//	frame:
+//	  min_length: 20
+//	  match: [{offset: 0, value: 0x03}, {offset: 1, value: 0x0b}]
+//	  offset: 7
+//	  length: 3
+//	  endian: big
+//	  sign_offset: 6
+//	  sign_mask: 0xff
+//	  sign_value: 0x2d
+//	  scale: 0.01
+//
+// Load a descriptor with LoadFile and make it available to scanning with
+// Register.
+package generic
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"tinygo.org/x/bluetooth"
+)
+
+// Descriptor describes a scale's Bluetooth protocol.
+type Descriptor struct {
+	// Name is the device name prefix the descriptor applies to.
+	Name string `json:"name" yaml:"name"`
+
+	// DisplayName is the user-friendly model name. Defaults to Name.
+	DisplayName string `json:"display_name" yaml:"display_name"`
+
+	// Service, NotifyChar and CommandChar are the UUIDs of the scale's
+	// service, the characteristic it sends weight frames on, and the one it
+	// takes commands on. CommandChar may be empty if no commands are used.
+	Service     string `json:"service" yaml:"service"`
+	NotifyChar  string `json:"notify_char" yaml:"notify_char"`
+	CommandChar string `json:"command_char" yaml:"command_char"`
+
+	// TareCommand is the hex-encoded frame that tares the scale, e.g.
+	// "03 0a 01 00 00 08". Empty if the scale can't be tared remotely.
+	TareCommand string `json:"tare_command" yaml:"tare_command"`
+
+	// Frame describes where the weight is found in a notification.
+	Frame FrameLayout `json:"frame" yaml:"frame"`
+
+	serviceUUID bluetooth.UUID
+	notifyUUID  bluetooth.UUID
+	commandUUID bluetooth.UUID
+	tare        []byte
+}
+
+// ByteMatch requires the byte at Offset to equal Value.
+type ByteMatch struct {
+	Offset int  `json:"offset" yaml:"offset"`
+	Value  byte `json:"value" yaml:"value"`
+}
+
+// FrameLayout describes how to read the weight out of a notification frame.
+type FrameLayout struct {
+	// MinLength is the shortest frame that holds a weight.
+	MinLength int `json:"min_length" yaml:"min_length"`
+
+	// Match lists bytes that identify a weight frame; frames that don't
+	// match are ignored.
+	Match []ByteMatch `json:"match" yaml:"match"`
+
+	// Offset and Length locate the raw weight value, 1 to 4 bytes.
+	Offset int `json:"offset" yaml:"offset"`
+	Length int `json:"length" yaml:"length"`
+
+	// Endian is "big" or "little". Defaults to big.
+	Endian string `json:"endian" yaml:"endian"`
+
+	// Signed treats the raw value as two's complement.
+	Signed bool `json:"signed" yaml:"signed"`
+
+	// SignOffset, if set, is a byte whose masked value marks the weight as
+	// negative: it is negative when frame[SignOffset]&SignMask == SignValue.
+	// SignMask defaults to 0xff.
+	SignOffset *int `json:"sign_offset" yaml:"sign_offset"`
+	SignMask   byte `json:"sign_mask" yaml:"sign_mask"`
+	SignValue  byte `json:"sign_value" yaml:"sign_value"`
+
+	// Scale converts the raw value to grams, e.g. 0.01 when the scale
+	// reports hundredths of a gram. Defaults to 1.
+	Scale float64 `json:"scale" yaml:"scale"`
+}
+
+// LoadFile reads a descriptor from path. Files ending in .json are parsed as
+// JSON and anything else as YAML.
+func LoadFile(path string) (*Descriptor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read descriptor: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ParseJSON(data)
+	}
+	return ParseYAML(data)
+}
+
+// ParseJSON parses and validates a JSON descriptor.
+func ParseJSON(data []byte) (*Descriptor, error) {
+	var d Descriptor
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("invalid descriptor: %w", err)
+	}
+	return &d, d.init()
+}
+
+// ParseYAML parses and validates a YAML descriptor.
+func ParseYAML(data []byte) (*Descriptor, error) {
+	var d Descriptor
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("invalid descriptor: %w", err)
+	}
+	return &d, d.init()
+}
+
+// init validates the descriptor, fills in defaults and parses the UUIDs and
+// tare command.
+func (d *Descriptor) init() error {
+	if d.Name == "" {
+		return errors.New("invalid descriptor: name is required")
+	}
+	if d.DisplayName == "" {
+		d.DisplayName = d.Name
+	}
+
+	var err error
+	if d.serviceUUID, err = parseUUID("service", d.Service); err != nil {
+		return err
+	}
+	if d.notifyUUID, err = parseUUID("notify_char", d.NotifyChar); err != nil {
+		return err
+	}
+	if d.CommandChar != "" {
+		if d.commandUUID, err = parseUUID("command_char", d.CommandChar); err != nil {
+			return err
+		}
+	}
+
+	if d.TareCommand != "" {
+		if d.CommandChar == "" {
+			return errors.New("invalid descriptor: tare_command needs command_char")
+		}
+		d.tare, err = hex.DecodeString(strings.ReplaceAll(d.TareCommand, " ", ""))
+		if err != nil {
+			return fmt.Errorf("invalid descriptor: tare_command: %w", err)
+		}
+	}
+
+	return d.Frame.init()
+}
+
+func (f *FrameLayout) init() error {
+	if f.Length < 1 || f.Length > 4 {
+		return fmt.Errorf("invalid descriptor: frame length must be 1 to 4 bytes, got %d", f.Length)
+	}
+	if f.Offset < 0 {
+		return fmt.Errorf("invalid descriptor: frame offset %d is negative", f.Offset)
+	}
+	switch f.Endian {
+	case "":
+		f.Endian = "big"
+	case "big", "little":
+	default:
+		return fmt.Errorf("invalid descriptor: frame endian must be big or little, got %q", f.Endian)
+	}
+	if f.SignOffset != nil && f.SignMask == 0 {
+		f.SignMask = 0xff
+	}
+	if f.Scale == 0 {
+		f.Scale = 1
+	}
+
+	// Make sure every byte the layout reads is inside MinLength.
+	end := f.Offset + f.Length
+	for _, m := range f.Match {
+		end = max(end, m.Offset+1)
+	}
+	if f.SignOffset != nil {
+		end = max(end, *f.SignOffset+1)
+	}
+	f.MinLength = max(f.MinLength, end)
+	return nil
+}
+
+func parseUUID(field, s string) (bluetooth.UUID, error) {
+	if s == "" {
+		return bluetooth.UUID{}, fmt.Errorf("invalid descriptor: %s is required", field)
+	}
+	uuid, err := bluetooth.ParseUUID(s)
+	if err != nil {
+		return bluetooth.UUID{}, fmt.Errorf("invalid descriptor: %s: %w", field, err)
+	}
+	return uuid, nil
+}
@@ -0,0 +1,64 @@
+package generic
+
+import "math"
+
+// Decode reads the weight in grams from a notification frame. It returns
+// false if the frame is too short or isn't a weight frame.
+func (f *FrameLayout) Decode(frame []byte) (float64, bool) {
+	raw, ok := f.DecodeRaw(frame)
+	if !ok {
+		return 0, false
+	}
+	return float64(raw) * f.Scale, true
+}
+
+// DecodeRaw is Decode, returning the signed weight as sent, before Scale is
+// applied.
+func (f *FrameLayout) DecodeRaw(frame []byte) (int64, bool) {
+	if len(frame) < f.MinLength {
+		return 0, false
+	}
+	for _, m := range f.Match {
+		if frame[m.Offset] != m.Value {
+			return 0, false
+		}
+	}
+
+	field := frame[f.Offset : f.Offset+f.Length]
+	var raw uint32
+	if f.Endian == "little" {
+		for i := len(field) - 1; i >= 0; i-- {
+			raw = raw<<8 | uint32(field[i])
+		}
+	} else {
+		for _, b := range field {
+			raw = raw<<8 | uint32(b)
+		}
+	}
+
+	value := int64(raw)
+	if f.Signed {
+		bits := uint(f.Length * 8)
+		if raw&(1<<(bits-1)) != 0 {
+			value = int64(raw) - int64(1)<<bits
+		}
+	}
+	if f.SignOffset != nil && frame[*f.SignOffset]&f.SignMask == f.SignValue {
+		value = -value
+	}
+
+	return value, true
+}
+
+// Divisor returns what the raw weight is divided by to give grams, or false
+// if Scale isn't the reciprocal of a whole number.
+func (f *FrameLayout) Divisor() (int64, bool) {
+	if f.Scale <= 0 {
+		return 0, false
+	}
+	d := math.Round(1 / f.Scale)
+	if d < 1 || math.Abs(d*f.Scale-1) > 1e-9 {
+		return 0, false
+	}
+	return int64(d), true
+}
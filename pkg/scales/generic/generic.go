@@ -0,0 +1,346 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/internal/connsup"
+	"github.com/mlsorensen/goscale/pkg/bleproxy"
+	"github.com/mlsorensen/goscale/pkg/flow"
+	"tinygo.org/x/bluetooth"
+)
+
+var _ goscale.Scale = (*GenericScale)(nil)
+var _ goscale.ContextConnector = (*GenericScale)(nil)
+var _ goscale.CommandLimited = (*GenericScale)(nil)
+var _ goscale.OverflowConfigurable = (*GenericScale)(nil)
+var _ goscale.SmoothingConfigurable = (*GenericScale)(nil)
+var _ goscale.TareOffsetter = (*GenericScale)(nil)
+var _ goscale.DisconnectReasoner = (*GenericScale)(nil)
+
+// Register makes scales matching d available to scanning and
+// goscale.NewScaleForDevice, under the prefix d.Name.
+func Register(d *Descriptor) {
+	goscale.RegisterWithOptions(d.Name, d.New, goscale.DriverOptions{
+		ServiceUUIDs: []bluetooth.UUID{d.serviceUUID},
+	})
+}
+
+// characteristic is what the driver needs of a GATT characteristic, so it
+// can be reached directly or through a bleproxy agent.
+type characteristic interface {
+	WriteWithoutResponse(p []byte) (int, error)
+	EnableNotifications(callback func(buf []byte)) error
+}
+
+// GenericScale is a scale driven by a Descriptor.
+type GenericScale struct {
+	desc  *Descriptor
+	proxy *bleproxy.Client // Nil to use the local adapter
+
+	name    string
+	address bluetooth.Address
+	sup     atomic.Pointer[connsup.Supervisor]
+
+	mu        sync.Mutex // Guards connected
+	connected bool
+
+	btDevice   interface{ Disconnect() error }
+	writeChar  characteristic
+	notifyChar characteristic
+
+	updates goscale.UpdateStream
+	seq     uint64
+	flow    *flow.Meter
+
+	limiter atomic.Pointer[goscale.CommandLimiter]
+}
+
+// New is the goscale.Factory for scales described by d.
+func (d *Descriptor) New(device *goscale.FoundDevice) goscale.Scale {
+	return &GenericScale{
+		desc:    d,
+		name:    device.Name,
+		address: device.Address,
+	}
+}
+
+// ProxyFactory returns a goscale.Factory for scales described by d that are
+// reached through the bleproxy agent c rather than the local adapter. Find
+// devices for it with c.Scan.
+func (d *Descriptor) ProxyFactory(c *bleproxy.Client) goscale.Factory {
+	return func(device *goscale.FoundDevice) goscale.Scale {
+		return &GenericScale{
+			desc:    d,
+			proxy:   c,
+			name:    device.Name,
+			address: device.Address,
+		}
+	}
+}
+
+// SetCommandLimiter rate limits the commands sent to the scale. Nil, the
+// default, turns limiting off.
+func (g *GenericScale) SetCommandLimiter(limiter *goscale.CommandLimiter) {
+	g.limiter.Store(limiter)
+}
+
+// SetOverflowPolicy sets what happens to weight updates when the consumer
+// falls behind. The default, goscale.OverflowBlock, holds up notifications
+// until it catches up.
+func (g *GenericScale) SetOverflowPolicy(p goscale.OverflowPolicy) {
+	g.updates.SetPolicy(p)
+}
+
+// SetSmoothing sets the filter weight updates are run through. The
+// default, goscale.SmoothingRaw, passes them on as the scale sent them.
+func (g *GenericScale) SetSmoothing(cfg goscale.SmoothingConfig) {
+	g.updates.SetSmoothing(cfg)
+}
+
+// SetTareOffset sets the grams subtracted from weight updates, such as a
+// container's. See goscale.TareToOffset.
+func (g *GenericScale) SetTareOffset(grams float64) {
+	g.updates.SetTareOffset(grams)
+}
+
+// TareOffset returns the grams subtracted from weight updates.
+func (g *GenericScale) TareOffset() float64 {
+	return g.updates.TareOffset()
+}
+
+// DroppedUpdates returns how many weight updates have been dropped under the
+// overflow policy since the scale connected.
+func (g *GenericScale) DroppedUpdates() uint64 {
+	return g.updates.Dropped()
+}
+
+func (g *GenericScale) GetFeatures() goscale.ScaleFeatures {
+	return goscale.ScaleFeatures{Tare: len(g.desc.tare) > 0}
+}
+
+func (g *GenericScale) Connect() (<-chan goscale.WeightUpdate, error) {
+	return g.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect, giving up and disconnecting if ctx is done
+// before the scale is connected and streaming.
+func (g *GenericScale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
+	updates := g.updates.Open(20)
+	g.seq = 0
+	g.flow = flow.NewMeter(flow.DefaultWindow)
+
+	var link connsup.Config
+	var err error
+	if g.proxy != nil {
+		link, err = g.connectProxy(ctx)
+	} else {
+		link, err = g.connectLocal(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = connsup.Await(ctx, func() error {
+		return g.notifyChar.EnableNotifications(goscale.RecoverNotifications(g.name, g.handleNotification, g.reportError))
+	})
+	if err != nil {
+		_ = g.btDevice.Disconnect()
+		return nil, fmt.Errorf("failed to enable notifications: %w", err)
+	}
+
+	g.mu.Lock()
+	g.connected = true
+	g.mu.Unlock()
+
+	// Disconnect when the link drops, or after a long stretch of silence
+	// in case that is never reported.
+	link.Silence = 30 * time.Second
+	link.Disconnect = func() { _ = g.Disconnect() }
+	g.sup.Store(connsup.Start(link))
+
+	return updates, nil
+}
+
+// connectLocal connects through goscale's active adapter, usually the local
+// one. It returns how to watch the link.
+func (g *GenericScale) connectLocal(ctx context.Context) (connsup.Config, error) {
+	if err := goscale.TryEnableAdapter(); err != nil {
+		return connsup.Config{}, err
+	}
+	device, err := connsup.Dial(ctx, g.address)
+	if err != nil {
+		return connsup.Config{}, err
+	}
+	g.btDevice = device
+	if err := connsup.Await(ctx, func() error { return g.setupCharacteristics(device) }); err != nil {
+		_ = device.Disconnect()
+		return connsup.Config{}, err
+	}
+	return connsup.Config{Address: g.address}, nil
+}
+
+// connectProxy connects through the bleproxy agent. It returns how to watch
+// the link.
+func (g *GenericScale) connectProxy(ctx context.Context) (connsup.Config, error) {
+	device, err := g.proxy.Connect(g.address)
+	if err != nil {
+		return connsup.Config{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		_ = device.Disconnect()
+		return connsup.Config{}, err
+	}
+	want := g.wantedChars()
+	chars, err := device.DiscoverCharacteristics(g.desc.serviceUUID, want)
+	if err != nil || len(chars) != len(want) {
+		_ = device.Disconnect()
+		return connsup.Config{}, fmt.Errorf("could not discover characteristics: %w", err)
+	}
+	for _, char := range chars {
+		g.assignChar(char.UUID(), char)
+	}
+	g.btDevice = device
+	return connsup.Config{LinkDone: device.Done()}, nil
+}
+
+func (g *GenericScale) Disconnect() error {
+	g.mu.Lock()
+	if !g.connected {
+		g.mu.Unlock()
+		return nil
+	}
+	g.connected = false
+	g.mu.Unlock()
+
+	err := g.btDevice.Disconnect()
+	g.updates.Close()
+	if sup := g.sup.Load(); sup != nil {
+		sup.Stop()
+	}
+	return err
+}
+
+// DisconnectReason returns why the last connection ended.
+func (g *GenericScale) DisconnectReason() goscale.DisconnectReason {
+	sup := g.sup.Load()
+	if sup == nil {
+		return goscale.DisconnectUnknown
+	}
+	return sup.Reason()
+}
+
+func (g *GenericScale) IsConnected() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.connected
+}
+
+func (g *GenericScale) DeviceName() string {
+	return g.name
+}
+
+func (g *GenericScale) DisplayName() string {
+	return g.desc.DisplayName
+}
+
+func (g *GenericScale) Tare(blocking bool) error {
+	if len(g.desc.tare) == 0 {
+		return goscale.ErrNotSupported
+	}
+	return g.limiter.Load().Do(goscale.CommandTare, func() error {
+		_, err := g.writeChar.WriteWithoutResponse(g.desc.tare)
+		return err
+	})
+}
+
+func (g *GenericScale) AdvanceSleepTimeout() error {
+	return goscale.ErrNotSupported
+}
+
+func (g *GenericScale) GetSleepTimeout() string {
+	return ""
+}
+
+func (g *GenericScale) GetBatteryChargePercent() (float64, error) {
+	return 0, goscale.ErrNotSupported
+}
+
+func (g *GenericScale) GetBeep() bool {
+	return false
+}
+
+func (g *GenericScale) SetBeep(bool) error {
+	return goscale.ErrNotSupported
+}
+
+func (g *GenericScale) setupCharacteristics(device goscale.Peripheral) error {
+	chars, err := goscale.DiscoverChars(device, g.desc.serviceUUID, g.wantedChars()...)
+	if errors.Is(err, goscale.ErrServiceNotFound) {
+		return &goscale.UnsupportedModelError{Device: g.name, Reason: fmt.Sprintf("service %s was not found", g.desc.serviceUUID)}
+	}
+	if err != nil {
+		return err
+	}
+	for _, char := range chars {
+		g.assignChar(char.UUID(), char)
+	}
+	return nil
+}
+
+// wantedChars returns the characteristics the descriptor uses.
+func (g *GenericScale) wantedChars() []bluetooth.UUID {
+	want := []bluetooth.UUID{g.desc.notifyUUID}
+	if g.desc.CommandChar != "" {
+		want = append(want, g.desc.commandUUID)
+	}
+	return want
+}
+
+func (g *GenericScale) assignChar(uuid bluetooth.UUID, char characteristic) {
+	if uuid == g.desc.notifyUUID {
+		g.notifyChar = char
+	}
+	if g.desc.CommandChar != "" && uuid == g.desc.commandUUID {
+		g.writeChar = char
+	}
+}
+
+func (g *GenericScale) handleNotification(buf []byte) {
+	// Stamp the reading on arrival, before decoding or any channel send can
+	// delay it.
+	now := time.Now()
+	if sup := g.sup.Load(); sup != nil {
+		sup.Notified(now)
+	}
+	raw, ok := g.desc.Frame.DecodeRaw(buf)
+	if !ok {
+		log.Printf("%s: ignoring frame: % X", g.desc.Name, buf)
+		return
+	}
+	weight := float64(raw) * g.desc.Frame.Scale
+	divisor, hasRaw := g.desc.Frame.Divisor()
+	g.seq++
+	g.updates.Send(goscale.WeightUpdate{
+		Value:       weight,
+		Seq:         g.seq,
+		Timestamp:   now,
+		FlowRate:    g.flow.Add(now, weight),
+		HasFlowRate: true,
+		Raw:         raw,
+		RawDivisor:  divisor,
+		HasRaw:      hasRaw,
+	})
+}
+
+// reportError sends err on the update channel, if there is room. It must not
+// block, as it runs on the notification goroutine.
+func (g *GenericScale) reportError(err error) {
+	g.updates.TrySend(goscale.WeightUpdate{Error: err})
+}
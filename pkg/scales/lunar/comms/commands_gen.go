@@ -0,0 +1,82 @@
+// Code generated by cmdgen from commands.yaml; DO NOT EDIT.
+
+package comms
+
+// BuildIdentifyCommand creates the command to identify.
+func BuildIdentifyCommand() []byte {
+	return encodeFrame(0x0B, []byte{0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x30, 0x31, 0x32, 0x33, 0x34})
+}
+
+// BuildNotificationRequestCommand creates a notification request command, subscribing to
+// weight, battery, timer, key and setting events.
+func BuildNotificationRequestCommand() []byte {
+	return encodeFrame(0x0C, []byte{0x09, 0x00, 0x01, 0x01, 0x02, 0x02, 0x05, 0x03, 0x04})
+}
+
+// BuildKeyActionCommand creates the command to press a key on the scale.
+func BuildKeyActionCommand(key KeyAction) []byte {
+	return encodeFrame(0x04, []byte{byte(key)})
+}
+
+// BuildTareCommand creates the command to tare the scale.
+func BuildTareCommand() []byte {
+	return encodeFrame(0x04, []byte{byte(KeyTare)})
+}
+
+// BuildGetStatusCommand creates the command to request a single status update from the scale.
+// This is often used as a simple heartbeat if a more complex one isn't required.
+func BuildGetStatusCommand() []byte {
+	return encodeFrame(0x06, []byte{0x00})
+}
+
+// BuildAutoOffCommand creates the command to adjust the auto-off timer
+func BuildAutoOffCommand(setting AutoOffSetting) []byte {
+	return encodeFrame(0x0A, []byte{0x00, 0x01, byte(setting)})
+}
+
+// BuildSetBeepCommand creates the command to enable/disable beep
+func BuildSetBeepCommand(beep bool) []byte {
+	return encodeFrame(0x0A, []byte{0x00, 0x05, boolByte(beep, 0x01)})
+}
+
+// BuildSetUnitCommand creates the command to switch the displayed unit.
+//
+// The setting id is an educated guess: the Acaia SDK's setting list starts
+// with the unit, ahead of auto-off (1). Send it and watch Unit in the next
+// status to confirm.
+func BuildSetUnitCommand(unit Unit) []byte {
+	return encodeFrame(0x0A, []byte{0x00, 0x00, byte(unit)})
+}
+
+// BuildKeyDisableCommand creates the command to set the key lock timer.
+//
+// The setting id is an educated guess, sitting between auto-off (1) and beep
+// (5) in the same order the status message reports them. Send it and watch
+// KeyDisableSetting in the next status to confirm.
+func BuildKeyDisableCommand(setting KeyDisableSetting) []byte {
+	return encodeFrame(0x0A, []byte{0x00, 0x02, byte(setting)})
+}
+
+// encodeFrame frames a command: the header, op code and payload, then the
+// checksum.
+func encodeFrame(op byte, payload []byte) []byte {
+	msg := append([]byte{HeaderPrefix1, HeaderPrefix2}, op)
+	msg = append(msg, payload...)
+	var csum1, csum2 byte
+	for i, c := range msg[3:] {
+		if i%2 == 0 {
+			csum1 += c
+		} else {
+			csum2 += c
+		}
+	}
+	return append(msg, csum1, csum2)
+}
+
+// boolByte returns on if v is true, and zero otherwise.
+func boolByte(v bool, on byte) byte {
+	if v {
+		return on
+	}
+	return 0
+}
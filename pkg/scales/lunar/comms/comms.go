@@ -13,5 +13,9 @@ var (
 	IdentifyCommand            = BuildIdentifyCommand()
 	NotificationRequestCommand = BuildNotificationRequestCommand()
 	TareCommand                = BuildTareCommand()
+	TimerStartCommand          = BuildKeyActionCommand(KeyTimerStart)
+	TimerStopCommand           = BuildKeyActionCommand(KeyTimerStop)
+	TimerResetCommand          = BuildKeyActionCommand(KeyTimerReset)
+	PowerOffCommand            = BuildKeyActionCommand(KeyPower)
 	GetStatusCommand           = BuildGetStatusCommand()
 )
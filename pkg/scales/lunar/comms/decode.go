@@ -1,25 +1,46 @@
 package comms
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 )
 
-// DecodeNotification decodes messages coming from the Lunar
-// It assumes the 'data' buffer contains one complete message frame.
-func DecodeNotification(data []byte) (LunarMessage, error) {
+var (
+	ErrHeaderNotFound  = errors.New("message header not found")
+	ErrFrameTooShort   = errors.New("incomplete message frame: too short for header and length")
+	ErrPayloadTooShort = errors.New("message frame too short for its command")
+)
+
+// A frame is the 2-byte header, the command and length bytes, a body and a
+// 2-byte checksum, and an event frame's (command 12) body starts with the
+// event type. These are the shortest frames holding all that, so the body
+// can be sliced out without checking again.
+const (
+	minFrameLen      = 6
+	minEventFrameLen = 7
+)
+
+// findFrame locates the first complete message frame in data. It is on the
+// per-notification hot path, so it scans for the header by hand and returns
+// a subslice of data rather than allocating.
+func findFrame(data []byte) ([]byte, error) {
 	// 1. Find the start of a message (EF DD)
-	idx := bytes.Index(data, []byte{HeaderPrefix1, HeaderPrefix2})
+	idx := -1
+	for i := 0; i+1 < len(data); i++ {
+		if data[i] == HeaderPrefix1 && data[i+1] == HeaderPrefix2 {
+			idx = i
+			break
+		}
+	}
 	if idx == -1 {
-		return nil, errors.New("message header not found")
+		return nil, ErrHeaderNotFound
 	}
 	frame := data[idx:] // Start processing from the header
 
 	// 2. Check for minimal length for header and length byte
 	if len(frame) < 4 {
-		return nil, errors.New("incomplete message frame: too short for header and length")
+		return nil, ErrFrameTooShort
 	}
 
 	// 3. Calculate the expected message length from the length byte.
@@ -30,8 +51,44 @@ func DecodeNotification(data []byte) (LunarMessage, error) {
 	}
 
 	// We only process the expected length, creating a clean frame.
-	frame = frame[:expectedFrameLen]
+	return frame[:expectedFrameLen], nil
+}
+
+// DecodeWeightNotification is the allocation-free fast path for the most
+// common notification. If data holds a weight event it is decoded into msg
+// and true is returned. For any other frame it returns false and leaves msg
+// untouched; pass such frames to DecodeNotification.
+func DecodeWeightNotification(data []byte, msg *WeightMessage) (bool, error) {
+	frame, err := findFrame(data)
+	if err != nil {
+		return false, err
+	}
+	if frame[2] != 12 || frame[4] != 5 {
+		return false, nil
+	}
+	if len(frame) < minEventFrameLen {
+		return false, ErrPayloadTooShort
+	}
+
+	w, err := decodeWeight(frame[5 : len(frame)-2])
+	if err != nil {
+		return false, fmt.Errorf("failed to decode weight for msgType 5: %w", err)
+	}
+	*msg = w
+	return true, nil
+}
+
+// DecodeNotification decodes messages coming from the Lunar
+// It assumes the 'data' buffer contains one complete message frame.
+func DecodeNotification(data []byte) (LunarMessage, error) {
+	frame, err := findFrame(data)
+	if err != nil {
+		return nil, err
+	}
 	commandID := frame[2]
+	if len(frame) < minFrameLen || commandID == 12 && len(frame) < minEventFrameLen {
+		return nil, ErrPayloadTooShort
+	}
 
 	switch commandID {
 	case 12: // Nested Message Type
@@ -71,6 +128,12 @@ func decodeEventMessage(msgType byte, payload []byte, rawFrame []byte) (LunarMes
 		}
 		return msg, nil
 
+	case 8: // Key: the button pressed, then what it did (weight or timer)
+		if len(payload) < 1 {
+			return nil, errors.New("key event payload too short")
+		}
+		return ButtonMessage{Key: KeyAction(payload[0])}, nil
+
 	default:
 		// This is an unhandled nested message type.
 		return UnhandledMessage{
@@ -90,18 +153,18 @@ func decodeWeight(payload []byte) (WeightMessage, error) {
 
 	// payload[4] is the divisor (n_dp in the SDK)
 	unit := payload[4]
-	var divisor float64
+	var divisor int64
 	switch unit {
 	case 1:
-		divisor = 10.0
+		divisor = 10
 	case 2:
-		divisor = 100.0
+		divisor = 100
 	case 3:
-		divisor = 1000.0
+		divisor = 1000
 	case 4:
-		divisor = 10000.0
+		divisor = 10000
 	default:
-		divisor = 10.0
+		divisor = 10
 	}
 
 	// payload[5] contains packed bitwise flags:
@@ -109,20 +172,21 @@ func decodeWeight(payload []byte) (WeightMessage, error) {
 	// Bit 1 (0x02): Sign (1 = negative)
 	// Bits 2-7    : Weight Type (Net, Gross, etc.)
 	isStable := (payload[5] & 0x01) == 0
-	sign := 1.0
+	var sign int64 = 1
 	if (payload[5] & 0x02) != 0 {
-		sign = -1.0
+		sign = -1
 	}
 	weightType := WeightType(payload[5] >> 2)
 
 	// payload[0:4] is the raw weight value (n_data)
-	raw := binary.LittleEndian.Uint32(payload[0:4])
-	weight := sign * (float64(raw) / divisor)
+	raw := sign * int64(binary.LittleEndian.Uint32(payload[0:4]))
 
 	return WeightMessage{
-		Weight:   weight,
+		Weight:   float64(raw) / float64(divisor),
 		Type:     weightType,
 		IsStable: isStable,
+		Raw:      raw,
+		Divisor:  divisor,
 	}, nil
 }
 
@@ -185,6 +249,9 @@ func DecodeDeviceInfoMessage(payload []byte) (DeviceInfoMessage, error) {
 
 	msg := DeviceInfoMessage{}
 
+	// Model: payload[1], after the length byte
+	msg.Model = Model(payload[1])
+
 	// Main Version: payload[3]
 	// Sub Version: payload[4]
 	// Add Version: payload[2]
@@ -203,3 +270,71 @@ func DecodeDeviceInfoMessage(payload []byte) (DeviceInfoMessage, error) {
 func bcdToDec(bcd byte) uint8 {
 	return (bcd>>4)*10 + (bcd & 0x0F)
 }
+
+// maxPending bounds how much of an incomplete frame a Reassembler holds,
+// so a corrupt length byte can't make it wait forever.
+const maxPending = 256
+
+// Reassembler joins frames split across notifications, as happens when a
+// frame doesn't fit in one at the link's MTU, and splits notifications that
+// carry more than one frame. It is not safe for concurrent use.
+type Reassembler struct {
+	buf []byte
+}
+
+// Push adds a notification's data and calls fn with each frame it
+// completes, in order. Bytes before a frame header are skipped. The frame
+// is only valid for the duration of the call.
+func (r *Reassembler) Push(data []byte, fn func(frame []byte)) {
+	if len(r.buf) == 0 {
+		// Nearly every notification is one whole frame, so avoid copying
+		// it unless some is left over.
+		if rest := nextFrames(data, fn); len(rest) > 0 {
+			r.buf = append(r.buf, rest...)
+		}
+		return
+	}
+
+	r.buf = append(r.buf, data...)
+	rest := nextFrames(r.buf, fn)
+	r.buf = append(r.buf[:0], rest...)
+	if len(r.buf) > maxPending {
+		r.buf = r.buf[:0]
+	}
+}
+
+// Reset discards any incomplete frame, e.g. on reconnecting.
+func (r *Reassembler) Reset() {
+	r.buf = r.buf[:0]
+}
+
+// nextFrames calls fn with each complete frame in data and returns what is
+// left: the start of an incomplete frame, or nothing.
+func nextFrames(data []byte, fn func(frame []byte)) []byte {
+	for {
+		idx := -1
+		for i := 0; i+1 < len(data); i++ {
+			if data[i] == HeaderPrefix1 && data[i+1] == HeaderPrefix2 {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			// The header itself may be split.
+			if len(data) > 0 && data[len(data)-1] == HeaderPrefix1 {
+				return data[len(data)-1:]
+			}
+			return nil
+		}
+		data = data[idx:]
+		if len(data) < 4 {
+			return data
+		}
+		n := int(data[3]) + 5
+		if len(data) < n {
+			return data
+		}
+		fn(data[:n])
+		data = data[n:]
+	}
+}
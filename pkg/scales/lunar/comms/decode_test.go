@@ -0,0 +1,94 @@
+package comms
+
+import (
+	"errors"
+	"testing"
+)
+
+// weightFrame is a stable 18.3 g weight event.
+var weightFrame = []byte{0xEF, 0xDD, 0x0C, 0x08, 0x05, 0xB7, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}
+
+func TestDecodeWeightNotification(t *testing.T) {
+	var msg WeightMessage
+	ok, err := DecodeWeightNotification(weightFrame, &msg)
+	if err != nil || !ok {
+		t.Fatalf("DecodeWeightNotification = %t, %v", ok, err)
+	}
+	if msg.Weight != 18.3 || !msg.IsStable || msg.Raw != 183 || msg.Divisor != 10 {
+		t.Errorf("decoded %+v, want a stable 18.3", msg)
+	}
+}
+
+func TestDecodeDeviceInfo(t *testing.T) {
+	frame := []byte{0xEF, 0xDD, 0x07, 0x07, 0x5A, 0x03, 0x02, 0x06, 0x00, 0x01, 0x00, 0x00}
+	msg, err := DecodeNotification(frame)
+	if err != nil {
+		t.Fatalf("DecodeNotification: %v", err)
+	}
+	info, ok := msg.(DeviceInfoMessage)
+	if !ok || info.Model != 0x5A || info.Firmware.String() != "2.6.3" || !info.IsPasswordSet {
+		t.Errorf("decoded %+v, want model 0x5A, firmware 2.6.3 and a password", msg)
+	}
+}
+
+// Frames too short for their command are refused rather than sliced past
+// their ends.
+func TestDecodeShortFrames(t *testing.T) {
+	for _, frame := range [][]byte{
+		{0xEF, 0xDD, 0x0C, 0x00, 0x05},       // Weight event, no body
+		{0xEF, 0xDD, 0x0C, 0x01, 0x05, 0x00}, // Weight event, no payload
+		{0xEF, 0xDD, 0x0E, 0x00, 0x00},       // Unknown command, no body
+	} {
+		var msg WeightMessage
+		if ok, err := DecodeWeightNotification(frame, &msg); ok || frame[2] == 0x0C && !errors.Is(err, ErrPayloadTooShort) {
+			t.Errorf("DecodeWeightNotification(% X) = %t, %v", frame, ok, err)
+		}
+		if got, err := DecodeNotification(frame); !errors.Is(err, ErrPayloadTooShort) {
+			t.Errorf("DecodeNotification(% X) = %v, %v", frame, got, err)
+		}
+	}
+}
+
+// The weight path runs for nearly every notification, so must not allocate.
+func TestDecodeWeightNotificationAllocs(t *testing.T) {
+	var msg WeightMessage
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = DecodeWeightNotification(weightFrame, &msg)
+	})
+	if allocs != 0 {
+		t.Errorf("DecodeWeightNotification allocates %v times, want 0", allocs)
+	}
+}
+
+func BenchmarkDecodeWeightNotification(b *testing.B) {
+	b.ReportAllocs()
+	var msg WeightMessage
+	for b.Loop() {
+		_, _ = DecodeWeightNotification(weightFrame, &msg)
+	}
+}
+
+func BenchmarkDecodeNotification(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = DecodeNotification(weightFrame)
+	}
+}
+
+func BenchmarkReassembler(b *testing.B) {
+	b.Run("whole", func(b *testing.B) {
+		b.ReportAllocs()
+		var r Reassembler
+		for b.Loop() {
+			r.Push(weightFrame, func([]byte) {})
+		}
+	})
+	b.Run("split", func(b *testing.B) {
+		b.ReportAllocs()
+		var r Reassembler
+		for b.Loop() {
+			r.Push(weightFrame[:6], func([]byte) {})
+			r.Push(weightFrame[6:], func([]byte) {})
+		}
+	})
+}
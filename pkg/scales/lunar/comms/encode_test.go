@@ -0,0 +1,41 @@
+package comms
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The identify, notification request and tare frames are as the Acaia apps
+// send them; the rest follow the same framing.
+func TestCommandFrames(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"identify", BuildIdentifyCommand(), []byte{
+			0xEF, 0xDD, 0x0B, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36,
+			0x37, 0x38, 0x39, 0x30, 0x31, 0x32, 0x33, 0x34, 0x9A, 0x6D,
+		}},
+		{"notification request", BuildNotificationRequestCommand(), []byte{
+			0xEF, 0xDD, 0x0C, 0x09, 0x00, 0x01, 0x01, 0x02, 0x02, 0x05, 0x03, 0x04, 0x15, 0x06,
+		}},
+		{"tare", BuildTareCommand(), []byte{0xEF, 0xDD, 0x04, 0x00, 0x00, 0x00}},
+		{"tare key", BuildKeyActionCommand(KeyTare), []byte{0xEF, 0xDD, 0x04, 0x00, 0x00, 0x00}},
+		{"timer start", BuildKeyActionCommand(KeyTimerStart), []byte{0xEF, 0xDD, 0x04, 0x08, 0x08, 0x00}},
+		{"timer reset", BuildKeyActionCommand(KeyTimerReset), []byte{0xEF, 0xDD, 0x04, 0x09, 0x09, 0x00}},
+		{"timer stop", BuildKeyActionCommand(KeyTimerStop), []byte{0xEF, 0xDD, 0x04, 0x0A, 0x0A, 0x00}},
+		{"power", BuildKeyActionCommand(KeyPower), []byte{0xEF, 0xDD, 0x04, 0x0B, 0x0B, 0x00}},
+		{"get status", BuildGetStatusCommand(), []byte{0xEF, 0xDD, 0x06, 0x00, 0x00, 0x00}},
+		{"auto-off 20 min", BuildAutoOffCommand(AutoOff20Min), []byte{0xEF, 0xDD, 0x0A, 0x00, 0x01, 0x03, 0x03, 0x01}},
+		{"beep on", BuildSetBeepCommand(true), []byte{0xEF, 0xDD, 0x0A, 0x00, 0x05, 0x01, 0x01, 0x05}},
+		{"beep off", BuildSetBeepCommand(false), []byte{0xEF, 0xDD, 0x0A, 0x00, 0x05, 0x00, 0x00, 0x05}},
+		{"unit ounces", BuildSetUnitCommand(UnitOunces), []byte{0xEF, 0xDD, 0x0A, 0x00, 0x00, 0x05, 0x05, 0x00}},
+		{"key lock 20s", BuildKeyDisableCommand(KeyDisable20s), []byte{0xEF, 0xDD, 0x0A, 0x00, 0x02, 0x02, 0x02, 0x02}},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s = % X, want % X", tt.name, tt.got, tt.want)
+		}
+	}
+}
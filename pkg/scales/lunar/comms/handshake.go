@@ -0,0 +1,162 @@
+package comms
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HandshakeState is where a connection is in the Lunar's handshake.
+type HandshakeState uint8
+
+const (
+	// HandshakeIdle: not connected, or connected and not yet identified.
+	HandshakeIdle HandshakeState = iota
+	// HandshakeIdentifying: the identify command has been sent.
+	HandshakeIdentifying
+	// HandshakeSubscribing: the notification request has been sent; the
+	// scale answers it with a status message.
+	HandshakeSubscribing
+	// HandshakeSynced: the scale has sent its status, so commands are
+	// accepted, but no weights have arrived yet.
+	HandshakeSynced
+	// HandshakeStreaming: weights are arriving.
+	HandshakeStreaming
+)
+
+func (s HandshakeState) String() string {
+	switch s {
+	case HandshakeIdle:
+		return "idle"
+	case HandshakeIdentifying:
+		return "identifying"
+	case HandshakeSubscribing:
+		return "subscribing"
+	case HandshakeSynced:
+		return "synced"
+	case HandshakeStreaming:
+		return "streaming"
+	default:
+		return fmt.Sprintf("Unknown State (%d)", s)
+	}
+}
+
+// HandshakeEvent drives a Handshake from one state to the next.
+type HandshakeEvent uint8
+
+const (
+	EventIdentifySent  HandshakeEvent = iota // IdentifyCommand written to start a handshake
+	EventSubscribeSent                       // NotificationRequestCommand written
+	EventStatus                              // StatusMessage received
+	EventWeight                              // WeightMessage received
+	EventReset                               // Connection lost or closed
+)
+
+func (e HandshakeEvent) String() string {
+	switch e {
+	case EventIdentifySent:
+		return "identify sent"
+	case EventSubscribeSent:
+		return "subscribe sent"
+	case EventStatus:
+		return "status received"
+	case EventWeight:
+		return "weight received"
+	case EventReset:
+		return "reset"
+	default:
+		return fmt.Sprintf("Unknown Event (%d)", e)
+	}
+}
+
+// handshakeTransitions lists, for each state, the events that move it on
+// and where to. Anything else is ignored.
+//
+// Identifying again from any state restarts the handshake, as happens when
+// the connection stalls. Some firmware answers the identify with a status,
+// and some start streaming before any status arrives, so both skip ahead.
+// A status never moves a streaming connection back; the heartbeat asks for
+// one every second.
+var handshakeTransitions = map[HandshakeState]map[HandshakeEvent]HandshakeState{
+	HandshakeIdle: {
+		EventIdentifySent: HandshakeIdentifying,
+	},
+	HandshakeIdentifying: {
+		EventIdentifySent:  HandshakeIdentifying,
+		EventSubscribeSent: HandshakeSubscribing,
+		EventStatus:        HandshakeSynced,
+		EventWeight:        HandshakeStreaming,
+		EventReset:         HandshakeIdle,
+	},
+	HandshakeSubscribing: {
+		EventIdentifySent: HandshakeIdentifying,
+		EventStatus:       HandshakeSynced,
+		EventWeight:       HandshakeStreaming,
+		EventReset:        HandshakeIdle,
+	},
+	HandshakeSynced: {
+		EventIdentifySent: HandshakeIdentifying,
+		EventWeight:       HandshakeStreaming,
+		EventReset:        HandshakeIdle,
+	},
+	HandshakeStreaming: {
+		EventIdentifySent: HandshakeIdentifying,
+		EventReset:        HandshakeIdle,
+	},
+}
+
+// Handshake tracks a connection through the handshake: identify, then
+// subscribe, then synced once the scale sends its status, then streaming
+// once weights arrive. It is safe for concurrent use, as commands are sent
+// and notifications received on different goroutines.
+type Handshake struct {
+	mu    sync.Mutex
+	state HandshakeState
+	since time.Time
+
+	// OnChange, if set, is called with every state change, with the
+	// Handshake's lock held.
+	OnChange func(from, to HandshakeState, ev HandshakeEvent)
+}
+
+// State returns the current state and when it was entered.
+func (h *Handshake) State() (HandshakeState, time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state, h.since
+}
+
+// Synced reports whether the scale has completed the handshake.
+func (h *Handshake) Synced() bool {
+	state, _ := h.State()
+	return state >= HandshakeSynced
+}
+
+// Handle applies ev, returning the new state and whether it changed.
+func (h *Handshake) Handle(ev HandshakeEvent) (HandshakeState, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	next, ok := handshakeTransitions[h.state][ev]
+	if !ok || next == h.state {
+		return h.state, false
+	}
+	if h.OnChange != nil {
+		h.OnChange(h.state, next, ev)
+	}
+	h.state, h.since = next, time.Now()
+	return next, true
+}
+
+// Observe applies the event a decoded message amounts to, if any, as
+// Handle does.
+func (h *Handshake) Observe(msg LunarMessage) (HandshakeState, bool) {
+	switch msg.(type) {
+	case StatusMessage:
+		return h.Handle(EventStatus)
+	case WeightMessage:
+		return h.Handle(EventWeight)
+	default:
+		state, _ := h.State()
+		return state, false
+	}
+}
@@ -0,0 +1,120 @@
+package comms
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHandshakeHandle(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []HandshakeEvent
+		want   HandshakeState
+	}{
+		{"success", []HandshakeEvent{EventIdentifySent, EventSubscribeSent, EventStatus, EventWeight}, HandshakeStreaming},
+		{"synced before weights", []HandshakeEvent{EventIdentifySent, EventSubscribeSent, EventStatus}, HandshakeSynced},
+		{"status answers identify", []HandshakeEvent{EventIdentifySent, EventStatus}, HandshakeSynced},
+		{"streams before any status", []HandshakeEvent{EventIdentifySent, EventSubscribeSent, EventWeight}, HandshakeStreaming},
+		{"heartbeat status while streaming", []HandshakeEvent{EventIdentifySent, EventWeight, EventStatus}, HandshakeStreaming},
+		{"no answer", []HandshakeEvent{EventIdentifySent, EventSubscribeSent}, HandshakeSubscribing},
+		{"retried after no answer", []HandshakeEvent{EventIdentifySent, EventSubscribeSent, EventIdentifySent}, HandshakeIdentifying},
+		{"retry succeeds", []HandshakeEvent{EventIdentifySent, EventSubscribeSent, EventIdentifySent, EventSubscribeSent, EventStatus}, HandshakeSynced},
+		{"retried after stall", []HandshakeEvent{EventIdentifySent, EventWeight, EventIdentifySent}, HandshakeIdentifying},
+		{"reset", []HandshakeEvent{EventIdentifySent, EventWeight, EventReset}, HandshakeIdle},
+		{"messages ignored before identify", []HandshakeEvent{EventStatus, EventWeight, EventSubscribeSent}, HandshakeIdle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h Handshake
+			for _, ev := range tt.events {
+				h.Handle(ev)
+			}
+			if got, _ := h.State(); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+			if synced := tt.want >= HandshakeSynced; h.Synced() != synced {
+				t.Errorf("Synced() = %t, want %t", h.Synced(), synced)
+			}
+		})
+	}
+}
+
+// A stalled handshake is told apart by how long it has been in its state,
+// so only changes may restart the clock.
+func TestHandshakeSince(t *testing.T) {
+	var h Handshake
+	if _, changed := h.Handle(EventIdentifySent); !changed {
+		t.Fatal("identify didn't start the handshake")
+	}
+	_, since := h.State()
+
+	time.Sleep(time.Millisecond)
+	if _, changed := h.Handle(EventIdentifySent); changed {
+		t.Error("identifying again reported a change")
+	}
+	if _, got := h.State(); !got.Equal(since) {
+		t.Error("ignored event restarted the clock")
+	}
+
+	h.Handle(EventSubscribeSent)
+	if _, got := h.State(); !got.After(since) {
+		t.Error("state change didn't restart the clock")
+	}
+}
+
+func TestHandshakeOnChange(t *testing.T) {
+	type change struct {
+		from, to HandshakeState
+		ev       HandshakeEvent
+	}
+	var got []change
+	h := Handshake{OnChange: func(from, to HandshakeState, ev HandshakeEvent) {
+		got = append(got, change{from, to, ev})
+	}}
+	for _, ev := range []HandshakeEvent{EventIdentifySent, EventSubscribeSent, EventSubscribeSent, EventStatus, EventReset} {
+		h.Handle(ev)
+	}
+
+	want := []change{
+		{HandshakeIdle, HandshakeIdentifying, EventIdentifySent},
+		{HandshakeIdentifying, HandshakeSubscribing, EventSubscribeSent},
+		{HandshakeSubscribing, HandshakeSynced, EventStatus},
+		{HandshakeSynced, HandshakeIdle, EventReset},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d changes, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestHandshakeObserve(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        []HandshakeEvent
+		msg         LunarMessage
+		want        HandshakeState
+		wantChanged bool
+	}{
+		{"status syncs", []HandshakeEvent{EventIdentifySent, EventSubscribeSent}, StatusMessage{}, HandshakeSynced, true},
+		{"weight streams", []HandshakeEvent{EventIdentifySent, EventSubscribeSent, EventStatus}, WeightMessage{Weight: 18.3}, HandshakeStreaming, true},
+		{"unhandled ignored", []HandshakeEvent{EventIdentifySent, EventSubscribeSent}, UnhandledMessage{CommandID: 0x0E}, HandshakeSubscribing, false},
+		{"device info ignored", []HandshakeEvent{EventIdentifySent}, DeviceInfoMessage{}, HandshakeIdentifying, false},
+		{"weight while idle ignored", nil, WeightMessage{}, HandshakeIdle, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h Handshake
+			for _, ev := range tt.from {
+				h.Handle(ev)
+			}
+			got, changed := h.Observe(tt.msg)
+			if got != tt.want || changed != tt.wantChanged {
+				t.Errorf("Observe = %s, %t, want %s, %t", got, changed, tt.want, tt.wantChanged)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package comms
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
 
 // Unit represents the unit of measurement for the scale.
 type Unit uint8
@@ -27,6 +32,15 @@ type UnhandledMessage struct {
 	RawFrame  []byte // Add this field
 }
 
+// Key identifies the kind of message: the command id, plus the message type
+// for nested event messages.
+func (m UnhandledMessage) Key() string {
+	if m.MsgType != nil {
+		return fmt.Sprintf("cmd=0x%02X msg=%d", m.CommandID, *m.MsgType)
+	}
+	return fmt.Sprintf("cmd=0x%02X", m.CommandID)
+}
+
 // String returns a formatted version string, e.g., "1.0.18".
 func (v FirmwareVersion) String() string {
 	return fmt.Sprintf("%d.%d.%d", v.Main, v.Sub, v.Add)
@@ -39,12 +53,44 @@ type FirmwareVersion struct {
 	Add  uint8
 }
 
+// ButtonMessage reports a press of one of the scale's buttons, from a type 8
+// key event.
+type ButtonMessage struct {
+	Key KeyAction
+}
+
 // DeviceInfoMessage holds the parsed device information from a type 7 info event message.
 type DeviceInfoMessage struct {
+	Model         Model
 	Firmware      FirmwareVersion
 	IsPasswordSet bool
 }
 
+// Model is the product code a device reports in its info message. Acaia
+// doesn't document the codes, and uses the Lunar's name and protocol for
+// hardware that isn't a scale; NonScaleModels lists the codes known to be
+// such hardware.
+type Model uint8
+
+// NonScaleModels names the models that aren't scales, which the driver
+// refuses to connect to. Codes are added as they turn up: the driver logs the
+// model of every device it connects to.
+var NonScaleModels = map[Model]string{}
+
+// IsScale reports whether m isn't one of NonScaleModels.
+func (m Model) IsScale() bool {
+	_, ok := NonScaleModels[m]
+	return !ok
+}
+
+// String returns the model's name if it is known, or its code.
+func (m Model) String() string {
+	if name, ok := NonScaleModels[m]; ok {
+		return fmt.Sprintf("%s (%#02x)", name, uint8(m))
+	}
+	return fmt.Sprintf("%#02x", uint8(m))
+}
+
 // ScaleMode represents the operational mode of the scale.
 type ScaleMode uint8
 
@@ -85,6 +131,10 @@ type WeightMessage struct {
 	Weight   float64
 	Type     WeightType
 	IsStable bool // True if the weight reading is stable.
+
+	// Raw is the weight as sent, signed; Weight is Raw/Divisor.
+	Raw     int64
+	Divisor int64
 }
 
 func (u Unit) String() string {
@@ -129,6 +179,27 @@ const (
 	AutoOff60Min    AutoOffSetting = 5 // Auto-off after 60 minutes
 )
 
+// AutoOffSettings is the order the scale's sleep timer steps through,
+// wrapping from 60 minutes back to disabled.
+var AutoOffSettings = goscale.NewSettingCycle(
+	AutoOffDisabled,
+	AutoOff5Min,
+	AutoOff10Min,
+	AutoOff20Min,
+	AutoOff30Min,
+	AutoOff60Min,
+)
+
+// SleepTimeouts maps the auto-off settings to goscale.SleepTimeouts.
+var SleepTimeouts = goscale.NewSleepTimeoutMap(map[AutoOffSetting]goscale.SleepTimeout{
+	AutoOffDisabled: goscale.SleepTimeoutDisabled,
+	AutoOff5Min:     goscale.SleepTimeout(5 * time.Minute),
+	AutoOff10Min:    goscale.SleepTimeout(10 * time.Minute),
+	AutoOff20Min:    goscale.SleepTimeout(20 * time.Minute),
+	AutoOff30Min:    goscale.SleepTimeout(30 * time.Minute),
+	AutoOff60Min:    goscale.SleepTimeout(60 * time.Minute),
+})
+
 func (s AutoOffSetting) String() string {
 	switch s {
 	case AutoOffDisabled:
@@ -0,0 +1,42 @@
+package comms
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+func TestSleepTimeouts(t *testing.T) {
+	tests := []struct {
+		setting AutoOffSetting
+		want    goscale.SleepTimeout
+	}{
+		{AutoOffDisabled, goscale.SleepTimeoutDisabled},
+		{AutoOff5Min, goscale.SleepTimeout(5 * time.Minute)},
+		{AutoOff10Min, goscale.SleepTimeout(10 * time.Minute)},
+		{AutoOff20Min, goscale.SleepTimeout(20 * time.Minute)},
+		{AutoOff30Min, goscale.SleepTimeout(30 * time.Minute)},
+		{AutoOff60Min, goscale.SleepTimeout(60 * time.Minute)},
+	}
+	for _, tt := range tests {
+		got, ok := SleepTimeouts.Timeout(tt.setting)
+		if !ok || got != tt.want {
+			t.Errorf("Timeout(%s) = %s, %t, want %s", tt.setting, got, ok, tt.want)
+			continue
+		}
+		if back, ok := SleepTimeouts.Native(got); !ok || back != tt.setting {
+			t.Errorf("Native(%s) = %s, %t, want %s", got, back, ok, tt.setting)
+		}
+		// The deprecated string getter reports the setting's String.
+		if got.String() != tt.setting.String() {
+			t.Errorf("%s reads %q, but the setting reads %q", got, got.String(), tt.setting.String())
+		}
+	}
+	if n := len(SleepTimeouts.Timeouts()); n != len(tests) {
+		t.Errorf("%d timeouts supported, want %d", n, len(tests))
+	}
+	if _, ok := SleepTimeouts.Native(goscale.SleepTimeout(15 * time.Minute)); ok {
+		t.Error("15 minutes, which the Lunar lacks, mapped to a setting")
+	}
+}
@@ -1,49 +1,158 @@
 package lunar
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/internal/connsup"
+	"github.com/mlsorensen/goscale/pkg/flow"
 	"github.com/mlsorensen/goscale/pkg/scales/lunar/comms"
 	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 	"tinygo.org/x/bluetooth"
 )
 
+// namePrefix is the device name prefix the driver is registered under.
+const namePrefix = "LUNAR"
+
 func init() {
-	// Register with a distinct name, "MOCK", so it can be requested specifically.
-	goscale.Register("LUNAR", New)
+	goscale.RegisterWithOptions(namePrefix, New, goscale.DriverOptions{
+		ServiceUUIDs: []bluetooth.UUID{comms.LunarServiceUUID},
+		Validate:     validate,
+	})
+}
+
+// validate refuses devices that advertise known services but not the Lunar's.
+// Acaia reuses similar names across its product line, including non-scale
+// hardware and newer models on a different service, none of which this
+// driver can talk to. Devices that advertise no known service are let
+// through, as many scales don't; Connect rejects them if the service turns
+// out to be missing, or the model in their device info isn't a scale.
+func validate(device *goscale.FoundDevice) error {
+	if len(device.ServiceUUIDs) == 0 || slices.Contains(device.ServiceUUIDs, comms.LunarServiceUUID) {
+		return nil
+	}
+	return &goscale.UnsupportedModelError{
+		Device: device.Name,
+		Reason: fmt.Sprintf("advertises %v instead of the Lunar service", device.ServiceUUIDs),
+	}
 }
 
 // This line is the compile-time check. It will fail to compile if
 // *LunarScale ever stops satisfying the goscale.Scale interface.
 var _ goscale.Scale = (*LunarScale)(nil)
+var _ goscale.ContextConnector = (*LunarScale)(nil)
+var _ goscale.FirmwareVersioner = (*LunarScale)(nil)
+var _ goscale.CommandLimited = (*LunarScale)(nil)
+var _ goscale.OverflowConfigurable = (*LunarScale)(nil)
+var _ goscale.SmoothingConfigurable = (*LunarScale)(nil)
+var _ goscale.TareOffsetter = (*LunarScale)(nil)
+var _ goscale.KeyLocker = (*LunarScale)(nil)
+var _ goscale.KeepAwaker = (*LunarScale)(nil)
+var _ goscale.Timer = (*LunarScale)(nil)
+var _ goscale.Sleeper = (*LunarScale)(nil)
+var _ goscale.TareRetrier = (*LunarScale)(nil)
+var _ goscale.UnitSetter = (*LunarScale)(nil)
+var _ goscale.SleepTimeoutSetter = (*LunarScale)(nil)
+var _ goscale.Configurable = (*LunarScale)(nil)
+var _ goscale.DisconnectReasoner = (*LunarScale)(nil)
+var _ goscale.BatteryNotifier = (*LunarScale)(nil)
+var _ goscale.ButtonNotifier = (*LunarScale)(nil)
 
 var features = goscale.ScaleFeatures{
 	Tare:           true,
 	BatteryPercent: true,
 	SleepTimeout:   true,
 	Beep:           true,
+	Timer:          true,
+	UnitSwitch:     true,
+	KeyLock:        true,
+	Resolution:     true,
+	StableFlag:     true,
 }
 
 type LunarScale struct {
-	name           string
-	address        bluetooth.Address
-	disconnectCtx  context.Context
-	disconnectFunc context.CancelFunc
-	synced         bool
+	name    string
+	address bluetooth.Address
+	sup     atomic.Pointer[connsup.Supervisor]
+
+	handshake comms.Handshake
+
+	btDevice   goscale.Peripheral
+	writeChar  goscale.Characteristic
+	notifyChar goscale.Characteristic
+
+	updates goscale.UpdateStream
+	seq     uint64
+	flow    *flow.Meter // Fed grams, so a unit change doesn't skew it
+	frames  comms.Reassembler
+	battery goscale.BatteryStream
+
+	limiter          atomic.Pointer[goscale.CommandLimiter]
+	unhandledHandler atomic.Pointer[func(comms.UnhandledMessage)]
+
+	// mu guards the fields below, which the notification handler and the
+	// supervisor's heartbeat update while callers read them.
+	mu sync.Mutex
+
+	buttonChan chan goscale.ButtonEvent
+
+	grossUpdateChan chan goscale.WeightUpdate
+	grossSeq        uint64
+	gross           float64
+	hasGross        bool
 
-	btDevice   bluetooth.Device
-	writeChar  bluetooth.DeviceCharacteristic
-	notifyChar bluetooth.DeviceCharacteristic
+	lastIdentified time.Time
+	isConnected    bool
 
-	weightUpdateChan chan goscale.WeightUpdate
+	mtu uint16 // Negotiated ATT MTU
 
-	lastNotified time.Time
-	isConnected  bool
+	status     comms.StatusMessage
+	deviceInfo *comms.DeviceInfoMessage
+	infoChan   chan comms.DeviceInfoMessage // Device info awaited by Connect
 
-	status comms.StatusMessage
+	tare *goscale.TareVerifier
+}
+
+// SetCommandLimiter rate limits the commands sent to the scale. Nil, the
+// default, turns limiting off.
+func (l *LunarScale) SetCommandLimiter(limiter *goscale.CommandLimiter) {
+	l.limiter.Store(limiter)
+}
+
+// SetOverflowPolicy sets what happens to weight updates when the consumer
+// falls behind. The default, goscale.OverflowBlock, holds up notifications
+// until it catches up.
+func (l *LunarScale) SetOverflowPolicy(p goscale.OverflowPolicy) {
+	l.updates.SetPolicy(p)
+}
+
+// SetSmoothing sets the filter weight updates are run through. The
+// default, goscale.SmoothingRaw, passes them on as the scale sent them.
+func (l *LunarScale) SetSmoothing(cfg goscale.SmoothingConfig) {
+	l.updates.SetSmoothing(cfg)
+}
+
+// SetTareOffset sets the grams subtracted from weight updates, such as a
+// container's. See goscale.TareToOffset.
+func (l *LunarScale) SetTareOffset(grams float64) {
+	l.updates.SetTareOffset(grams)
+}
+
+// TareOffset returns the grams subtracted from weight updates.
+func (l *LunarScale) TareOffset() float64 {
+	return l.updates.TareOffset()
+}
+
+// DroppedUpdates returns how many weight updates have been dropped under the
+// overflow policy since the scale connected.
+func (l *LunarScale) DroppedUpdates() uint64 {
+	return l.updates.Dropped()
 }
 
 func (l *LunarScale) GetFeatures() goscale.ScaleFeatures {
@@ -51,6 +160,8 @@ func (l *LunarScale) GetFeatures() goscale.ScaleFeatures {
 }
 
 func (l *LunarScale) IsConnected() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	return l.isConnected
 }
 
@@ -63,235 +174,610 @@ func (l *LunarScale) DisplayName() string {
 }
 
 func (l *LunarScale) GetSleepTimeout() string {
-	return l.status.SleepTimerSetting.String()
+	return l.lastStatus().SleepTimerSetting.String()
+}
+
+// lastStatus returns the last status received.
+func (l *LunarScale) lastStatus() comms.StatusMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.status
 }
 
 func New(device *goscale.FoundDevice) goscale.Scale {
-	return &LunarScale{
+	l := &LunarScale{
 		name:    device.Name,
 		address: device.Address,
+		tare:    goscale.NewTareVerifier(goscale.DefaultTareRetryConfig),
 	}
+	l.handshake.OnChange = func(from, to comms.HandshakeState, ev comms.HandshakeEvent) {
+		log.Printf("handshake: %s -> %s (%s)", from, to, ev)
+	}
+	return l
+}
+
+// HandshakeState returns where the connection is in the handshake, and
+// since when, for diagnostics.
+func (l *LunarScale) HandshakeState() (comms.HandshakeState, time.Time) {
+	return l.handshake.State()
 }
 
 // Connect will connect the scale, setting up heartbeat to maintain connection, and return a channel
 // for receiving weight updates
 func (l *LunarScale) Connect() (<-chan goscale.WeightUpdate, error) {
+	return l.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect, giving up and disconnecting if ctx is done
+// before the scale is connected and streaming.
+func (l *LunarScale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
 	err := goscale.TryEnableAdapter()
 	if err != nil {
 		return nil, err
 	}
 
-	l.weightUpdateChan = make(chan goscale.WeightUpdate, 20)
-
-	l.disconnectCtx, l.disconnectFunc = context.WithCancel(context.Background())
-
-	l.btDevice, err = goscale.BTAdapter.Connect(l.address, bluetooth.ConnectionParams{})
+	updates := l.updates.Open(20)
+	l.seq = 0
+	l.battery.Open()
+	l.flow = flow.NewMeter(flow.DefaultWindow)
+	l.frames.Reset()
+	l.mu.Lock()
+	l.grossUpdateChan = make(chan goscale.WeightUpdate, 20)
+	l.buttonChan = make(chan goscale.ButtonEvent, 8)
+	l.grossSeq = 0
+	l.hasGross = false
+	l.mtu = goscale.DefaultMTU
+	l.infoChan = make(chan comms.DeviceInfoMessage, 1)
+	l.mu.Unlock()
+
+	l.btDevice, err = connsup.Dial(ctx, l.address)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = l.setupCharacteristics()
+	err = connsup.Await(ctx, l.setupCharacteristics)
 	if err != nil {
-		_ = l.Disconnect()
+		_ = l.btDevice.Disconnect()
 		return nil, err
 	}
 
 	log.Println("setting up notifications")
-	err = l.setupNotifications()
+	err = connsup.Await(ctx, l.setupNotifications)
 	if err != nil {
-		_ = l.Disconnect()
+		_ = l.btDevice.Disconnect()
 		return nil, err
 	}
-	l.lastNotified = time.Now()
 
+	err = l.checkModel(ctx)
+	if err != nil {
+		_ = l.btDevice.Disconnect()
+		return nil, err
+	}
+	l.mu.Lock()
 	l.isConnected = true
-
-	// Fast disconnect detection via the BLE link's HCI Disconnection
-	// Complete event. Without this we'd only notice the link is dead when
-	// the next heartbeat Write times out.
-	goscale.BTAdapter.SetConnectHandler(func(d bluetooth.Device, connected bool) {
-		if !connected && l.disconnectFunc != nil {
-			l.disconnectFunc()
-		}
-	})
-
-	// Start the heartbeat goroutine
-	go func() {
-		for {
-			select {
-			case <-l.disconnectCtx.Done():
-				_ = l.Disconnect()
-				return
-			default:
-				// Send heartbeat signal to the scale
-				if err := l.sendHeartbeat(); err != nil {
-					log.Printf("Error sending heartbeat: %v", err)
-				}
-			}
-		}
-	}()
-
-	return l.weightUpdateChan, nil
+	l.mu.Unlock()
+
+	// The heartbeat keeps the scale streaming; a failed one, or the HCI
+	// disconnect event, ends the connection. Re-run the handshake after a
+	// stall (was 1s; too aggressive on slower transports — the repeated
+	// Identify/NotificationRequest commands appear to disrupt the scale's
+	// notification flow while it's still warming up).
+	l.sup.Store(connsup.Start(connsup.Config{
+		Heartbeat:  l.sendHeartbeat,
+		StallAfter: 5 * time.Second,
+		OnStall: func() {
+			state, since := l.handshake.State()
+			log.Printf("stalled while %s for %s, setting up notifications again", state, time.Since(since).Round(time.Second))
+			_ = l.setupNotifications()
+		},
+		Address: l.address,
+		Status: func() (goscale.SleepTimeout, float64, bool) {
+			timeout, err := l.SleepTimeoutSetting()
+			return timeout, l.lastStatus().Battery, err == nil
+		},
+		Disconnect: func() { _ = l.Disconnect() },
+	}))
+
+	return updates, nil
 }
 
 func (l *LunarScale) Disconnect() error {
-	// Idempotent — multiple paths (heartbeat error, external driver) can
-	// race into Disconnect. Closing the update channel twice panics.
+	// Idempotent — multiple paths (supervisor, external driver) can race
+	// into Disconnect. Closing the update channel twice panics.
+	l.mu.Lock()
 	if !l.isConnected {
+		l.mu.Unlock()
 		return nil
 	}
 	l.isConnected = false
+	if l.grossUpdateChan != nil {
+		close(l.grossUpdateChan)
+		l.grossUpdateChan = nil
+	}
+	if l.buttonChan != nil {
+		close(l.buttonChan)
+		l.buttonChan = nil
+	}
+	l.mu.Unlock()
+	l.handshake.Handle(comms.EventReset)
 
 	err := l.btDevice.Disconnect()
-	if l.weightUpdateChan != nil {
-		close(l.weightUpdateChan)
-		l.weightUpdateChan = nil
-	}
-	if l.disconnectFunc != nil {
-		l.disconnectFunc()
+	l.updates.Close()
+	l.battery.Close()
+	if sup := l.sup.Load(); sup != nil {
+		sup.Stop()
 	}
 	return err
 }
 
+// DisconnectReason returns why the last connection ended. A dropped link
+// is put down to the scale switching itself off when its last status
+// suggests it did: its battery was flat, or it had been idle for its sleep
+// timeout.
+func (l *LunarScale) DisconnectReason() goscale.DisconnectReason {
+	sup := l.sup.Load()
+	if sup == nil {
+		return goscale.DisconnectUnknown
+	}
+	return sup.Reason()
+}
+
+// Tare tares the scale. The Lunar occasionally ignores a tare under load, so
+// the tare is verified against the readings that follow and resent if need
+// be; see SetTareRetry.
 func (l *LunarScale) Tare(blocking bool) error {
-	_, err := l.writeChar.WriteWithoutResponse(comms.TareCommand)
-	return err
+	return l.limiter.Load().Do(goscale.CommandTare, func() error {
+		return l.tare.Tare(blocking, func() error {
+			return l.send(comms.TareCommand)
+		})
+	})
+}
+
+// SetTareRetry configures how tares are verified and retried. The default is
+// goscale.DefaultTareRetryConfig.
+func (l *LunarScale) SetTareRetry(cfg goscale.TareRetryConfig) {
+	l.tare.SetConfig(cfg)
+}
+
+// StartTimer starts the scale's timer.
+func (l *LunarScale) StartTimer() error {
+	return l.sendTimerCommand(comms.TimerStartCommand)
+}
+
+// StopTimer stops the scale's timer.
+func (l *LunarScale) StopTimer() error {
+	return l.sendTimerCommand(comms.TimerStopCommand)
+}
+
+// ResetTimer resets the scale's timer to zero.
+func (l *LunarScale) ResetTimer() error {
+	return l.sendTimerCommand(comms.TimerResetCommand)
+}
+
+// Sleep switches the scale off by pressing its power key, then
+// disconnects. See comms.KeyPower.
+func (l *LunarScale) Sleep() error {
+	err := l.limiter.Load().Do(goscale.CommandSleep, func() error {
+		return l.send(comms.PowerOffCommand)
+	})
+	if err != nil {
+		return fmt.Errorf("error while writing power off command: %w", err)
+	}
+	return l.Disconnect()
+}
+
+func (l *LunarScale) sendTimerCommand(cmd []byte) error {
+	err := l.limiter.Load().Do(goscale.CommandTimer, func() error {
+		return l.send(cmd)
+	})
+	if err != nil {
+		return fmt.Errorf("error while writing timer command: %w", err)
+	}
+	return nil
 }
 
 func (l *LunarScale) AdvanceSleepTimeout() error {
-	timeout := comms.AutoOffDisabled
-	if l.status.SleepTimerSetting != 5 {
-		timeout = l.status.SleepTimerSetting + 1
+	return l.setAutoOff(comms.AutoOffSettings.Next(l.lastStatus().SleepTimerSetting))
+}
+
+// SleepTimeoutSetting returns the sleep timer setting, as of the last status.
+func (l *LunarScale) SleepTimeoutSetting() (goscale.SleepTimeout, error) {
+	if !l.handshake.Synced() {
+		return 0, fmt.Errorf("no status received yet")
+	}
+	setting := l.lastStatus().SleepTimerSetting
+	t, ok := comms.SleepTimeouts.Timeout(setting)
+	if !ok {
+		return 0, fmt.Errorf("unknown sleep timer setting %s", setting)
+	}
+	return t, nil
+}
+
+// SetSleepTimeout sets the sleep timer directly, rather than stepping through
+// the settings.
+func (l *LunarScale) SetSleepTimeout(t goscale.SleepTimeout) error {
+	timeout, ok := comms.SleepTimeouts.Native(t)
+	if !ok {
+		return &goscale.UnsupportedSleepTimeoutError{Timeout: t, Supported: l.SleepTimeouts()}
 	}
+	return l.setAutoOff(timeout)
+}
+
+// SleepTimeouts returns the sleep timeouts the Lunar supports.
+func (l *LunarScale) SleepTimeouts() []goscale.SleepTimeout {
+	return comms.SleepTimeouts.Timeouts()
+}
 
-	_, err := l.writeChar.WriteWithoutResponse(comms.BuildAutoOffCommand(timeout))
+func (l *LunarScale) setAutoOff(timeout comms.AutoOffSetting) error {
+	if !comms.AutoOffSettings.Contains(timeout) {
+		return fmt.Errorf("unsupported sleep timeout %s", timeout)
+	}
+
+	err := l.limiter.Load().Do(goscale.CommandSleepTimeout, func() error {
+		return l.send(comms.BuildAutoOffCommand(timeout))
+	})
 	if err != nil {
-		return fmt.Errorf("error while writing new sleep timeout: %v", err)
+		return fmt.Errorf("error while writing new sleep timeout: %w", err)
 	}
 	return nil
 }
 
 func (l *LunarScale) SetBeep(beep bool) error {
-	_, err := l.writeChar.WriteWithoutResponse(comms.BuildSetBeepCommand(beep))
+	err := l.limiter.Load().Do(goscale.CommandBeep, func() error {
+		return l.send(comms.BuildSetBeepCommand(beep))
+	})
 	if err != nil {
-		return fmt.Errorf("error while writing new beep setting: %v", err)
+		return fmt.Errorf("error while writing new beep setting: %w", err)
 	}
 	return nil
 }
 
-func (l *LunarScale) GetBeep() bool {
-	return l.status.SoundSetting.Boolean()
+// SetUnit switches the unit the scale displays and reports weights in.
+func (l *LunarScale) SetUnit(unit string) error {
+	unit, err := goscale.NormalizeUnit(unit)
+	if err != nil {
+		return err
+	}
+	setting := comms.UnitGrams
+	if unit == goscale.UnitOunces {
+		setting = comms.UnitOunces
+	}
+	err = l.limiter.Load().Do(goscale.CommandUnit, func() error {
+		return l.send(comms.BuildSetUnitCommand(setting))
+	})
+	if err != nil {
+		return fmt.Errorf("error while writing unit: %w", err)
+	}
+	return nil
 }
 
-func (l *LunarScale) GetBatteryChargePercent() (float64, error) {
-	return l.status.Battery, nil
+// unit returns the unit weights are reported in, as of the last status.
+func (l *LunarScale) unit() string {
+	if l.lastStatus().Unit == comms.UnitOunces {
+		return goscale.UnitOunces
+	}
+	return goscale.UnitGrams
 }
 
-func (l *LunarScale) sendHeartbeat() error {
-	log.Printf("sending heartbeat")
-	if !l.isConnected {
-		return fmt.Errorf("no heartbeat allowed if not connected")
+// LockKeys disables the scale's buttons d after the last key press. The
+// Lunar supports 10, 20 and 30 second delays; d is rounded up to one of them.
+func (l *LunarScale) LockKeys(d time.Duration) error {
+	var setting comms.KeyDisableSetting
+	switch {
+	case d <= 10*time.Second:
+		setting = comms.KeyDisable10s
+	case d <= 20*time.Second:
+		setting = comms.KeyDisable20s
+	case d <= 30*time.Second:
+		setting = comms.KeyDisable30s
+	default:
+		return fmt.Errorf("key lock delay %v is longer than the Lunar supports (30s)", d)
 	}
+	return l.setKeyDisable(setting)
+}
+
+// UnlockKeys re-enables the scale's buttons.
+func (l *LunarScale) UnlockKeys() error {
+	return l.setKeyDisable(comms.KeyDisableOff)
+}
+
+func (l *LunarScale) setKeyDisable(setting comms.KeyDisableSetting) error {
+	err := l.limiter.Load().Do(goscale.CommandKeyLock, func() error {
+		return l.send(comms.BuildKeyDisableCommand(setting))
+	})
+	if err != nil {
+		return fmt.Errorf("error while writing key lock setting: %w", err)
+	}
+	return nil
+}
+
+// SetKeepAwake is accepted for compatibility with other drivers. The Lunar's
+// heartbeat requests a status update every second, so the scale is kept
+// awake whenever it is connected regardless.
+func (l *LunarScale) SetKeepAwake(on bool) error {
+	return nil
+}
 
-	if !l.synced {
-		_, err := l.writeChar.Write(comms.GetStatusCommand)
+// keyLockDelays are the delays of the key lock settings.
+var keyLockDelays = map[comms.KeyDisableSetting]time.Duration{
+	comms.KeyDisableOff: 0,
+	comms.KeyDisable10s: 10 * time.Second,
+	comms.KeyDisable20s: 20 * time.Second,
+	comms.KeyDisable30s: 30 * time.Second,
+}
+
+// Settings returns the scale's settings, as of the last status. The
+// resolution and capacity can only be changed on the scale itself.
+func (l *LunarScale) Settings() (map[goscale.SettingKey]any, error) {
+	if !l.handshake.Synced() {
+		return nil, fmt.Errorf("no status received yet")
+	}
+	status := l.lastStatus()
+	settings := map[goscale.SettingKey]any{
+		goscale.SettingBeep:       status.SoundSetting.Boolean(),
+		goscale.SettingResolution: goscale.Resolution(status.ResolutionSetting),
+		goscale.SettingCapacity:   1000.0,
+		goscale.SettingUnit:       l.unit(),
+	}
+	if status.CapacitySetting == comms.Capacity2000g {
+		settings[goscale.SettingCapacity] = 2000.0
+	}
+	if t, ok := comms.SleepTimeouts.Timeout(status.SleepTimerSetting); ok {
+		settings[goscale.SettingAutoOff] = t
+	}
+	if d, ok := keyLockDelays[status.KeyDisableSetting]; ok {
+		settings[goscale.SettingKeyLock] = d
+	}
+	return settings, nil
+}
+
+// SetSetting changes one of the scale's settings.
+func (l *LunarScale) SetSetting(key goscale.SettingKey, value any) error {
+	switch key {
+	case goscale.SettingBeep:
+		beep, err := goscale.SettingValue[bool](key, value)
 		if err != nil {
-			log.Printf("Error on heartbeat: %v", err)
+			return err
 		}
-		time.Sleep(500 * time.Millisecond)
-	} else {
-		_, err := l.writeChar.Write(comms.GetStatusCommand)
+		return l.SetBeep(beep)
+	case goscale.SettingAutoOff:
+		t, err := goscale.SettingValue[goscale.SleepTimeout](key, value)
+		if err != nil {
+			return err
+		}
+		return l.SetSleepTimeout(t)
+	case goscale.SettingKeyLock:
+		d, err := goscale.SettingValue[time.Duration](key, value)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return l.UnlockKeys()
+		}
+		return l.LockKeys(d)
+	case goscale.SettingUnit:
+		unit, err := goscale.SettingValue[string](key, value)
 		if err != nil {
-			log.Printf("Error on heartbeat: %v", err)
-			l.Disconnect()
+			return err
 		}
-		time.Sleep(time.Second)
+		return l.SetUnit(unit)
+	case goscale.SettingResolution, goscale.SettingCapacity:
+		return fmt.Errorf("setting %s: %w", key, goscale.ErrReadOnlySetting)
+	default:
+		return fmt.Errorf("setting %s: %w", key, goscale.ErrNotSupported)
 	}
+}
 
-	// Re-run handshake after a stall (was 1s; too aggressive on slower
-	// transports — the repeated Identify/NotificationRequest commands appear
-	// to disrupt the scale's notification flow while it's still warming up).
-	if !l.lastNotified.IsZero() && time.Now().After(l.lastNotified.Add(5*time.Second)) {
-		log.Println("setting up notifications again")
-		_ = l.setupNotifications()
+// FirmwareVersion returns the version from the device info the scale sends
+// after the handshake.
+func (l *LunarScale) FirmwareVersion() (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.deviceInfo == nil {
+		return "", goscale.ErrVersionUnknown
 	}
+	return l.deviceInfo.Firmware.String(), nil
+}
+
+func (l *LunarScale) GetBeep() bool {
+	return l.lastStatus().SoundSetting.Boolean()
+}
+
+// BatteryUpdates returns the battery readings from the scale's status
+// reports; see goscale.BatteryNotifier.
+func (l *LunarScale) BatteryUpdates() <-chan goscale.BatteryUpdate {
+	return l.battery.Updates()
+}
+
+func (l *LunarScale) GetBatteryChargePercent() (float64, error) {
+	return l.lastStatus().Battery, nil
+}
+
+// write writes a command, split to fit the MTU if need be, waiting for
+// each write to be acknowledged.
+func (l *LunarScale) write(cmd []byte) error {
+	return goscale.WriteChunked(func(b []byte) (int, error) { return l.writeChar.Write(b) }, l.negotiatedMTU(), cmd)
+}
+
+// send writes a command, split to fit the MTU if need be, without waiting
+// for acknowledgement.
+func (l *LunarScale) send(cmd []byte) error {
+	return goscale.WriteChunked(func(b []byte) (int, error) { return l.writeChar.WriteWithoutResponse(b) }, l.negotiatedMTU(), cmd)
+}
+
+func (l *LunarScale) negotiatedMTU() uint16 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.mtu
+}
+
+// sendHeartbeat requests a status update, which keeps the scale streaming.
+// Failures only end the connection once the handshake has completed.
+func (l *LunarScale) sendHeartbeat() error {
+	log.Printf("sending heartbeat")
+	if err := l.write(comms.GetStatusCommand); err != nil {
+		if l.handshake.Synced() {
+			return err
+		}
+		log.Printf("Error on heartbeat: %v", err)
+	}
+
+	l.applyReidentifyQuirk()
 	return nil
 }
 
+// applyReidentifyQuirk re-sends the identify command if the scale is known
+// to stop streaming without it.
+func (l *LunarScale) applyReidentifyQuirk() {
+	firmware, _ := l.FirmwareVersion()
+	q, ok := goscale.QuirksFor(namePrefix, firmware).Get(goscale.QuirkReidentifyWhenIdle)
+	l.mu.Lock()
+	lastIdentified := l.lastIdentified
+	l.mu.Unlock()
+	if !ok || q.Duration <= 0 || time.Since(lastIdentified) < time.Duration(q.Duration) {
+		return
+	}
+	log.Println("re-sending identify")
+	if err := l.write(comms.IdentifyCommand); err != nil {
+		log.Printf("Error re-sending identify: %v", err)
+		return
+	}
+	l.identified()
+}
+
+// identified records that the identify command was just sent.
+func (l *LunarScale) identified() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastIdentified = time.Now()
+}
+
 func (l *LunarScale) setupNotifications() error {
 	// Negotiate a larger ATT MTU. On platforms like macOS this happens
 	// automatically; on TinyGo/HCI it does not and the scale refuses to
 	// stream larger messages (e.g. StatusMessage) because they don't fit
 	// inside the default 23-byte ATT MTU.
-	if mtu, err := l.writeChar.GetMTU(); err != nil {
+	// Commands are split to fit it, and notifications reassembled.
+	mtu, err := l.writeChar.GetMTU()
+	if err != nil {
 		log.Printf("MTU negotiation failed (continuing with default): %v", err)
+		mtu = goscale.DefaultMTU
 	} else {
 		log.Printf("negotiated MTU: %d", mtu)
 	}
+	l.mu.Lock()
+	l.mtu = mtu
+	l.mu.Unlock()
 
-	err := l.notifyChar.EnableNotifications(l.handleNotification)
+	err = l.notifyChar.EnableNotifications(goscale.RecoverNotifications(l.name, l.handleNotification, l.reportError))
 	if err != nil {
 		return fmt.Errorf("failed to enable notifications: %w", err)
 	}
 
 	log.Println("initiating handshake")
-	_, err = l.writeChar.Write(comms.IdentifyCommand)
+	err = l.write(comms.IdentifyCommand)
 	if err != nil {
 		return fmt.Errorf("failed to send initial handshake: %w", err)
 	}
+	l.identified()
+	l.handshake.Handle(comms.EventIdentifySent)
 
-	_, err = l.writeChar.Write(comms.NotificationRequestCommand)
+	err = l.write(comms.NotificationRequestCommand)
 	if err != nil {
 		return fmt.Errorf("failed to send notification request: %w", err)
 	}
+	l.handshake.Handle(comms.EventSubscribeSent)
 
 	return nil
 }
 
-func (l *LunarScale) setupCharacteristics() error {
-	log.Println("Discovering services...")
-	services, err := l.btDevice.DiscoverServices([]bluetooth.UUID{comms.LunarServiceUUID})
-	if err != nil {
-		return fmt.Errorf("could not discover services: %w", err)
-	}
-
-	if len(services) == 0 {
-		return errors.New("could not find the Lunar BT service")
+// infoWait is how long Connect waits for the device info the scale sends in
+// answer to the identify command before going on without it.
+const infoWait = time.Second
+
+// checkModel waits for the device info and refuses hardware whose model
+// isn't a scale, which validate can't tell from the advertisement. A device
+// that doesn't send its info in time is let through.
+func (l *LunarScale) checkModel(ctx context.Context) error {
+	l.mu.Lock()
+	infoChan := l.infoChan
+	l.mu.Unlock()
+
+	var info comms.DeviceInfoMessage
+	select {
+	case info = <-infoChan:
+	case <-time.After(infoWait):
+		log.Println("no device info, not checking the model")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-
-	for _, service := range services {
-		log.Printf("found service %v, scanning for write char", service)
-		chars, err := service.DiscoverCharacteristics([]bluetooth.UUID{
-			comms.LunarCommandCharUUID,
-			comms.LunarNotifyCharUUID,
-		})
-
-		if err != nil || len(chars) != 2 {
-			return fmt.Errorf("could not discover characteristics: %w", err)
+	log.Printf("model %s, firmware %s", info.Model, info.Firmware)
+	if !info.Model.IsScale() {
+		return &goscale.UnsupportedModelError{
+			Device: l.name,
+			Reason: fmt.Sprintf("reports model %s, which isn't a scale", info.Model),
 		}
+	}
+	return nil
+}
 
-		for _, char := range chars {
-			if char.UUID() == comms.LunarCommandCharUUID {
-				l.writeChar = char
-			}
-			if char.UUID() == comms.LunarNotifyCharUUID {
-				l.notifyChar = char
-			}
-		}
+func (l *LunarScale) setupCharacteristics() error {
+	log.Println("Discovering services...")
+	chars, err := goscale.DiscoverChars(l.btDevice, comms.LunarServiceUUID, comms.LunarCommandCharUUID, comms.LunarNotifyCharUUID)
+	if errors.Is(err, goscale.ErrServiceNotFound) {
+		return &goscale.UnsupportedModelError{Device: l.name, Reason: "the Lunar BT service was not found"}
 	}
+	if err != nil {
+		return err
+	}
+	l.writeChar, l.notifyChar = chars[0], chars[1]
 
 	log.Println("Successfully set up characteristics.")
 	return nil
 }
 
-// handleNotification is the callback for all incoming BLE data.
-// It assumes one notification callback contains one complete message.
+// handleNotification is the callback for all incoming BLE data. Frames
+// split across notifications, when they don't fit in one at the MTU, are
+// reassembled first.
 func (l *LunarScale) handleNotification(buf []byte) {
-	// Any valid traffic from the scale counts as "still alive" — update
-	// lastNotified so the heartbeat doesn't re-run the handshake.
-	l.lastNotified = time.Now()
+	// Stamp the reading on arrival, before decoding or any channel send can
+	// delay it.
+	now := time.Now()
+
+	// Any valid traffic from the scale counts as "still alive", so the
+	// supervisor doesn't re-run the handshake.
+	if sup := l.sup.Load(); sup != nil {
+		sup.Notified(now)
+	}
+
+	l.frames.Push(buf, func(frame []byte) {
+		l.handleFrame(frame, now)
+	})
+}
+
+// handleFrame decodes and handles one complete frame, received at the
+// given time.
+func (l *LunarScale) handleFrame(frame []byte, at time.Time) {
+	// Weight events make up nearly all of the traffic, so try the
+	// allocation-free weight decoder before the general one.
+	var weight comms.WeightMessage
+	ok, err := comms.DecodeWeightNotification(frame, &weight)
+	if err != nil {
+		log.Printf("[HANDLER] Failed to parse notification: %v. Data: % X", err, frame)
+		return
+	}
+	if ok {
+		l.sendWeight(weight, at)
+	} else {
+		l.handleMessage(frame, at)
+	}
+}
 
+// handleMessage decodes and handles any notification other than a weight
+// event, received at the given time.
+func (l *LunarScale) handleMessage(buf []byte, at time.Time) {
 	// Attempt to parse the entire buffer as a single message.
 	msg, err := comms.DecodeNotification(buf)
 	if err != nil {
@@ -306,26 +792,159 @@ func (l *LunarScale) handleNotification(buf []byte) {
 	switch t := msg.(type) {
 	case comms.WeightMessage:
 		//log.Printf("--> Weight Update: %v", t)
-		// Send the update to the user's channel.
-		l.weightUpdateChan <- goscale.WeightUpdate{Value: t.Weight}
+		l.sendWeight(t, at)
 	case comms.StatusMessage:
-		l.synced = true
+		l.mu.Lock()
 		l.status = t
+		l.mu.Unlock()
+		l.handshake.Observe(t)
+		l.battery.Report(at, t.Battery)
 		log.Printf("----> Got settings update: %v", t)
+	case comms.ButtonMessage:
+		l.sendButton(t, at)
 	case comms.DeviceInfoMessage:
+		l.mu.Lock()
+		l.deviceInfo = &t
+		select {
+		case l.infoChan <- t:
+		default: // Connect isn't waiting, or has it already
+		}
+		l.mu.Unlock()
 		log.Printf("---> Got device info: %v", t)
 	case comms.UnhandledMessage:
-		// This is the updated logging case
-		if t.MsgType != nil {
-			// It was an unhandled nested message (from command 12)
-			log.Printf("--> Unhandled Nested Message. Type: %d. Raw Frame: % X", *t.MsgType, t.RawFrame)
-		} else {
-			// It was an unhandled top-level command
-			log.Printf("--> Unhandled Command. ID: 0x%X. Raw Frame: % X", t.CommandID, t.RawFrame)
-		}
+		l.handleUnhandled(t)
 	default:
 		// This default case is a fallback for unexpected parsed types
 		log.Printf("--> Unknown packet type after successful parsing. Raw Data: % X", buf)
 	}
-	time.Sleep(50 * time.Millisecond)
+}
+
+// sendWeight forwards a decoded weight to the user's channel. Gross
+// (platform) weights go to the gross channel instead. at is when the
+// notification carrying it arrived.
+func (l *LunarScale) sendWeight(w comms.WeightMessage, at time.Time) {
+	if w.Type == comms.WeightTypeGross {
+		l.sendGross(w, at)
+		return
+	}
+	l.handshake.Handle(comms.EventWeight)
+	unit := l.unit()
+	grams, _ := goscale.ConvertWeight(w.Weight, unit, goscale.UnitGrams)
+	l.tare.Observe(grams)
+	if sup := l.sup.Load(); sup != nil {
+		sup.Weighed(at, grams)
+	}
+	rate, _ := goscale.ConvertWeight(l.flow.Add(at, grams), goscale.UnitGrams, unit)
+	l.seq++
+	l.updates.Send(goscale.WeightUpdate{
+		Value:       w.Weight,
+		Unit:        unit,
+		Seq:         l.seq,
+		Timestamp:   at,
+		FlowRate:    rate,
+		HasFlowRate: true,
+		Stable:      w.IsStable,
+		HasStable:   true,
+		Raw:         w.Raw,
+		RawDivisor:  w.Divisor,
+		HasRaw:      true,
+	})
+}
+
+// SetUnhandledFrameHandler registers fn to receive every frame the driver
+// decodes but doesn't otherwise handle, so applications can support newer
+// protocol features before the driver does. fn runs on the notification
+// goroutine and must not block. Set it before calling Connect; pass nil to
+// remove it.
+func (l *LunarScale) SetUnhandledFrameHandler(fn func(comms.UnhandledMessage)) {
+	if fn == nil {
+		l.unhandledHandler.Store(nil)
+		return
+	}
+	l.unhandledHandler.Store(&fn)
+}
+
+// handleUnhandled passes a message the driver doesn't understand to the
+// user's handler and the frame capture. If neither is set, it is logged.
+func (l *LunarScale) handleUnhandled(m comms.UnhandledMessage) {
+	handler := l.unhandledHandler.Load()
+	if handler != nil {
+		// The notification buffer may be reused once we return.
+		m.Payload = bytes.Clone(m.Payload)
+		m.RawFrame = bytes.Clone(m.RawFrame)
+		(*handler)(m)
+	}
+
+	captured := goscale.CaptureUnhandledFrame(goscale.UnhandledFrame{
+		Device:  l.name,
+		Address: l.address.String(),
+		Model:   l.DisplayName(),
+		Key:     m.Key(),
+		Frame:   m.RawFrame,
+	})
+	if !captured && handler == nil {
+		log.Printf("--> Unhandled message. %s. Raw Frame: % X", m.Key(), m.RawFrame)
+	}
+}
+
+// sendGross records a gross weight and forwards it to the gross channel.
+// Unlike the main weight channel, nobody has to be reading it: updates are
+// dropped when the channel is full.
+func (l *LunarScale) sendGross(w comms.WeightMessage, at time.Time) {
+	unit := l.unit()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gross = w.Weight
+	l.hasGross = true
+	l.grossSeq++
+	select {
+	case l.grossUpdateChan <- goscale.WeightUpdate{Value: w.Weight, Unit: unit, Seq: l.grossSeq, Timestamp: at}:
+	default:
+	}
+}
+
+// sendButton forwards a press of one of the scale's buttons to the button
+// channel, dropping it if the receiver has fallen behind.
+func (l *LunarScale) sendButton(b comms.ButtonMessage, at time.Time) {
+	if sup := l.sup.Load(); sup != nil {
+		sup.Active(at)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case l.buttonChan <- goscale.ButtonEvent{Button: b.Key.String(), Timestamp: at}:
+	default:
+	}
+}
+
+// ButtonEvents returns the presses of the scale's tare and timer buttons;
+// see goscale.ButtonNotifier.
+func (l *LunarScale) ButtonEvents() <-chan goscale.ButtonEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buttonChan
+}
+
+// GrossUpdates returns the stream of gross (platform) weights, the total
+// load on the scale regardless of tare. The Lunar only sends these in modes
+// that display both weights. The channel is replaced on each Connect and
+// closed on Disconnect, so call this after Connect.
+func (l *LunarScale) GrossUpdates() <-chan goscale.WeightUpdate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.grossUpdateChan
+}
+
+// GrossWeight returns the last gross weight received, and false if none has
+// been received since Connect.
+func (l *LunarScale) GrossWeight() (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.gross, l.hasGross
+}
+
+// reportError sends err on the update channel, if there is room. It must not
+// block, as it runs on the notification goroutine.
+func (l *LunarScale) reportError(err error) {
+	l.updates.TrySend(goscale.WeightUpdate{Error: err})
 }
@@ -0,0 +1,252 @@
+package lunar_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/bttest"
+	"github.com/mlsorensen/goscale/pkg/scales/lunar/comms"
+	"tinygo.org/x/bluetooth"
+)
+
+// weightFrame is a weight event of grams*10, stable, as the Lunar sends it.
+func weightFrame(decigrams uint32) []byte {
+	return []byte{
+		comms.HeaderPrefix1, comms.HeaderPrefix2, 0x0C, 0x08, 0x05,
+		byte(decigrams), byte(decigrams >> 8), byte(decigrams >> 16), byte(decigrams >> 24),
+		0x01, 0x00, // One decimal place; stable, positive
+		0x00, 0x00, // Checksum, unchecked
+	}
+}
+
+// infoFrame is the device info the Lunar sends in answer to the identify
+// command: the model, firmware 2.6.3 and no password.
+func infoFrame(model comms.Model) []byte {
+	return []byte{
+		comms.HeaderPrefix1, comms.HeaderPrefix2, 0x07, 0x07,
+		byte(model), 0x03, 0x02, 0x06, 0x00, 0x00,
+		0x00, 0x00, // Checksum, unchecked
+	}
+}
+
+// scaleModel is the model fakeLunar reports.
+const scaleModel comms.Model = 0x01
+
+// fakeLunar installs an adapter with one Lunar in range, and returns its
+// command and notification characteristics. It answers the identify command
+// with its device info.
+func fakeLunar(t *testing.T) (*bttest.Device, *bttest.Characteristic, *bttest.Characteristic) {
+	t.Helper()
+	a := bttest.NewAdapter()
+	a.AdvertisingInterval = 10 * time.Millisecond
+	t.Cleanup(a.Install())
+
+	dev := a.AddDevice("LUNAR-123456", "C8:3A:35:00:00:01")
+	dev.Advertise(comms.LunarServiceUUID)
+	cmd := dev.AddCharacteristic(comms.LunarServiceUUID, comms.LunarCommandCharUUID)
+	notify := dev.AddCharacteristic(comms.LunarServiceUUID, comms.LunarNotifyCharUUID)
+	answerIdentify(cmd, notify, scaleModel)
+	return dev, cmd, notify
+}
+
+// answerIdentify makes a fake Lunar send its device info, reporting model,
+// when it is identified.
+func answerIdentify(cmd, notify *bttest.Characteristic, model comms.Model) {
+	cmd.OnWrite(func(p []byte) {
+		if bytes.Equal(p, comms.IdentifyCommand) {
+			notify.Notify(infoFrame(model))
+		}
+	})
+}
+
+func connect(t *testing.T) (goscale.Scale, <-chan goscale.WeightUpdate) {
+	t.Helper()
+	found, err := goscale.ScanForOne(time.Second)
+	if err != nil {
+		t.Fatalf("ScanForOne: %v", err)
+	}
+	s, err := goscale.NewScaleForDevice(found)
+	if err != nil {
+		t.Fatalf("NewScaleForDevice: %v", err)
+	}
+	updates, err := s.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Disconnect() })
+	return s, updates
+}
+
+func nextUpdate(t *testing.T, updates <-chan goscale.WeightUpdate) goscale.WeightUpdate {
+	t.Helper()
+	select {
+	case u, ok := <-updates:
+		if !ok {
+			t.Fatal("updates closed")
+		}
+		return u
+	case <-time.After(time.Second):
+		t.Fatal("no weight update")
+	}
+	return goscale.WeightUpdate{}
+}
+
+func TestConnect(t *testing.T) {
+	dev, cmd, notify := fakeLunar(t)
+	s, updates := connect(t)
+
+	if !dev.Connected() || !s.IsConnected() {
+		t.Fatal("not connected after Connect")
+	}
+	want := append(bytes.Clone(comms.IdentifyCommand), comms.NotificationRequestCommand...)
+	if got := bytes.Join(cmd.Writes(), nil); !bytes.HasPrefix(got, want) {
+		t.Errorf("handshake wrote % X, want identify then notification request", got)
+	}
+
+	notify.Notify(weightFrame(183))
+	u := nextUpdate(t, updates)
+	if u.Value != 18.3 || u.Unit != goscale.UnitGrams || !u.Stable {
+		t.Errorf("update = %v %s (stable %t), want 18.3 g stable", u.Value, u.Unit, u.Stable)
+	}
+	if v, err := goscale.FirmwareVersion(s); v != "2.6.3" || err != nil {
+		t.Errorf("firmware = %q, %v, want 2.6.3 from the device info", v, err)
+	}
+}
+
+// A scale that doesn't send its device info is connected without its model
+// being checked.
+func TestConnectWithoutDeviceInfo(t *testing.T) {
+	_, cmd, notify := fakeLunar(t)
+	cmd.OnWrite(nil)
+	_, updates := connect(t)
+
+	notify.Notify(weightFrame(183))
+	if u := nextUpdate(t, updates); u.Value != 18.3 {
+		t.Errorf("update = %v, want 18.3", u.Value)
+	}
+}
+
+// Acaia hardware that isn't a scale answers to the Lunar's name and service,
+// and only its device info gives it away.
+func TestConnectNotAScale(t *testing.T) {
+	const grinder comms.Model = 0x7E
+	comms.NonScaleModels[grinder] = "grinder"
+	t.Cleanup(func() { delete(comms.NonScaleModels, grinder) })
+
+	dev, cmd, notify := fakeLunar(t)
+	answerIdentify(cmd, notify, grinder)
+
+	found, err := goscale.ScanForOne(time.Second)
+	if err != nil {
+		t.Fatalf("ScanForOne: %v", err)
+	}
+	s, err := goscale.NewScaleForDevice(found)
+	if err != nil {
+		t.Fatalf("NewScaleForDevice: %v", err)
+	}
+	_, err = s.Connect()
+	var unsupported *goscale.UnsupportedModelError
+	if !errors.As(err, &unsupported) {
+		t.Errorf("Connect = %v, want an UnsupportedModelError", err)
+	}
+	if dev.Connected() || s.IsConnected() {
+		t.Error("still connected to a device that isn't a scale")
+	}
+}
+
+// A device that advertises another service than the Lunar's is refused
+// before connecting.
+func TestNewScaleOtherService(t *testing.T) {
+	a := bttest.NewAdapter()
+	a.AdvertisingInterval = 10 * time.Millisecond
+	t.Cleanup(a.Install())
+	dev := a.AddDevice("LUNAR-123456", "C8:3A:35:00:00:01")
+	dev.Advertise(bluetooth.New16BitUUID(0xFFF0))
+
+	found, err := goscale.ScanForOne(time.Second)
+	if err != nil {
+		t.Fatalf("ScanForOne: %v", err)
+	}
+	_, err = goscale.NewScaleForDevice(found)
+	var unsupported *goscale.UnsupportedModelError
+	if !errors.As(err, &unsupported) {
+		t.Errorf("NewScaleForDevice = %v, want an UnsupportedModelError", err)
+	}
+}
+
+// A frame too long for the link's MTU arrives in pieces.
+func TestConnectSplitFrame(t *testing.T) {
+	_, _, notify := fakeLunar(t)
+	_, updates := connect(t)
+
+	frame := weightFrame(3600)
+	notify.Schedule([]bttest.Frame{
+		{After: 10 * time.Millisecond, Data: frame[:6]},
+		{After: 10 * time.Millisecond, Data: frame[6:]},
+	})
+	if u := nextUpdate(t, updates); u.Value != 360 {
+		t.Errorf("update = %v, want 360", u.Value)
+	}
+}
+
+func TestConnectDropped(t *testing.T) {
+	dev, _, _ := fakeLunar(t)
+	s, updates := connect(t)
+
+	dev.Drop()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Fatal("update after the link dropped")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("updates not closed after the link dropped")
+	}
+	if s.IsConnected() {
+		t.Error("still connected after the link dropped")
+	}
+}
+
+func TestConnectMissingService(t *testing.T) {
+	restore := goscale.DefaultDiscoveryConfig
+	goscale.DefaultDiscoveryConfig = goscale.DiscoveryConfig{Attempts: 1}
+	t.Cleanup(func() { goscale.DefaultDiscoveryConfig = restore })
+
+	a := bttest.NewAdapter()
+	a.AdvertisingInterval = 10 * time.Millisecond
+	t.Cleanup(a.Install())
+	a.AddDevice("LUNAR-123456", "C8:3A:35:00:00:01")
+
+	found, err := goscale.ScanForOne(time.Second)
+	if err != nil {
+		t.Fatalf("ScanForOne: %v", err)
+	}
+	s, err := goscale.NewScaleForDevice(found)
+	if err != nil {
+		t.Fatalf("NewScaleForDevice: %v", err)
+	}
+	_, err = s.Connect()
+	var unsupported *goscale.UnsupportedModelError
+	if !errors.As(err, &unsupported) {
+		t.Errorf("Connect = %v, want an UnsupportedModelError", err)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	dev, cmd, _ := fakeLunar(t)
+	s, _ := connect(t)
+
+	if err := goscale.Sleep(s); err != nil {
+		t.Fatalf("Sleep: %v", err)
+	}
+	writes := cmd.Writes()
+	if got := writes[len(writes)-1]; !bytes.Equal(got, comms.PowerOffCommand) {
+		t.Errorf("last write = % X, want the power off command", got)
+	}
+	if dev.Connected() || s.IsConnected() {
+		t.Error("still connected after Sleep")
+	}
+}
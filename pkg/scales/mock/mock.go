@@ -12,18 +12,22 @@ import (
 	"tinygo.org/x/bluetooth"
 
 	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/flow"
 )
 
 // This init function registers the MockScale with the central registry.
 // To use it, you must explicitly import this package.
 func init() {
 	// Register with a distinct name, "MOCK", so it can be requested specifically.
-	goscale.Register("MOCK", New)
+	goscale.RegisterWithOptions("MOCK", New, goscale.DriverOptions{Virtual: true})
 }
 
 // This line is the compile-time check. It will fail to compile if
 // *MockScale ever stops satisfying the goscale.Scale interface.
 var _ goscale.Scale = (*MockScale)(nil)
+var _ goscale.Sleeper = (*MockScale)(nil)
+var _ goscale.ContextConnector = (*MockScale)(nil)
+var _ goscale.TareOffsetter = (*MockScale)(nil)
 var features = goscale.ScaleFeatures{
 	Tare:           true,
 	BatteryPercent: true,
@@ -38,6 +42,14 @@ type MockScale struct {
 	connected    bool
 	batteryLevel float64
 	weight       float64
+	tareOffset   float64 // Grams, see SetTareOffset
+
+	// Simulated timing behaviour, see Option.
+	interval time.Duration
+	wander   float64
+	driftPPM float64
+	jitter   time.Duration
+	latency  LatencyDistribution
 
 	disconnectCtx context.Context
 	disconnect    context.CancelFunc
@@ -52,6 +64,8 @@ func (s *MockScale) GetFeatures() goscale.ScaleFeatures {
 }
 
 func (s *MockScale) IsConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.connected
 }
 
@@ -65,16 +79,43 @@ func (s *MockScale) DisplayName() string {
 
 // New creates a new, uninitialized MockScale.
 func New(device *goscale.FoundDevice) goscale.Scale {
-	return &MockScale{
+	return NewWithOptions(device)
+}
+
+// NewWithOptions creates a new, uninitialized MockScale with the given
+// timing options applied. To have the registry hand out a configured mock,
+// register a factory that wraps it:
+//
+//	goscale.Register("MOCK", func(d *goscale.FoundDevice) goscale.Scale {
+//		return mock.NewWithOptions(d, mock.WithLatency(mock.NormalLatency{Mean: 30 * time.Millisecond, StdDev: 10 * time.Millisecond}))
+//	})
+func NewWithOptions(device *goscale.FoundDevice, opts ...Option) *MockScale {
+	s := &MockScale{
 		name:         device.Name,
 		address:      bluetooth.Address{},
-		batteryLevel: .98,  // Start with a high battery
+		batteryLevel: 98,   // Start with a high battery
 		weight:       21.5, // Start with some initial weight
+		interval:     750 * time.Millisecond,
+		wander:       0.5,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Connect starts the simulation.
 func (s *MockScale) Connect() (<-chan goscale.WeightUpdate, error) {
+	return s.ConnectContext(context.Background())
+}
+
+// ConnectContext connects the simulated scale, unless ctx is already done;
+// the simulated connection is instant.
+func (s *MockScale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -92,27 +133,62 @@ func (s *MockScale) Connect() (<-chan goscale.WeightUpdate, error) {
 	updates := make(chan goscale.WeightUpdate)
 
 	// Start the simulation goroutine
-	go s.simulate(s.disconnectCtx, updates)
+	go s.simulate(s.disconnectCtx, s.stopChan, s.tareRequested, updates)
 
 	log.Println("MOCK: Connected successfully.")
 	return updates, nil
 }
 
-// simulate is the core loop that generates fake data.
-func (s *MockScale) simulate(ctx context.Context, updates chan<- goscale.WeightUpdate) {
-	// IMPORTANT: Ensure the channel is closed on exit to signal disconnection.
-	defer close(updates)
+// scheduledUpdate is a reading waiting out its simulated delivery latency.
+type scheduledUpdate struct {
+	due    time.Time
+	update goscale.WeightUpdate
+}
+
+// simulate is the core loop that generates fake data. It is handed the
+// connection's channels, as Disconnect and the next Connect replace them.
+func (s *MockScale) simulate(ctx context.Context, stop, tare <-chan struct{}, updates chan<- goscale.WeightUpdate) {
 	defer log.Println("MOCK: Simulation stopped.")
 
-	ticker := time.NewTicker(750 * time.Millisecond)
+	// Readings pass through a delivery queue so simulated latency doesn't
+	// hold up taking the next reading.
+	queue := make(chan scheduledUpdate, 64)
+	defer close(queue)
+	go s.deliver(ctx, queue, updates)
+
+	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
 
+	start := time.Now()
+	var seq uint64
+	// The flow rate is measured on the true clock, not the drifting and
+	// jittered one the timestamps are taken from.
+	meter := flow.NewMeter(flow.DefaultWindow)
+	var delta goscale.DeltaTracker
+	send := func(u goscale.WeightUpdate) bool {
+		now := time.Now()
+		seq++
+		u.Seq = seq
+		u.Timestamp = s.timestamp(start, now)
+		s.mu.Lock()
+		u = goscale.ApplyTareOffset(u, s.tareOffset)
+		s.mu.Unlock()
+		u.FlowRate, u.HasFlowRate = meter.Add(now, u.Value), true
+		u = delta.Apply(u)
+		select {
+		case queue <- scheduledUpdate{due: now.Add(s.deliveryDelay()), update: u}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
 	for {
 		select {
 		case <-ticker.C:
 			s.mu.Lock()
 			// Add a small random drift to the weight
-			s.weight += (rand.Float64() - 0.4) * 0.5 // a little up, a little down
+			s.weight += (rand.Float64() - 0.4) * s.wander // a little up, a little down
 			if s.weight < 0 {
 				s.weight = 0
 			}
@@ -121,17 +197,21 @@ func (s *MockScale) simulate(ctx context.Context, updates chan<- goscale.WeightU
 				Unit:  "g",
 			}
 			s.mu.Unlock()
-			updates <- update
+			if !send(update) {
+				return
+			}
 
-		case <-s.tareRequested:
+		case <-tare:
 			log.Println("MOCK: Tare requested, resetting weight to 0.")
 			s.mu.Lock()
 			s.weight = 0
 			s.mu.Unlock()
 			// Send an immediate update after taring
-			updates <- goscale.WeightUpdate{Value: 0, Unit: "g"}
+			if !send(goscale.WeightUpdate{Value: 0, Unit: "g"}) {
+				return
+			}
 
-		case <-s.stopChan: // Disconnect() was called
+		case <-stop: // Disconnect() was called
 			return
 
 		case <-ctx.Done(): // Parent context was cancelled
@@ -140,6 +220,28 @@ func (s *MockScale) simulate(ctx context.Context, updates chan<- goscale.WeightU
 	}
 }
 
+// deliver hands queued readings to the consumer once their simulated
+// latency has elapsed, preserving the order they were taken in.
+func (s *MockScale) deliver(ctx context.Context, queue <-chan scheduledUpdate, updates chan<- goscale.WeightUpdate) {
+	// IMPORTANT: Ensure the channel is closed on exit to signal disconnection.
+	defer close(updates)
+
+	for su := range queue {
+		if wait := time.Until(su.due); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case updates <- su.update:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // Disconnect stops the simulation.
 func (s *MockScale) Disconnect() error {
 	s.mu.Lock()
@@ -161,6 +263,21 @@ func (s *MockScale) Disconnect() error {
 	return nil
 }
 
+// SetTareOffset sets the grams subtracted from weight updates, such as a
+// container's. See goscale.TareToOffset.
+func (s *MockScale) SetTareOffset(grams float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tareOffset = grams
+}
+
+// TareOffset returns the grams subtracted from weight updates.
+func (s *MockScale) TareOffset() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tareOffset
+}
+
 // Tare sends a request to the simulation to zero the weight.
 func (s *MockScale) Tare(blocking bool) error {
 	s.mu.Lock()
@@ -183,12 +300,27 @@ func (s *MockScale) Tare(blocking bool) error {
 	return nil
 }
 
+// Place adds grams to the simulated weight, or takes them off if negative,
+// e.g. to simulate a grinder filling a cup. The change shows up in the next
+// reading.
+func (s *MockScale) Place(grams float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weight = max(s.weight+grams, 0)
+}
+
 // SetSleepTimeout just logs the action.
 func (s *MockScale) AdvanceSleepTimeout() error {
 	log.Printf("MOCK: SetSleepTimeout called")
 	return nil
 }
 
+// Sleep simulates the scale powering down, which drops the connection.
+func (s *MockScale) Sleep() error {
+	log.Println("MOCK: Sleep called")
+	return s.Disconnect()
+}
+
 // ReadBatteryChargePercent returns the simulated battery level.
 func (s *MockScale) GetBatteryChargePercent() (float64, error) {
 	s.mu.Lock()
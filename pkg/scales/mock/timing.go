@@ -0,0 +1,110 @@
+package mock
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Option configures a MockScale created with NewWithOptions.
+type Option func(*MockScale)
+
+// LatencyDistribution produces the delay between a simulated reading being
+// taken and it being delivered on the update channel.
+type LatencyDistribution interface {
+	Sample() time.Duration
+}
+
+// FixedLatency delays every update by the same amount.
+type FixedLatency time.Duration
+
+func (f FixedLatency) Sample() time.Duration {
+	return time.Duration(f)
+}
+
+// UniformLatency delays each update by a random amount in [Min, Max).
+type UniformLatency struct {
+	Min, Max time.Duration
+}
+
+func (u UniformLatency) Sample() time.Duration {
+	if u.Max <= u.Min {
+		return u.Min
+	}
+	return u.Min + time.Duration(rand.Int63n(int64(u.Max-u.Min)))
+}
+
+// NormalLatency delays each update by a normally distributed amount, clamped
+// at zero. BLE connection intervals make real delivery latency cluster
+// around a mean with occasional long tails, which this approximates.
+type NormalLatency struct {
+	Mean, StdDev time.Duration
+}
+
+func (n NormalLatency) Sample() time.Duration {
+	d := n.Mean + time.Duration(rand.NormFloat64()*float64(n.StdDev))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// WithInterval sets how often the mock takes a reading. The default is
+// 750ms.
+func WithInterval(d time.Duration) Option {
+	return func(s *MockScale) {
+		s.interval = d
+	}
+}
+
+// WithWander sets how far, in grams, the simulated weight may wander
+// between readings. The default is 0.5; zero holds it steady, for code that
+// waits for the weight to settle.
+func WithWander(grams float64) Option {
+	return func(s *MockScale) {
+		s.wander = grams
+	}
+}
+
+// WithClockDrift makes the mock's clock, used for WeightUpdate.Timestamp,
+// run fast (positive) or slow (negative) relative to the host by the given
+// parts per million.
+func WithClockDrift(ppm float64) Option {
+	return func(s *MockScale) {
+		s.driftPPM = ppm
+	}
+}
+
+// WithTimestampJitter adds normally distributed noise with the given
+// standard deviation to each WeightUpdate.Timestamp.
+func WithTimestampJitter(stdDev time.Duration) Option {
+	return func(s *MockScale) {
+		s.jitter = stdDev
+	}
+}
+
+// WithLatency delays delivery of each update by a sample from dist. Updates
+// are still delivered in the order they were taken, as BLE notifications
+// are, so a long delay holds back the readings behind it.
+func WithLatency(dist LatencyDistribution) Option {
+	return func(s *MockScale) {
+		s.latency = dist
+	}
+}
+
+// timestamp returns the mock clock's view of a reading taken at now.
+func (s *MockScale) timestamp(start, now time.Time) time.Time {
+	elapsed := now.Sub(start)
+	ts := start.Add(elapsed + time.Duration(float64(elapsed)*s.driftPPM/1e6))
+	if s.jitter > 0 {
+		ts = ts.Add(time.Duration(rand.NormFloat64() * float64(s.jitter)))
+	}
+	return ts
+}
+
+// deliveryDelay returns how long to hold an update before delivering it.
+func (s *MockScale) deliveryDelay() time.Duration {
+	if s.latency == nil {
+		return 0
+	}
+	return s.latency.Sample()
+}
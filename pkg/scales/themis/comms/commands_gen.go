@@ -0,0 +1,49 @@
+// Code generated by cmdgen from commands.yaml; DO NOT EDIT.
+
+package comms
+
+// BuildTareCommand creates the command to tare the scale.
+func BuildTareCommand() []byte {
+	return encodeFrame(0x01, []byte{0x00, 0x00})
+}
+
+// BuildAutoOffCommand creates the command to set the standby time.
+func BuildAutoOffCommand(setting AutoOffSetting) []byte {
+	return encodeFrame(0x03, []byte{0x00, byte(setting)})
+}
+
+// BuildChangeBeepCommand creates the command to turn the buzzer on or off.
+func BuildChangeBeepCommand(beep bool) []byte {
+	return encodeFrame(0x02, []byte{0x00, boolByte(beep, 0x05)})
+}
+
+// BuildLightCommand creates the command to turn the display light on or off.
+func BuildLightCommand(on bool) []byte {
+	return encodeFrame(0x09, []byte{0x00, boolByte(on, 0x01)})
+}
+
+// BuildFlowRateWindowCommand creates the command to set the window the flow rate is averaged
+// over, in tenths of a second.
+func BuildFlowRateWindowCommand(tenths uint8) []byte {
+	return encodeFrame(0x0A, []byte{0x00, byte(tenths)})
+}
+
+// encodeFrame frames a command: the header, op code and payload, then the
+// checksum.
+func encodeFrame(op byte, payload []byte) []byte {
+	msg := append([]byte{0x03, 0x0A}, op)
+	msg = append(msg, payload...)
+	var csum byte
+	for _, c := range msg {
+		csum ^= c
+	}
+	return append(msg, csum)
+}
+
+// boolByte returns on if v is true, and zero otherwise.
+func boolByte(v bool, on byte) byte {
+	if v {
+		return on
+	}
+	return 0
+}
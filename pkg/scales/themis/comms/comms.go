@@ -1,7 +1,11 @@
 package comms
 
+//go:generate go run github.com/mlsorensen/goscale/internal/cmdgen
+
 import (
-	"log"
+	"time"
+
+	"github.com/mlsorensen/goscale"
 	"tinygo.org/x/bluetooth"
 )
 
@@ -10,11 +14,27 @@ var (
 	ThemisCommandCharUUID, _ = bluetooth.ParseUUID("FF12")
 	ThemisNotifyCharUUID, _  = bluetooth.ParseUUID("FF11")
 
-	ThemisTareCommand = []byte{0x03, 0x0a, 0x01, 0x00, 0x00, 0x08}
+	// ThemisTareCommand tares the scale.
+	//
+	// Deprecated: use BuildTareCommand.
+	ThemisTareCommand = BuildTareCommand()
 
-	AutoOffSettings = newAutoOffSettingsManager()
+	// AutoOffSettings are the standby times the scale supports, in minutes.
+	AutoOffSettings = goscale.NewSettingCycle(AutoOff5Min, AutoOff10Min, AutoOff15Min, AutoOff20Min, AutoOff30Min)
+
+	// SleepTimeouts maps the standby times to goscale.SleepTimeouts.
+	SleepTimeouts = goscale.NewSleepTimeoutMap(map[AutoOffSetting]goscale.SleepTimeout{
+		AutoOff5Min:  goscale.SleepTimeout(5 * time.Minute),
+		AutoOff10Min: goscale.SleepTimeout(10 * time.Minute),
+		AutoOff15Min: goscale.SleepTimeout(15 * time.Minute),
+		AutoOff20Min: goscale.SleepTimeout(20 * time.Minute),
+		AutoOff30Min: goscale.SleepTimeout(30 * time.Minute),
+	})
 )
 
+// WeightDivisor is what StatusUpdate.GramsRaw is divided by to give grams.
+const WeightDivisor = 100
+
 type StatusUpdate struct {
 	ProductNumber    uint8
 	Type             uint8
@@ -22,6 +42,7 @@ type StatusUpdate struct {
 	UnitOfWeight     uint8   // BYTE6: Unit of weight (grams only)
 	WeightSymbolData uint8   // BYTE7: Weight symbol data points (+/-)
 	GramsWeight      float64 // Combined from bytes 8-10 (indices 7, 8, 9) representing grams * 100
+	GramsRaw         int32   // GramsWeight as sent, signed, in 1/WeightDivisor grams
 	FlowRateSymbol   uint8   // BYTE11: Flow rate symbol data points (+/-)
 	FlowRate         float64 // Combined from bytes 12 and 13 (indices 11, 12) representing flow rate * 100
 	PowerPercentage  uint8   // BYTE14: Percentage of remaining power
@@ -30,14 +51,34 @@ type StatusUpdate struct {
 	SmoothingSwitch  uint8   // BYTE18: Flow rate smoothing switch
 	Reserved1        uint8   // BYTE19: Reserved (00)
 	Reserved2        uint8   // BYTE20: Reserved (00)
+
+	// Sent by FirmwareExtended only; see Variant.
+	LightOn        bool  // BYTE21: Display light on (01) or off (00)
+	FlowRateWindow uint8 // BYTE22: Window the flow rate is averaged over, in tenths of a second
+
+	Variant Variant // Model and firmware generation detected from the frame
+	Extra   []byte  // Status bytes beyond those decoded, sent by newer firmware
 }
 
-// DecodeStatusUpdate decodes the raw Themis notification. Returns the weight and whether decode was successful
+// DecodeStatusUpdate decodes the raw Themis notification. Returns the weight and whether decode was successful.
+// Frames from every known model share the base layout; the bytes extended firmware adds after it are
+// decoded too, and any others are kept in Extra. Frames of unknown types aren't decoded.
 func DecodeStatusUpdate(data []byte) (*StatusUpdate, bool) {
 	var n StatusUpdate
-	
-	if len(data) != 20 {
-		return nil, false // Return zeroed struct if data length is incorrect
+
+	variant, ok := DetectVariant(data)
+	if !ok {
+		return nil, false
+	}
+	n.Variant = variant
+	decoded := BaseStatusLength
+	if variant.Firmware == FirmwareExtended {
+		n.LightOn = data[20] != 0
+		n.FlowRateWindow = data[21]
+		decoded = ExtendedStatusLength
+	}
+	if len(data) > decoded {
+		n.Extra = append([]byte(nil), data[decoded:]...)
 	}
 
 	// Milliseconds: Combine bytes 3-5 (indices 2, 3, 4) into a uint32 (big-endian)
@@ -48,18 +89,19 @@ func DecodeStatusUpdate(data []byte) (*StatusUpdate, bool) {
 	gramsUint = uint32(data[7])<<16 | uint32(data[8])<<8 | uint32(data[9])
 
 	// Handle sign based on WeightSymbolData
+	n.GramsRaw = int32(gramsUint)
 	if data[6] == 45 { // Check if the value is negative (ASCII for '-')
-		n.GramsWeight = -float64(gramsUint) / 100.0
-	} else {
-		n.GramsWeight = float64(gramsUint) / 100.0
+		n.GramsRaw = -n.GramsRaw
 	}
+	n.GramsWeight = float64(n.GramsRaw) / WeightDivisor
 
 	// FlowRate: Combine bytes 12 and 13 (indices 11, 12) into a uint16 (big-endian) representing flow rate * 100
 	var flowRateUint uint16
 	flowRateUint = uint16(data[11])<<8 | uint16(data[12])
 	n.FlowRate = float64(flowRateUint) / 100.0
 
-	// StandbyTime: Combine bytes 15 and 16 (indices 14, 15) into a uint16 (big-endian) representing minutes
+	// StandbyTime: Combine bytes 15 and 16 (indices 14, 15) into a uint16 (big-endian) representing
+	// minutes * 10, e.g. 0x00 0x64 for 10 minutes
 	n.StandbyTime = (uint16(data[14])<<8 | uint16(data[15])) / 10
 
 	// Assign other fields directly from the byte slice
@@ -76,21 +118,3 @@ func DecodeStatusUpdate(data []byte) (*StatusUpdate, bool) {
 
 	return &n, true
 }
-
-func BuildAutoOffCommand(setting AutoOffSetting) []byte {
-	payload := []byte{0x03, 0x0a, 0x03, 0x00, uint8(setting)}
-	msg := append(payload, CalculateChecksum(payload))
-	log.Println(msg)
-	return msg
-}
-
-func BuildChangeBeepCommand(beep bool) []byte {
-	set := 0
-	if beep {
-		set = 5
-	}
-	payload := []byte{0x03, 0x0a, 0x02, 0x00, uint8(set)}
-	msg := append(payload, CalculateChecksum(payload))
-	log.Println(msg)
-	return msg
-}
@@ -0,0 +1,173 @@
+package comms
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// status is a Themis status frame: 18.30 g, 1.20 g/s, 80% battery and a
+// 10 minute standby time.
+var status = []byte{
+	0x03, 0x0B, 0x00, 0x2E, 0xE0, 0x00, 0x2B, 0x00, 0x07, 0x26,
+	0x2B, 0x00, 0x78, 0x50, 0x00, 0x64, 0x01, 0x01, 0x00, 0x00,
+}
+
+// withStandby returns status with its standby bytes set to b0 and b1.
+func withStandby(b0, b1 byte) []byte {
+	frame := append([]byte(nil), status...)
+	frame[14], frame[15] = b0, b1
+	return frame
+}
+
+func TestDecodeStatusUpdate(t *testing.T) {
+	got, ok := DecodeStatusUpdate(status)
+	if !ok {
+		t.Fatal("status frame not decoded")
+	}
+	want := StatusUpdate{
+		ProductNumber:    ProductScale,
+		Type:             FrameTypeWeight,
+		Milliseconds:     12000,
+		WeightSymbolData: '+',
+		GramsWeight:      18.3,
+		GramsRaw:         1830,
+		FlowRateSymbol:   '+',
+		FlowRate:         1.2,
+		PowerPercentage:  80,
+		StandbyTime:      10,
+		BuzzerGear:       1,
+		SmoothingSwitch:  1,
+		Variant:          Variant{Model: ModelThemis, FrameType: FrameTypeWeight, Firmware: FirmwareBase},
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("decoded %+v, want %+v", *got, want)
+	}
+}
+
+// withHeader returns status with the frame type set to frameType, and extra
+// appended.
+func withHeader(frameType byte, extra ...byte) []byte {
+	frame := append([]byte(nil), status...)
+	frame[1] = frameType
+	return append(frame, extra...)
+}
+
+func TestDetectVariant(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  Variant
+		ok    bool
+	}{
+		{"Themis", status, Variant{Model: ModelThemis, FrameType: FrameTypeWeight, Firmware: FirmwareBase}, true},
+		{"Mini", withHeader(FrameTypeWeightMini), Variant{Model: ModelThemisMini, FrameType: FrameTypeWeightMini, Firmware: FirmwareBase}, true},
+		{"Ultra, extended", withHeader(FrameTypeWeightUltra, 0x01, 0x0A), Variant{Model: ModelThemisUltra, FrameType: FrameTypeWeightUltra, Firmware: FirmwareExtended, Extended: true}, true},
+		{"unknown firmware", withHeader(FrameTypeWeight, 0x01), Variant{Model: ModelThemis, FrameType: FrameTypeWeight, Firmware: FirmwareUnknown, Extended: true}, true},
+		{"unknown frame type", withHeader(0x0E), Variant{}, false},
+		{"command echo", withHeader(FrameTypeCommand), Variant{}, false},
+		{"other product", append([]byte{0x02}, status[1:]...), Variant{}, false},
+		{"short", status[:BaseStatusLength-1], Variant{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DetectVariant(tt.frame)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("DetectVariant = %+v, %t, want %+v, %t", got, ok, tt.want, tt.ok)
+			}
+			if _, decoded := DecodeStatusUpdate(tt.frame); decoded != tt.ok {
+				t.Errorf("DecodeStatusUpdate decoded %t, want %t", decoded, tt.ok)
+			}
+		})
+	}
+}
+
+// Extended firmware reports the light and flow-rate window after the base
+// layout; bytes after those are kept undecoded.
+func TestDecodeExtendedStatus(t *testing.T) {
+	got, ok := DecodeStatusUpdate(withHeader(FrameTypeWeightMini, 0x01, 0x0A, 0x7F))
+	if !ok {
+		t.Fatal("extended status not decoded")
+	}
+	if got.GramsWeight != 18.3 || !got.LightOn || got.FlowRateWindow != 10 || !bytes.Equal(got.Extra, []byte{0x7F}) {
+		t.Errorf("decoded %.2f g, light %t, window %d, extra % X; want 18.30 g, light on, window 10, extra 7F",
+			got.GramsWeight, got.LightOn, got.FlowRateWindow, got.Extra)
+	}
+
+	// A byte too few for the extended layout is kept, not decoded.
+	got, ok = DecodeStatusUpdate(withHeader(FrameTypeWeight, 0x01))
+	if !ok || got.LightOn || !bytes.Equal(got.Extra, []byte{0x01}) {
+		t.Errorf("21-byte status decoded light %t, extra % X; want light off, extra 01", got.LightOn, got.Extra)
+	}
+}
+
+func TestExtendedCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"light on", BuildLightCommand(true), []byte{0x03, 0x0A, 0x09, 0x00, 0x01, 0x01}},
+		{"light off", BuildLightCommand(false), []byte{0x03, 0x0A, 0x09, 0x00, 0x00, 0x00}},
+		{"flow rate window", BuildFlowRateWindowCommand(15), []byte{0x03, 0x0A, 0x0A, 0x00, 0x0F, 0x0C}},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s: % X, want % X", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+// The standby time is sent big-endian in tenths of a minute.
+func TestDecodeStandbyTime(t *testing.T) {
+	tests := []struct {
+		b0, b1 byte
+		want   uint16
+	}{
+		{0x00, 0x32, 5},
+		{0x00, 0x64, 10},
+		{0x00, 0x96, 15},
+		{0x00, 0xC8, 20},
+		{0x01, 0x2C, 30},
+		{0x00, 0x46, 7}, // Not a setting; decoded, and refused by the driver
+	}
+	for _, tt := range tests {
+		got, ok := DecodeStatusUpdate(withStandby(tt.b0, tt.b1))
+		if !ok {
+			t.Fatalf("% X: not decoded", []byte{tt.b0, tt.b1})
+		}
+		if got.StandbyTime != tt.want {
+			t.Errorf("% X: StandbyTime = %d, want %d", []byte{tt.b0, tt.b1}, got.StandbyTime, tt.want)
+		}
+	}
+}
+
+func BenchmarkDecodeStatusUpdate(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = DecodeStatusUpdate(status)
+	}
+}
+
+// The Themis's settings are its standby times in minutes.
+func TestSleepTimeouts(t *testing.T) {
+	for _, setting := range []AutoOffSetting{AutoOff5Min, AutoOff10Min, AutoOff15Min, AutoOff20Min, AutoOff30Min} {
+		want := goscale.SleepTimeout(time.Duration(setting) * time.Minute)
+		got, ok := SleepTimeouts.Timeout(setting)
+		if !ok || got != want {
+			t.Errorf("Timeout(%d) = %s, %t, want %s", setting, got, ok, want)
+			continue
+		}
+		if back, ok := SleepTimeouts.Native(got); !ok || back != setting {
+			t.Errorf("Native(%s) = %d, %t, want %d", got, back, ok, setting)
+		}
+	}
+	for _, unsupported := range []goscale.SleepTimeout{goscale.SleepTimeoutDisabled, goscale.SleepTimeout(60 * time.Minute)} {
+		if _, ok := SleepTimeouts.Native(unsupported); ok {
+			t.Errorf("%s, which the Themis lacks, mapped to a setting", unsupported)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package comms
+
+import "fmt"
+
+// Frame header bytes. Every BOOKOO frame starts with a product number and a
+// frame type; commands we send use FrameTypeCommand, status notifications
+// from the original Themis use FrameTypeWeight.
+const (
+	ProductScale     uint8 = 0x03
+	FrameTypeCommand uint8 = 0x0a
+	FrameTypeWeight  uint8 = 0x0b
+
+	// The Mini's and Ultra's status frame types are provisional: they
+	// follow on from the Themis's, and haven't been checked against
+	// captures. Correct them here, and in statusModels, when captures
+	// come in.
+	FrameTypeWeightMini  uint8 = 0x0c
+	FrameTypeWeightUltra uint8 = 0x0d
+)
+
+// BaseStatusLength is the length of the status frame sent by the original
+// Themis firmware. Newer models and firmware append extra status bytes after
+// these, keeping the base layout intact.
+const BaseStatusLength = 20
+
+// ExtendedStatusLength is the length of the status frame sent by firmware
+// with light control and a flow-rate window, which reports both after the
+// base layout.
+const ExtendedStatusLength = 22
+
+// Model identifies which BOOKOO scale sent a status frame.
+type Model uint8
+
+const (
+	ModelUnknown Model = iota
+	ModelThemis
+	ModelThemisMini
+	ModelThemisUltra
+)
+
+func (m Model) String() string {
+	switch m {
+	case ModelThemis:
+		return "Themis"
+	case ModelThemisMini:
+		return "Themis Mini"
+	case ModelThemisUltra:
+		return "Themis Ultra"
+	default:
+		return fmt.Sprintf("Unknown Model (%d)", m)
+	}
+}
+
+// Firmware is the generation of firmware a status frame came from, told by
+// its length.
+type Firmware uint8
+
+const (
+	// FirmwareUnknown sent a frame longer than the base layout, but too
+	// short for the extended one; only the base layout is decoded.
+	FirmwareUnknown Firmware = iota
+
+	// FirmwareBase sends the original 20-byte status.
+	FirmwareBase
+
+	// FirmwareExtended adds the light and the flow-rate window to the
+	// status, and takes commands to set them.
+	FirmwareExtended
+)
+
+func (f Firmware) String() string {
+	switch f {
+	case FirmwareBase:
+		return "base"
+	case FirmwareExtended:
+		return "extended"
+	default:
+		return "unknown"
+	}
+}
+
+// Variant describes the hardware and firmware generation of a status frame.
+type Variant struct {
+	Model Model
+	// FrameType is the raw type byte the model was detected from.
+	FrameType uint8
+	Firmware  Firmware
+	// Extended is true when the frame carries status bytes beyond the
+	// original 20-byte layout.
+	Extended bool
+}
+
+// SupportsLight reports whether the scale takes BuildLightCommand.
+func (v Variant) SupportsLight() bool {
+	return v.Firmware == FirmwareExtended
+}
+
+// SupportsFlowRateWindow reports whether the scale takes
+// BuildFlowRateWindowCommand.
+func (v Variant) SupportsFlowRateWindow() bool {
+	return v.Firmware == FirmwareExtended
+}
+
+// statusModels maps the frame type byte of a scale status frame to the model
+// known to send it. Frames of any other type aren't status frames, or are
+// from a model whose layout isn't known, and aren't decoded.
+var statusModels = map[uint8]Model{
+	FrameTypeWeight:      ModelThemis,
+	FrameTypeWeightMini:  ModelThemisMini,
+	FrameTypeWeightUltra: ModelThemisUltra,
+}
+
+// DetectVariant inspects the header and length of a raw notification. It
+// returns false if the frame isn't a status frame of a known model, so
+// callers don't feed command echoes, short frames or frames of an unknown
+// type to the status decoder.
+func DetectVariant(data []byte) (Variant, bool) {
+	if len(data) < BaseStatusLength || data[0] != ProductScale {
+		return Variant{}, false
+	}
+	model, ok := statusModels[data[1]]
+	if !ok {
+		return Variant{}, false
+	}
+
+	v := Variant{
+		Model:     model,
+		FrameType: data[1],
+		Extended:  len(data) > BaseStatusLength,
+	}
+	switch {
+	case len(data) == BaseStatusLength:
+		v.Firmware = FirmwareBase
+	case len(data) >= ExtendedStatusLength:
+		v.Firmware = FirmwareExtended
+	}
+	return v, true
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/internal/connsup"
 	"github.com/mlsorensen/goscale/pkg/scales/themis/comms"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 	"tinygo.org/x/bluetooth"
 )
@@ -16,31 +19,48 @@ func init() {
 }
 
 type ThemisScale struct {
-	name           string
-	address        bluetooth.Address
-	disconnectCtx  context.Context
-	disconnectFunc context.CancelFunc
-	connected      bool
-
-	btDevice   bluetooth.Device
-	writeChar  bluetooth.DeviceCharacteristic
-	notifyChar bluetooth.DeviceCharacteristic
-
-	weightUpdateChan chan goscale.WeightUpdate
-	lastNotified     time.Time
-
-	status *comms.StatusUpdate
+	name    string
+	address bluetooth.Address
+	sup     atomic.Pointer[connsup.Supervisor]
+
+	btDevice   goscale.Peripheral
+	writeChar  goscale.Characteristic
+	notifyChar goscale.Characteristic
+
+	updates goscale.UpdateStream
+	seq     uint64
+	battery goscale.BatteryStream
+
+	// mu guards the fields below, which the notification handler updates
+	// while callers read them.
+	mu        sync.Mutex
+	connected bool
+	status    *comms.StatusUpdate
+	model     comms.Model
+
+	limiter atomic.Pointer[goscale.CommandLimiter]
 }
 
 // This line is the compile-time check. It will fail to compile if
 // *ThemisScale ever stops satisfying the goscale.Scale interface.
 var _ goscale.Scale = (*ThemisScale)(nil)
+var _ goscale.ContextConnector = (*ThemisScale)(nil)
+var _ goscale.CommandLimited = (*ThemisScale)(nil)
+var _ goscale.OverflowConfigurable = (*ThemisScale)(nil)
+var _ goscale.SmoothingConfigurable = (*ThemisScale)(nil)
+var _ goscale.TareOffsetter = (*ThemisScale)(nil)
+var _ goscale.FlowRater = (*ThemisScale)(nil)
+var _ goscale.SleepTimeoutSetter = (*ThemisScale)(nil)
+var _ goscale.Configurable = (*ThemisScale)(nil)
+var _ goscale.DisconnectReasoner = (*ThemisScale)(nil)
+var _ goscale.BatteryNotifier = (*ThemisScale)(nil)
 
 var features = goscale.ScaleFeatures{
 	Tare:           true,
 	SleepTimeout:   true,
 	Beep:           true,
 	BatteryPercent: true,
+	FlowRate:       true,
 }
 
 func New(device *goscale.FoundDevice) goscale.Scale {
@@ -50,84 +70,113 @@ func New(device *goscale.FoundDevice) goscale.Scale {
 	}
 }
 
+// SetCommandLimiter rate limits the commands sent to the scale. Nil, the
+// default, turns limiting off.
+func (t *ThemisScale) SetCommandLimiter(limiter *goscale.CommandLimiter) {
+	t.limiter.Store(limiter)
+}
+
+// SetOverflowPolicy sets what happens to weight updates when the consumer
+// falls behind. The default, goscale.OverflowBlock, holds up notifications
+// until it catches up.
+func (t *ThemisScale) SetOverflowPolicy(p goscale.OverflowPolicy) {
+	t.updates.SetPolicy(p)
+}
+
+// SetSmoothing sets the filter weight updates are run through. The
+// default, goscale.SmoothingRaw, passes them on as the scale sent them.
+func (t *ThemisScale) SetSmoothing(cfg goscale.SmoothingConfig) {
+	t.updates.SetSmoothing(cfg)
+}
+
+// SetTareOffset sets the grams subtracted from weight updates, such as a
+// container's. See goscale.TareToOffset.
+func (t *ThemisScale) SetTareOffset(grams float64) {
+	t.updates.SetTareOffset(grams)
+}
+
+// TareOffset returns the grams subtracted from weight updates.
+func (t *ThemisScale) TareOffset() float64 {
+	return t.updates.TareOffset()
+}
+
+// DroppedUpdates returns how many weight updates have been dropped under the
+// overflow policy since the scale connected.
+func (t *ThemisScale) DroppedUpdates() uint64 {
+	return t.updates.Dropped()
+}
+
 func (t *ThemisScale) GetFeatures() goscale.ScaleFeatures {
 	return features
 }
 
 func (t *ThemisScale) Connect() (<-chan goscale.WeightUpdate, error) {
+	return t.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect, giving up and disconnecting if ctx is done
+// before the scale is connected and streaming.
+func (t *ThemisScale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
 	err := goscale.TryEnableAdapter()
 	if err != nil {
 		return nil, err
 	}
 
-	t.weightUpdateChan = make(chan goscale.WeightUpdate, 20)
-
-	t.disconnectCtx, t.disconnectFunc = context.WithCancel(context.Background())
+	updates := t.updates.Open(20)
+	t.seq = 0
+	t.battery.Open()
 
-	t.btDevice, err = goscale.BTAdapter.Connect(t.address, bluetooth.ConnectionParams{})
+	t.btDevice, err = connsup.Dial(ctx, t.address)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = t.setupCharacteristics()
+	err = connsup.Await(ctx, t.setupCharacteristics)
 	if err != nil {
-		_ = t.Disconnect()
+		_ = t.btDevice.Disconnect()
 		return nil, err
 	}
 
 	log.Println("setting up notifications")
-	err = t.setupNotifications()
+	err = connsup.Await(ctx, t.setupNotifications)
 	if err != nil {
-		_ = t.Disconnect()
+		_ = t.btDevice.Disconnect()
 		return nil, err
 	}
-	t.lastNotified = time.Now()
 
+	t.mu.Lock()
 	t.connected = true
-
-	// Fast disconnect detection via the BLE link's HCI Disconnection
-	// Complete event. The handler cancels our context; the watchdog
-	// goroutine below picks it up and runs Disconnect off the bluetooth
-	// event thread.
-	goscale.BTAdapter.SetConnectHandler(func(d bluetooth.Device, connected bool) {
-		if !connected && t.disconnectFunc != nil {
-			t.disconnectFunc()
-		}
-	})
-
-	// Watchdog: react to context cancel (external Disconnect or HCI
-	// disconnect event) or to a longer no-notifications fallback.
-	go func() {
-		const idleLimit = 30 * time.Second
-		ticker := time.NewTicker(time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-t.disconnectCtx.Done():
-				_ = t.Disconnect()
-				return
-			case <-ticker.C:
-				if time.Now().After(t.lastNotified.Add(idleLimit)) {
-					_ = t.Disconnect()
-					return
-				}
+	t.mu.Unlock()
+
+	// Disconnect on the HCI disconnect event, or after a long stretch of
+	// silence in case that never comes.
+	t.sup.Store(connsup.Start(connsup.Config{
+		Silence: 30 * time.Second,
+		Address: t.address,
+		Status: func() (goscale.SleepTimeout, float64, bool) {
+			timeout, err := t.SleepTimeoutSetting()
+			if err != nil {
+				return 0, 0, false
 			}
-		}
-	}()
+			return timeout, float64(t.lastStatus().PowerPercentage), true
+		},
+		Disconnect: func() { _ = t.Disconnect() },
+	}))
 
-	return t.weightUpdateChan, nil
+	return updates, nil
 }
 
 func (t *ThemisScale) Disconnect() error {
-	// Idempotent: the connectivity-monitor goroutine can race itself
-	// (timeout check → Disconnect → ctx.Done case → Disconnect) and also
-	// races the external scale.Driver disconnect path. Closing
-	// weightUpdateChan twice panics.
+	// Idempotent: the supervisor giving up on the link races the external
+	// scale.Driver disconnect path. Closing weightUpdateChan twice panics.
+	t.mu.Lock()
 	if !t.connected {
+		t.mu.Unlock()
 		return nil
 	}
 	t.connected = false
+	t.mu.Unlock()
 
 	err := t.btDevice.Disconnect()
 	if err != nil {
@@ -135,118 +184,315 @@ func (t *ThemisScale) Disconnect() error {
 		// teardown as authoritative — we won't be sending on the channel
 		// any more from this side.
 	}
-	if t.weightUpdateChan != nil {
-		close(t.weightUpdateChan)
-		t.weightUpdateChan = nil
-	}
-	if t.disconnectFunc != nil {
-		t.disconnectFunc()
+	t.updates.Close()
+	t.battery.Close()
+	if sup := t.sup.Load(); sup != nil {
+		sup.Stop()
 	}
 	return err
 }
 
+// DisconnectReason returns why the last connection ended. A dropped link
+// is put down to the scale switching itself off when its last status
+// suggests it did: its battery was flat, or it had been idle for its sleep
+// timeout.
+func (t *ThemisScale) DisconnectReason() goscale.DisconnectReason {
+	sup := t.sup.Load()
+	if sup == nil {
+		return goscale.DisconnectUnknown
+	}
+	return sup.Reason()
+}
+
 func (t *ThemisScale) IsConnected() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	return t.connected
 }
 
+// lastStatus returns the last status received, or nil if there has been
+// none. Statuses are never modified once received.
+func (t *ThemisScale) lastStatus() *comms.StatusUpdate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
 func (t *ThemisScale) DeviceName() string {
 	return t.name
 }
 
 func (t *ThemisScale) DisplayName() string {
-	return "BOOKOO Themis scale"
+	// The model is only known once the first status frame arrives.
+	t.mu.Lock()
+	model := t.model
+	t.mu.Unlock()
+	if model == comms.ModelUnknown {
+		return "BOOKOO Themis scale"
+	}
+	return fmt.Sprintf("BOOKOO %s scale", model)
 }
 
 func (t *ThemisScale) Tare(blocking bool) error {
-	_, err := t.writeChar.Write(comms.ThemisTareCommand)
-	return err
+	return t.limiter.Load().Do(goscale.CommandTare, func() error {
+		_, err := t.writeChar.Write(comms.BuildTareCommand())
+		return err
+	})
 }
 
 func (t *ThemisScale) AdvanceSleepTimeout() error {
-	timeout := comms.AutoOffSettings.NextWithInt(t.status.StandbyTime)
+	status := t.lastStatus()
+	if status == nil {
+		return fmt.Errorf("no status received yet")
+	}
+	return t.writeAutoOff(comms.NextAutoOff(status.StandbyTime))
+}
+
+// SetStandbyMinutes sets the standby time directly, rather than stepping
+// through the settings. m must be one of comms.AutoOffSettings.
+func (t *ThemisScale) SetStandbyMinutes(m uint16) error {
+	if m > 0xff || !comms.AutoOffSettings.Contains(comms.AutoOffSetting(m)) {
+		return fmt.Errorf("unsupported standby time %d minutes, want one of %v", m, comms.AutoOffSettings.Values())
+	}
+	return t.writeAutoOff(comms.AutoOffSetting(m))
+}
+
+// SleepTimeoutSetting returns the standby time, as of the last status.
+func (t *ThemisScale) SleepTimeoutSetting() (goscale.SleepTimeout, error) {
+	status := t.lastStatus()
+	if status == nil {
+		return 0, fmt.Errorf("no status received yet")
+	}
+	m := status.StandbyTime
+	timeout, ok := comms.SleepTimeouts.Timeout(comms.AutoOffSetting(m))
+	if m > 0xff || !ok {
+		return 0, fmt.Errorf("unknown standby time %d minutes", m)
+	}
+	return timeout, nil
+}
+
+// SetSleepTimeout sets the standby time. The Themis can't disable it.
+func (t *ThemisScale) SetSleepTimeout(timeout goscale.SleepTimeout) error {
+	m, ok := comms.SleepTimeouts.Native(timeout)
+	if !ok {
+		return &goscale.UnsupportedSleepTimeoutError{Timeout: timeout, Supported: t.SleepTimeouts()}
+	}
+	return t.writeAutoOff(m)
+}
+
+// SleepTimeouts returns the standby times the Themis supports.
+func (t *ThemisScale) SleepTimeouts() []goscale.SleepTimeout {
+	return comms.SleepTimeouts.Timeouts()
+}
+
+func (t *ThemisScale) writeAutoOff(timeout comms.AutoOffSetting) error {
 	cmd := comms.BuildAutoOffCommand(timeout)
 	fmt.Printf("sleep timer cmd: % x\n", cmd)
-	_, err := t.writeChar.Write(cmd)
+	err := t.limiter.Load().Do(goscale.CommandSleepTimeout, func() error {
+		_, err := t.writeChar.Write(cmd)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("error while writing new sleep timeout: %v", err)
+		return fmt.Errorf("error while writing new sleep timeout: %w", err)
 	}
 	return nil
 }
 
 func (t *ThemisScale) GetSleepTimeout() string {
-	return fmt.Sprintf("%d Minutes", t.status.StandbyTime)
+	status := t.lastStatus()
+	if status == nil {
+		return "Unknown Setting"
+	}
+	m := status.StandbyTime
+	if m > 0xff || !comms.AutoOffSettings.Contains(comms.AutoOffSetting(m)) {
+		return fmt.Sprintf("Unknown Setting (%d)", m)
+	}
+	return fmt.Sprintf("%d Minutes", m)
+}
+
+// BatteryUpdates returns the battery readings from the scale's status
+// reports; see goscale.BatteryNotifier.
+func (t *ThemisScale) BatteryUpdates() <-chan goscale.BatteryUpdate {
+	return t.battery.Updates()
 }
 
 func (t *ThemisScale) GetBatteryChargePercent() (float64, error) {
-	return float64(t.status.PowerPercentage), nil
+	status := t.lastStatus()
+	if status == nil {
+		return 0, errors.New("no status received yet")
+	}
+	return float64(status.PowerPercentage), nil
+}
+
+// FlowRate returns the flow rate from the latest status frame, in grams per
+// second.
+func (t *ThemisScale) FlowRate() (float64, error) {
+	status := t.lastStatus()
+	if status == nil {
+		return 0, errors.New("no status received yet")
+	}
+	return flowRate(status), nil
+}
+
+// flowRate returns the status's flow rate, signed.
+func flowRate(status *comms.StatusUpdate) float64 {
+	if status.FlowRateSymbol == '-' {
+		return -status.FlowRate
+	}
+	return status.FlowRate
 }
 
 func (t *ThemisScale) SetBeep(b bool) error {
 	cmd := comms.BuildChangeBeepCommand(b)
 	fmt.Printf("beep cmd: % x\n", cmd)
-	_, err := t.writeChar.Write(cmd)
+	err := t.limiter.Load().Do(goscale.CommandBeep, func() error {
+		_, err := t.writeChar.Write(cmd)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("error while writing new beep setting: %v", err)
+		return fmt.Errorf("error while writing new beep setting: %w", err)
 	}
 
 	return nil
 }
 
-func (t *ThemisScale) GetBeep() bool {
-	return t.status.BuzzerGear > 0
+// SetLight turns the display light on or off. Only extended firmware has
+// one; the scale must have sent a status first, so its firmware is known.
+func (t *ThemisScale) SetLight(on bool) error {
+	if status := t.lastStatus(); status == nil || !status.Variant.SupportsLight() {
+		return fmt.Errorf("light: %w", goscale.ErrNotSupported)
+	}
+	return t.limiter.Load().Do(goscale.CommandLight, func() error {
+		_, err := t.writeChar.Write(comms.BuildLightCommand(on))
+		return err
+	})
 }
 
-func (t *ThemisScale) setupCharacteristics() error {
-	log.Println("Discovering services...")
-	services, err := t.btDevice.DiscoverServices([]bluetooth.UUID{comms.ThemisServiceUUID})
-	if err != nil {
-		return fmt.Errorf("could not discover services: %w", err)
+// SetFlowRateWindow sets the window the scale averages its flow rate over,
+// to the nearest tenth of a second, up to 25.5 s. Only extended firmware
+// takes it; the scale must have sent a status first, so its firmware is
+// known.
+func (t *ThemisScale) SetFlowRateWindow(window time.Duration) error {
+	if status := t.lastStatus(); status == nil || !status.Variant.SupportsFlowRateWindow() {
+		return fmt.Errorf("flow rate window: %w", goscale.ErrNotSupported)
 	}
-
-	if len(services) == 0 {
-		return errors.New("could not find the Lunar BT service")
+	tenths := window.Round(100*time.Millisecond) / (100 * time.Millisecond)
+	if tenths < 1 || tenths > 0xff {
+		return fmt.Errorf("flow rate window %s out of range", window)
 	}
+	return t.limiter.Load().Do(goscale.CommandFlowWindow, func() error {
+		_, err := t.writeChar.Write(comms.BuildFlowRateWindowCommand(uint8(tenths)))
+		return err
+	})
+}
 
-	for _, service := range services {
-		log.Printf("found service %v, scanning for write char", service)
-		chars, err := service.DiscoverCharacteristics([]bluetooth.UUID{
-			comms.ThemisCommandCharUUID,
-			comms.ThemisNotifyCharUUID,
-		})
+func (t *ThemisScale) GetBeep() bool {
+	status := t.lastStatus()
+	return status != nil && status.BuzzerGear > 0
+}
 
-		if err != nil || len(chars) != 2 {
-			return fmt.Errorf("could not discover characteristics: %w", err)
+// Settings returns the scale's settings, as of the last status.
+func (t *ThemisScale) Settings() (map[goscale.SettingKey]any, error) {
+	status := t.lastStatus()
+	if status == nil {
+		return nil, fmt.Errorf("no status received yet")
+	}
+	settings := map[goscale.SettingKey]any{
+		goscale.SettingBeep: status.BuzzerGear > 0,
+	}
+	if status.StandbyTime <= 0xff {
+		if timeout, ok := comms.SleepTimeouts.Timeout(comms.AutoOffSetting(status.StandbyTime)); ok {
+			settings[goscale.SettingAutoOff] = timeout
 		}
+	}
+	return settings, nil
+}
 
-		for _, char := range chars {
-			if char.UUID() == comms.ThemisCommandCharUUID {
-				t.writeChar = char
-			}
-			if char.UUID() == comms.ThemisNotifyCharUUID {
-				t.notifyChar = char
-			}
+// SetSetting changes one of the scale's settings.
+func (t *ThemisScale) SetSetting(key goscale.SettingKey, value any) error {
+	switch key {
+	case goscale.SettingBeep:
+		beep, err := goscale.SettingValue[bool](key, value)
+		if err != nil {
+			return err
+		}
+		return t.SetBeep(beep)
+	case goscale.SettingAutoOff:
+		timeout, err := goscale.SettingValue[goscale.SleepTimeout](key, value)
+		if err != nil {
+			return err
 		}
+		return t.SetSleepTimeout(timeout)
+	default:
+		return fmt.Errorf("setting %s: %w", key, goscale.ErrNotSupported)
 	}
+}
+
+func (t *ThemisScale) setupCharacteristics() error {
+	log.Println("Discovering services...")
+	chars, err := goscale.DiscoverChars(t.btDevice, comms.ThemisServiceUUID, comms.ThemisCommandCharUUID, comms.ThemisNotifyCharUUID)
+	if err != nil {
+		return err
+	}
+	t.writeChar, t.notifyChar = chars[0], chars[1]
 
 	log.Println("Successfully set up characteristics.")
 	return nil
 }
 
 func (t *ThemisScale) handleNotification(buf []byte) {
-	t.lastNotified = time.Now()
+	// Stamp the reading on arrival, before decoding or any channel send can
+	// delay it.
+	now := time.Now()
+	if sup := t.sup.Load(); sup != nil {
+		sup.Notified(now)
+	}
 	status, ok := comms.DecodeStatusUpdate(buf)
-	t.status = status
 	if !ok {
-		log.Printf("unable to decode raw data from notification")
+		if len(buf) >= 2 && buf[0] == comms.ProductScale && buf[1] != comms.FrameTypeCommand {
+			log.Printf("ignoring BOOKOO frame of unknown type 0x%02X: % X", buf[1], buf)
+			return
+		}
+		log.Printf("unable to decode raw data from notification: % X", buf)
+		return
+	}
+	t.mu.Lock()
+	if t.status == nil || t.status.Variant != status.Variant {
+		log.Printf("BOOKOO variant: model %s, frame type 0x%02X, %s firmware", status.Variant.Model, status.Variant.FrameType, status.Variant.Firmware)
 	}
-	t.weightUpdateChan <- goscale.WeightUpdate{Value: status.GramsWeight}
+	t.model = status.Variant.Model
+	t.status = status
+	t.mu.Unlock()
+	t.battery.Report(now, float64(status.PowerPercentage))
+	if sup := t.sup.Load(); sup != nil {
+		sup.Weighed(now, status.GramsWeight)
+	}
+	t.seq++
+	t.updates.Send(goscale.WeightUpdate{
+		Value:         status.GramsWeight,
+		Seq:           t.seq,
+		Timestamp:     now,
+		ScaleTimer:    time.Duration(status.Milliseconds) * time.Millisecond,
+		HasScaleTimer: true,
+		FlowRate:      flowRate(status),
+		HasFlowRate:   true,
+		Raw:           int64(status.GramsRaw),
+		RawDivisor:    comms.WeightDivisor,
+		HasRaw:        true,
+	})
 }
 
 func (t *ThemisScale) setupNotifications() error {
-	err := t.notifyChar.EnableNotifications(t.handleNotification)
+	err := t.notifyChar.EnableNotifications(goscale.RecoverNotifications(t.name, t.handleNotification, t.reportError))
 	if err != nil {
 		return fmt.Errorf("failed to enable notifications: %w", err)
 	}
 
 	return nil
 }
+
+// reportError sends err on the update channel, if there is room. It must not
+// block, as it runs on the notification goroutine.
+func (t *ThemisScale) reportError(err error) {
+	t.updates.TrySend(goscale.WeightUpdate{Error: err})
+}
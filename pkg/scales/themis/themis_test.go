@@ -0,0 +1,160 @@
+package themis_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/bttest"
+	"github.com/mlsorensen/goscale/pkg/scales/themis/comms"
+)
+
+// status is a Themis status frame of 18.30 g with the given standby bytes,
+// which hold tenths of a minute.
+func status(b0, b1 byte) []byte {
+	return []byte{
+		0x03, 0x0B, 0x00, 0x2E, 0xE0, 0x00, 0x2B, 0x00, 0x07, 0x26,
+		0x2B, 0x00, 0x78, 0x50, b0, b1, 0x01, 0x01, 0x00, 0x00,
+	}
+}
+
+// connect connects to a fake Themis, and returns it with its command and
+// notification characteristics.
+func connect(t *testing.T) (goscale.Scale, <-chan goscale.WeightUpdate, *bttest.Characteristic, *bttest.Characteristic) {
+	t.Helper()
+	a := bttest.NewAdapter()
+	a.AdvertisingInterval = 10 * time.Millisecond
+	t.Cleanup(a.Install())
+
+	dev := a.AddDevice("BOOKOO_SC 123456", "C8:3A:35:00:00:03")
+	dev.Advertise(comms.ThemisServiceUUID)
+	cmd := dev.AddCharacteristic(comms.ThemisServiceUUID, comms.ThemisCommandCharUUID)
+	notify := dev.AddCharacteristic(comms.ThemisServiceUUID, comms.ThemisNotifyCharUUID)
+
+	found, err := goscale.ScanForOne(time.Second)
+	if err != nil {
+		t.Fatalf("ScanForOne: %v", err)
+	}
+	s, err := goscale.NewScaleForDevice(found)
+	if err != nil {
+		t.Fatalf("NewScaleForDevice: %v", err)
+	}
+	updates, err := s.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Disconnect() })
+	return s, updates, cmd, notify
+}
+
+func TestStandbyTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		b0, b1  byte
+		want    string
+		timeout goscale.SleepTimeout // Zero if unknown
+	}{
+		{"5 minutes", 0x00, 0x32, "5 Minutes", goscale.SleepTimeout(5 * time.Minute)},
+		{"15 minutes", 0x00, 0x96, "15 Minutes", goscale.SleepTimeout(15 * time.Minute)},
+		{"30 minutes", 0x01, 0x2C, "30 Minutes", goscale.SleepTimeout(30 * time.Minute)},
+		{"unknown", 0x00, 0x46, "Unknown Setting (7)", 0},
+		{"too long for a setting", 0x0A, 0x32, "Unknown Setting (261)", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, updates, _, notify := connect(t)
+			notify.Notify(status(tt.b0, tt.b1))
+			select {
+			case <-updates:
+			case <-time.After(time.Second):
+				t.Fatal("status not received")
+			}
+
+			if got := s.GetSleepTimeout(); got != tt.want {
+				t.Errorf("GetSleepTimeout() = %q, want %q", got, tt.want)
+			}
+			timeout, err := goscale.SleepTimeoutSetting(s)
+			if tt.timeout == 0 {
+				if err == nil {
+					t.Errorf("SleepTimeoutSetting() = %s, want an error", timeout)
+				}
+			} else if err != nil || timeout != tt.timeout {
+				t.Errorf("SleepTimeoutSetting() = %s, %v, want %s", timeout, err, tt.timeout)
+			}
+		})
+	}
+}
+
+func TestSetStandbyMinutes(t *testing.T) {
+	s, _, cmd, _ := connect(t)
+	ss := s.(interface{ SetStandbyMinutes(m uint16) error })
+
+	for _, m := range []uint16{0, 7, 60, 261} {
+		if err := ss.SetStandbyMinutes(m); err == nil {
+			t.Errorf("SetStandbyMinutes(%d) succeeded", m)
+		}
+	}
+	if writes := cmd.Writes(); len(writes) != 0 {
+		t.Fatalf("refused standby times wrote % X", writes)
+	}
+
+	if err := ss.SetStandbyMinutes(20); err != nil {
+		t.Fatalf("SetStandbyMinutes(20): %v", err)
+	}
+	writes := cmd.Writes()
+	if len(writes) != 1 || !bytes.Equal(writes[0], comms.BuildAutoOffCommand(comms.AutoOff20Min)) {
+		t.Errorf("SetStandbyMinutes(20) wrote % X", writes)
+	}
+}
+
+// extendedStatus is status(0x00, 0x64) from extended firmware, with the light
+// on and a one second flow-rate window.
+func extendedStatus() []byte {
+	return append(status(0x00, 0x64), 0x01, 0x0A)
+}
+
+func TestSetLight(t *testing.T) {
+	s, updates, cmd, notify := connect(t)
+	ls := s.(interface{ SetLight(on bool) error })
+
+	// Base firmware has no light to set.
+	notify.Notify(status(0x00, 0x64))
+	<-updates
+	if err := ls.SetLight(true); !errors.Is(err, goscale.ErrNotSupported) {
+		t.Errorf("SetLight on base firmware = %v, want ErrNotSupported", err)
+	}
+
+	notify.Notify(extendedStatus())
+	<-updates
+	if err := ls.SetLight(true); err != nil {
+		t.Fatalf("SetLight: %v", err)
+	}
+	writes := cmd.Writes()
+	if len(writes) != 1 || !bytes.Equal(writes[0], comms.BuildLightCommand(true)) {
+		t.Errorf("SetLight(true) wrote % X", writes)
+	}
+}
+
+func TestSetFlowRateWindow(t *testing.T) {
+	s, updates, cmd, notify := connect(t)
+	fs := s.(interface {
+		SetFlowRateWindow(window time.Duration) error
+	})
+	notify.Notify(extendedStatus())
+	<-updates
+
+	for _, w := range []time.Duration{0, 30 * time.Second} {
+		if err := fs.SetFlowRateWindow(w); err == nil {
+			t.Errorf("SetFlowRateWindow(%s) succeeded", w)
+		}
+	}
+	if err := fs.SetFlowRateWindow(1500 * time.Millisecond); err != nil {
+		t.Fatalf("SetFlowRateWindow: %v", err)
+	}
+	writes := cmd.Writes()
+	if len(writes) != 1 || !bytes.Equal(writes[0], comms.BuildFlowRateWindowCommand(15)) {
+		t.Errorf("SetFlowRateWindow(1.5s) wrote % X", writes)
+	}
+}
@@ -0,0 +1,51 @@
+// Code generated by cmdgen from commands.yaml; DO NOT EDIT.
+
+package comms
+
+func BuildIdentifyCommand() []byte {
+	return encodeFrame(0x0B, []byte{0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x30, 0x31, 0x32, 0x33, 0x34})
+}
+
+func BuildNotificationRequestCommand() []byte {
+	return encodeFrame(0x0C, []byte{0x09, 0x00, 0x01, 0x01, 0x02, 0x02, 0x05, 0x03, 0x04})
+}
+
+func BuildTareCommand() []byte {
+	return encodeFrame(0x04, []byte{0x00})
+}
+
+func BuildGetStatusCommand() []byte {
+	return encodeFrame(0x06, []byte{0x00})
+}
+
+func BuildAutoOffCommand(setting AutoOffSetting) []byte {
+	return encodeFrame(0x0A, []byte{0x00, settingIDUmbraSleep, byte(setting)})
+}
+
+func BuildSetBeepCommand(beep bool) []byte {
+	return encodeFrame(0x0A, []byte{0x00, settingIDUmbraBeep, boolByte(beep, 0x01)})
+}
+
+// encodeFrame frames a command: the header, op code and payload, then the
+// checksum.
+func encodeFrame(op byte, payload []byte) []byte {
+	msg := append([]byte{HeaderPrefix1, HeaderPrefix2}, op)
+	msg = append(msg, payload...)
+	var csum1, csum2 byte
+	for i, c := range msg[3:] {
+		if i%2 == 0 {
+			csum1 += c
+		} else {
+			csum2 += c
+		}
+	}
+	return append(msg, csum1, csum2)
+}
+
+// boolByte returns on if v is true, and zero otherwise.
+func boolByte(v bool, on byte) byte {
+	if v {
+		return on
+	}
+	return 0
+}
@@ -83,41 +83,43 @@ func decodeWeight(payload []byte) (WeightMessage, error) {
 	}
 
 	unit := payload[4]
-	var divisor float64
+	var divisor int64
 	switch unit {
 	case 1:
-		divisor = 10.0
+		divisor = 10
 	case 2:
-		divisor = 100.0
+		divisor = 100
 	case 3:
-		divisor = 1000.0
+		divisor = 1000
 	case 4:
-		divisor = 10000.0
+		divisor = 10000
 	default:
-		divisor = 10.0
+		divisor = 10
 	}
 
 	isStable := (payload[5] & 0x01) == 0
-	sign := 1.0
+	var sign int64 = 1
 	if (payload[5] & 0x02) != 0 {
-		sign = -1.0
+		sign = -1
 	}
 	weightType := WeightType(payload[5] >> 2)
 
-	rawBE := binary.BigEndian.Uint32(payload[0:4])
-	weight := sign * (float64(rawBE) / divisor)
+	raw := sign * int64(binary.BigEndian.Uint32(payload[0:4]))
+	weight := float64(raw) / float64(divisor)
 
 	// Sanity check — fall back to little-endian if BE produced an absurd value.
 	// 2 kg covers the largest Acaia capacity setting with headroom.
 	if weight < -2000 || weight > 2000 {
-		rawLE := binary.LittleEndian.Uint32(payload[0:4])
-		weight = sign * (float64(rawLE) / divisor)
+		raw = sign * int64(binary.LittleEndian.Uint32(payload[0:4]))
+		weight = float64(raw) / float64(divisor)
 	}
 
 	return WeightMessage{
 		Weight:   weight,
 		Type:     weightType,
 		IsStable: isStable,
+		Raw:      raw,
+		Divisor:  divisor,
 	}, nil
 }
 
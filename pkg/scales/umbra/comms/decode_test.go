@@ -0,0 +1,13 @@
+package comms
+
+import "testing"
+
+// weightFrame is a stable 18.3 g weight event.
+var weightFrame = []byte{0xEF, 0xDD, 0x0C, 0x08, 0x05, 0x00, 0x00, 0x00, 0xB7, 0x01, 0x00, 0x00, 0x00}
+
+func BenchmarkDecodeNotification(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = DecodeNotification(weightFrame)
+	}
+}
@@ -1,6 +1,11 @@
 package comms
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
 
 // Unit represents the unit of measurement for the scale.
 //
@@ -28,6 +33,15 @@ type UnhandledMessage struct {
 	RawFrame  []byte
 }
 
+// Key identifies the kind of message: the command id, plus the message type
+// for nested event messages.
+func (m UnhandledMessage) Key() string {
+	if m.MsgType != nil {
+		return fmt.Sprintf("cmd=0x%02X msg=%d", m.CommandID, *m.MsgType)
+	}
+	return fmt.Sprintf("cmd=0x%02X", m.CommandID)
+}
+
 func (v FirmwareVersion) String() string {
 	return fmt.Sprintf("%d.%d.%d", v.Main, v.Sub, v.Add)
 }
@@ -79,6 +93,10 @@ type WeightMessage struct {
 	Weight   float64
 	Type     WeightType
 	IsStable bool
+
+	// Raw is the weight as sent, signed; Weight is Raw/Divisor.
+	Raw     int64
+	Divisor int64
 }
 
 func (u Unit) String() string {
@@ -152,6 +170,20 @@ func (s AutoOffSetting) String() string {
 	}
 }
 
+// SleepTimeouts maps the auto-off settings to goscale.SleepTimeouts. Sleep
+// and power off after the same time share a timeout; setting it picks
+// sleep, which is the lower setting.
+var SleepTimeouts = goscale.NewSleepTimeoutMap(map[AutoOffSetting]goscale.SleepTimeout{
+	AutoOffDisabled: goscale.SleepTimeoutDisabled,
+	AutoOffSleep1M:  goscale.SleepTimeout(time.Minute),
+	AutoOffSleep5M:  goscale.SleepTimeout(5 * time.Minute),
+	AutoOffSleep10M: goscale.SleepTimeout(10 * time.Minute),
+	AutoOffSleep30M: goscale.SleepTimeout(30 * time.Minute),
+	AutoOffPower5M:  goscale.SleepTimeout(5 * time.Minute),
+	AutoOffPower10M: goscale.SleepTimeout(10 * time.Minute),
+	AutoOffPower30M: goscale.SleepTimeout(30 * time.Minute),
+})
+
 type SoundSetting uint8
 
 const (
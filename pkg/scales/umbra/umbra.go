@@ -3,66 +3,142 @@
 // The Umbra speaks the same Acaia framing protocol as the Lunar but with
 // different BLE UUIDs and a big-endian byte order on the raw weight value.
 // It also does not require periodic heartbeats to keep the connection alive,
-// so this driver omits the heartbeat goroutine that the Lunar driver runs
-// and instead relies on the natural notification stream plus a connectivity
-// watchdog to detect a dead link.
+// so this driver sends no heartbeat and instead relies on the natural
+// notification stream plus connection supervision to detect a dead link.
 package umbra
 
 import (
+	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/internal/connsup"
+	"github.com/mlsorensen/goscale/pkg/flow"
 	"github.com/mlsorensen/goscale/pkg/scales/umbra/comms"
 	"tinygo.org/x/bluetooth"
 )
 
 func init() {
-	goscale.Register("UMBRA", New)
+	goscale.RegisterWithOptions("UMBRA", New, goscale.DriverOptions{
+		ServiceUUIDs: []bluetooth.UUID{comms.UmbraServiceUUID},
+	})
 }
 
 var _ goscale.Scale = (*UmbraScale)(nil)
+var _ goscale.ContextConnector = (*UmbraScale)(nil)
+var _ goscale.FirmwareVersioner = (*UmbraScale)(nil)
+var _ goscale.CommandLimited = (*UmbraScale)(nil)
+var _ goscale.OverflowConfigurable = (*UmbraScale)(nil)
+var _ goscale.SmoothingConfigurable = (*UmbraScale)(nil)
+var _ goscale.TareOffsetter = (*UmbraScale)(nil)
+var _ goscale.BatteryPoller = (*UmbraScale)(nil)
+var _ goscale.KeepAwaker = (*UmbraScale)(nil)
+var _ goscale.TareRetrier = (*UmbraScale)(nil)
+var _ goscale.SleepTimeoutSetter = (*UmbraScale)(nil)
+var _ goscale.Configurable = (*UmbraScale)(nil)
+var _ goscale.DisconnectReasoner = (*UmbraScale)(nil)
+var _ goscale.BatteryNotifier = (*UmbraScale)(nil)
+
+// KeepAwakeInterval is how often a status update is requested while
+// keep-awake is on. It is well inside the shortest auto-off setting.
+const KeepAwakeInterval = 30 * time.Second
 
 var features = goscale.ScaleFeatures{
 	Tare:           true,
 	BatteryPercent: true,
 	SleepTimeout:   true,
 	Beep:           true,
+	Resolution:     true,
+	StableFlag:     true,
 }
 
 type UmbraScale struct {
-	name           string
-	address        bluetooth.Address
-	disconnectCtx  context.Context
-	disconnectFunc context.CancelFunc
-
-	btDevice   bluetooth.Device
-	writeChar  bluetooth.DeviceCharacteristic
-	notifyChar bluetooth.DeviceCharacteristic
-
-	weightUpdateChan chan goscale.WeightUpdate
-
-	lastNotified time.Time
-	isConnected  bool
-
-	status comms.StatusMessage
+	name    string
+	address bluetooth.Address
+	sup     atomic.Pointer[connsup.Supervisor]
+
+	btDevice   goscale.Peripheral
+	writeChar  goscale.Characteristic
+	notifyChar goscale.Characteristic
+
+	updates goscale.UpdateStream
+	seq     uint64
+	flow    *flow.Meter // Fed grams, so a unit change doesn't skew it
+	battery goscale.BatteryStream
+
+	// batteryPollInterval is a time.Duration; zero means no polling.
+	batteryPollInterval atomic.Int64
+	keepAwake           atomic.Bool
+
+	limiter          atomic.Pointer[goscale.CommandLimiter]
+	unhandledHandler atomic.Pointer[func(comms.UnhandledMessage)]
+
+	// mu guards the fields below, which the notification handler updates
+	// while callers read them.
+	mu          sync.Mutex
+	isConnected bool
+	status      comms.StatusMessage
+	deviceInfo  *comms.DeviceInfoMessage
+
+	tare *goscale.TareVerifier
 }
 
 func New(device *goscale.FoundDevice) goscale.Scale {
 	return &UmbraScale{
 		name:    device.Name,
 		address: device.Address,
+		tare:    goscale.NewTareVerifier(goscale.DefaultTareRetryConfig),
 	}
 }
 
+// SetCommandLimiter rate limits the commands sent to the scale. Nil, the
+// default, turns limiting off.
+func (u *UmbraScale) SetCommandLimiter(limiter *goscale.CommandLimiter) {
+	u.limiter.Store(limiter)
+}
+
+// SetOverflowPolicy sets what happens to weight updates when the consumer
+// falls behind. The default, goscale.OverflowBlock, holds up notifications
+// until it catches up.
+func (u *UmbraScale) SetOverflowPolicy(p goscale.OverflowPolicy) {
+	u.updates.SetPolicy(p)
+}
+
+// SetSmoothing sets the filter weight updates are run through. The
+// default, goscale.SmoothingRaw, passes them on as the scale sent them.
+func (u *UmbraScale) SetSmoothing(cfg goscale.SmoothingConfig) {
+	u.updates.SetSmoothing(cfg)
+}
+
+// SetTareOffset sets the grams subtracted from weight updates, such as a
+// container's. See goscale.TareToOffset.
+func (u *UmbraScale) SetTareOffset(grams float64) {
+	u.updates.SetTareOffset(grams)
+}
+
+// TareOffset returns the grams subtracted from weight updates.
+func (u *UmbraScale) TareOffset() float64 {
+	return u.updates.TareOffset()
+}
+
+// DroppedUpdates returns how many weight updates have been dropped under the
+// overflow policy since the scale connected.
+func (u *UmbraScale) DroppedUpdates() uint64 {
+	return u.updates.Dropped()
+}
+
 func (u *UmbraScale) GetFeatures() goscale.ScaleFeatures {
 	return features
 }
 
 func (u *UmbraScale) IsConnected() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
 	return u.isConnected
 }
 
@@ -75,129 +151,291 @@ func (u *UmbraScale) DisplayName() string {
 }
 
 func (u *UmbraScale) GetSleepTimeout() string {
-	return u.status.SleepTimerSetting.String()
+	return u.lastStatus().SleepTimerSetting.String()
+}
+
+// lastStatus returns the last status received.
+func (u *UmbraScale) lastStatus() comms.StatusMessage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.status
 }
 
 func (u *UmbraScale) Connect() (<-chan goscale.WeightUpdate, error) {
+	return u.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect, giving up and disconnecting if ctx is done
+// before the scale is connected and streaming.
+func (u *UmbraScale) ConnectContext(ctx context.Context) (<-chan goscale.WeightUpdate, error) {
 	if err := goscale.TryEnableAdapter(); err != nil {
 		return nil, err
 	}
 
-	u.weightUpdateChan = make(chan goscale.WeightUpdate, 20)
-	u.disconnectCtx, u.disconnectFunc = context.WithCancel(context.Background())
+	updates := u.updates.Open(20)
+	u.seq = 0
+	u.battery.Open()
+	u.flow = flow.NewMeter(flow.DefaultWindow)
 
 	var err error
-	u.btDevice, err = goscale.BTAdapter.Connect(u.address, bluetooth.ConnectionParams{})
+	u.btDevice, err = connsup.Dial(ctx, u.address)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := u.setupCharacteristics(); err != nil {
-		_ = u.Disconnect()
+	if err := connsup.Await(ctx, u.setupCharacteristics); err != nil {
+		_ = u.btDevice.Disconnect()
 		return nil, err
 	}
 
 	log.Println("setting up notifications")
-	if err := u.setupNotifications(); err != nil {
-		_ = u.Disconnect()
+	if err := connsup.Await(ctx, u.setupNotifications); err != nil {
+		_ = u.btDevice.Disconnect()
 		return nil, err
 	}
 
-	u.lastNotified = time.Now()
+	u.mu.Lock()
 	u.isConnected = true
-
-	// Fast disconnect detection: hook the BLE link's HCI Disconnection
-	// Complete event (fires within ~2s of the scale powering off via the
-	// link supervision timeout). The handler simply cancels our context;
-	// a watchdog goroutine then runs Disconnect off the bluetooth event
-	// thread to avoid recursing back into the bluetooth lib.
-	goscale.BTAdapter.SetConnectHandler(func(d bluetooth.Device, connected bool) {
-		if !connected && u.disconnectFunc != nil {
-			u.disconnectFunc()
-		}
-	})
-
-	// Watchdog: react to either an externally-triggered Disconnect (via
-	// disconnectCtx) or a long stretch of silence (fallback in case the
-	// HCI disconnect event doesn't fire for some reason).
-	go func() {
-		const idleLimit = 30 * time.Second
-		t := time.NewTicker(time.Second)
-		defer t.Stop()
-		for {
-			select {
-			case <-u.disconnectCtx.Done():
-				_ = u.Disconnect()
-				return
-			case <-t.C:
-				if time.Now().After(u.lastNotified.Add(idleLimit)) {
-					log.Println("Umbra: no notifications for", idleLimit, "— disconnecting")
-					_ = u.Disconnect()
-					return
+	u.mu.Unlock()
+
+	// Disconnect on the HCI disconnect event (which fires within ~2s of the
+	// scale powering off, via the link supervision timeout), or after a long
+	// stretch of silence in case that never comes. The heartbeat requests
+	// status updates when battery polling or keep-awake is on, as the Umbra
+	// only reports its battery level in status messages.
+	lastPoll := time.Now()
+	u.sup.Store(connsup.Start(connsup.Config{
+		Heartbeat: func() error {
+			if interval := u.statusInterval(); interval > 0 && time.Since(lastPoll) >= interval {
+				lastPoll = time.Now()
+				if _, err := u.writeChar.WriteWithoutResponse(comms.GetStatusCommand); err != nil {
+					log.Printf("Error requesting status: %v", err)
 				}
 			}
-		}
-	}()
-
-	return u.weightUpdateChan, nil
+			return nil
+		},
+		Silence: 30 * time.Second,
+		Address: u.address,
+		Status: func() (goscale.SleepTimeout, float64, bool) {
+			timeout, err := u.SleepTimeoutSetting()
+			status := u.lastStatus()
+			return timeout, status.Battery, err == nil && status.StatusLength > 0
+		},
+		Disconnect: func() { _ = u.Disconnect() },
+	}))
+
+	return updates, nil
 }
 
 func (u *UmbraScale) Disconnect() error {
-	// Idempotent — multiple producers (watchdog goroutine, ctx.Done case,
-	// external scale.Driver) can race into Disconnect. Closing the update
+	// Idempotent — the supervisor and the external scale.Driver can race
+	// into Disconnect. Closing the update
 	// channel twice panics.
+	u.mu.Lock()
 	if !u.isConnected {
+		u.mu.Unlock()
 		return nil
 	}
 	u.isConnected = false
+	u.mu.Unlock()
 
 	err := u.btDevice.Disconnect()
-	if u.weightUpdateChan != nil {
-		close(u.weightUpdateChan)
-		u.weightUpdateChan = nil
-	}
-	if u.disconnectFunc != nil {
-		u.disconnectFunc()
+	u.updates.Close()
+	u.battery.Close()
+	if sup := u.sup.Load(); sup != nil {
+		sup.Stop()
 	}
 	return err
 }
 
+// DisconnectReason returns why the last connection ended. A dropped link
+// is put down to the scale switching itself off when its last status
+// suggests it did: its battery was flat, or it had been idle for its sleep
+// timeout.
+func (u *UmbraScale) DisconnectReason() goscale.DisconnectReason {
+	sup := u.sup.Load()
+	if sup == nil {
+		return goscale.DisconnectUnknown
+	}
+	return sup.Reason()
+}
+
+// Tare tares the scale, verifying it against the readings that follow and
+// resending it if need be; see SetTareRetry.
 func (u *UmbraScale) Tare(blocking bool) error {
-	_, err := u.writeChar.WriteWithoutResponse(comms.TareCommand)
-	return err
+	return u.limiter.Load().Do(goscale.CommandTare, func() error {
+		return u.tare.Tare(blocking, func() error {
+			_, err := u.writeChar.WriteWithoutResponse(comms.TareCommand)
+			return err
+		})
+	})
+}
+
+// SetTareRetry configures how tares are verified and retried. The default is
+// goscale.DefaultTareRetryConfig.
+func (u *UmbraScale) SetTareRetry(cfg goscale.TareRetryConfig) {
+	u.tare.SetConfig(cfg)
+}
+
+// unit returns the unit weights are reported in, as of the last status.
+func (u *UmbraScale) unit() string {
+	if u.lastStatus().Unit == comms.UnitOunces {
+		return goscale.UnitOunces
+	}
+	return goscale.UnitGrams
 }
 
 func (u *UmbraScale) AdvanceSleepTimeout() error {
 	timeout := comms.AutoOffDisabled
-	if u.status.SleepTimerSetting != comms.AutoOffMaxSetting {
-		timeout = u.status.SleepTimerSetting + 1
+	if setting := u.lastStatus().SleepTimerSetting; setting != comms.AutoOffMaxSetting {
+		timeout = setting + 1
 	}
+	return u.setAutoOff(timeout)
+}
+
+// SleepTimeoutSetting returns the auto-off setting, as of the last status,
+// whether the scale sleeps or powers off when it runs out.
+func (u *UmbraScale) SleepTimeoutSetting() (goscale.SleepTimeout, error) {
+	setting := u.lastStatus().SleepTimerSetting
+	t, ok := comms.SleepTimeouts.Timeout(setting)
+	if !ok {
+		return 0, fmt.Errorf("unknown sleep timer setting %s", setting)
+	}
+	return t, nil
+}
+
+// SetSleepTimeout sets the auto-off setting. Where the Umbra can either
+// sleep or power off after t, it sleeps.
+func (u *UmbraScale) SetSleepTimeout(t goscale.SleepTimeout) error {
+	timeout, ok := comms.SleepTimeouts.Native(t)
+	if !ok {
+		return &goscale.UnsupportedSleepTimeoutError{Timeout: t, Supported: u.SleepTimeouts()}
+	}
+	return u.setAutoOff(timeout)
+}
+
+// SleepTimeouts returns the sleep timeouts the Umbra supports.
+func (u *UmbraScale) SleepTimeouts() []goscale.SleepTimeout {
+	return comms.SleepTimeouts.Timeouts()
+}
 
-	_, err := u.writeChar.WriteWithoutResponse(comms.BuildAutoOffCommand(timeout))
+func (u *UmbraScale) setAutoOff(timeout comms.AutoOffSetting) error {
+	err := u.limiter.Load().Do(goscale.CommandSleepTimeout, func() error {
+		_, err := u.writeChar.WriteWithoutResponse(comms.BuildAutoOffCommand(timeout))
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("error while writing new sleep timeout: %v", err)
+		return fmt.Errorf("error while writing new sleep timeout: %w", err)
 	}
 	return nil
 }
 
 func (u *UmbraScale) SetBeep(beep bool) error {
-	_, err := u.writeChar.WriteWithoutResponse(comms.BuildSetBeepCommand(beep))
+	err := u.limiter.Load().Do(goscale.CommandBeep, func() error {
+		_, err := u.writeChar.WriteWithoutResponse(comms.BuildSetBeepCommand(beep))
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("error while writing new beep setting: %v", err)
+		return fmt.Errorf("error while writing new beep setting: %w", err)
 	}
 	return nil
 }
 
+// FirmwareVersion returns the version from the device info the scale sends
+// after the handshake, falling back to the one in the status message.
+func (u *UmbraScale) FirmwareVersion() (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.deviceInfo != nil {
+		return u.deviceInfo.Firmware.String(), nil
+	}
+	if u.status.Firmware != (comms.FirmwareVersion{}) {
+		return u.status.Firmware.String(), nil
+	}
+	return "", goscale.ErrVersionUnknown
+}
+
 func (u *UmbraScale) GetBeep() bool {
-	return u.status.SoundSetting.Boolean()
+	return u.lastStatus().SoundSetting.Boolean()
+}
+
+// Settings returns the scale's settings, as of the last status. The
+// resolution and unit can only be changed on the scale itself.
+func (u *UmbraScale) Settings() (map[goscale.SettingKey]any, error) {
+	status := u.lastStatus()
+	if status.StatusLength == 0 {
+		return nil, fmt.Errorf("no status received yet")
+	}
+	settings := map[goscale.SettingKey]any{
+		goscale.SettingBeep:       status.SoundSetting.Boolean(),
+		goscale.SettingResolution: goscale.Resolution(status.ResolutionSetting),
+		goscale.SettingUnit:       u.unit(),
+	}
+	if t, ok := comms.SleepTimeouts.Timeout(status.SleepTimerSetting); ok {
+		settings[goscale.SettingAutoOff] = t
+	}
+	return settings, nil
+}
+
+// SetSetting changes one of the scale's settings.
+func (u *UmbraScale) SetSetting(key goscale.SettingKey, value any) error {
+	switch key {
+	case goscale.SettingBeep:
+		beep, err := goscale.SettingValue[bool](key, value)
+		if err != nil {
+			return err
+		}
+		return u.SetBeep(beep)
+	case goscale.SettingAutoOff:
+		t, err := goscale.SettingValue[goscale.SleepTimeout](key, value)
+		if err != nil {
+			return err
+		}
+		return u.SetSleepTimeout(t)
+	case goscale.SettingResolution, goscale.SettingUnit:
+		return fmt.Errorf("setting %s: %w", key, goscale.ErrReadOnlySetting)
+	default:
+		return fmt.Errorf("setting %s: %w", key, goscale.ErrNotSupported)
+	}
+}
+
+// BatteryUpdates returns the battery readings from the scale's status
+// reports; see goscale.BatteryNotifier.
+func (u *UmbraScale) BatteryUpdates() <-chan goscale.BatteryUpdate {
+	return u.battery.Updates()
 }
 
 func (u *UmbraScale) GetBatteryChargePercent() (float64, error) {
-	return u.status.Battery, nil
+	return u.lastStatus().Battery, nil
+}
+
+// SetBatteryPollInterval makes the driver request a status update, which
+// carries the battery level, every d while connected. Zero, the default,
+// turns polling off. Intervals under a second are polled once a second.
+func (u *UmbraScale) SetBatteryPollInterval(d time.Duration) {
+	u.batteryPollInterval.Store(int64(d))
+}
+
+// SetKeepAwake turns keep-awake on or off. While on, the driver requests a
+// status update at least every KeepAwakeInterval, which counts as activity
+// and stops the scale from auto-sleeping. It can be toggled at any time.
+func (u *UmbraScale) SetKeepAwake(on bool) error {
+	u.keepAwake.Store(on)
+	return nil
+}
+
+// statusInterval returns how often the heartbeat should request a status
+// update, or zero for never.
+func (u *UmbraScale) statusInterval() time.Duration {
+	interval := time.Duration(u.batteryPollInterval.Load())
+	if u.keepAwake.Load() && (interval <= 0 || interval > KeepAwakeInterval) {
+		interval = KeepAwakeInterval
+	}
+	return interval
 }
 
 func (u *UmbraScale) setupNotifications() error {
-	if err := u.notifyChar.EnableNotifications(u.handleNotification); err != nil {
+	if err := u.notifyChar.EnableNotifications(goscale.RecoverNotifications(u.name, u.handleNotification, u.reportError)); err != nil {
 		return fmt.Errorf("failed to enable notifications: %w", err)
 	}
 
@@ -217,35 +455,11 @@ func (u *UmbraScale) setupNotifications() error {
 
 func (u *UmbraScale) setupCharacteristics() error {
 	log.Println("Discovering services...")
-	services, err := u.btDevice.DiscoverServices([]bluetooth.UUID{comms.UmbraServiceUUID})
+	chars, err := goscale.DiscoverChars(u.btDevice, comms.UmbraServiceUUID, comms.UmbraCommandCharUUID, comms.UmbraNotifyCharUUID)
 	if err != nil {
-		return fmt.Errorf("could not discover services: %w", err)
-	}
-
-	if len(services) == 0 {
-		return errors.New("could not find the Umbra BT service")
-	}
-
-	for _, service := range services {
-		log.Printf("found service %v, scanning for write char", service)
-		chars, err := service.DiscoverCharacteristics([]bluetooth.UUID{
-			comms.UmbraCommandCharUUID,
-			comms.UmbraNotifyCharUUID,
-		})
-
-		if err != nil || len(chars) != 2 {
-			return fmt.Errorf("could not discover characteristics: %w", err)
-		}
-
-		for _, char := range chars {
-			if char.UUID() == comms.UmbraCommandCharUUID {
-				u.writeChar = char
-			}
-			if char.UUID() == comms.UmbraNotifyCharUUID {
-				u.notifyChar = char
-			}
-		}
+		return err
 	}
+	u.writeChar, u.notifyChar = chars[0], chars[1]
 
 	log.Println("Successfully set up characteristics.")
 	return nil
@@ -253,7 +467,12 @@ func (u *UmbraScale) setupCharacteristics() error {
 
 // handleNotification is the callback for all incoming BLE data.
 func (u *UmbraScale) handleNotification(buf []byte) {
-	u.lastNotified = time.Now()
+	// Stamp the reading on arrival, before decoding or any channel send can
+	// delay it.
+	now := time.Now()
+	if sup := u.sup.Load(); sup != nil {
+		sup.Notified(now)
+	}
 
 	msg, err := comms.DecodeNotification(buf)
 	if err != nil {
@@ -263,21 +482,83 @@ func (u *UmbraScale) handleNotification(buf []byte) {
 
 	switch t := msg.(type) {
 	case comms.WeightMessage:
-		if u.weightUpdateChan != nil {
-			u.weightUpdateChan <- goscale.WeightUpdate{Value: t.Weight}
+		unit := u.unit()
+		grams, _ := goscale.ConvertWeight(t.Weight, unit, goscale.UnitGrams)
+		u.tare.Observe(grams)
+		if sup := u.sup.Load(); sup != nil {
+			sup.Weighed(now, grams)
 		}
+		rate, _ := goscale.ConvertWeight(u.flow.Add(now, grams), goscale.UnitGrams, unit)
+		u.seq++
+		u.updates.Send(goscale.WeightUpdate{
+			Value:       t.Weight,
+			Unit:        unit,
+			Seq:         u.seq,
+			Timestamp:   now,
+			FlowRate:    rate,
+			HasFlowRate: true,
+			Stable:      t.IsStable,
+			HasStable:   true,
+			Raw:         t.Raw,
+			RawDivisor:  t.Divisor,
+			HasRaw:      true,
+		})
 	case comms.StatusMessage:
+		u.mu.Lock()
 		u.status = t
+		u.mu.Unlock()
+		u.battery.Report(now, t.Battery)
 		log.Printf("----> Got settings update: %v", t)
 	case comms.DeviceInfoMessage:
+		u.mu.Lock()
+		u.deviceInfo = &t
+		u.mu.Unlock()
 		log.Printf("---> Got device info: %v", t)
 	case comms.UnhandledMessage:
-		if t.MsgType != nil {
-			log.Printf("--> Unhandled Nested Message. Type: %d. Raw Frame: % X", *t.MsgType, t.RawFrame)
-		} else {
-			log.Printf("--> Unhandled Command. ID: 0x%X. Raw Frame: % X", t.CommandID, t.RawFrame)
-		}
+		u.handleUnhandled(t)
 	default:
 		log.Printf("--> Unknown decoded message type: %T", msg)
 	}
 }
+
+// SetUnhandledFrameHandler registers fn to receive every frame the driver
+// decodes but doesn't otherwise handle, so applications can support newer
+// protocol features before the driver does. fn runs on the notification
+// goroutine and must not block. Set it before calling Connect; pass nil to
+// remove it.
+func (u *UmbraScale) SetUnhandledFrameHandler(fn func(comms.UnhandledMessage)) {
+	if fn == nil {
+		u.unhandledHandler.Store(nil)
+		return
+	}
+	u.unhandledHandler.Store(&fn)
+}
+
+// handleUnhandled passes a message the driver doesn't understand to the
+// user's handler and the frame capture. If neither is set, it is logged.
+func (u *UmbraScale) handleUnhandled(m comms.UnhandledMessage) {
+	handler := u.unhandledHandler.Load()
+	if handler != nil {
+		// The notification buffer may be reused once we return.
+		m.Payload = bytes.Clone(m.Payload)
+		m.RawFrame = bytes.Clone(m.RawFrame)
+		(*handler)(m)
+	}
+
+	captured := goscale.CaptureUnhandledFrame(goscale.UnhandledFrame{
+		Device:  u.name,
+		Address: u.address.String(),
+		Model:   u.DisplayName(),
+		Key:     m.Key(),
+		Frame:   m.RawFrame,
+	})
+	if !captured && handler == nil {
+		log.Printf("--> Unhandled message. %s. Raw Frame: % X", m.Key(), m.RawFrame)
+	}
+}
+
+// reportError sends err on the update channel, if there is room. It must not
+// block, as it runs on the notification goroutine.
+func (u *UmbraScale) reportError(err error) {
+	u.updates.TrySend(goscale.WeightUpdate{Error: err})
+}
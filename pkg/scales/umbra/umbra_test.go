@@ -0,0 +1,113 @@
+package umbra_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+	"github.com/mlsorensen/goscale/pkg/bttest"
+	"github.com/mlsorensen/goscale/pkg/scales/umbra/comms"
+)
+
+// weightFrame is a weight event of grams*10, stable, as the Umbra sends it:
+// like the Lunar's, but big-endian.
+func weightFrame(decigrams uint32) []byte {
+	return []byte{
+		comms.HeaderPrefix1, comms.HeaderPrefix2, 0x0C, 0x08, 0x05,
+		byte(decigrams >> 24), byte(decigrams >> 16), byte(decigrams >> 8), byte(decigrams),
+		0x01, 0x00, // One decimal place; stable, positive
+		0x00, 0x00, // Checksum, unchecked
+	}
+}
+
+// fakeUmbra installs an adapter with one Umbra in range, and returns its
+// command and notification characteristics.
+func fakeUmbra(t *testing.T) (*bttest.Device, *bttest.Characteristic, *bttest.Characteristic) {
+	t.Helper()
+	a := bttest.NewAdapter()
+	a.AdvertisingInterval = 10 * time.Millisecond
+	t.Cleanup(a.Install())
+
+	dev := a.AddDevice("UMBRA-01", "C8:3A:35:00:00:02")
+	dev.Advertise(comms.UmbraServiceUUID)
+	cmd := dev.AddCharacteristic(comms.UmbraServiceUUID, comms.UmbraCommandCharUUID)
+	notify := dev.AddCharacteristic(comms.UmbraServiceUUID, comms.UmbraNotifyCharUUID)
+	return dev, cmd, notify
+}
+
+func connect(t *testing.T) (goscale.Scale, <-chan goscale.WeightUpdate) {
+	t.Helper()
+	found, err := goscale.ScanForOne(time.Second)
+	if err != nil {
+		t.Fatalf("ScanForOne: %v", err)
+	}
+	s, err := goscale.NewScaleForDevice(found)
+	if err != nil {
+		t.Fatalf("NewScaleForDevice: %v", err)
+	}
+	updates, err := s.Connect()
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Disconnect() })
+	return s, updates
+}
+
+func TestConnect(t *testing.T) {
+	dev, cmd, notify := fakeUmbra(t)
+	s, updates := connect(t)
+
+	if !dev.Connected() || !s.IsConnected() {
+		t.Fatal("not connected after Connect")
+	}
+	want := [][]byte{comms.IdentifyCommand, comms.NotificationRequestCommand}
+	if got := cmd.Writes(); len(got) < 2 || !bytes.Equal(got[0], want[0]) || !bytes.Equal(got[1], want[1]) {
+		t.Errorf("handshake wrote % X, want identify then notification request", got)
+	}
+
+	notify.Schedule([]bttest.Frame{
+		{After: 10 * time.Millisecond, Data: weightFrame(183)},
+		{After: 10 * time.Millisecond, Data: weightFrame(364)},
+	})
+	for _, want := range []float64{18.3, 36.4} {
+		select {
+		case u := <-updates:
+			if u.Value != want || u.Unit != goscale.UnitGrams || !u.Stable {
+				t.Errorf("update = %v %s (stable %t), want %v g stable", u.Value, u.Unit, u.Stable, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no update of %v g", want)
+		}
+	}
+}
+
+func TestConnectDropped(t *testing.T) {
+	dev, _, _ := fakeUmbra(t)
+	s, updates := connect(t)
+
+	dev.Drop()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Fatal("update after the link dropped")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("updates not closed after the link dropped")
+	}
+	if s.IsConnected() {
+		t.Error("still connected after the link dropped")
+	}
+}
+
+// Discovery straight after connecting can come up empty, as with BlueZ;
+// Connect retries it.
+func TestConnectRetriesDiscovery(t *testing.T) {
+	restore := goscale.DefaultDiscoveryConfig
+	goscale.DefaultDiscoveryConfig = goscale.DiscoveryConfig{Attempts: 3, Delay: time.Millisecond}
+	t.Cleanup(func() { goscale.DefaultDiscoveryConfig = restore })
+
+	dev, _, _ := fakeUmbra(t)
+	dev.FailDiscovery(2)
+	connect(t)
+}
@@ -0,0 +1,203 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// ErrAuditTampered is matched (via errors.Is) by the error returned when an
+// audit log's hash chain doesn't hold: an entry was changed, removed,
+// reordered or inserted after it was written.
+var ErrAuditTampered = errors.New("audit log hash chain broken")
+
+// AuditEntry is one settled weight in an AuditLog. Each entry's Hash covers
+// its other fields and the previous entry's hash, so no entry can be
+// altered without breaking every hash after it.
+type AuditEntry struct {
+	Seq     uint64    `json:"seq"` // 1 for the first entry
+	Time    time.Time `json:"time"`
+	Device  string    `json:"device"`
+	Session uint64    `json:"session,omitempty"` // Session ID, if one was assigned
+	Weight  float64   `json:"weight"`            // Grams
+	Prev    string    `json:"prev"`              // Hash of the previous entry, hex; empty for the first
+	Hash    string    `json:"hash"`              // SHA-256, hex
+}
+
+// hash returns the hash of e's fields other than Hash.
+func (e AuditEntry) hash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n%s\n%d\n%s\n%s",
+		e.Seq,
+		e.Time.UTC().Format(time.RFC3339Nano),
+		strconv.Quote(e.Device),
+		e.Session,
+		strconv.FormatFloat(e.Weight, 'f', -1, 64),
+		e.Prev)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditLog is an append-only, hash-chained record of settled weights, with
+// their time and the device that weighed them, for users who must be able
+// to show their records haven't been edited, such as packing lines. It is
+// stored as line-delimited JSON, one AuditEntry per line, and each entry
+// is synced to disk as it is written. The chain shows tampering with the
+// file, but not its wholesale replacement, so keep a copy of the latest
+// hash elsewhere to anchor it. It is safe for concurrent use.
+type AuditLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	last AuditEntry // Zero if the log is empty
+}
+
+// OpenAuditLog opens the audit log at path to append to it, creating it if
+// needed. An existing log is verified first, and refused if its chain is
+// broken.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit log: %w", err)
+	}
+	entries, err := VerifyAudit(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	a := &AuditLog{f: f}
+	if len(entries) > 0 {
+		a.last = entries[len(entries)-1]
+	}
+	return a, nil
+}
+
+// Append adds a weight in grams, taken at the given time by the named
+// device, to the log, and returns the entry written.
+func (a *AuditLog) Append(at time.Time, device string, session uint64, grams float64) (AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e := AuditEntry{
+		Seq:     a.last.Seq + 1,
+		Time:    at,
+		Device:  device,
+		Session: session,
+		Weight:  grams,
+		Prev:    a.last.Hash,
+	}
+	e.Hash = e.hash()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		return AuditEntry{}, fmt.Errorf("could not write audit log: %w", err)
+	}
+	if err := a.f.Sync(); err != nil {
+		return AuditEntry{}, fmt.Errorf("could not write audit log: %w", err)
+	}
+	a.last = e
+	return e, nil
+}
+
+// Last returns the most recent entry, whose Hash anchors the whole chain,
+// and false if the log is empty.
+func (a *AuditLog) Last() (AuditEntry, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last, a.last.Seq > 0
+}
+
+// Close closes the audit log file.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.f.Close()
+}
+
+// VerifyAudit reads an audit log and checks its hash chain, returning its
+// entries. If the chain is broken it returns an error matching
+// ErrAuditTampered, naming the first bad line.
+func VerifyAudit(r io.Reader) ([]AuditEntry, error) {
+	scanner := bufio.NewScanner(r)
+	var (
+		entries []AuditEntry
+		prev    AuditEntry
+	)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%w: line %d is malformed", ErrAuditTampered, line)
+		}
+		if e.Seq != prev.Seq+1 || e.Prev != prev.Hash || e.Hash != e.hash() {
+			return nil, fmt.Errorf("%w at line %d (entry %d)", ErrAuditTampered, line, e.Seq)
+		}
+		entries = append(entries, e)
+		prev = e
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WriteAuditCSV exports audit entries as CSV, one row per entry, with the
+// time in RFC 3339 format and the weight in grams. The hashes are
+// included, so the export can be checked against the log.
+func WriteAuditCSV(w io.Writer, entries []AuditEntry) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"seq", "time", "device", "session", "weight", "prev", "hash"})
+	for _, e := range entries {
+		_ = cw.Write([]string{
+			strconv.FormatUint(e.Seq, 10),
+			e.Time.UTC().Format(time.RFC3339Nano),
+			e.Device,
+			strconv.FormatUint(e.Session, 10),
+			strconv.FormatFloat(e.Weight, 'f', -1, 64),
+			e.Prev,
+			e.Hash,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// auditor picks out the weights a Recorder logs to an AuditLog: one each
+// time the weight settles.
+type auditor struct {
+	log     *AuditLog
+	stable  *goscale.StabilityDetector
+	settled bool
+}
+
+// observe logs u's settled weight if it has just settled.
+func (a *auditor) observe(s *Session, u goscale.WeightUpdate) error {
+	settled := a.stable.Observe(u)
+	defer func() { a.settled = settled }()
+	if !settled || a.settled {
+		return nil
+	}
+	at := u.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	// Round to the scales' finest resolution, so the log doesn't record
+	// float noise from averaging.
+	_, err := a.log.Append(at, s.Device, s.ID, math.Round(a.stable.Mean()*100)/100)
+	return err
+}
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"slices"
+	"time"
+)
+
+// DownsampleTier thins out samples older than After to at most one per
+// Interval.
+type DownsampleTier struct {
+	After    time.Duration
+	Interval time.Duration
+}
+
+// DefaultDownsampleTiers keeps the last 10 minutes at full rate, the last
+// hour at 1 Hz and anything older at 0.1 Hz, which bounds a recording of a
+// 12 hour cold brew to a few thousand samples.
+var DefaultDownsampleTiers = []DownsampleTier{
+	{After: 10 * time.Minute, Interval: time.Second},
+	{After: time.Hour, Interval: 10 * time.Second},
+}
+
+// Downsample thins out the session's older samples according to tiers. Ages
+// are measured back from the last sample. Within a tier, the first sample
+// of each Interval is kept, so downsampling again, or as samples age into a
+// coarser tier whose Interval is a multiple of the finer one, keeps a
+// subset of what was there.
+func (s *Session) Downsample(tiers []DownsampleTier) {
+	if len(s.Samples) == 0 || len(tiers) == 0 {
+		return
+	}
+	tiers = slices.Clone(tiers)
+	slices.SortFunc(tiers, func(a, b DownsampleTier) int { return int(a.After - b.After) })
+
+	newest := s.Samples[len(s.Samples)-1].Elapsed
+	kept := s.Samples[:0]
+	prevTier, prevBucket := -1, time.Duration(-1)
+	for _, smp := range s.Samples {
+		age := newest - smp.Elapsed
+		tier := -1
+		for i, t := range tiers {
+			if age > t.After && t.Interval > 0 {
+				tier = i
+			}
+		}
+		if tier < 0 {
+			kept = append(kept, smp)
+			prevTier = -1
+			continue
+		}
+		bucket := smp.Elapsed / tiers[tier].Interval
+		if tier == prevTier && bucket == prevBucket {
+			continue
+		}
+		kept = append(kept, smp)
+		prevTier, prevBucket = tier, bucket
+	}
+	clear(s.Samples[len(kept):])
+	s.Samples = kept
+}
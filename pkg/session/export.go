@@ -0,0 +1,265 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON and CSV formats sessions are
+// exported in. It is raised whenever a change would stop an older reader
+// understanding a new export; Load reads every version up to it. Exports
+// from before versioning have no version, and are read as version 1.
+const SchemaVersion = 1
+
+// ErrUnsupportedSchema is returned when loading an export written by a
+// newer goscale than this one.
+var ErrUnsupportedSchema = errors.New("unsupported session schema version")
+
+func checkSchema(version int) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("%w %d (up to %d is supported)", ErrUnsupportedSchema, version, SchemaVersion)
+	}
+	return nil
+}
+
+// The kinds of row in a session CSV export.
+const (
+	csvSample    = "sample"
+	csvGap       = "gap"
+	csvTelemetry = "telemetry"
+)
+
+var csvColumns = []string{"kind", "elapsed", "weight", "length", "pressure", "flow"}
+
+// WriteCSV exports the session as CSV. A few key-value rows come first,
+// giving the schema version, ID, device and start time, then a blank line
+// and a table with a row per sample, gap and telemetry reading:
+//
+//	schema,1
+//	id,42
+//	device,LUNAR-A23B
+//	start,2026-01-02T08:30:00Z
+//
+//	kind,elapsed,weight,length,pressure,flow
+//	sample,0.000,18.20,,,
+//	gap,4.100,,2.500,,
+//	telemetry,6.600,,,9.01,2.10
+//
+// Times are in seconds, weights in grams, pressure in bar and flow in
+// millilitres per second. Filtering on kind gives each series on its own.
+func (s *Session) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"schema", strconv.Itoa(SchemaVersion)})
+	_ = cw.Write([]string{"id", strconv.FormatUint(s.ID, 10)})
+	_ = cw.Write([]string{"device", s.Device})
+	_ = cw.Write([]string{"start", s.Start.Format(time.RFC3339Nano)})
+	cw.Flush()
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return err
+	}
+
+	_ = cw.Write(csvColumns)
+	for _, smp := range s.Samples {
+		_ = cw.Write([]string{csvSample, formatSeconds(smp.Elapsed), formatFloat(smp.Weight, 2), "", "", ""})
+	}
+	for _, g := range s.Gaps {
+		_ = cw.Write([]string{csvGap, formatSeconds(g.Elapsed), "", formatSeconds(g.Length), "", ""})
+	}
+	for _, t := range s.Telemetry {
+		flow := ""
+		if t.HasFlow {
+			flow = formatFloat(t.Flow, 2)
+		}
+		_ = cw.Write([]string{csvTelemetry, formatSeconds(t.Elapsed), "", "", formatFloat(t.Pressure, 2), flow})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Load reads a session from any of the formats goscale writes one in: JSON
+// (WriteJSON), CSV (WriteCSV) or a journal (Journal), telling them apart by
+// their content. Exports from newer goscale versions are refused with
+// ErrUnsupportedSchema.
+func Load(r io.Reader) (*Session, error) {
+	br := bufio.NewReader(r)
+	first, err := firstByte(br)
+	if err != nil {
+		return nil, err
+	}
+	if first != '{' {
+		return loadCSV(br)
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	// A journal is a run of JSON values, one per line; an export is one.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var head json.RawMessage
+	if err := dec.Decode(&head); err != nil {
+		return nil, fmt.Errorf("invalid session JSON: %w", err)
+	}
+	if dec.More() {
+		return Recover(bytes.NewReader(data))
+	}
+	s := &Session{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadFile reads a session from the file at path. See Load.
+func LoadFile(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// firstByte returns the first byte of r that isn't white space, without
+// consuming it.
+func firstByte(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, errors.New("session export is empty")
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, r.UnreadByte()
+	}
+}
+
+// loadCSV reads a session written by WriteCSV.
+func loadCSV(r io.Reader) (*Session, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // The key-value rows are shorter than the table
+
+	s := &Session{}
+	version := 0
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil, errors.New("session CSV has no table")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec[0] == csvColumns[0] {
+			break
+		}
+		if len(rec) != 2 {
+			return nil, fmt.Errorf("malformed session CSV header row %q", rec)
+		}
+		switch key, value := rec[0], rec[1]; key {
+		case "schema":
+			if version, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid session CSV schema %q", value)
+			}
+		case "id":
+			if s.ID, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid session CSV id %q", value)
+			}
+		case "device":
+			s.Device = value
+		case "start":
+			if s.Start, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return nil, fmt.Errorf("invalid session CSV start time: %w", err)
+			}
+		}
+		// Keys added by later versions are skipped.
+	}
+	if version == 0 {
+		return nil, errors.New("session CSV has no schema version")
+	}
+	if err := checkSchema(version); err != nil {
+		return nil, err
+	}
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < len(csvColumns) {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("malformed session CSV line %d", line)
+		}
+		if err := s.addCSVRow(rec); err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("session CSV line %d: %w", line, err)
+		}
+	}
+}
+
+// addCSVRow adds one row of a session CSV table to s. Rows of kinds added
+// by later versions are skipped.
+func (s *Session) addCSVRow(rec []string) error {
+	elapsed, err := parseSeconds(rec[1])
+	if err != nil {
+		return err
+	}
+	switch rec[0] {
+	case csvSample:
+		weight, err := strconv.ParseFloat(rec[2], 64)
+		if err != nil {
+			return err
+		}
+		s.Samples = append(s.Samples, Sample{Elapsed: elapsed, Weight: weight})
+	case csvGap:
+		length, err := parseSeconds(rec[3])
+		if err != nil {
+			return err
+		}
+		s.Gaps = append(s.Gaps, Gap{Elapsed: elapsed, Length: length})
+	case csvTelemetry:
+		t := Telemetry{Elapsed: elapsed}
+		if t.Pressure, err = strconv.ParseFloat(rec[4], 64); err != nil {
+			return err
+		}
+		if rec[5] != "" {
+			if t.Flow, err = strconv.ParseFloat(rec[5], 64); err != nil {
+				return err
+			}
+			t.HasFlow = true
+		}
+		s.Telemetry = append(s.Telemetry, t)
+	}
+	return nil
+}
+
+func formatSeconds(d time.Duration) string {
+	return formatFloat(d.Seconds(), 3)
+}
+
+func formatFloat(f float64, prec int) string {
+	return strconv.FormatFloat(f, 'f', prec, 64)
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return seconds(f), nil
+}
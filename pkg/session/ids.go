@@ -0,0 +1,66 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// IDStore hands out session IDs that keep increasing across restarts, by
+// keeping the last one issued in a file. It is safe for concurrent use
+// within a process, but not for several processes sharing one file.
+type IDStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultIDStorePath returns where the last session ID is kept by default,
+// under the user's configuration directory.
+func DefaultIDStorePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goscale", "last-session-id"), nil
+}
+
+// NewIDStore returns an IDStore keeping its state in the file at path.
+func NewIDStore(path string) *IDStore {
+	return &IDStore{path: path}
+}
+
+// Next returns a new ID, one more than the last. The first ID is 1.
+func (s *IDStore) Next() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var last uint64
+	data, err := os.ReadFile(s.path)
+	switch {
+	case err == nil:
+		last, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid session ID file %s: %w", s.path, err)
+		}
+	case !os.IsNotExist(err):
+		return 0, err
+	}
+	next := last + 1
+
+	// Write alongside and rename, so a crash can't leave a truncated file
+	// that would restart the count.
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return 0, fmt.Errorf("could not save session ID: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(next, 10)+"\n"), 0o644); err != nil {
+		return 0, fmt.Errorf("could not save session ID: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return 0, fmt.Errorf("could not save session ID: %w", err)
+	}
+	return next, nil
+}
@@ -0,0 +1,285 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FsyncPolicy controls how often a Journal forces its writes to disk.
+type FsyncPolicy uint8
+
+const (
+	// FsyncNever leaves flushing to the operating system. Fastest, but a power
+	// loss can lose the tail of the journal.
+	FsyncNever FsyncPolicy = iota
+	// FsyncInterval syncs at most once per Journal.SyncInterval.
+	FsyncInterval
+	// FsyncEverySample syncs after every sample. Safest; may be slow on SD
+	// cards.
+	FsyncEverySample
+)
+
+// DefaultSyncInterval is used by FsyncInterval journals with no interval set.
+const DefaultSyncInterval = time.Second
+
+// Journal is an append-only, line-delimited JSON record of a session: a
+// header line with the session ID, device and start time, followed by one
+// line per sample, and a marker line for each Gap and Telemetry reading. Each line is written as
+// soon as its sample arrives.
+type Journal struct {
+	// SyncInterval is how often an FsyncInterval journal syncs.
+	SyncInterval time.Duration
+
+	f         *os.File
+	policy    FsyncPolicy
+	lastSync  time.Time
+	hasHeader bool
+}
+
+type journalHeader struct {
+	ID     uint64 `json:"id,omitempty"`
+	Device string `json:"device"`
+	Start  string `json:"start"`
+}
+
+// journalGap is the marker line for a Gap.
+type journalGap struct {
+	Gap Gap `json:"gap"`
+}
+
+// journalTelemetry is the marker line for a Telemetry reading.
+type journalTelemetry struct {
+	Telemetry Telemetry `json:"telemetry"`
+}
+
+var (
+	gapPrefix       = []byte(`{"gap":`)
+	telemetryPrefix = []byte(`{"telemetry":`)
+)
+
+func newJournalHeader(s *Session) journalHeader {
+	return journalHeader{ID: s.ID, Device: s.Device, Start: s.Start.Format(time.RFC3339Nano)}
+}
+
+// OpenJournal creates (or truncates) the journal file at path.
+func OpenJournal(path string, policy FsyncPolicy) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+	return &Journal{f: f, policy: policy, SyncInterval: DefaultSyncInterval}, nil
+}
+
+// OpenJournalAppend opens the journal file at path to carry on appending to
+// it, creating it if needed, e.g. to resume a session recovered from it. A
+// final line cut short by a crash is dropped first, so it doesn't end up in
+// the middle of the journal.
+func OpenJournalAppend(path string, policy FsyncPolicy) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err == nil {
+		err = f.Truncate(int64(bytes.LastIndexByte(data, '\n') + 1))
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+	return &Journal{
+		f:            f,
+		policy:       policy,
+		SyncInterval: DefaultSyncInterval,
+		hasHeader:    bytes.IndexByte(data, '\n') >= 0,
+	}, nil
+}
+
+func (j *Journal) writeHeader(s *Session) error {
+	if err := j.writeLine(newJournalHeader(s)); err != nil {
+		return err
+	}
+	j.hasHeader = true
+	return nil
+}
+
+func (j *Journal) writeSample(smp Sample) error {
+	return j.writeLine(smp)
+}
+
+func (j *Journal) writeGap(g Gap) error {
+	return j.writeLine(journalGap{Gap: g})
+}
+
+func (j *Journal) writeTelemetry(t Telemetry) error {
+	return j.writeLine(journalTelemetry{Telemetry: t})
+}
+
+func (j *Journal) writeLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write journal: %w", err)
+	}
+
+	switch j.policy {
+	case FsyncEverySample:
+		return j.f.Sync()
+	case FsyncInterval:
+		if time.Since(j.lastSync) >= j.SyncInterval {
+			j.lastSync = time.Now()
+			return j.f.Sync()
+		}
+	}
+	return nil
+}
+
+// rewrite replaces the journal's contents with s, e.g. after downsampling.
+// The new journal is written alongside and renamed into place, so a crash
+// part way through leaves the old one intact.
+func (j *Journal) rewrite(s *Session) error {
+	path := j.f.Name()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not rewrite journal: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	err = enc.Encode(newJournalHeader(s))
+	for _, g := range s.Gaps {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(journalGap{Gap: g})
+	}
+	for _, smp := range s.Samples {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(smp)
+	}
+	for _, t := range s.Telemetry {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(journalTelemetry{Telemetry: t})
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// Windows can't rename over an open file.
+		_ = j.f.Close()
+		err = os.Rename(tmp.Name(), path)
+		// Carry on appending to whichever journal is now at path.
+		f, openErr := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		if openErr != nil {
+			return fmt.Errorf("could not reopen journal: %w", openErr)
+		}
+		j.f = f
+		j.lastSync = time.Now()
+		j.hasHeader = true
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("could not rewrite journal: %w", err)
+	}
+	return nil
+}
+
+// Close syncs and closes the journal file.
+func (j *Journal) Close() error {
+	if err := j.f.Sync(); err != nil {
+		_ = j.f.Close()
+		return err
+	}
+	return j.f.Close()
+}
+
+// Recover rebuilds a Session from a journal, which may have been cut short
+// by a crash. A truncated final line is discarded; a malformed line anywhere
+// else is an error.
+func Recover(r io.Reader) (*Session, error) {
+	scanner := bufio.NewScanner(r)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("journal is empty")
+	}
+	var header journalHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("invalid journal header: %w", err)
+	}
+	start, err := time.Parse(time.RFC3339Nano, header.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid journal start time: %w", err)
+	}
+
+	s := &Session{ID: header.ID, Device: header.Device, Start: start}
+	var badLine int
+	for line := 2; scanner.Scan(); line++ {
+		if badLine != 0 {
+			return nil, fmt.Errorf("malformed journal line %d", badLine)
+		}
+		if bytes.HasPrefix(scanner.Bytes(), gapPrefix) {
+			var g journalGap
+			if err := json.Unmarshal(scanner.Bytes(), &g); err != nil {
+				badLine = line
+				continue
+			}
+			s.Gaps = append(s.Gaps, g.Gap)
+			continue
+		}
+		if bytes.HasPrefix(scanner.Bytes(), telemetryPrefix) {
+			var t journalTelemetry
+			if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+				badLine = line
+				continue
+			}
+			s.Telemetry = append(s.Telemetry, t.Telemetry)
+			continue
+		}
+		var smp Sample
+		if err := json.Unmarshal(scanner.Bytes(), &smp); err != nil {
+			// Only acceptable if this turns out to be the last line.
+			badLine = line
+			continue
+		}
+		s.Samples = append(s.Samples, smp)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// RecoverFile rebuilds a Session from the journal file at path.
+func RecoverFile(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Recover(f)
+}
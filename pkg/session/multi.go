@@ -0,0 +1,172 @@
+package session
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// MultiRecorder records several scales at once, e.g. the two scales of a
+// dual-scale gravimetric flow setup, so their sessions can be aligned onto
+// a common timebase with Align. It is safe for concurrent use.
+type MultiRecorder struct {
+	opts []RecorderOption
+
+	mu        sync.Mutex
+	recorders map[string]*Recorder
+	order     []string
+}
+
+// NewMultiRecorder returns a MultiRecorder whose per-scale recorders are
+// created with opts.
+func NewMultiRecorder(opts ...RecorderOption) *MultiRecorder {
+	return &MultiRecorder{opts: opts, recorders: make(map[string]*Recorder)}
+}
+
+func (m *MultiRecorder) recorder(device string) *Recorder {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.recorders[device]
+	if !ok {
+		r = NewRecorder(device, m.opts...)
+		m.recorders[device] = r
+		m.order = append(m.order, device)
+	}
+	return r
+}
+
+// Record adds a weight update from the named device.
+func (m *MultiRecorder) Record(device string, u goscale.WeightUpdate) error {
+	return m.recorder(device).Record(u)
+}
+
+// RunAll records every scale's updates until all their channels close, e.g.
+// the scales returned by Manager.ConnectAll. Updates are read through
+// ConnectedScale.Subscribe, so other subscribers can share them. It returns
+// the joined errors of their recorders.
+func (m *MultiRecorder) RunAll(scales []*goscale.ConnectedScale) error {
+	errs := make([]error, len(scales))
+	var wg sync.WaitGroup
+	for i, cs := range scales {
+		r := m.recorder(cs.Device.Name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.Run(cs.Subscribe(0))
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Sessions returns a snapshot of each device's session, in the order the
+// devices were first seen.
+func (m *MultiRecorder) Sessions() []*Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := make([]*Session, 0, len(m.order))
+	for _, device := range m.order {
+		sessions = append(sessions, m.recorders[device].Session())
+	}
+	return sessions
+}
+
+// Close closes every recorder's journal, if any.
+func (m *MultiRecorder) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for _, r := range m.recorders {
+		errs = append(errs, r.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Dataset is several sessions resampled onto one timebase.
+type Dataset struct {
+	Start   time.Time       // The earliest session start
+	Devices []string        // One per column
+	Elapsed []time.Duration // One per row, since Start
+
+	// Weights holds a row per Elapsed and a column per device, in grams.
+	// A device's value is NaN outside the time it was recording.
+	Weights [][]float64
+}
+
+// Align resamples sessions onto a common timebase, one row every interval,
+// interpolating linearly between each session's samples. The sessions'
+// wall-clock start times line them up, so they must have been recorded on
+// the same host (or hosts with synchronised clocks).
+func Align(sessions []*Session, interval time.Duration) *Dataset {
+	d := &Dataset{}
+	var end time.Time
+	for _, s := range sessions {
+		d.Devices = append(d.Devices, s.Device)
+		if len(s.Samples) == 0 {
+			continue
+		}
+		if d.Start.IsZero() || s.Start.Before(d.Start) {
+			d.Start = s.Start
+		}
+		if last := s.Start.Add(s.Duration()); last.After(end) {
+			end = last
+		}
+	}
+	if d.Start.IsZero() || interval <= 0 {
+		return d
+	}
+
+	for t := time.Duration(0); !d.Start.Add(t).After(end); t += interval {
+		d.Elapsed = append(d.Elapsed, t)
+		row := make([]float64, len(sessions))
+		for i, s := range sessions {
+			row[i] = interpolate(s, d.Start.Add(t).Sub(s.Start))
+		}
+		d.Weights = append(d.Weights, row)
+	}
+	return d
+}
+
+// interpolate returns the session's weight at elapsed, or NaN if it wasn't
+// recording then.
+func interpolate(s *Session, elapsed time.Duration) float64 {
+	smp := s.Samples
+	if len(smp) == 0 || elapsed < smp[0].Elapsed || elapsed > smp[len(smp)-1].Elapsed {
+		return math.NaN()
+	}
+	i := sort.Search(len(smp), func(i int) bool { return smp[i].Elapsed >= elapsed })
+	if smp[i].Elapsed == elapsed || i == 0 {
+		return smp[i].Weight
+	}
+	a, b := smp[i-1], smp[i]
+	frac := float64(elapsed-a.Elapsed) / float64(b.Elapsed-a.Elapsed)
+	return a.Weight + frac*(b.Weight-a.Weight)
+}
+
+// WriteCSV writes the dataset as CSV: an "elapsed" column in seconds, then
+// a column per device. Cells where a device wasn't recording are empty.
+func (d *Dataset) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(append([]string{"elapsed"}, d.Devices...))
+	for i, t := range d.Elapsed {
+		rec := make([]string, 0, len(d.Devices)+1)
+		rec = append(rec, strconv.FormatFloat(t.Seconds(), 'f', 3, 64))
+		for _, v := range d.Weights[i] {
+			if math.IsNaN(v) {
+				rec = append(rec, "")
+				continue
+			}
+			rec = append(rec, strconv.FormatFloat(v, 'f', 2, 64))
+		}
+		_ = cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
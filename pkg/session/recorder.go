@@ -0,0 +1,251 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithJournal makes the recorder append every sample to j as it arrives, so
+// a crash mid-shot leaves a journal that Recover can rebuild the session
+// from. The recorder closes the journal when it is closed.
+func WithJournal(j *Journal) RecorderOption {
+	return func(r *Recorder) {
+		r.journal = j
+	}
+}
+
+// WithID sets the ID of the recorded session, e.g. one from IDStore.Next.
+func WithID(id uint64) RecorderOption {
+	return func(r *Recorder) {
+		r.session.ID = id
+	}
+}
+
+// WithTimeSource makes the recorder timestamp samples with ts instead of
+// each update's own Timestamp, e.g. a goscale.ScaleTimerClock to follow the
+// scale's timer.
+func WithTimeSource(ts goscale.TimeSource) RecorderOption {
+	return func(r *Recorder) {
+		r.timeSource = ts
+	}
+}
+
+// WithDownsampling makes the recorder thin out older samples as it goes,
+// keeping memory bounded for recordings that run for many hours. With no
+// tiers, DefaultDownsampleTiers are used. A journal, if any, is rewritten
+// to match, so disk use stays bounded too. See Session.Downsample.
+func WithDownsampling(tiers ...DownsampleTier) RecorderOption {
+	if len(tiers) == 0 {
+		tiers = DefaultDownsampleTiers
+	}
+	return func(r *Recorder) {
+		r.tiers = tiers
+	}
+}
+
+// WithAuditLog makes the recorder add each weight the session settles at to
+// a, as judged by cfg, along with the session's device and ID. The log is
+// usually shared by many sessions, so the recorder doesn't close it.
+func WithAuditLog(a *AuditLog, cfg goscale.StableConfig) RecorderOption {
+	return func(r *Recorder) {
+		r.audit = &auditor{log: a, stable: goscale.NewStabilityDetector(cfg)}
+	}
+}
+
+// downsampleEvery is how much recording time passes between downsampling
+// passes.
+const downsampleEvery = time.Minute
+
+// Recorder builds a Session from a live weight update stream. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu         sync.Mutex
+	session    *Session
+	journal    *Journal
+	timeSource goscale.TimeSource
+	audit      *auditor
+
+	tiers          []DownsampleTier
+	lastDownsample time.Duration
+
+	// resumed is set between a reconnect and the first sample after it,
+	// which closes the Gap.
+	resumed bool
+}
+
+// NewRecorder returns a Recorder for a new session on the named device.
+func NewRecorder(device string, opts ...RecorderOption) *Recorder {
+	r := &Recorder{session: New(device)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// ResumeRecorder returns a Recorder that carries on recording s, e.g. one
+// rebuilt with RecoverFile after a crash, rather than starting a new
+// session. The time until its next sample is marked as a Gap. To keep
+// journaling to the same file, pass a journal from OpenJournalAppend; a new,
+// empty journal is filled with s first.
+func ResumeRecorder(s *Session, opts ...RecorderOption) *Recorder {
+	r := &Recorder{session: s, resumed: len(s.Samples) > 0}
+	r.lastDownsample = s.Duration()
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Record adds a weight update to the session, and to the journal and audit
+// log if they are configured. Updates carrying an error are ignored.
+func (r *Recorder) Record(u goscale.WeightUpdate) error {
+	if u.Error != nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timeSource != nil {
+		u.Timestamp = r.timeSource.Time(u)
+	}
+	n := len(r.session.Samples)
+	r.session.Add(u)
+	if len(r.session.Samples) == n {
+		return nil
+	}
+	var gap *Gap
+	if r.resumed {
+		r.resumed = false
+		last := r.session.Samples[n-1].Elapsed
+		r.session.Gaps = append(r.session.Gaps, Gap{
+			Elapsed: last,
+			Length:  r.session.Samples[n].Elapsed - last,
+		})
+		gap = &r.session.Gaps[len(r.session.Gaps)-1]
+	}
+	if r.journal != nil {
+		if err := r.writeJournal(n, gap); err != nil {
+			return err
+		}
+	}
+	if r.audit != nil {
+		if err := r.audit.observe(r.session, u); err != nil {
+			return err
+		}
+	}
+	return r.downsample()
+}
+
+// RecordTelemetry adds a telemetry reading taken at the given time, e.g.
+// from a pressure sensor, to the session, and to the journal if one is
+// configured. Recording it alongside the scale's updates puts the two on
+// one timeline.
+func (r *Recorder) RecordTelemetry(at time.Time, t Telemetry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.session.AddTelemetry(at, t)
+	if r.journal == nil {
+		return nil
+	}
+	if !r.journal.hasHeader {
+		if len(r.session.Samples) > 0 || len(r.session.Telemetry) > 1 {
+			return r.journal.rewrite(r.session)
+		}
+		if err := r.journal.writeHeader(r.session); err != nil {
+			return err
+		}
+	}
+	return r.journal.writeTelemetry(r.session.Telemetry[len(r.session.Telemetry)-1])
+}
+
+// writeJournal appends sample n, and the gap before it if any, to the
+// journal.
+func (r *Recorder) writeJournal(n int, gap *Gap) error {
+	if !r.journal.hasHeader {
+		if n > 0 || len(r.session.Telemetry) > 0 {
+			// Resuming into a new journal: write out everything so far.
+			return r.journal.rewrite(r.session)
+		}
+		if err := r.journal.writeHeader(r.session); err != nil {
+			return err
+		}
+	}
+	if gap != nil {
+		if err := r.journal.writeGap(*gap); err != nil {
+			return err
+		}
+	}
+	return r.journal.writeSample(r.session.Samples[n])
+}
+
+// downsample runs a downsampling pass if one is due.
+func (r *Recorder) downsample() error {
+	if len(r.tiers) == 0 {
+		return nil
+	}
+	elapsed := r.session.Duration()
+	if elapsed-r.lastDownsample < downsampleEvery {
+		return nil
+	}
+	r.lastDownsample = elapsed
+
+	n := len(r.session.Samples)
+	r.session.Downsample(r.tiers)
+	if r.journal == nil || len(r.session.Samples) == n {
+		return nil
+	}
+	return r.journal.rewrite(r.session)
+}
+
+// Run records every update from in until it is closed. It returns the first
+// journal error encountered, after draining in.
+//
+// Run may be called again with the update channel of a new connection, e.g.
+// after a Bluetooth hiccup mid-brew. The session then carries on, with the
+// time until the first reading on the new connection marked as a Gap, so
+// an interrupted shot stays one session.
+func (r *Recorder) Run(in <-chan goscale.WeightUpdate) error {
+	r.mu.Lock()
+	if len(r.session.Samples) > 0 {
+		r.resumed = true
+	}
+	r.mu.Unlock()
+
+	var firstErr error
+	for u := range in {
+		if err := r.Record(u); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Session returns a snapshot of the session recorded so far.
+func (r *Recorder) Session() *Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := *r.session
+	s.Samples = append([]Sample(nil), r.session.Samples...)
+	s.Gaps = append([]Gap(nil), r.session.Gaps...)
+	s.Telemetry = append([]Telemetry(nil), r.session.Telemetry...)
+	return &s
+}
+
+// Close flushes and closes the journal, if any.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal == nil {
+		return nil
+	}
+	return r.journal.Close()
+}
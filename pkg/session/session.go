@@ -0,0 +1,177 @@
+// Package session holds the weight readings taken from a scale over the
+// course of one brew, and computes statistics about them.
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// Sample is a single weight reading within a session.
+type Sample struct {
+	Elapsed time.Duration // Time since the session started
+	Weight  float64       // Grams
+}
+
+// Gap marks a stretch of a session with no readings because the scale was
+// disconnected, e.g. by a Bluetooth hiccup mid-brew.
+type Gap struct {
+	Elapsed time.Duration // Time of the last reading before the gap
+	Length  time.Duration
+}
+
+// Session is a recording of weight readings from one scale.
+type Session struct {
+	ID      uint64 // Zero if none was assigned; see IDStore
+	Device  string
+	Start   time.Time
+	Samples []Sample
+	Gaps    []Gap
+
+	// Telemetry holds readings from other devices recorded alongside the
+	// scale, such as the machine's pressure; see Recorder.RecordTelemetry.
+	Telemetry []Telemetry
+}
+
+// New returns an empty session for the named device. The start time is set
+// by the first reading added.
+func New(device string) *Session {
+	return &Session{Device: device}
+}
+
+// Add appends a weight update to the session, converted to grams. Updates
+// carrying an error or in an unknown unit are ignored. Updates without a
+// Timestamp are stamped with the current time.
+func (s *Session) Add(u goscale.WeightUpdate) {
+	if u = goscale.ConvertUpdate(u, goscale.UnitGrams); u.Error != nil {
+		return
+	}
+
+	ts := u.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	if s.Start.IsZero() {
+		s.Start = ts
+	}
+
+	s.Samples = append(s.Samples, Sample{Elapsed: ts.Sub(s.Start), Weight: u.Value})
+}
+
+// Duration returns the time between the session start and the last sample.
+func (s *Session) Duration() time.Duration {
+	if len(s.Samples) == 0 {
+		return 0
+	}
+	return s.Samples[len(s.Samples)-1].Elapsed
+}
+
+type sampleJSON struct {
+	Elapsed float64 `json:"elapsed"`
+	Weight  float64 `json:"weight"`
+}
+
+// MarshalJSON encodes the elapsed time in seconds.
+func (s Sample) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sampleJSON{Elapsed: s.Elapsed.Seconds(), Weight: s.Weight})
+}
+
+func (s *Sample) UnmarshalJSON(data []byte) error {
+	var j sampleJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	s.Elapsed = seconds(j.Elapsed)
+	s.Weight = j.Weight
+	return nil
+}
+
+type gapJSON struct {
+	Elapsed float64 `json:"elapsed"`
+	Length  float64 `json:"length"`
+}
+
+// MarshalJSON encodes times in seconds.
+func (g Gap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(gapJSON{Elapsed: g.Elapsed.Seconds(), Length: g.Length.Seconds()})
+}
+
+func (g *Gap) UnmarshalJSON(data []byte) error {
+	var j gapJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	g.Elapsed = seconds(j.Elapsed)
+	g.Length = seconds(j.Length)
+	return nil
+}
+
+type sessionJSON struct {
+	Schema  int      `json:"schema"`
+	ID      uint64   `json:"id,omitempty"`
+	Device  string   `json:"device"`
+	Start   string   `json:"start"`
+	Summary Summary  `json:"summary"`
+	Samples []Sample `json:"samples"`
+	Gaps    []Gap    `json:"gaps,omitempty"`
+
+	Telemetry []Telemetry `json:"telemetry,omitempty"`
+}
+
+// MarshalJSON exports the session along with its computed Summary, tagged
+// with SchemaVersion.
+func (s *Session) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sessionJSON{
+		Schema:  SchemaVersion,
+		ID:      s.ID,
+		Device:  s.Device,
+		Start:   s.Start.Format(time.RFC3339Nano),
+		Summary: Summarize(s),
+		Samples: s.Samples,
+		Gaps:    s.Gaps,
+
+		Telemetry: s.Telemetry,
+	})
+}
+
+// UnmarshalJSON reads a session exported by MarshalJSON, by this or an
+// earlier version of goscale. The summary is ignored, as it is computed from
+// the samples.
+func (s *Session) UnmarshalJSON(data []byte) error {
+	var j sessionJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := checkSchema(j.Schema); err != nil {
+		return err
+	}
+	start, err := time.Parse(time.RFC3339Nano, j.Start)
+	if err != nil {
+		return fmt.Errorf("invalid session start time: %w", err)
+	}
+	*s = Session{
+		ID:      j.ID,
+		Device:  j.Device,
+		Start:   start,
+		Samples: j.Samples,
+		Gaps:    j.Gaps,
+
+		Telemetry: j.Telemetry,
+	}
+	return nil
+}
+
+// WriteJSON exports the session as indented JSON.
+func (s *Session) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func seconds(f float64) time.Duration {
+	return time.Duration(f * float64(time.Second))
+}
@@ -0,0 +1,153 @@
+package session
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/mlsorensen/goscale/pkg/flow"
+)
+
+const (
+	// FirstDripThreshold is how far, in grams, the weight must rise above the
+	// session's first reading to count as the first drip.
+	FirstDripThreshold = 0.5
+
+	// PreInfusionFlowThreshold is the flow rate, in grams per second, that
+	// marks the end of pre-infusion and the start of the main extraction.
+	PreInfusionFlowThreshold = 1.0
+
+	// The weight range, in grams, over which flow stability is measured.
+	// Early drips and the tail of a shot are naturally unsteady, so only the
+	// body of an espresso shot is considered.
+	StabilityWindowLow  = 10.0
+	StabilityWindowHigh = 30.0
+)
+
+// Summary holds statistics about a session.
+type Summary struct {
+	TotalTime   time.Duration // Time from the first to the last reading
+	FinalWeight float64       // Grams at the last reading
+	AverageFlow float64       // Grams per second from first drip to the end
+	PeakFlow    float64       // Highest flow rate seen, grams per second
+
+	HasFirstDrip    bool          // Whether the weight ever rose past FirstDripThreshold
+	TimeToFirstDrip time.Duration // Time until the first drip
+	PreInfusion     time.Duration // Time until flow first reached PreInfusionFlowThreshold
+
+	// WindowFlowMean is the mean flow rate while the weight was between
+	// StabilityWindowLow and StabilityWindowHigh grams, and WindowFlowStability
+	// the coefficient of variation of it there (standard deviation divided by
+	// mean). Lower is steadier; zero if the window wasn't reached.
+	WindowFlowMean      float64
+	WindowFlowStability float64
+}
+
+// Summarize computes shot statistics for s. Flow rates are estimated with a
+// flow.Meter over flow.DefaultWindow.
+func Summarize(s *Session) Summary {
+	var sum Summary
+	if len(s.Samples) == 0 {
+		return sum
+	}
+
+	base := s.Samples[0].Weight
+	last := s.Samples[len(s.Samples)-1]
+	sum.TotalTime = last.Elapsed - s.Samples[0].Elapsed
+	sum.FinalWeight = last.Weight
+
+	meter := flow.NewMeter(flow.DefaultWindow)
+	var (
+		firstDripWeight float64
+		preInfusionDone bool
+		windowFlows     []float64
+	)
+	for _, smp := range s.Samples {
+		rate := meter.Add(s.Start.Add(smp.Elapsed), smp.Weight)
+		sum.PeakFlow = math.Max(sum.PeakFlow, rate)
+
+		if !sum.HasFirstDrip && smp.Weight-base >= FirstDripThreshold {
+			sum.HasFirstDrip = true
+			sum.TimeToFirstDrip = smp.Elapsed
+			firstDripWeight = smp.Weight
+		}
+		if !preInfusionDone && rate >= PreInfusionFlowThreshold {
+			preInfusionDone = true
+			sum.PreInfusion = smp.Elapsed
+		}
+		if smp.Weight >= StabilityWindowLow && smp.Weight <= StabilityWindowHigh {
+			windowFlows = append(windowFlows, rate)
+		}
+	}
+
+	if sum.HasFirstDrip && last.Elapsed > sum.TimeToFirstDrip {
+		sum.AverageFlow = (last.Weight - firstDripWeight) / (last.Elapsed - sum.TimeToFirstDrip).Seconds()
+	}
+
+	if len(windowFlows) >= 2 {
+		var total float64
+		for _, f := range windowFlows {
+			total += f
+		}
+		mean := total / float64(len(windowFlows))
+
+		var variance float64
+		for _, f := range windowFlows {
+			variance += (f - mean) * (f - mean)
+		}
+		variance /= float64(len(windowFlows))
+
+		sum.WindowFlowMean = mean
+		if mean > 0 {
+			sum.WindowFlowStability = math.Sqrt(variance) / mean
+		}
+	}
+
+	return sum
+}
+
+type summaryJSON struct {
+	TotalTime           float64 `json:"total_time"`
+	FinalWeight         float64 `json:"final_weight"`
+	AverageFlow         float64 `json:"average_flow"`
+	PeakFlow            float64 `json:"peak_flow"`
+	HasFirstDrip        bool    `json:"has_first_drip"`
+	TimeToFirstDrip     float64 `json:"time_to_first_drip"`
+	PreInfusion         float64 `json:"pre_infusion"`
+	WindowFlowMean      float64 `json:"window_flow_mean"`
+	WindowFlowStability float64 `json:"window_flow_stability"`
+}
+
+// MarshalJSON encodes durations in seconds.
+func (s Summary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(summaryJSON{
+		TotalTime:           s.TotalTime.Seconds(),
+		FinalWeight:         s.FinalWeight,
+		AverageFlow:         s.AverageFlow,
+		PeakFlow:            s.PeakFlow,
+		HasFirstDrip:        s.HasFirstDrip,
+		TimeToFirstDrip:     s.TimeToFirstDrip.Seconds(),
+		PreInfusion:         s.PreInfusion.Seconds(),
+		WindowFlowMean:      s.WindowFlowMean,
+		WindowFlowStability: s.WindowFlowStability,
+	})
+}
+
+func (s *Summary) UnmarshalJSON(data []byte) error {
+	var j summaryJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*s = Summary{
+		TotalTime:           seconds(j.TotalTime),
+		FinalWeight:         j.FinalWeight,
+		AverageFlow:         j.AverageFlow,
+		PeakFlow:            j.PeakFlow,
+		HasFirstDrip:        j.HasFirstDrip,
+		TimeToFirstDrip:     seconds(j.TimeToFirstDrip),
+		PreInfusion:         seconds(j.PreInfusion),
+		WindowFlowMean:      j.WindowFlowMean,
+		WindowFlowStability: j.WindowFlowStability,
+	}
+	return nil
+}
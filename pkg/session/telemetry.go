@@ -0,0 +1,67 @@
+package session
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Telemetry is a single reading of shot telemetry from a device other than
+// the scale, such as a pressure sensor on the machine's group head, on the
+// same timeline as the session's samples.
+type Telemetry struct {
+	Elapsed  time.Duration // Time since the session started
+	Pressure float64       // Bar
+	Flow     float64       // Millilitres per second; valid if HasFlow
+	HasFlow  bool
+}
+
+// AddTelemetry appends a telemetry reading taken at the given time. If it
+// is the session's first reading of any kind, it sets the start time.
+func (s *Session) AddTelemetry(at time.Time, t Telemetry) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if s.Start.IsZero() {
+		s.Start = at
+	}
+	t.Elapsed = at.Sub(s.Start)
+	s.Telemetry = append(s.Telemetry, t)
+}
+
+// PeakPressure returns the highest pressure recorded, in bar, or zero if
+// there is no telemetry.
+func (s *Session) PeakPressure() float64 {
+	var peak float64
+	for _, t := range s.Telemetry {
+		peak = max(peak, t.Pressure)
+	}
+	return peak
+}
+
+type telemetryJSON struct {
+	Elapsed  float64  `json:"elapsed"`
+	Pressure float64  `json:"pressure"`
+	Flow     *float64 `json:"flow,omitempty"`
+}
+
+// MarshalJSON encodes the elapsed time in seconds, and leaves out the flow
+// if there is none.
+func (t Telemetry) MarshalJSON() ([]byte, error) {
+	j := telemetryJSON{Elapsed: t.Elapsed.Seconds(), Pressure: t.Pressure}
+	if t.HasFlow {
+		j.Flow = &t.Flow
+	}
+	return json.Marshal(j)
+}
+
+func (t *Telemetry) UnmarshalJSON(data []byte) error {
+	var j telemetryJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*t = Telemetry{Elapsed: seconds(j.Elapsed), Pressure: j.Pressure}
+	if j.Flow != nil {
+		t.Flow, t.HasFlow = *j.Flow, true
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package tare provides software tare: subtracting a known container weight
+// from the readings in place of zeroing the scale itself, which keeps the
+// gross weight available for logging.
+package tare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// ErrPresetNotFound is returned when looking up a preset that doesn't exist.
+var ErrPresetNotFound = errors.New("tare preset not found")
+
+// Presets is a named set of saved tare weights, e.g. "Acme dosing cup" at
+// 42.3 g. It is safe for concurrent use.
+type Presets struct {
+	mu      sync.RWMutex
+	weights map[string]float64
+}
+
+// NewPresets returns an empty preset set.
+func NewPresets() *Presets {
+	return &Presets{weights: make(map[string]float64)}
+}
+
+// DefaultPresetsPath returns where presets are stored by default, under the
+// user's configuration directory.
+func DefaultPresetsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goscale", "tare-presets.json"), nil
+}
+
+// LoadPresets reads presets from the JSON file at path. A missing file gives
+// an empty set.
+func LoadPresets(path string) (*Presets, error) {
+	p := NewPresets()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read tare presets: %w", err)
+	}
+	if err := json.Unmarshal(data, &p.weights); err != nil {
+		return nil, fmt.Errorf("invalid tare presets file: %w", err)
+	}
+	if p.weights == nil {
+		p.weights = make(map[string]float64)
+	}
+	return p, nil
+}
+
+// Save writes the presets to path as JSON, creating its directory if needed.
+func (p *Presets) Save(path string) error {
+	p.mu.RLock()
+	data, err := json.MarshalIndent(p.weights, "", "  ")
+	p.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not save tare presets: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not save tare presets: %w", err)
+	}
+	return nil
+}
+
+// Set saves a preset of the given weight in grams, replacing any preset of
+// the same name.
+func (p *Presets) Set(name string, grams float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.weights[name] = grams
+}
+
+// Get returns the weight of the named preset.
+func (p *Presets) Get(name string) (float64, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	grams, ok := p.weights[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrPresetNotFound, name)
+	}
+	return grams, nil
+}
+
+// Delete removes the named preset, if present.
+func (p *Presets) Delete(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.weights, name)
+}
+
+// Names returns the preset names in sorted order.
+func (p *Presets) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, 0, len(p.weights))
+	for name := range p.weights {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
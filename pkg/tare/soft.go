@@ -0,0 +1,96 @@
+package tare
+
+import (
+	"sync"
+
+	"github.com/mlsorensen/goscale"
+)
+
+// SoftTare subtracts an offset from weight readings. The scale itself is
+// never zeroed, so Gross still reports what is actually on the platform. It
+// is safe for concurrent use.
+type SoftTare struct {
+	presets *Presets
+
+	mu     sync.RWMutex
+	offset float64
+	gross  float64
+}
+
+// NewSoftTare returns a SoftTare with no offset. presets may be nil if
+// TareToPreset won't be used.
+func NewSoftTare(presets *Presets) *SoftTare {
+	return &SoftTare{presets: presets}
+}
+
+// TareToPreset sets the offset to the weight of the named preset.
+func (t *SoftTare) TareToPreset(name string) error {
+	if t.presets == nil {
+		return ErrPresetNotFound
+	}
+	grams, err := t.presets.Get(name)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.offset = grams
+	return nil
+}
+
+// Zero sets the offset to the last reading, so readings start again from
+// zero, as a scale's own tare would.
+func (t *SoftTare) Zero() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.offset = t.gross
+}
+
+// Clear removes the offset.
+func (t *SoftTare) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.offset = 0
+}
+
+// Offset returns the grams currently subtracted from readings.
+func (t *SoftTare) Offset() float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.offset
+}
+
+// Gross returns the last reading before the offset was applied.
+func (t *SoftTare) Gross() float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.gross
+}
+
+// Apply returns u with the offset subtracted. Updates carrying an error are
+// returned unchanged.
+func (t *SoftTare) Apply(u goscale.WeightUpdate) goscale.WeightUpdate {
+	if u.Error != nil {
+		return u
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gross = u.Value
+	u.Value -= t.offset
+	return u
+}
+
+// Run applies the tare to every update from in. The returned channel is
+// closed when in closes.
+func (t *SoftTare) Run(in <-chan goscale.WeightUpdate) <-chan goscale.WeightUpdate {
+	out := make(chan goscale.WeightUpdate, cap(in))
+	go func() {
+		defer close(out)
+		for u := range in {
+			out <- t.Apply(u)
+		}
+	}()
+	return out
+}
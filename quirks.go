@@ -0,0 +1,220 @@
+package goscale
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quirks drivers know how to work around. A driver only applies the quirks
+// it knows; others are ignored.
+const (
+	// QuirkResubscribeAfterTare means notifications stop after a tare
+	// until they are enabled again.
+	QuirkResubscribeAfterTare = "resubscribe-after-tare"
+
+	// QuirkReidentifyWhenIdle means the scale must be sent its handshake
+	// again every Duration or it stops streaming.
+	QuirkReidentifyWhenIdle = "reidentify-when-idle"
+)
+
+// Quirk is a known misbehaviour of some scales, and what the driver should
+// do about it. Quirks are data, so new ones can be added with LoadQuirks
+// without changing code, and contributed back to quirks.json.
+type Quirk struct {
+	// Driver is the registered name prefix of the driver the quirk applies
+	// to, e.g. "LUNAR".
+	Driver string `json:"driver"`
+
+	// Firmware limits the quirk to some firmware versions, e.g. "<1.2" or
+	// ">=2.0.1". Empty means every version. A quirk with a constraint still
+	// applies when the firmware version isn't known.
+	Firmware string `json:"firmware,omitempty"`
+
+	// Name is what the quirk is, e.g. QuirkResubscribeAfterTare.
+	Name string `json:"quirk"`
+
+	// Duration parameterises quirks that need a time, e.g. how long the
+	// scale can go without QuirkReidentifyWhenIdle.
+	Duration Duration `json:"duration,omitempty"`
+
+	Note string `json:"note,omitempty"`
+}
+
+// Duration is a time.Duration that is written in JSON as a string such as
+// "30s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Quirks is a set of quirks, as returned by QuirksFor.
+type Quirks []Quirk
+
+// Has reports whether the set includes the named quirk.
+func (qs Quirks) Has(name string) bool {
+	_, ok := qs.Get(name)
+	return ok
+}
+
+// Get returns the named quirk, if the set includes it.
+func (qs Quirks) Get(name string) (Quirk, bool) {
+	for _, q := range qs {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return Quirk{}, false
+}
+
+//go:embed quirks.json
+var builtinQuirks []byte
+
+var (
+	quirks    []Quirk
+	quirkLock sync.RWMutex
+)
+
+func init() {
+	if err := LoadQuirks(bytes.NewReader(builtinQuirks)); err != nil {
+		panic(fmt.Sprintf("goscale: invalid built-in quirks: %v", err))
+	}
+}
+
+// LoadQuirks adds the quirks in r, a JSON array of Quirk, to those drivers
+// apply. Nothing is added if any entry is invalid.
+func LoadQuirks(r io.Reader) error {
+	var qs []Quirk
+	if err := json.NewDecoder(r).Decode(&qs); err != nil {
+		return fmt.Errorf("failed to decode quirks: %w", err)
+	}
+	for i, q := range qs {
+		if q.Driver == "" || q.Name == "" {
+			return fmt.Errorf("quirk %d: driver and quirk are required", i)
+		}
+		if _, _, err := parseFirmwareConstraint(q.Firmware); err != nil {
+			return fmt.Errorf("quirk %d: %w", i, err)
+		}
+	}
+
+	quirkLock.Lock()
+	defer quirkLock.Unlock()
+	quirks = append(quirks, qs...)
+	return nil
+}
+
+// LoadQuirksFile is LoadQuirks reading from a file.
+func LoadQuirksFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return LoadQuirks(f)
+}
+
+// QuirksFor returns the quirks that apply to a driver's scales running the
+// given firmware version. Pass an empty firmware if it isn't known.
+func QuirksFor(driver, firmware string) Quirks {
+	quirkLock.RLock()
+	defer quirkLock.RUnlock()
+
+	var qs Quirks
+	for _, q := range quirks {
+		if q.Driver != driver {
+			continue
+		}
+		if firmware != "" && q.Firmware != "" {
+			op, want, _ := parseFirmwareConstraint(q.Firmware)
+			have, err := parseVersion(firmware)
+			if err == nil && !op(compareVersions(have, want)) {
+				continue
+			}
+		}
+		qs = append(qs, q)
+	}
+	return qs
+}
+
+// parseFirmwareConstraint parses a constraint such as "<1.2" into a test of
+// the result of comparing a version with it, and the version to compare.
+func parseFirmwareConstraint(c string) (func(int) bool, []int, error) {
+	if c == "" {
+		return func(int) bool { return true }, nil, nil
+	}
+	ops := []struct {
+		prefix string
+		test   func(int) bool
+	}{
+		{"<=", func(c int) bool { return c <= 0 }},
+		{">=", func(c int) bool { return c >= 0 }},
+		{"<", func(c int) bool { return c < 0 }},
+		{">", func(c int) bool { return c > 0 }},
+		{"=", func(c int) bool { return c == 0 }},
+	}
+	for _, op := range ops {
+		if rest, ok := strings.CutPrefix(c, op.prefix); ok {
+			v, err := parseVersion(rest)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid firmware constraint %q: %w", c, err)
+			}
+			return op.test, v, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("invalid firmware constraint %q: must start with <, <=, >, >= or =", c)
+}
+
+// parseVersion parses a dotted version such as "1.2.3".
+func parseVersion(s string) ([]int, error) {
+	var v []int
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		v = append(v, n)
+	}
+	return v, nil
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, the same as or
+// newer than b. Missing parts count as zero, so 1.2 is the same as 1.2.0.
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package goscale
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a command is refused because the same
+// command was sent too recently.
+var ErrRateLimited = errors.New("command rate limited")
+
+// Names of the commands drivers pass through a CommandLimiter, for use with
+// CommandLimiter.SetInterval.
+const (
+	CommandTare         = "tare"
+	CommandBeep         = "beep"
+	CommandSleepTimeout = "sleep-timeout"
+	CommandKeyLock      = "key-lock"
+	CommandTimer        = "timer"
+	CommandUnit         = "unit"
+	CommandSleep        = "sleep"
+	CommandLight        = "light"
+	CommandFlowWindow   = "flow-rate-window"
+)
+
+// CommandLimiter stops commands being sent to a scale faster than its
+// firmware can cope with, e.g. from rapid tare taps. Each command name is
+// limited separately. A nil *CommandLimiter lets everything through. It is
+// safe for concurrent use.
+type CommandLimiter struct {
+	mu        sync.Mutex
+	interval  time.Duration
+	intervals map[string]time.Duration
+	coalesce  bool
+	last      map[string]time.Time
+}
+
+// NewCommandLimiter returns a limiter allowing each command at most once per
+// interval. With coalesce set, a command arriving too soon is dropped and
+// reported as sent, on the basis that it duplicates the one just sent;
+// otherwise it fails with ErrRateLimited.
+func NewCommandLimiter(interval time.Duration, coalesce bool) *CommandLimiter {
+	return &CommandLimiter{
+		interval:  interval,
+		intervals: make(map[string]time.Duration),
+		coalesce:  coalesce,
+		last:      make(map[string]time.Time),
+	}
+}
+
+// SetInterval overrides the interval for one command.
+func (l *CommandLimiter) SetInterval(command string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.intervals[command] = d
+}
+
+// Do calls send unless command was sent within its interval.
+func (l *CommandLimiter) Do(command string, send func() error) error {
+	if l == nil {
+		return send()
+	}
+
+	l.mu.Lock()
+	interval, ok := l.intervals[command]
+	if !ok {
+		interval = l.interval
+	}
+	now := time.Now()
+	if last, ok := l.last[command]; ok && now.Sub(last) < interval {
+		l.mu.Unlock()
+		if l.coalesce {
+			return nil
+		}
+		return ErrRateLimited
+	}
+	l.last[command] = now
+	l.mu.Unlock()
+
+	return send()
+}
+
+// CommandLimited is implemented by drivers that can rate limit the commands
+// they send.
+type CommandLimited interface {
+	// SetCommandLimiter sets the limiter used for commands. Nil, the
+	// default, turns limiting off.
+	SetCommandLimiter(l *CommandLimiter)
+}
+
+// SetCommandLimiter sets the command limiter of s, or returns
+// ErrNotSupported.
+func SetCommandLimiter(s Scale, l *CommandLimiter) error {
+	if cl, ok := As[CommandLimited](s); ok {
+		cl.SetCommandLimiter(l)
+		return nil
+	}
+	return ErrNotSupported
+}
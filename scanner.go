@@ -1,6 +1,7 @@
 package goscale
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -18,8 +19,89 @@ type FoundDevice struct {
 	Name    string
 	Address bluetooth.Address
 	RSSI    int
+
+	// ServiceUUIDs lists the registered drivers' service UUIDs (see
+	// DriverOptions) present in the device's advertisement.
+	ServiceUUIDs []bluetooth.UUID
+
+	// ManufacturerData is the device's advertised manufacturer data, keyed
+	// by company ID, kept so NewScaleForDevice can try drivers' Matchers.
+	ManufacturerData map[uint16][]byte
+
+	// Category is the kind of device its name, or a Matcher, registered it
+	// as.
+	Category Category
+}
+
+// ScanResult is what a device advertised, as passed to a Matcher.
+type ScanResult struct {
+	Name    string // Empty if it advertised none
+	Address bluetooth.Address
+	RSSI    int
+
+	// ServiceUUIDs lists the registered drivers' service UUIDs (see
+	// DriverOptions) present in the advertisement.
+	ServiceUUIDs []bluetooth.UUID
+
+	// ManufacturerData is the advertised manufacturer data, keyed by
+	// company ID.
+	ManufacturerData map[uint16][]byte
+}
+
+// newScanResult captures what result advertised, recording which of the
+// candidate service UUIDs it carries. The advertisement payload is only
+// valid during the host adapter's scan callback, so this has to be done
+// there.
+func newScanResult(result bluetooth.ScanResult, candidates []bluetooth.UUID) ScanResult {
+	r := ScanResult{
+		Name:         result.LocalName(),
+		Address:      result.Address,
+		RSSI:         int(result.RSSI),
+		ServiceUUIDs: advertisedServices(result, candidates),
+	}
+	for _, m := range result.ManufacturerData() {
+		if r.ManufacturerData == nil {
+			r.ManufacturerData = make(map[uint16][]byte)
+		}
+		r.ManufacturerData[m.CompanyID] = bytes.Clone(m.Data)
+	}
+	return r
+}
+
+// foundDevice returns the FoundDevice for r, of the given category.
+func (r ScanResult) foundDevice(category Category) FoundDevice {
+	return FoundDevice{
+		Name:             r.Name,
+		Address:          r.Address,
+		RSSI:             r.RSSI,
+		ServiceUUIDs:     r.ServiceUUIDs,
+		ManufacturerData: r.ManufacturerData,
+		Category:         category,
+	}
 }
 
+// scanResult returns what d was seen advertising.
+func (d *FoundDevice) scanResult() ScanResult {
+	return ScanResult{
+		Name:             d.Name,
+		Address:          d.Address,
+		RSSI:             d.RSSI,
+		ServiceUUIDs:     d.ServiceUUIDs,
+		ManufacturerData: d.ManufacturerData,
+	}
+}
+
+// ID returns a stable identifier for the device: its Bluetooth address, or
+// its name when no address is known (e.g. mock devices).
+func (d *FoundDevice) ID() string {
+	if d.Address == (bluetooth.Address{}) {
+		return d.Name
+	}
+	return d.Address.String()
+}
+
+// BTAdapter is the host's Bluetooth adapter, which goscale scans and
+// connects through unless SetAdapter installs another.
 var BTAdapter = bluetooth.DefaultAdapter
 
 // ScanForOne scans until the first registered scale name is found
@@ -35,30 +117,19 @@ func ScanForOne(duration time.Duration) (*FoundDevice, error) {
 	var found FoundDevice
 	prefixesToScan := getRegisteredPrefixes()
 
-	if len(prefixesToScan) == 0 {
+	if !categoryRegistered(CategoryScale) {
 		return nil, errors.New("scan warning: no implementations registered")
 	}
 	log.Printf("Scanning for devices with prefixes: %v.", prefixesToScan)
 
-	handler := func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
-		name := result.LocalName()
-
-		if name == "" {
-			return // Ignore packets without a name.
+	handler := func(r ScanResult) {
+		if reg, ok := matchScanResult(r); !ok || reg.category != CategoryScale {
+			return // Ignore devices that aren't registered scales.
 		}
 
-		for _, prefix := range prefixesToScan {
-			if strings.HasPrefix(name, prefix) {
-				log.Printf("    --> Found a match! Device: %s", name)
-				found = FoundDevice{
-					Name:    name,
-					Address: result.Address,
-					RSSI:    int(result.RSSI),
-				}
-				cancel()
-				break
-			}
-		}
+		log.Printf("    --> Found a match! Device: %s (%s)", r.Name, r.Address.String())
+		found = r.foundDevice(CategoryScale)
+		cancel()
 	}
 
 	var wg sync.WaitGroup
@@ -68,7 +139,7 @@ func ScanForOne(duration time.Duration) (*FoundDevice, error) {
 	go func() {
 		defer wg.Done()
 		log.Println("Starting a blocking scan...")
-		err := BTAdapter.Scan(handler)
+		err := ActiveAdapter().Scan(handler)
 		if err != nil {
 			scanErrChan <- err
 			// Wake the main goroutine immediately rather than waiting for
@@ -81,7 +152,7 @@ func ScanForOne(duration time.Duration) (*FoundDevice, error) {
 	<-ctx.Done()
 
 	log.Println("Stopping scan...")
-	err = BTAdapter.StopScan()
+	err = ActiveAdapter().StopScan()
 	if err != nil {
 		log.Printf("Warning: failed to stop scan cleanly: %v", err)
 	}
@@ -90,7 +161,7 @@ func ScanForOne(duration time.Duration) (*FoundDevice, error) {
 	close(scanErrChan)
 
 	if scanErr := <-scanErrChan; scanErr != nil {
-		return nil, scanErr
+		return nil, adapterError("scan", scanErr)
 	}
 
 	if err := ctx.Err(); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
@@ -114,34 +185,23 @@ func Scan(duration time.Duration) ([]FoundDevice, error) {
 	foundDevices := make(map[string]FoundDevice)
 	prefixesToScan := getRegisteredPrefixes()
 
-	if len(prefixesToScan) == 0 {
+	if !categoryRegistered(CategoryScale) {
 		return nil, errors.New("scan warning: no implementations registered")
 	}
 	log.Printf("Scanning for devices with prefixes: %v.", prefixesToScan)
 
-	handler := func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
-		name := result.LocalName()
-
-		if name == "" {
-			return // Ignore packets without a name.
+	handler := func(r ScanResult) {
+		if reg, ok := matchScanResult(r); !ok || reg.category != CategoryScale {
+			return // Ignore devices that aren't registered scales.
 		}
 
-		for _, prefix := range prefixesToScan {
-			if strings.HasPrefix(name, prefix) {
-				id := result.Address.String()
-				mu.Lock()
-				if _, exists := foundDevices[id]; !exists {
-					log.Printf("    --> Found a match! Device: %s", name)
-					foundDevices[id] = FoundDevice{
-						Name:    name,
-						Address: result.Address,
-						RSSI:    int(result.RSSI),
-					}
-				}
-				mu.Unlock()
-				break
-			}
+		id := r.Address.String()
+		mu.Lock()
+		if _, exists := foundDevices[id]; !exists {
+			log.Printf("    --> Found a match! Device: %s (%s)", r.Name, id)
+			foundDevices[id] = r.foundDevice(CategoryScale)
 		}
+		mu.Unlock()
 	}
 
 	var wg sync.WaitGroup
@@ -151,7 +211,7 @@ func Scan(duration time.Duration) ([]FoundDevice, error) {
 	go func() {
 		defer wg.Done()
 		log.Println("Starting a blocking scan...")
-		err := BTAdapter.Scan(handler)
+		err := ActiveAdapter().Scan(handler)
 		if err != nil {
 			scanErrChan <- err
 			// Wake the main goroutine immediately rather than waiting for
@@ -164,7 +224,7 @@ func Scan(duration time.Duration) ([]FoundDevice, error) {
 	<-ctx.Done()
 
 	log.Println("Timeout reached. Stopping scan...")
-	err = BTAdapter.StopScan()
+	err = ActiveAdapter().StopScan()
 	if err != nil {
 		log.Printf("Warning: failed to stop scan cleanly: %v", err)
 	}
@@ -173,7 +233,7 @@ func Scan(duration time.Duration) ([]FoundDevice, error) {
 	close(scanErrChan)
 
 	if scanErr := <-scanErrChan; scanErr != nil {
-		return nil, scanErr
+		return nil, adapterError("scan", scanErr)
 	}
 
 	if err := ctx.Err(); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
@@ -189,6 +249,116 @@ func Scan(duration time.Duration) ([]FoundDevice, error) {
 	return results, nil
 }
 
+// ScanStreamRefresh is how often ScanStream re-sends a device it has
+// already reported, with its latest RSSI.
+const ScanStreamRefresh = time.Second
+
+// ScanStream scans until ctx is done, sending registered devices as they are
+// found, so a UI can list them live rather than waiting out a fixed scan.
+// Each device is sent when first seen and again, at most every
+// ScanStreamRefresh, while its RSSI changes; use FoundDevice.ID to tell
+// repeats apart. The channel is closed once the scan has stopped. Devices
+// are dropped rather than stall the scan if the receiver falls behind.
+func ScanStream(ctx context.Context) (<-chan FoundDevice, error) {
+	return ScanStreamCategories(ctx, CategoryScale)
+}
+
+// ScanStreamCategories is ScanStream for registered devices of the given
+// categories rather than just scales.
+func ScanStreamCategories(ctx context.Context, categories ...Category) (<-chan FoundDevice, error) {
+	if !categoryRegistered(categories...) {
+		return nil, fmt.Errorf("scan warning: no %v implementations registered", categories)
+	}
+	return scanStream(ctx, categoryPrefixes(categories...), func(r ScanResult) (Category, bool) {
+		reg, ok := matchScanResult(r)
+		return reg.category, ok && slices.Contains(categories, reg.category)
+	})
+}
+
+// ScanStreamPrefixes is ScanStream for devices whose names start with one
+// of prefixes rather than a registered scale's, e.g. to find other
+// Bluetooth devices used alongside a scale. With no prefixes it scans for
+// registered scales.
+func ScanStreamPrefixes(ctx context.Context, prefixes ...string) (<-chan FoundDevice, error) {
+	if len(prefixes) == 0 {
+		return ScanStream(ctx)
+	}
+	return scanStream(ctx, prefixes, func(r ScanResult) (Category, bool) {
+		if r.Name == "" || !slices.ContainsFunc(prefixes, func(p string) bool { return strings.HasPrefix(r.Name, p) }) {
+			return 0, false
+		}
+		return categoryOf(r.Name), true
+	})
+}
+
+// scanStream streams the devices match accepts, with the category it gives
+// them. prefixes are only logged.
+func scanStream(ctx context.Context, prefixes []string, match func(ScanResult) (Category, bool)) (<-chan FoundDevice, error) {
+	if err := TryEnableAdapter(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Streaming scan for devices with prefixes: %v.", prefixes)
+
+	type seen struct {
+		rssi int
+		sent time.Time
+	}
+	out := make(chan FoundDevice, 16)
+	var mu sync.Mutex
+	lastSeen := make(map[string]seen)
+	stopped := false
+
+	handler := func(r ScanResult) {
+		category, ok := match(r)
+		if !ok {
+			return
+		}
+		id := r.Address.String()
+		now := time.Now()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		if prev, ok := lastSeen[id]; ok && (prev.rssi == r.RSSI || now.Sub(prev.sent) < ScanStreamRefresh) {
+			return
+		}
+		lastSeen[id] = seen{rssi: r.RSSI, sent: now}
+		select {
+		case out <- r.foundDevice(category):
+		default:
+		}
+	}
+
+	scanDone := make(chan struct{})
+	go func() {
+		defer close(scanDone)
+		if err := ActiveAdapter().Scan(handler); err != nil {
+			log.Printf("Scan stream failed: %v", adapterError("scan", err))
+		}
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := ActiveAdapter().StopScan(); err != nil {
+				log.Printf("Warning: failed to stop scan cleanly: %v", err)
+			}
+			<-scanDone
+		case <-scanDone:
+		}
+
+		mu.Lock()
+		stopped = true
+		close(out)
+		mu.Unlock()
+	}()
+
+	return out, nil
+}
+
 // ScanAndConnect scans for any registered scale, looks up the matching
 // implementation, and connects. Returns the live Scale and its weight-update
 // channel on success. Equivalent to ScanForOne + NewScaleForDevice +
@@ -216,28 +386,35 @@ func ScanAndConnect(scanTimeout time.Duration) (Scale, <-chan WeightUpdate, erro
 	return s, updates, nil
 }
 
+// TryEnableAdapter enables the Bluetooth adapter, if it isn't already. A
+// failure is returned as an *AdapterError, with a hint at how to fix it.
 func TryEnableAdapter() error {
-	log.Println("Enabling Bluetooth BTAdapter...")
-	err := BTAdapter.Enable()
+	log.Println("Enabling Bluetooth adapter...")
+	err := ActiveAdapter().Enable()
 	if err == nil || strings.Contains(err.Error(), "already calling Enable") {
 		return nil
 	}
-	return err
+	return adapterError("enable", err)
+}
+
+// advertisedServices returns which of the candidate service UUIDs the scan
+// result advertises. The advertisement payload is only valid during the scan
+// callback, so this has to be captured there.
+func advertisedServices(result bluetooth.ScanResult, candidates []bluetooth.UUID) []bluetooth.UUID {
+	var found []bluetooth.UUID
+	for _, u := range candidates {
+		if result.HasServiceUUID(u) {
+			found = append(found, u)
+		}
+	}
+	return found
 }
 
 // getRegisteredPrefixes helper function
-// optional customPrefixes allow one to provide prefixes in addition to registered scale prefixes
+// optional customPrefixes allow one to provide prefixes instead of the registered scale prefixes
 func getRegisteredPrefixes(customPrefixes ...string) []string {
 	if len(customPrefixes) > 0 {
 		return customPrefixes
 	}
-	regLock.RLock()
-	defer regLock.RUnlock()
-	keys := make([]string, 0, len(registry))
-	for k := range registry {
-		if !slices.Contains(keys, k) {
-			keys = append(keys, k)
-		}
-	}
-	return keys
+	return categoryPrefixes(CategoryScale)
 }
@@ -0,0 +1,115 @@
+package goscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// scanResultsVersion is the current format of exported scan results.
+const scanResultsVersion = 1
+
+// ScanRecord is the saved form of a FoundDevice.
+type ScanRecord struct {
+	Name         string   `json:"name"`
+	Address      string   `json:"address,omitempty"`
+	RSSI         int      `json:"rssi"`
+	ServiceUUIDs []string `json:"service_uuids,omitempty"`
+
+	// Driver is the registered name prefix the device matched when it was
+	// exported, or empty if none did.
+	Driver string `json:"driver,omitempty"`
+}
+
+// NewScanRecord returns the saved form of d.
+func NewScanRecord(d FoundDevice) ScanRecord {
+	rec := ScanRecord{
+		Name:   d.Name,
+		RSSI:   d.RSSI,
+		Driver: MatchDeviceDriver(&d),
+	}
+	if d.Address != (bluetooth.Address{}) {
+		rec.Address = d.Address.String()
+	}
+	for _, u := range d.ServiceUUIDs {
+		rec.ServiceUUIDs = append(rec.ServiceUUIDs, u.String())
+	}
+	return rec
+}
+
+type scanResultsFile struct {
+	Version  int          `json:"version"`
+	Exported time.Time    `json:"exported"`
+	Devices  []ScanRecord `json:"devices"`
+}
+
+// ExportScanResults writes devices to w as JSON, along with the driver each
+// matched, so a scan run on-site can be used to provision a headless host
+// with exact addresses instead of it having to scan again. Read the results
+// back with ImportScanResults.
+//
+// Addresses are platform specific: MAC addresses on Linux and Windows, but
+// per-host UUIDs on macOS, so results exported on a Mac are only useful to
+// that Mac.
+func ExportScanResults(w io.Writer, devices []FoundDevice) error {
+	f := scanResultsFile{
+		Version:  scanResultsVersion,
+		Exported: time.Now().UTC(),
+		Devices:  make([]ScanRecord, 0, len(devices)),
+	}
+	for _, d := range devices {
+		f.Devices = append(f.Devices, NewScanRecord(d))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// ImportScanResults reads scan results written by ExportScanResults. The
+// devices returned can be passed straight to NewScaleForDevice.
+func ImportScanResults(r io.Reader) ([]FoundDevice, error) {
+	var f scanResultsFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to decode scan results: %w", err)
+	}
+	if f.Version != scanResultsVersion {
+		return nil, fmt.Errorf("unsupported scan results version %d", f.Version)
+	}
+
+	devices := make([]FoundDevice, 0, len(f.Devices))
+	for i, rec := range f.Devices {
+		if rec.Driver != "" && !matchOnlyDriver(rec.Driver) && !strings.HasPrefix(rec.Name, rec.Driver) {
+			return nil, fmt.Errorf("scan result %d: name '%s' does not match driver '%s'", i, rec.Name, rec.Driver)
+		}
+		d, err := rec.FoundDevice()
+		if err != nil {
+			return nil, fmt.Errorf("scan result %d: %w", i, err)
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// FoundDevice returns the FoundDevice r was saved from.
+func (r ScanRecord) FoundDevice() (FoundDevice, error) {
+	d := FoundDevice{Name: r.Name, RSSI: r.RSSI}
+	if r.Address != "" {
+		d.Address.Set(r.Address)
+		if d.Address == (bluetooth.Address{}) {
+			return FoundDevice{}, fmt.Errorf("invalid address '%s'", r.Address)
+		}
+	}
+	for _, s := range r.ServiceUUIDs {
+		u, err := bluetooth.ParseUUID(s)
+		if err != nil {
+			return FoundDevice{}, fmt.Errorf("invalid service UUID '%s': %w", s, err)
+		}
+		d.ServiceUUIDs = append(d.ServiceUUIDs, u)
+	}
+	return d, nil
+}
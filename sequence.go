@@ -0,0 +1,45 @@
+package goscale
+
+// GapDetector watches the Seq numbers of a weight update stream and reports
+// updates that went missing between the driver and the consumer, e.g. ones
+// dropped by an overflowing buffer or coalesced away by Throttle.
+//
+// The zero value is ready to use. A GapDetector is not safe for concurrent
+// use; give each consumer its own.
+type GapDetector struct {
+	last    uint64
+	started bool
+	missed  uint64
+}
+
+// Observe records u and returns how many updates were skipped between the
+// previously observed update and u. Updates without a sequence number are
+// ignored. A sequence number at or below the last one seen means the stream
+// restarted (e.g. after a reconnect), so tracking begins again from u.
+func (g *GapDetector) Observe(u WeightUpdate) uint64 {
+	if u.Seq == 0 {
+		return 0
+	}
+
+	if !g.started || u.Seq <= g.last {
+		g.started = true
+		g.last = u.Seq
+		return 0
+	}
+
+	gap := u.Seq - g.last - 1
+	g.last = u.Seq
+	g.missed += gap
+	return gap
+}
+
+// Missed returns the total number of updates skipped across all
+// observations.
+func (g *GapDetector) Missed() uint64 {
+	return g.missed
+}
+
+// Reset forgets all previously observed updates.
+func (g *GapDetector) Reset() {
+	*g = GapDetector{}
+}
@@ -0,0 +1,51 @@
+package goscale
+
+import (
+	"cmp"
+	"slices"
+)
+
+// SettingCycle is the ordered set of values a scale setting can take, for
+// drivers whose Advance methods step through them the way the scale's own
+// buttons do. It is immutable, so safe for concurrent use.
+type SettingCycle[T cmp.Ordered] struct {
+	values []T
+}
+
+// NewSettingCycle returns a cycle through values, in ascending order.
+// Duplicates are dropped.
+func NewSettingCycle[T cmp.Ordered](values ...T) *SettingCycle[T] {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return &SettingCycle[T]{values: slices.Compact(sorted)}
+}
+
+// Next returns the first value greater than current, wrapping around to the
+// lowest once current is at or past the highest. current need not be in the
+// cycle, so a value reported by the scale that doesn't match a known setting
+// still advances sensibly.
+func (c *SettingCycle[T]) Next(current T) T {
+	var zero T
+	if len(c.values) == 0 {
+		return zero
+	}
+	i, found := slices.BinarySearch(c.values, current)
+	if found {
+		i++
+	}
+	if i >= len(c.values) {
+		return c.values[0]
+	}
+	return c.values[i]
+}
+
+// Contains reports whether v is one of the values in the cycle.
+func (c *SettingCycle[T]) Contains(v T) bool {
+	_, found := slices.BinarySearch(c.values, v)
+	return found
+}
+
+// Values returns the values in the cycle, in ascending order.
+func (c *SettingCycle[T]) Values() []T {
+	return slices.Clone(c.values)
+}
@@ -0,0 +1,162 @@
+package goscale
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SettingKey names a scale setting in the generic settings API; see
+// Configurable. Each key's value has one type, given with the key below.
+type SettingKey uint8
+
+const (
+	SettingBeep       SettingKey = iota // bool
+	SettingAutoOff                      // SleepTimeout
+	SettingKeyLock                      // time.Duration after the last key press; zero when unlocked
+	SettingResolution                   // Resolution
+	SettingCapacity                     // float64, in grams
+	SettingUnit                         // string: UnitGrams or UnitOunces
+)
+
+// SettingKeys lists every key, in the order a settings UI might show them.
+var SettingKeys = []SettingKey{
+	SettingBeep, SettingAutoOff, SettingKeyLock, SettingResolution, SettingCapacity, SettingUnit,
+}
+
+var settingKeyNames = map[SettingKey]string{
+	SettingBeep:       "beep",
+	SettingAutoOff:    "auto_off",
+	SettingKeyLock:    "key_lock",
+	SettingResolution: "resolution",
+	SettingCapacity:   "capacity",
+	SettingUnit:       "unit",
+}
+
+func (k SettingKey) String() string {
+	if name, ok := settingKeyNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown Setting (%d)", k)
+}
+
+// ParseSettingKey returns the key named name, as returned by String.
+func ParseSettingKey(name string) (SettingKey, error) {
+	for k, n := range settingKeyNames {
+		if strings.EqualFold(n, name) {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown setting %q", name)
+}
+
+// MarshalText encodes the key by name, so settings maps marshal to JSON
+// objects keyed by name.
+func (k SettingKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *SettingKey) UnmarshalText(text []byte) error {
+	key, err := ParseSettingKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = key
+	return nil
+}
+
+// Resolution is how finely a scale displays weight.
+type Resolution uint8
+
+const (
+	ResolutionLow  Resolution = iota // e.g. 0.1g steps
+	ResolutionHigh                   // e.g. 0.01g steps
+)
+
+func (r Resolution) String() string {
+	switch r {
+	case ResolutionLow:
+		return "Low"
+	case ResolutionHigh:
+		return "High"
+	default:
+		return fmt.Sprintf("Unknown Setting (%d)", r)
+	}
+}
+
+// Configurable is implemented by scales whose settings can be read and set
+// through one generic API, so applications can build a settings UI without
+// knowing each scale's setters.
+type Configurable interface {
+	// Settings returns the scale's current settings, as of its last status
+	// report, keyed by SettingKey. Only the settings the scale has are
+	// present.
+	Settings() (map[SettingKey]any, error)
+
+	// SetSetting changes one setting. value must be of the key's type,
+	// though a time.Duration is accepted for a SleepTimeout. A setting the
+	// scale doesn't have, or can't change, returns an error matching
+	// ErrNotSupported; a value of the wrong type, a *SettingValueError.
+	SetSetting(key SettingKey, value any) error
+}
+
+// Settings returns the settings of s, or ErrNotSupported.
+func Settings(s Scale) (map[SettingKey]any, error) {
+	if c, ok := As[Configurable](s); ok {
+		return c.Settings()
+	}
+	return nil, ErrNotSupported
+}
+
+// SetSetting changes a setting of s, or returns ErrNotSupported.
+func SetSetting(s Scale, key SettingKey, value any) error {
+	if c, ok := As[Configurable](s); ok {
+		return c.SetSetting(key, value)
+	}
+	return ErrNotSupported
+}
+
+// Setting returns one setting of s as a T, which must be the key's type.
+func Setting[T any](s Scale, key SettingKey) (T, error) {
+	var zero T
+	settings, err := Settings(s)
+	if err != nil {
+		return zero, err
+	}
+	v, ok := settings[key]
+	if !ok {
+		return zero, fmt.Errorf("setting %s: %w", key, ErrNotSupported)
+	}
+	return SettingValue[T](key, v)
+}
+
+// SettingValue returns value as a T, for drivers implementing SetSetting,
+// or a *SettingValueError if it isn't one.
+func SettingValue[T any](key SettingKey, value any) (T, error) {
+	if t, ok := value.(T); ok {
+		return t, nil
+	}
+	var zero T
+	if d, ok := value.(time.Duration); ok {
+		if t, ok := any(SleepTimeout(d)).(T); ok {
+			return t, nil
+		}
+	}
+	return zero, &SettingValueError{Key: key, Value: value, Want: fmt.Sprintf("%T", zero)}
+}
+
+// SettingValueError is returned when a setting is given a value of the
+// wrong type.
+type SettingValueError struct {
+	Key   SettingKey
+	Value any
+	Want  string // The type wanted
+}
+
+func (e *SettingValueError) Error() string {
+	return fmt.Sprintf("invalid value %v (%T) for setting %s, want %s", e.Value, e.Value, e.Key, e.Want)
+}
+
+// ErrReadOnlySetting is returned, wrapping ErrNotSupported, when setting
+// something a scale reports but can't change remotely.
+var ErrReadOnlySetting = fmt.Errorf("read-only setting: %w", ErrNotSupported)
@@ -0,0 +1,96 @@
+package goscale
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SleepTimeout is how long a scale sits idle before it goes to sleep or
+// powers down. SleepTimeoutDisabled means it never does.
+type SleepTimeout time.Duration
+
+// SleepTimeoutDisabled turns the sleep timer off.
+const SleepTimeoutDisabled SleepTimeout = 0
+
+// Duration returns t as a time.Duration.
+func (t SleepTimeout) Duration() time.Duration {
+	return time.Duration(t)
+}
+
+// String returns, e.g., "5 Minutes" or "Disabled", as the string getters
+// always have.
+func (t SleepTimeout) String() string {
+	d := time.Duration(t)
+	switch {
+	case t == SleepTimeoutDisabled:
+		return "Disabled"
+	case d == time.Minute:
+		return "1 Minute"
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d Minutes", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
+
+// SleepTimeoutMap translates between a driver's own sleep timer settings,
+// such as an enum or a count of minutes, and SleepTimeouts. It is read-only
+// once made, so it is safe for concurrent use.
+type SleepTimeoutMap[T cmp.Ordered] struct {
+	timeouts map[T]SleepTimeout
+	natives  map[SleepTimeout]T
+	ordered  []SleepTimeout
+}
+
+// NewSleepTimeoutMap returns a map of the given settings. Where several
+// settings share a timeout, such as sleeping or powering off after 5
+// minutes, the lowest is the one SetSleepTimeout picks.
+func NewSleepTimeoutMap[T cmp.Ordered](settings map[T]SleepTimeout) *SleepTimeoutMap[T] {
+	m := &SleepTimeoutMap[T]{
+		timeouts: settings,
+		natives:  make(map[SleepTimeout]T, len(settings)),
+	}
+	for native, t := range settings {
+		if prev, ok := m.natives[t]; !ok || native < prev {
+			m.natives[t] = native
+		}
+	}
+	for t := range m.natives {
+		m.ordered = append(m.ordered, t)
+	}
+	slices.Sort(m.ordered)
+	return m
+}
+
+// Timeout returns the timeout of a driver setting, or false if the setting
+// is unknown.
+func (m *SleepTimeoutMap[T]) Timeout(native T) (SleepTimeout, bool) {
+	t, ok := m.timeouts[native]
+	return t, ok
+}
+
+// Native returns the driver setting for a timeout, or false if the scale
+// has none that matches it exactly.
+func (m *SleepTimeoutMap[T]) Native(t SleepTimeout) (T, bool) {
+	native, ok := m.natives[t]
+	return native, ok
+}
+
+// Timeouts returns the supported timeouts, shortest first, with
+// SleepTimeoutDisabled first if supported.
+func (m *SleepTimeoutMap[T]) Timeouts() []SleepTimeout {
+	return slices.Clone(m.ordered)
+}
+
+// UnsupportedSleepTimeoutError is returned when setting a sleep timeout the
+// scale has no setting for.
+type UnsupportedSleepTimeoutError struct {
+	Timeout   SleepTimeout
+	Supported []SleepTimeout
+}
+
+func (e *UnsupportedSleepTimeoutError) Error() string {
+	return fmt.Sprintf("unsupported sleep timeout %s, want one of %v", e.Timeout, e.Supported)
+}
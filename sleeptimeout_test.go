@@ -0,0 +1,66 @@
+package goscale
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSleepTimeoutString(t *testing.T) {
+	tests := []struct {
+		t    SleepTimeout
+		want string
+	}{
+		{SleepTimeoutDisabled, "Disabled"},
+		{SleepTimeout(time.Minute), "1 Minute"},
+		{SleepTimeout(5 * time.Minute), "5 Minutes"},
+		{SleepTimeout(90 * time.Second), "1m30s"},
+	}
+	for _, tt := range tests {
+		if got := tt.t.String(); got != tt.want {
+			t.Errorf("SleepTimeout(%d).String() = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestSleepTimeoutMap(t *testing.T) {
+	// Settings 1 and 2 both mean 5 minutes, as a sleep and a power-off
+	// setting might.
+	m := NewSleepTimeoutMap(map[uint8]SleepTimeout{
+		0: SleepTimeoutDisabled,
+		2: SleepTimeout(5 * time.Minute),
+		1: SleepTimeout(5 * time.Minute),
+		3: SleepTimeout(30 * time.Minute),
+	})
+
+	want := []SleepTimeout{SleepTimeoutDisabled, SleepTimeout(5 * time.Minute), SleepTimeout(30 * time.Minute)}
+	if got := m.Timeouts(); !slices.Equal(got, want) {
+		t.Errorf("Timeouts() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		timeout SleepTimeout
+		native  uint8
+		ok      bool
+	}{
+		{SleepTimeoutDisabled, 0, true},
+		{SleepTimeout(5 * time.Minute), 1, true}, // The lowest of the two
+		{SleepTimeout(30 * time.Minute), 3, true},
+		{SleepTimeout(10 * time.Minute), 0, false},
+	}
+	for _, tt := range tests {
+		native, ok := m.Native(tt.timeout)
+		if native != tt.native || ok != tt.ok {
+			t.Errorf("Native(%s) = %d, %t, want %d, %t", tt.timeout, native, ok, tt.native, tt.ok)
+		}
+		if !ok {
+			continue
+		}
+		if back, _ := m.Timeout(native); back != tt.timeout {
+			t.Errorf("Timeout(%d) = %s, want %s", native, back, tt.timeout)
+		}
+	}
+	if _, ok := m.Timeout(9); ok {
+		t.Error("Timeout of an unknown setting reported ok")
+	}
+}
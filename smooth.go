@@ -0,0 +1,225 @@
+package goscale
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SmoothingMethod is the filter Smooth runs readings through.
+type SmoothingMethod uint8
+
+const (
+	SmoothNone          SmoothingMethod = iota // Readings pass through unchanged
+	SmoothMovingAverage                        // The mean of the readings over the window
+	SmoothExponential                          // An exponential moving average, with the window as its time constant
+	SmoothMedian                               // The median of the readings over the window, which ignores lone spikes
+)
+
+var smoothingMethodNames = map[SmoothingMethod]string{
+	SmoothNone:          "none",
+	SmoothMovingAverage: "moving average",
+	SmoothExponential:   "exponential",
+	SmoothMedian:        "median",
+}
+
+func (m SmoothingMethod) String() string {
+	if name, ok := smoothingMethodNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown SmoothingMethod (%d)", m)
+}
+
+// SmoothingConfig configures Smooth. Most users want one of the presets,
+// chosen by what the scale is being used for; see SmoothingPreset.
+type SmoothingConfig struct {
+	Method SmoothingMethod
+	Window time.Duration
+
+	// ZeroBand is how close to zero, in grams, a smoothed reading must be
+	// to be reported as exactly zero, so an empty scale doesn't flicker
+	// between -0.1 and 0.1. Zero turns it off.
+	ZeroBand float64
+}
+
+// The smoothing presets, by use case.
+var (
+	// SmoothingEspresso follows a shot closely, taking the edge off the
+	// jitter without hiding the ramp.
+	SmoothingEspresso = SmoothingConfig{Method: SmoothExponential, Window: 300 * time.Millisecond, ZeroBand: 0.1}
+
+	// SmoothingPourOver favours a steady display over a quick one, as pours
+	// are slower and splashier.
+	SmoothingPourOver = SmoothingConfig{Method: SmoothMovingAverage, Window: time.Second, ZeroBand: 0.2}
+
+	// SmoothingDosing settles on a steady figure for weighing beans, with a
+	// tight zero band so the first few beans register.
+	SmoothingDosing = SmoothingConfig{Method: SmoothMovingAverage, Window: 750 * time.Millisecond, ZeroBand: 0.05}
+
+	// SmoothingRaw leaves readings as the scale sent them.
+	SmoothingRaw = SmoothingConfig{}
+)
+
+// SmoothingPresets maps the names accepted by SmoothingPreset to presets.
+var SmoothingPresets = map[string]SmoothingConfig{
+	"espresso": SmoothingEspresso,
+	"pourover": SmoothingPourOver,
+	"dosing":   SmoothingDosing,
+	"raw":      SmoothingRaw,
+}
+
+// SmoothingPreset returns the preset with the given name, e.g. "espresso",
+// ignoring case, so it can be chosen from a flag or config file.
+func SmoothingPreset(name string) (SmoothingConfig, error) {
+	if cfg, ok := SmoothingPresets[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return cfg, nil
+	}
+	names := make([]string, 0, len(SmoothingPresets))
+	for n := range SmoothingPresets {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	return SmoothingConfig{}, fmt.Errorf("unknown smoothing preset %q, want one of %s", name, strings.Join(names, ", "))
+}
+
+// SmoothingConfigurable is implemented by scales that can smooth their own
+// readings, so each connection can have its own filter without a Smooth
+// stage in front of every consumer.
+type SmoothingConfigurable interface {
+	// SetSmoothing sets the filter for this and later connections. The
+	// default is SmoothingRaw.
+	SetSmoothing(cfg SmoothingConfig)
+}
+
+// SetSmoothing sets the filter s runs its readings through, or returns
+// ErrNotSupported.
+func SetSmoothing(s Scale, cfg SmoothingConfig) error {
+	if sc, ok := As[SmoothingConfigurable](s); ok {
+		sc.SetSmoothing(cfg)
+		return nil
+	}
+	return ErrNotSupported
+}
+
+// Smooth passes every update from in through the filter cfg describes,
+// replacing its Value and keeping the value it replaced in Unsmoothed.
+// Filtering is done in each update's own unit, and starts over if the unit
+// changes. Updates carrying an error are passed on untouched. The returned
+// channel is closed when in closes.
+func Smooth(in <-chan WeightUpdate, cfg SmoothingConfig) <-chan WeightUpdate {
+	out := make(chan WeightUpdate, cap(in))
+	go func() {
+		defer close(out)
+		s := newSmoother(cfg)
+		for u := range in {
+			out <- s.smooth(u)
+		}
+	}()
+	return out
+}
+
+// smoother holds a filter's state between readings.
+type smoother struct {
+	cfg    SmoothingConfig
+	unit   string
+	times  []time.Time
+	values []float64
+	ema    float64
+}
+
+func newSmoother(cfg SmoothingConfig) *smoother {
+	return &smoother{cfg: cfg}
+}
+
+// smooth returns u with its value filtered.
+func (s *smoother) smooth(u WeightUpdate) WeightUpdate {
+	if u.Error != nil {
+		return u
+	}
+	unit, err := NormalizeUnit(u.Unit)
+	if err != nil {
+		return u
+	}
+	if unit != s.unit {
+		s.unit = unit
+		s.times, s.values = s.times[:0], s.values[:0]
+	}
+	at := u.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	if s.cfg == (SmoothingConfig{}) {
+		return u
+	}
+	if !u.HasUnsmoothed {
+		u.Unsmoothed, u.HasUnsmoothed = u.Value, true
+	}
+
+	v := u.Value
+	switch s.cfg.Method {
+	case SmoothMovingAverage:
+		v = s.movingAverage(at, v)
+	case SmoothExponential:
+		v = s.exponential(at, v)
+	case SmoothMedian:
+		v = s.median(at, v)
+	}
+
+	if s.cfg.ZeroBand > 0 {
+		if grams, err := ConvertWeight(v, unit, UnitGrams); err == nil && math.Abs(grams) <= s.cfg.ZeroBand {
+			v = 0
+		}
+	}
+	if v != u.Value {
+		u.Value = v
+		// The raw reading no longer adds up to the value.
+		u.Raw, u.RawDivisor, u.HasRaw = 0, 0, false
+	}
+	return u
+}
+
+// window adds a reading and drops those that have fallen out of the window.
+func (s *smoother) window(at time.Time, v float64) {
+	s.times = append(s.times, at)
+	s.values = append(s.values, v)
+	first := 0
+	for first < len(s.times)-1 && at.Sub(s.times[first]) >= s.cfg.Window {
+		first++
+	}
+	s.times, s.values = s.times[first:], s.values[first:]
+}
+
+func (s *smoother) movingAverage(at time.Time, v float64) float64 {
+	s.window(at, v)
+	var sum float64
+	for _, x := range s.values {
+		sum += x
+	}
+	return sum / float64(len(s.values))
+}
+
+func (s *smoother) median(at time.Time, v float64) float64 {
+	s.window(at, v)
+	sorted := slices.Sorted(slices.Values(s.values))
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+func (s *smoother) exponential(at time.Time, v float64) float64 {
+	if len(s.times) == 0 || s.cfg.Window <= 0 {
+		s.times = append(s.times[:0], at)
+		s.ema = v
+		return v
+	}
+	dt := at.Sub(s.times[0])
+	s.times[0] = at
+	alpha := 1 - math.Exp(-dt.Seconds()/s.cfg.Window.Seconds())
+	s.ema += alpha * (v - s.ema)
+	return s.ema
+}
@@ -0,0 +1,118 @@
+package goscale
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrUpdatesClosed is returned by CaptureStable if the update stream ends
+// before the weight settles, usually because the scale disconnected.
+var ErrUpdatesClosed = errors.New("weight updates ended")
+
+// StableConfig defines when a weight counts as settled.
+type StableConfig struct {
+	// Window is how long readings must stay within Tolerance.
+	Window time.Duration
+
+	// Tolerance is how many grams readings may wander by over the window.
+	Tolerance float64
+
+	// MinWeight is the least weight, in grams, that counts, so an empty
+	// scale settling at zero isn't captured.
+	MinWeight float64
+}
+
+// DefaultStableConfig suits weighing beans or a cup by hand.
+var DefaultStableConfig = StableConfig{
+	Window:    time.Second,
+	Tolerance: 0.2,
+}
+
+// StabilityDetector reports when a weight has settled. Readings are
+// compared in grams, whatever unit they arrive in. It is not safe for
+// concurrent use.
+type StabilityDetector struct {
+	cfg    StableConfig
+	times  []time.Time
+	values []float64
+}
+
+// NewStabilityDetector returns a StabilityDetector using cfg.
+func NewStabilityDetector(cfg StableConfig) *StabilityDetector {
+	return &StabilityDetector{cfg: cfg}
+}
+
+// Observe adds a reading and reports whether the weight has now been
+// steady for the whole window.
+func (d *StabilityDetector) Observe(u WeightUpdate) bool {
+	if u = ConvertUpdate(u, UnitGrams); u.Error != nil {
+		return false
+	}
+	at := u.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	d.times = append(d.times, at)
+	d.values = append(d.values, u.Value)
+
+	// Keep only what's needed to cover the window: the newest reading at
+	// least a window old, and everything after it.
+	first := 0
+	for i := range d.times {
+		if at.Sub(d.times[i]) >= d.cfg.Window {
+			first = i
+		}
+	}
+	d.times, d.values = d.times[first:], d.values[first:]
+
+	if at.Sub(d.times[0]) < d.cfg.Window {
+		return false
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range d.values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return hi-lo <= d.cfg.Tolerance && lo >= d.cfg.MinWeight
+}
+
+// Mean returns the mean of the readings in the current window, in grams.
+func (d *StabilityDetector) Mean() float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range d.values {
+		sum += v
+	}
+	return sum / float64(len(d.values))
+}
+
+// Reset forgets all readings.
+func (d *StabilityDetector) Reset() {
+	d.times, d.values = nil, nil
+}
+
+// CaptureStable reads from in until the weight settles, then returns it:
+// the last update, with its Value replaced by the mean over the window, in
+// grams. It returns ErrUpdatesClosed if in closes first, or ctx's error if
+// ctx is done first.
+func CaptureStable(ctx context.Context, in <-chan WeightUpdate, cfg StableConfig) (WeightUpdate, error) {
+	d := NewStabilityDetector(cfg)
+	for {
+		select {
+		case u, ok := <-in:
+			if !ok {
+				return WeightUpdate{}, ErrUpdatesClosed
+			}
+			if d.Observe(u) {
+				u.Value = d.Mean()
+				u.Unit = UnitGrams
+				return u, nil
+			}
+		case <-ctx.Done():
+			return WeightUpdate{}, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package goscale
+
+import "time"
+
+// Throttle coalesces a weight update stream so that at most one update is
+// delivered per interval, always the most recent one. The input channel is
+// drained eagerly, so a slow consumer of the returned channel (a GUI redraw,
+// a network write) never backs up into the scale's notification handler.
+//
+// An update carrying an Error is held until delivered rather than being
+// overwritten by a later plain reading. The returned channel is closed once
+// the input is closed and any pending update has been delivered.
+func Throttle(in <-chan WeightUpdate, interval time.Duration) <-chan WeightUpdate {
+	out := make(chan WeightUpdate)
+
+	go func() {
+		defer close(out)
+
+		var (
+			pending    WeightUpdate
+			hasPending bool
+			ready      = true
+			wait       <-chan time.Time
+		)
+
+		for {
+			var send chan<- WeightUpdate
+			if hasPending && ready {
+				send = out
+			}
+
+			select {
+			case u, ok := <-in:
+				if !ok {
+					if hasPending {
+						out <- pending
+					}
+					return
+				}
+				if hasPending && pending.Error != nil && u.Error == nil {
+					continue
+				}
+				pending = u
+				hasPending = true
+			case send <- pending:
+				hasPending = false
+				ready = false
+				wait = time.After(interval)
+			case <-wait:
+				ready = true
+				wait = nil
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,81 @@
+package goscale
+
+import "fmt"
+
+// TareOffsetter is implemented by scales that can subtract a fixed weight,
+// such as a container's, from every reading they send. See TareToOffset.
+type TareOffsetter interface {
+	// SetTareOffset sets the grams subtracted from the readings of this
+	// and later connections. Zero removes the offset.
+	SetTareOffset(grams float64)
+
+	// TareOffset returns the grams currently subtracted.
+	TareOffset() float64
+}
+
+// TareOffsetMode is whether TareToOffset zeroes the scale as well.
+type TareOffsetMode uint8
+
+const (
+	// TareOffsetSoftware leaves the scale's own zero alone and only
+	// subtracts the offset, so nothing needs to be taken off the scale.
+	TareOffsetSoftware TareOffsetMode = iota
+	// TareOffsetHardware tares the scale, waiting for it to confirm, then
+	// subtracts the offset from its new zero. Use it with the platform
+	// empty, to clear any drift before the container goes on.
+	TareOffsetHardware
+)
+
+var tareOffsetModeNames = map[TareOffsetMode]string{
+	TareOffsetSoftware: "software",
+	TareOffsetHardware: "hardware",
+}
+
+func (m TareOffsetMode) String() string {
+	if name, ok := tareOffsetModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown TareOffsetMode (%d)", m)
+}
+
+// TareToOffset makes s report weights less grams, such as a known 312 g
+// server, without the server having to be on the scale when it is tared.
+// From then on every update s sends, on this connection and later ones, has
+// the offset subtracted from its Value before smoothing, and records it in
+// WeightUpdate.TareOffset. Zero grams removes the offset. With
+// TareOffsetHardware the scale is tared first, blocking; if that fails the
+// offset is left as it was. It returns ErrNotSupported if s can't offset
+// its readings, or can't tare when asked to.
+func TareToOffset(s Scale, grams float64, mode TareOffsetMode) error {
+	to, ok := As[TareOffsetter](s)
+	if !ok {
+		return ErrNotSupported
+	}
+	if mode == TareOffsetHardware {
+		if !s.GetFeatures().Tare {
+			return ErrNotSupported
+		}
+		if err := s.Tare(true); err != nil {
+			return err
+		}
+	}
+	to.SetTareOffset(grams)
+	return nil
+}
+
+// ApplyTareOffset returns u with grams subtracted from its Value, in u's
+// own unit, and recorded in its TareOffset, for drivers that don't deliver
+// updates through an UpdateStream. Updates carrying an error, or in a unit
+// that can't be converted, are returned unchanged.
+func ApplyTareOffset(u WeightUpdate, grams float64) WeightUpdate {
+	if u.Error != nil || grams == 0 {
+		return u
+	}
+	offset, err := ConvertWeight(grams, UnitGrams, u.Unit)
+	if err != nil {
+		return u
+	}
+	u.Value -= offset
+	u.TareOffset = offset
+	return u
+}
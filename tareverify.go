@@ -0,0 +1,215 @@
+package goscale
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrTareNotConfirmed is returned when a scale doesn't report zero after
+// every tare attempt.
+var ErrTareNotConfirmed = errors.New("tare not confirmed by the scale")
+
+// TareRetryConfig configures how a tare is verified and retried. Some scales
+// occasionally ignore a tare command under load, so rather than writing it
+// once and hoping, the driver watches the readings that follow and sends it
+// again if they don't drop to zero. It also configures when a tare isn't
+// sent at all, as it would change nothing.
+type TareRetryConfig struct {
+	// Attempts is how many times the tare command is sent before giving up.
+	// Zero turns verification off: the command is sent once and not checked.
+	Attempts int
+
+	// Verify is how long to wait after each attempt for a zero reading.
+	Verify time.Duration
+
+	// Deadline bounds all attempts together.
+	Deadline time.Duration
+
+	// Tolerance is how close to zero, in grams, a reading must be to count
+	// as confirming the tare.
+	Tolerance float64
+
+	// Debounce drops a tare requested within this long of the last one
+	// sent, such as from a double-tapped button, reporting it as done.
+	Debounce time.Duration
+
+	// SkipAtZero makes a tare a no-op while the weight is already within
+	// Tolerance of zero, and has been for Verify.
+	SkipAtZero bool
+}
+
+// DefaultTareRetryConfig allows three attempts within two seconds, which is
+// several readings' worth at the rate scales notify, and drops repeated
+// tares within 300 ms.
+var DefaultTareRetryConfig = TareRetryConfig{
+	Attempts:  3,
+	Verify:    500 * time.Millisecond,
+	Deadline:  2 * time.Second,
+	Tolerance: 0.5,
+	Debounce:  300 * time.Millisecond,
+}
+
+// TareRetrier is implemented by scales that verify and retry their tares.
+type TareRetrier interface {
+	SetTareRetry(cfg TareRetryConfig)
+}
+
+// SetTareRetry configures how tares of s are verified, or returns
+// ErrNotSupported.
+func SetTareRetry(s Scale, cfg TareRetryConfig) error {
+	if tr, ok := As[TareRetrier](s); ok {
+		tr.SetTareRetry(cfg)
+		return nil
+	}
+	return ErrNotSupported
+}
+
+// TareVerifier sends tare commands for a driver and confirms them against
+// the readings that follow. The driver passes it every net weight reading
+// through Observe. It is safe for concurrent use.
+type TareVerifier struct {
+	mu       sync.Mutex
+	cfg      TareRetryConfig
+	readings chan float64 // Set while a tare is being verified
+
+	lastSent    time.Time // When a tare was last written
+	lastReading time.Time
+	zeroSince   time.Time // When readings last came within Tolerance of zero; zero if they aren't
+}
+
+// NewTareVerifier returns a TareVerifier using cfg.
+func NewTareVerifier(cfg TareRetryConfig) *TareVerifier {
+	return &TareVerifier{cfg: cfg}
+}
+
+// SetConfig changes the configuration used by later tares.
+func (v *TareVerifier) SetConfig(cfg TareRetryConfig) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.cfg = cfg
+}
+
+// Observe passes a weight reading, in grams, to a tare being verified. It
+// never blocks, so it is safe to call from a notification handler.
+func (v *TareVerifier) Observe(grams float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	v.lastReading = now
+	switch {
+	case math.Abs(grams) > v.cfg.Tolerance:
+		v.zeroSince = time.Time{}
+	case v.zeroSince.IsZero():
+		v.zeroSince = now
+	}
+	if v.readings == nil {
+		return
+	}
+	// Keep only the newest reading if the verifier has fallen behind.
+	select {
+	case <-v.readings:
+	default:
+	}
+	v.readings <- grams
+}
+
+// Tare sends the tare command with write until a reading confirms it, as
+// configured. If blocking, it waits for the outcome, returning
+// ErrTareNotConfirmed if no attempt was confirmed. Otherwise it returns once
+// the first command is written, and verifies in the background, logging a
+// failure. Only one tare is verified at a time; a tare while another is
+// being verified is written once and not checked. A tare that would change
+// nothing, as configured by Debounce and SkipAtZero, isn't written at all.
+func (v *TareVerifier) Tare(blocking bool, write func() error) error {
+	v.mu.Lock()
+	cfg := v.cfg
+	now := time.Now()
+	if v.redundant(now) {
+		v.mu.Unlock()
+		return nil
+	}
+	v.lastSent = now
+	if cfg.Attempts <= 0 || v.readings != nil {
+		v.mu.Unlock()
+		return write()
+	}
+	readings := make(chan float64, 1)
+	v.readings = readings
+	v.mu.Unlock()
+
+	if err := write(); err != nil {
+		v.stop()
+		return err
+	}
+	if !blocking {
+		go func() {
+			if err := v.verify(cfg, readings, write); err != nil {
+				log.Printf("tare: %v", err)
+			}
+		}()
+		return nil
+	}
+	return v.verify(cfg, readings, write)
+}
+
+// verify waits for the tare already written to be confirmed, writing it
+// again as configured.
+func (v *TareVerifier) verify(cfg TareRetryConfig, readings <-chan float64, write func() error) error {
+	defer v.stop()
+
+	deadline := time.NewTimer(cfg.Deadline)
+	defer deadline.Stop()
+
+	for attempt := 1; ; attempt++ {
+		confirmed, expired := waitForZero(cfg, readings, deadline.C)
+		if confirmed {
+			return nil
+		}
+		if expired || attempt >= cfg.Attempts {
+			return fmt.Errorf("%w after %d attempt(s)", ErrTareNotConfirmed, attempt)
+		}
+		if err := write(); err != nil {
+			return fmt.Errorf("could not resend tare: %w", err)
+		}
+	}
+}
+
+// waitForZero waits up to cfg.Verify for a reading within tolerance of zero.
+func waitForZero(cfg TareRetryConfig, readings <-chan float64, deadline <-chan time.Time) (confirmed, expired bool) {
+	timeout := time.NewTimer(cfg.Verify)
+	defer timeout.Stop()
+	for {
+		select {
+		case grams := <-readings:
+			if math.Abs(grams) <= cfg.Tolerance {
+				return true, false
+			}
+		case <-timeout.C:
+			return false, false
+		case <-deadline:
+			return false, true
+		}
+	}
+}
+
+// redundant reports whether a tare requested at now would change nothing:
+// one was just sent, or the scale is already steady at zero. v.mu must be
+// held.
+func (v *TareVerifier) redundant(now time.Time) bool {
+	if v.cfg.Debounce > 0 && !v.lastSent.IsZero() && now.Sub(v.lastSent) < v.cfg.Debounce {
+		return true
+	}
+	// Readings must be current, or the scale may have moved since.
+	return v.cfg.SkipAtZero && !v.zeroSince.IsZero() &&
+		now.Sub(v.zeroSince) >= v.cfg.Verify && now.Sub(v.lastReading) < v.cfg.Verify
+}
+
+func (v *TareVerifier) stop() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.readings = nil
+}
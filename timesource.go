@@ -0,0 +1,66 @@
+package goscale
+
+import (
+	"sync"
+	"time"
+)
+
+// TimeSource decides the timestamp of a weight update.
+type TimeSource interface {
+	Time(u WeightUpdate) time.Time
+}
+
+// HostClock timestamps updates with the host clock: the update's Timestamp,
+// or the current time if it has none.
+type HostClock struct{}
+
+func (HostClock) Time(u WeightUpdate) time.Time {
+	if u.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return u.Timestamp
+}
+
+// ScaleTimerClock derives timestamps from the scale's own timer while it is
+// running, which avoids skew and jumps on hosts with poor clocks, such as
+// single-board computers without an RTC. Once the timer is seen advancing,
+// timestamps are the host time at which the timer was zero plus the timer
+// reading. Whenever the timer is stopped, reset, or not reported, it falls
+// back to the host clock. It is safe for concurrent use.
+type ScaleTimerClock struct {
+	mu        sync.Mutex
+	last      time.Duration
+	hasLast   bool
+	anchor    time.Time
+	hasAnchor bool
+}
+
+// NewScaleTimerClock returns a ScaleTimerClock.
+func NewScaleTimerClock() *ScaleTimerClock {
+	return &ScaleTimerClock{}
+}
+
+func (c *ScaleTimerClock) Time(u WeightUpdate) time.Time {
+	host := HostClock{}.Time(u)
+	if !u.HasScaleTimer {
+		return host
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	running := c.hasLast && u.ScaleTimer > c.last
+	c.last = u.ScaleTimer
+	c.hasLast = true
+
+	if !running {
+		// Anchor afresh once it runs again.
+		c.hasAnchor = false
+		return host
+	}
+	if !c.hasAnchor {
+		c.anchor = host.Add(-u.ScaleTimer)
+		c.hasAnchor = true
+	}
+	return c.anchor.Add(u.ScaleTimer)
+}
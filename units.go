@@ -0,0 +1,138 @@
+package goscale
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Weight units, as found in WeightUpdate.Unit.
+const (
+	UnitGrams  = "g"
+	UnitOunces = "oz"
+)
+
+const gramsPerOunce = 28.349523125
+
+// ErrUnknownUnit is returned when converting from or to a unit goscale
+// doesn't know.
+var ErrUnknownUnit = errors.New("unknown weight unit")
+
+// NormalizeUnit returns the canonical form of a unit name, e.g. UnitGrams
+// for "grams". An empty unit is grams, as drivers that don't report a unit
+// send grams.
+func NormalizeUnit(unit string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "", "g", "gram", "grams":
+		return UnitGrams, nil
+	case "oz", "ounce", "ounces":
+		return UnitOunces, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownUnit, unit)
+}
+
+// ConvertWeight converts value from one unit to another.
+func ConvertWeight(value float64, from, to string) (float64, error) {
+	from, err := NormalizeUnit(from)
+	if err != nil {
+		return 0, err
+	}
+	to, err = NormalizeUnit(to)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case from == to:
+		return value, nil
+	case from == UnitOunces:
+		return value * gramsPerOunce, nil
+	default:
+		return value / gramsPerOunce, nil
+	}
+}
+
+// ConvertUpdate returns u with its value converted to the given unit. Each
+// update is converted according to its own Unit, so a consumer sees one
+// consistent unit even if the scale is switched part way through a session.
+// An update in a unit that can't be converted is returned with Error set.
+// The raw reading is in the scale's unit, so it is dropped if the unit
+// changes.
+func ConvertUpdate(u WeightUpdate, to string) WeightUpdate {
+	if u.Error != nil {
+		return u
+	}
+	v, err := ConvertWeight(u.Value, u.Unit, to)
+	if err != nil {
+		u.Error = err
+		return u
+	}
+	if u.HasFlowRate {
+		u.FlowRate, _ = ConvertWeight(u.FlowRate, u.Unit, to)
+	}
+	if u.HasDelta {
+		u.DeltaSinceLast, _ = ConvertWeight(u.DeltaSinceLast, u.Unit, to)
+	}
+	if u.HasUnsmoothed {
+		u.Unsmoothed, _ = ConvertWeight(u.Unsmoothed, u.Unit, to)
+	}
+	if u.TareOffset != 0 {
+		u.TareOffset, _ = ConvertWeight(u.TareOffset, u.Unit, to)
+	}
+	from, _ := NormalizeUnit(u.Unit)
+	u.Value = v
+	u.Unit, _ = NormalizeUnit(to)
+	if u.Unit != from {
+		u.Raw, u.RawDivisor, u.HasRaw = 0, 0, false
+	}
+	return u
+}
+
+// ConvertUnits converts every update from in to the given unit, so each
+// consumer can pick its own display unit. See ConvertUpdate. The returned
+// channel is closed when in closes.
+func ConvertUnits(in <-chan WeightUpdate, to string) <-chan WeightUpdate {
+	out := make(chan WeightUpdate, cap(in))
+	go func() {
+		defer close(out)
+		for u := range in {
+			out <- ConvertUpdate(u, to)
+		}
+	}()
+	return out
+}
+
+// GramsConfig configures NormalizeToGrams.
+type GramsConfig struct {
+	// SwitchScale allows switching the scale itself to grams when it is
+	// seen reporting another unit, for scales that support it (UnitSetter).
+	// This changes what the scale displays, so only set it with the user's
+	// consent.
+	SwitchScale bool
+}
+
+// NormalizeToGrams converts every update from s's update stream in to grams,
+// so brew math downstream never sees mixed units. With cfg.SwitchScale set,
+// the first time an update arrives in another unit the scale is also asked
+// to switch to grams; updates are converted either way, so it doesn't
+// matter how soon the switch takes effect. The returned channel is closed
+// when in closes.
+func NormalizeToGrams(s Scale, in <-chan WeightUpdate, cfg GramsConfig) <-chan WeightUpdate {
+	out := make(chan WeightUpdate, cap(in))
+	go func() {
+		defer close(out)
+		switched := false
+		for u := range in {
+			if u.Error == nil && !switched && cfg.SwitchScale {
+				if unit, err := NormalizeUnit(u.Unit); err == nil && unit != UnitGrams {
+					switched = true
+					if err := SetUnit(s, UnitGrams); err != nil && !errors.Is(err, ErrNotSupported) {
+						log.Printf("could not switch %s to grams: %v", s.DeviceName(), err)
+					}
+				}
+			}
+			out <- ConvertUpdate(u, UnitGrams)
+		}
+	}()
+	return out
+}
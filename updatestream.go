@@ -0,0 +1,229 @@
+package goscale
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy is what happens to a weight update when the consumer has
+// fallen behind and the update channel is full.
+type OverflowPolicy uint8
+
+const (
+	// OverflowBlock waits for the consumer. Nothing is lost, but the
+	// driver's notification handler, and with it the Bluetooth stack, is
+	// held up until the consumer catches up.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued update to make room,
+	// so the consumer sees the latest readings once it catches up.
+	OverflowDropOldest
+	// OverflowDropNewest discards the new update, keeping those queued.
+	OverflowDropNewest
+)
+
+var overflowPolicyNames = map[OverflowPolicy]string{
+	OverflowBlock:      "block",
+	OverflowDropOldest: "drop oldest",
+	OverflowDropNewest: "drop newest",
+}
+
+func (p OverflowPolicy) String() string {
+	if name, ok := overflowPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown OverflowPolicy (%d)", p)
+}
+
+// OverflowConfigurable is implemented by scales whose update channel can be
+// configured to drop updates rather than stall when the consumer falls
+// behind.
+type OverflowConfigurable interface {
+	// SetOverflowPolicy sets the policy for this and later connections.
+	// The default is OverflowBlock.
+	SetOverflowPolicy(p OverflowPolicy)
+
+	// DroppedUpdates returns how many updates have been dropped since the
+	// scale last connected. Each shows up as a gap in WeightUpdate.Seq.
+	DroppedUpdates() uint64
+}
+
+// SetOverflowPolicy sets the overflow policy of s, or returns
+// ErrNotSupported.
+func SetOverflowPolicy(s Scale, p OverflowPolicy) error {
+	if oc, ok := As[OverflowConfigurable](s); ok {
+		oc.SetOverflowPolicy(p)
+		return nil
+	}
+	return ErrNotSupported
+}
+
+// DroppedUpdates returns how many updates s has dropped since it last
+// connected, or ErrNotSupported.
+func DroppedUpdates(s Scale) (uint64, error) {
+	if oc, ok := As[OverflowConfigurable](s); ok {
+		return oc.DroppedUpdates(), nil
+	}
+	return 0, ErrNotSupported
+}
+
+// UpdateStream helps drivers deliver weight updates from their notification
+// handler. Open it on connect, Send each update, and Close it on
+// disconnect. Send subtracts the offset SetTareOffset sets from each
+// update, smooths it as SetSmoothing says, then fills in its DeltaSinceLast
+// and GramsPerSecondInstant. Unlike sending on a channel directly, it is
+// safe to Close while a Send is waiting on a slow consumer: the Send gives
+// up rather than panicking on the closed channel. What Send does when the
+// channel is full is set by its OverflowPolicy. It is safe for concurrent
+// use.
+type UpdateStream struct {
+	mu     sync.Mutex
+	c      chan WeightUpdate
+	done   chan struct{} // Closed to call off waiting Sends
+	policy OverflowPolicy
+
+	sending sync.RWMutex // Held for reading by each Send
+	dropped atomic.Uint64
+	delta   DeltaTracker // Guarded by mu
+	smooth  *smoother    // Guarded by mu; nil for none
+	offset  float64      // Grams; guarded by mu
+}
+
+// SetPolicy sets what Send does when the channel is full.
+func (s *UpdateStream) SetPolicy(p OverflowPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+}
+
+// SetSmoothing sets the filter Send runs updates through, starting afresh.
+// The zero SmoothingConfig, SmoothingRaw, turns it off.
+func (s *UpdateStream) SetSmoothing(cfg SmoothingConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cfg == (SmoothingConfig{}) {
+		s.smooth = nil
+		return
+	}
+	s.smooth = newSmoother(cfg)
+}
+
+// SetTareOffset sets the grams Send subtracts from each update, for this
+// and later connections (see TareToOffset). Zero removes the offset.
+func (s *UpdateStream) SetTareOffset(grams float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = grams
+}
+
+// TareOffset returns the grams Send subtracts from each update.
+func (s *UpdateStream) TareOffset() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset
+}
+
+// Dropped returns how many updates Send has dropped since Open.
+func (s *UpdateStream) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Open replaces the stream's channel with a new one holding up to size
+// updates, closing the old, and returns it.
+func (s *UpdateStream) Open(size int) <-chan WeightUpdate {
+	s.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.c = make(chan WeightUpdate, size)
+	s.done = make(chan struct{})
+	s.dropped.Store(0)
+	s.delta.Reset()
+	if s.smooth != nil {
+		s.smooth = newSmoother(s.smooth.cfg)
+	}
+	return s.c
+}
+
+// Close closes the stream's channel, once any Sends under way have given
+// up. Closing a closed stream does nothing.
+func (s *UpdateStream) Close() {
+	s.mu.Lock()
+	c, done := s.c, s.done
+	s.c, s.done = nil, nil
+	s.mu.Unlock()
+	if c == nil {
+		return
+	}
+	close(done)
+	s.sending.Lock()
+	close(c)
+	s.sending.Unlock()
+}
+
+// Send sends u, reporting whether it was queued. If the channel is full it
+// waits for room, or drops an update, as the OverflowPolicy says. It
+// reports false, having sent nothing, if the stream is closed before or
+// while it waits.
+func (s *UpdateStream) Send(u WeightUpdate) bool {
+	s.sending.RLock()
+	defer s.sending.RUnlock()
+	s.mu.Lock()
+	c, done, policy := s.c, s.done, s.policy
+	if c != nil {
+		u = ApplyTareOffset(u, s.offset)
+		if s.smooth != nil {
+			u = s.smooth.smooth(u)
+		}
+		u = s.delta.Apply(u)
+	}
+	s.mu.Unlock()
+	if c == nil {
+		return false
+	}
+	switch policy {
+	case OverflowDropNewest:
+		select {
+		case c <- u:
+			return true
+		default:
+			s.dropped.Add(1)
+			return false
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case c <- u:
+				return true
+			default:
+			}
+			// Full: drop the oldest update to make room. The consumer may
+			// have taken it already, in which case there's room anyway.
+			select {
+			case <-c:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case c <- u:
+			return true
+		case <-done:
+			return false
+		}
+	}
+}
+
+// TrySend sends u if there is room in the channel, reporting whether it
+// did. It never blocks, so suits reporting errors from a notification
+// handler.
+func (s *UpdateStream) TrySend(u WeightUpdate) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case s.c <- u:
+		return true
+	default:
+		return false
+	}
+}
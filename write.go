@@ -0,0 +1,39 @@
+package goscale
+
+import "io"
+
+// DefaultMTU is the ATT MTU every Bluetooth LE link starts with, and keeps
+// unless a larger one is negotiated.
+const DefaultMTU = 23
+
+// attWriteOverhead is how many bytes of each ATT write its opcode and
+// attribute handle take up.
+const attWriteOverhead = 3
+
+// MaxWriteLen returns how many bytes of data fit in one write at the given
+// ATT MTU. An MTU too small to be real, such as zero when negotiation
+// failed, is taken as DefaultMTU.
+func MaxWriteLen(mtu uint16) int {
+	return int(max(mtu, DefaultMTU)) - attWriteOverhead
+}
+
+// WriteChunked writes data with write in pieces that each fit in one write
+// at the given ATT MTU. Some stacks silently truncate or reject a write
+// that doesn't fit rather than splitting it, so drivers whose frames may
+// not fit write them with this instead. A short write is continued from
+// where it stopped; one that accepts nothing fails with io.ErrShortWrite.
+func WriteChunked(write func([]byte) (int, error), mtu uint16, data []byte) error {
+	size := MaxWriteLen(mtu)
+	for len(data) > 0 {
+		chunk := data[:min(size, len(data))]
+		n, err := write(chunk)
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return io.ErrShortWrite
+		}
+		data = data[min(n, len(chunk)):]
+	}
+	return nil
+}